@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/orkestr8/fsm"
+	"github.com/orkestr8/fsm/systemd"
 
 	"encoding/json"
 	"fmt"
@@ -261,7 +262,14 @@ func (a *simple) initialize() (err error) {
 		foundDown:    "down",
 	}
 
-	a.machines.Run(fsm.Wall(time.Tick(2*time.Second)), options)
+	// notify systemd, if run as a unit, while every target is running
+	a.machines.AddSource(&systemd.Notifier{
+		Ready: func() bool {
+			return a.machines.Ready(fsm.AllIn(targetRunning))
+		},
+	})
+
+	a.machines.Run(fsm.WallTicker(2*time.Second), options)
 
 	// for each target create an instance
 	for target := range a.config {
@@ -299,14 +307,10 @@ func (a *simple) handleHTTP() {
 				w.WriteHeader(a.httpStatus)
 				return
 			}
-			if len(a.config) > 0 {
-				// if there are dependencies, check to see everyone is ok
-				for target := range a.config {
-					if a.fsms[target].State() != targetRunning {
-						w.WriteHeader(http.StatusServiceUnavailable)
-						return
-					}
-				}
+			// if there are dependencies, check to see everyone is ok
+			if !a.machines.Ready(fsm.AllIn(targetRunning)) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
 			}
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte(fmt.Sprintf("%s,%s,%s", VERSION, REVISION, HASH)))
@@ -0,0 +1,50 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// contextual is the FSM given to context actions, with the context of the transition
+type contextual struct {
+	*instance
+	ctx TransitionContext
+}
+
+// action adapts the context action to an action, which gets its context from the FSM it's given
+func (a ActionCtx) action() Action {
+	return func(f FSM) error {
+		ctx := TransitionContext{Signal: NoSignal, From: NoState, To: NoState}
+		if c, is := f.(contextual); is {
+			ctx = c.ctx
+		}
+		return a(ctx, f)
+	}
+}
+
+// contextActions returns the actions of the state with its context actions adapted to actions
+func (s *spec) contextActions(st State) (map[Signal]Action, error) {
+	if len(st.ContextActions) == 0 {
+		return st.Actions, nil
+	}
+	actions := map[Signal]Action{}
+	for signal, action := range st.Actions {
+		actions[signal] = action
+	}
+	for signal, action := range st.ContextActions {
+		if action == nil {
+			return nil, ErrNilAction(signal)
+		}
+		_, named := st.ActionNames[signal]
+		if _, has := actions[signal]; has || named {
+			return nil, ErrDuplicateAction{spec: s, State: st.Index, Signal: signal}
+		}
+		actions[signal] = action.action()
+	}
+	return actions, nil
+}
+
+// given returns the FSM given to the action of the signal: the instance itself for actions, so that it can be
+// compared or used as a key, and the instance with the context of the transition for context actions.
+func (g *runner) given(instance *instance, current, next Index, event *event) FSM {
+	if _, has := g.spec().states[current].ContextActions[event.signal]; !has {
+		return instance
+	}
+	return contextual{instance, TransitionContext{Signal: event.signal, From: current, To: next, Data: event.data,
+		Origin: event.origin, CorrelationID: event.corrID}}
+}
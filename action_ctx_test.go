@@ -0,0 +1,115 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextActions(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	contexts := make(chan TransitionContext, 1)
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			ContextActions: map[Signal]ActionCtx{
+				start: func(ctx TransitionContext, f FSM) error {
+					contexts <- ctx
+					return nil
+				},
+			},
+		},
+		State{
+			Index: running,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	a, err := machines.New(pending)
+	require.NoError(t, err)
+	require.NoError(t, a.SignalFrom(OriginSource, start, "image:v2"))
+	require.Equal(t, TransitionContext{
+		Signal: start,
+		From:   pending,
+		To:     running,
+		Data:   []interface{}{"image:v2"},
+		Origin: OriginSource,
+	}, <-contexts)
+	require.Equal(t, running, a.State())
+
+	_, err = define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			Actions: map[Signal]Action{
+				start: func(FSM) error { return nil },
+			},
+			ContextActions: map[Signal]ActionCtx{
+				start: func(TransitionContext, FSM) error { return nil },
+			},
+		},
+		State{
+			Index: running,
+		},
+	)
+	require.IsType(t, ErrDuplicateAction{}, err)
+}
+
+func TestActionGivenInstance(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	given := make(chan FSM, 1)
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			Actions: map[Signal]Action{
+				start: func(f FSM) error {
+					given <- f
+					return nil
+				},
+			},
+		},
+		State{
+			Index: running,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	a, err := machines.New(pending)
+	require.NoError(t, err)
+	require.NoError(t, a.Signal(start, CorrelationID("req-1")))
+
+	// the handle itself, e.g. to look it up in a map
+	f := <-given
+	require.True(t, f == a)
+	require.Equal(t, map[FSM]bool{a: true}, map[FSM]bool{f: true})
+}
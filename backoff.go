@@ -0,0 +1,66 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"math"
+)
+
+// Backoff increases the TTL of a state each time the state's deadline expires, for states that retry
+// by way of the signal raised on expiry.  After n consecutive expiries, the TTL is TTL * Factor^n, up to
+// Max.  The count is reset when the state is left by a signal that's not raised by the expiry, or when
+// the instance enters any of the ResetOn states.  Jitter, a fraction between 0 and 1, randomizes each backed
+// off TTL by up to that fraction either way, so that instances that failed together don't retry together.
+type Backoff struct {
+	Factor  float64
+	Max     Tick
+	ResetOn []Index
+	Jitter  float64
+}
+
+// ttl returns the effective TTL of the state for the instance
+func (g *runner) ttl(instance *instance, state Index, ttl Tick) Tick {
+	backoff := g.spec().states[state].Backoff
+	count := instance.expiries[state]
+	if backoff.Factor <= 1 || count == 0 {
+		return ttl
+	}
+	effective := float64(ttl) * math.Pow(backoff.Factor, float64(count))
+	if backoff.Max > 0 && effective > float64(backoff.Max) {
+		effective = float64(backoff.Max)
+	}
+	if backoff.Jitter > 0 {
+		effective += effective * backoff.Jitter * (2*g.random.Float64() - 1)
+	}
+	if effective < 1 {
+		return 1
+	}
+	return Tick(effective)
+}
+
+// expired records the expiry of the state's deadline
+func (g *runner) expired(instance *instance, state Index) {
+	if g.spec().states[state].Backoff.Factor <= 1 {
+		return
+	}
+	if instance.expiries == nil {
+		instance.expiries = map[Index]int{}
+	}
+	instance.expiries[state]++
+}
+
+// resetBackoff resets the expiry counts after the instance leaves the current state by the event
+func (g *runner) resetBackoff(instance *instance, current, next Index, event *event) {
+	if len(instance.expiries) == 0 {
+		return
+	}
+	if event.due == 0 {
+		delete(instance.expiries, current)
+	}
+	for state := range instance.expiries {
+		for _, reset := range g.spec().states[state].Backoff.ResetOn {
+			if reset == next {
+				delete(instance.expiries, state)
+				break
+			}
+		}
+	}
+}
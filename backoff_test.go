@@ -0,0 +1,137 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+
+	const (
+		down Index = iota
+		running
+	)
+
+	const (
+		retry Signal = iota
+		found
+		lost
+	)
+
+	machines, err := define(
+		State{
+			Index: down,
+			Transitions: map[Signal]Index{
+				retry: down,
+				found: running,
+			},
+			TTL:     Expiry{2, retry},
+			Backoff: Backoff{Factor: 2, Max: 10},
+		},
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				lost: down,
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	clock := NewClock()
+	options := DefaultOptions()
+	options.InlineDeadlines = true
+	require.NoError(t, machines.Run(clock, options))
+	defer machines.Done()
+
+	instance, err := machines.New(down)
+	require.NoError(t, err)
+
+	due := func() Time {
+		pending := machines.PendingDeadlines()
+		require.Equal(t, 1, len(pending))
+		return pending[0].Due
+	}
+
+	require.Equal(t, Time(2), due())
+
+	clock.Ticks(2)
+	require.Equal(t, Time(2+4), due())
+
+	clock.Ticks(4)
+	require.Equal(t, Time(6+8), due())
+
+	clock.Ticks(8)
+	require.Equal(t, Time(14+10), due()) // capped
+
+	// recovered and then lost again starts over
+	require.NoError(t, instance.Signal(found))
+	require.NoError(t, instance.Signal(lost))
+	require.Equal(t, Time(14+2), due())
+}
+
+func TestBackoffJitter(t *testing.T) {
+
+	const (
+		down Index = iota
+	)
+
+	const (
+		retry Signal = iota
+	)
+
+	dues := func(seed int64) []Time {
+		machines, err := define(
+			State{
+				Index: down,
+				Transitions: map[Signal]Index{
+					retry: down,
+				},
+				TTL:     Expiry{10, retry},
+				Backoff: Backoff{Factor: 2, Max: 100, Jitter: 0.5},
+			},
+		)
+		require.NoError(t, err)
+
+		clock := NewClock()
+		options := DefaultOptions()
+		options.InlineDeadlines = true
+		options.IDs = IDRandom
+		options.Random = rand.NewSource(seed)
+		require.NoError(t, machines.Run(clock, options))
+		defer machines.Done()
+
+		instance, err := machines.New(down)
+		require.NoError(t, err)
+
+		out := []Time{Time(instance.ID())}
+		last := Time(0)
+		for i := 0; i < 5; i++ {
+			pending := machines.PendingDeadlines()
+			require.Equal(t, 1, len(pending))
+			due := pending[0].Due
+			out = append(out, due)
+			clock.Ticks(int(due - last))
+			last = due
+		}
+		return out
+	}
+
+	first := dues(1)
+	require.Equal(t, first, dues(1)) // reproducible with the same seed
+	require.NotEqual(t, first, dues(2))
+
+	// the unjittered TTLs are 10, 20, 40, 80 and 100
+	for i, ttl := range []Time{10, 20, 40, 80, 100} {
+		elapsed := first[i+1]
+		if i > 0 {
+			elapsed -= first[i]
+		}
+		if i == 0 {
+			require.Equal(t, ttl, elapsed) // not backed off yet
+			continue
+		}
+		require.True(t, elapsed >= ttl/2 && elapsed <= ttl*3/2, "ttl %v elapsed %v", ttl, elapsed)
+	}
+}
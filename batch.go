@@ -0,0 +1,41 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"time"
+)
+
+// SignalRequest is a signal to an instance, for Machines.SignalBatch
+type SignalRequest struct {
+	// ID is the ID of the instance
+	ID ID
+
+	// Signal is the signal to send
+	Signal Signal
+
+	// Data is the optional data of the signal, as for FSM.Signal
+	Data []interface{}
+}
+
+// lookup returns the instances of the requests, nil for those unknown.  This must be called from within the
+// transaction loop.
+func (g *runner) lookup(batch []SignalRequest) []*instance {
+	instances := make([]*instance, len(batch))
+	for i, request := range batch {
+		instances[i] = g.members[request.ID]
+	}
+	return instances
+}
+
+// signalBatch sends the signals of the batch to the instances looked up, in order, as FSM.Signal does.  The errors
+// are of the admission of each signal, and are nil for the signals admitted.
+func (g *runner) signalBatch(batch []SignalRequest, instances []*instance) []error {
+	errs := make([]error, len(batch))
+	for i, request := range batch {
+		if instances[i] == nil {
+			errs[i] = ErrUnknownFSM(request.ID)
+			continue
+		}
+		errs[i] = g.signalAt(OriginAPI, time.Time{}, request.Signal, instances[i], request.Data...)
+	}
+	return errs
+}
@@ -0,0 +1,75 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignalBatch(t *testing.T) {
+
+	const (
+		down Index = iota
+		up
+	)
+
+	const (
+		poll Signal = iota
+		fail
+		unknown
+	)
+
+	machines, err := define(
+		State{
+			Index: down,
+			Transitions: map[Signal]Index{
+				poll: up,
+			},
+		},
+		State{
+			Index: up,
+			Transitions: map[Signal]Index{
+				fail: down,
+			},
+		},
+	)
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.OnSignal = func(id ID, s Signal, data []interface{}) bool {
+		return len(data) == 0 || data[0] != "blocked"
+	}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	instances := []FSM{}
+	for i := 0; i < 4; i++ {
+		instance, err := machines.New(down)
+		require.NoError(t, err)
+		instances = append(instances, instance)
+	}
+	require.NoError(t, machines.Free(instances[3].ID()))
+
+	errs := machines.SignalBatch([]SignalRequest{
+		{ID: instances[0].ID(), Signal: poll, Data: []interface{}{"ok"}},
+		{ID: instances[1].ID(), Signal: poll},
+		{ID: instances[1].ID(), Signal: fail},
+		{ID: instances[2].ID(), Signal: poll, Data: []interface{}{"blocked"}},
+		{ID: instances[3].ID(), Signal: poll},
+		{ID: instances[0].ID(), Signal: unknown},
+	})
+	require.Equal(t, []error{
+		nil,
+		nil,
+		nil,
+		ErrSignalRejected{spec: machines.runner.spec(), ID: instances[2].ID(), Signal: poll},
+		ErrUnknownFSM(instances[3].ID()),
+		ErrUnknownSignal{Signal: unknown},
+	}, errs)
+
+	// the signals are queued in order, ahead of the reads after them
+	require.Equal(t, 3, machines.Count())
+	require.Equal(t, up, instances[0].State())
+	require.Equal(t, []interface{}{"ok"}, instances[0].Data())
+	require.Equal(t, down, instances[1].State())
+	require.Equal(t, down, instances[2].State())
+}
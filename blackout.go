@@ -0,0 +1,70 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"time"
+)
+
+// Blackout is a window of wall time, e.g. a change freeze, during which the signals raised by the
+// machines themselves (TTLs, visit limits, flap limits, watchdogs and error budgets) are deferred until
+// the window ends.  Signals from the API and sources, and the bookkeeping of the clock, are not affected.
+type Blackout struct {
+	Start time.Time
+	End   time.Time
+}
+
+// contains returns true if the time is in the window, including the start and excluding the end
+func (b Blackout) contains(t time.Time) bool {
+	return !t.Before(b.Start) && t.Before(b.End)
+}
+
+// deferredEvent is an event raised during a blackout, for the instance in the given state
+type deferredEvent struct {
+	instance *instance
+	state    Index
+	event    *event
+}
+
+func (g *runner) inBlackout(now time.Time) bool {
+	for _, b := range g.blackouts {
+		if b.contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// deferInBlackout keeps the event until the blackout ends, and returns true if in a blackout.
+// This must be called from within the transaction loop.
+func (g *runner) deferInBlackout(instance *instance, event *event) bool {
+	if !g.inBlackout(time.Now()) {
+		return false
+	}
+	g.debug("Deferred in blackout", snapshot{instance},
+		"instance", instance.id, "signal", g.spec().signalName(event.signal))
+	if event.queued.IsZero() {
+		event.queued = time.Now()
+	}
+	instance.enqueue(event, true)
+	g.deferred = append(g.deferred, deferredEvent{instance: instance, state: instance.state, event: event})
+	return true
+}
+
+// releaseDeferred raises the deferred events once out of the blackout.  Events of instances that have
+// since been freed or changed state are dropped.  This must be called from within the transaction loop.
+func (g *runner) releaseDeferred(tid int64) {
+	if len(g.deferred) == 0 || g.inBlackout(time.Now()) {
+		return
+	}
+	deferred := g.deferred
+	g.deferred = nil
+	for _, d := range deferred {
+		if _, has := g.members[d.instance.id]; !has || d.instance.state != d.state {
+			d.instance.dequeue(d.event)
+			continue
+		}
+		d.instance.lock.Lock()
+		d.event.queued = time.Now() // for the queue latency, which excludes the blackout
+		d.instance.lock.Unlock()
+		g.raiseEvent(tid, d.instance, d.event)
+	}
+}
@@ -0,0 +1,68 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlackout(t *testing.T) {
+
+	const (
+		running Index = iota
+		restarting
+		stopped
+	)
+
+	const (
+		restart Signal = iota
+		stop
+	)
+
+	machines, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				restart: restarting,
+				stop:    stopped,
+			},
+			TTL: Expiry{2, restart},
+		},
+		State{
+			Index: restarting,
+		},
+		State{
+			Index: stopped,
+		},
+	)
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	options.Blackouts = []Blackout{{Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour)}}
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, options))
+	defer machines.Done()
+
+	a, err := machines.New(running)
+	require.NoError(t, err)
+	b, err := machines.New(running)
+	require.NoError(t, err)
+
+	clock.Ticks(5)
+	require.Equal(t, Time(5), machines.Stats().Now) // the clock keeps going
+	require.Equal(t, running, a.State())
+	require.Equal(t, running, b.State())
+
+	// signals from the api are not deferred, and the deferred signal of b is dropped after it
+	require.NoError(t, b.Signal(stop))
+	require.Equal(t, stopped, b.State())
+
+	machines.SetBlackouts(nil)
+	clock.Tick()
+	for i := 0; i < 100 && a.State() != restarting; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(t, restarting, a.State())
+	require.Equal(t, stopped, b.State())
+}
@@ -0,0 +1,41 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// ErrorBudget is the number of action errors an instance can have within a window of ticks.  When the
+// budget is exhausted, the signal is raised, e.g. to cordon the instance.  If Within is 0, the errors
+// are counted since the last time the budget was exhausted.
+type ErrorBudget struct {
+	Errors int
+	Within Tick
+	Raise  Signal
+}
+
+// spendErrorBudget records the action error of the instance and raises the signal of the error budget if
+// the budget is exhausted.
+func (g *runner) spendErrorBudget(tid int64, instance *instance, current Index, now Time) error {
+	budget := g.options.ErrorBudget
+	if budget.Errors <= 0 {
+		return nil
+	}
+
+	failures := instance.failures
+	if budget.Within > 0 {
+		kept := []Time{}
+		for _, t := range failures {
+			if now-t < Time(budget.Within) {
+				kept = append(kept, t)
+			}
+		}
+		failures = kept
+	}
+	failures = append(failures, now)
+
+	if len(failures) < budget.Errors {
+		instance.failures = failures
+		return nil
+	}
+
+	instance.failures = nil
+	g.log.Info("error budget exhausted", g.withFields(snapshot{instance}, "tid", tid, "id", instance.id,
+		"state", g.spec().stateName(current), "raise", g.spec().signalName(budget.Raise))...)
+	return g.raise(tid, instance, budget.Raise, current, OriginErrorBudget)
+}
@@ -0,0 +1,72 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorBudget(t *testing.T) {
+
+	const (
+		running Index = iota
+		cordoned
+	)
+
+	const (
+		check Signal = iota
+		cordon
+		undefined
+	)
+
+	machines, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				check:  running,
+				cordon: cordoned,
+			},
+			Actions: map[Signal]Action{
+				check: func(FSM) error {
+					return fmt.Errorf("boom")
+				},
+			},
+			Errors: map[Signal]Index{
+				check: running,
+			},
+		},
+		State{
+			Index: cordoned,
+		},
+	)
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	options.ErrorBudget = ErrorBudget{Errors: 3, Within: 5, Raise: undefined}
+	require.Error(t, machines.Run(NewClock(), options))
+
+	clock := NewClock()
+	options.ErrorBudget.Raise = cordon
+	require.NoError(t, machines.Run(clock, options))
+	defer machines.Done()
+
+	instance, err := machines.New(running)
+	require.NoError(t, err)
+
+	// errors spread out beyond the window
+	for i := 0; i < 4; i++ {
+		require.NoError(t, instance.Signal(check))
+		clock.Ticks(3)
+	}
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, running, instance.State())
+
+	// errors within the window
+	for i := 0; i < 3; i++ {
+		require.NoError(t, instance.Signal(check))
+	}
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, cordoned, instance.State())
+}
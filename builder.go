@@ -0,0 +1,151 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// Builder builds the states of a spec with chained calls, as an alternative to State literals.  For example,
+//
+//	NewBuilder().
+//		State(up).On(shutdown).To(down).Do(stop).
+//		State(down).On(startup).To(up).TTL(5, startup).
+//		Build()
+//
+// Calls out of order, e.g. To before any On, are reported by Build.
+type Builder struct {
+	states  []*State
+	current *State
+	signal  *Signal // the signal of the last On
+	err     error
+}
+
+// NewBuilder returns a builder with no states
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+func (b *Builder) fail(format string, args ...interface{}) *Builder {
+	if b.err == nil {
+		b.err = Errorf(SpecError, format, args...)
+	}
+	return b
+}
+
+// State starts the state, or resumes it if it's already built.  The states are defined in the order
+// they are started.
+func (b *Builder) State(index Index) *Builder {
+	b.signal = nil
+	for _, st := range b.states {
+		if st.Index == index {
+			b.current = st
+			return b
+		}
+	}
+	b.current = &State{Index: index}
+	b.states = append(b.states, b.current)
+	return b
+}
+
+// On starts the transition of the current state on the signal
+func (b *Builder) On(signal Signal) *Builder {
+	if b.current == nil {
+		return b.fail("builder: On(%v) before State", signal)
+	}
+	b.signal = &signal
+	return b
+}
+
+// To sets the next state of the transition
+func (b *Builder) To(next Index) *Builder {
+	if b.signal == nil {
+		return b.fail("builder: To(%v) before On", next)
+	}
+	if b.current.Transitions == nil {
+		b.current.Transitions = map[Signal]Index{}
+	}
+	b.current.Transitions[*b.signal] = next
+	return b
+}
+
+// Do sets the action of the transition
+func (b *Builder) Do(action Action) *Builder {
+	if b.signal == nil {
+		return b.fail("builder: Do before On")
+	}
+	if b.current.Actions == nil {
+		b.current.Actions = map[Signal]Action{}
+	}
+	b.current.Actions[*b.signal] = action
+	return b
+}
+
+// OnError sets the next state of the transition when the action fails.  See State.Errors.
+func (b *Builder) OnError(next Index) *Builder {
+	if b.signal == nil {
+		return b.fail("builder: OnError(%v) before On", next)
+	}
+	if b.current.Errors == nil {
+		b.current.Errors = map[Signal]Index{}
+	}
+	b.current.Errors[*b.signal] = next
+	return b
+}
+
+// TTL sets the expiry of the current state
+func (b *Builder) TTL(ttl Tick, raise Signal) *Builder {
+	if b.current == nil {
+		return b.fail("builder: TTL before State")
+	}
+	b.current.TTL = Expiry{TTL: ttl, Raise: raise}
+	return b
+}
+
+// Visit sets the limit of visits of the current state
+func (b *Builder) Visit(limit int, raise Signal) *Builder {
+	if b.current == nil {
+		return b.fail("builder: Visit before State")
+	}
+	b.current.Visit = Limit{Value: limit, Raise: raise}
+	return b
+}
+
+// Terminal marks the current state as an intended end state
+func (b *Builder) Terminal() *Builder {
+	if b.current == nil {
+		return b.fail("builder: Terminal before State")
+	}
+	b.current.Terminal = true
+	return b
+}
+
+// Meta sets the metadata of the current state
+func (b *Builder) Meta(key, value string) *Builder {
+	if b.current == nil {
+		return b.fail("builder: Meta before State")
+	}
+	if b.current.Meta == nil {
+		b.current.Meta = map[string]string{}
+	}
+	b.current.Meta[key] = value
+	return b
+}
+
+// States returns the states built, in the order they were started, e.g. to customize them before Define
+func (b *Builder) States() ([]State, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.states) == 0 {
+		return nil, Errorf(SpecError, "builder: no states")
+	}
+	states := []State{}
+	for _, st := range b.states {
+		states = append(states, *st)
+	}
+	return states, nil
+}
+
+// Build defines the spec of the states built
+func (b *Builder) Build() (Machines, error) {
+	states, err := b.States()
+	if err != nil {
+		return nil, err
+	}
+	return Define(states[0], states[1:]...)
+}
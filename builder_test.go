@@ -0,0 +1,77 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilder(t *testing.T) {
+
+	const (
+		up Index = iota
+		down
+		failed
+	)
+
+	const (
+		shutdown Signal = iota
+		startup
+		timeout
+	)
+
+	stopped := make(chan ID, 1)
+	stop := func(f FSM) error {
+		stopped <- f.ID()
+		return nil
+	}
+
+	states, err := NewBuilder().
+		State(up).On(shutdown).To(down).Do(stop).
+		State(down).On(startup).To(up).OnError(failed).TTL(5, timeout).
+		On(timeout).To(failed).Meta("owner", "ops").
+		State(failed).Terminal().
+		States()
+	require.NoError(t, err)
+	require.Equal(t, 3, len(states))
+	require.Equal(t, map[Signal]Index{shutdown: down}, states[0].Transitions)
+	require.NotNil(t, states[0].Actions[shutdown])
+	require.Equal(t, State{
+		Index: down,
+		Transitions: map[Signal]Index{
+			startup: up,
+			timeout: failed,
+		},
+		Errors: map[Signal]Index{
+			startup: failed,
+		},
+		TTL:  Expiry{5, timeout},
+		Meta: map[string]string{"owner": "ops"},
+	}, states[1])
+	require.Equal(t, State{Index: failed, Terminal: true}, states[2])
+
+	machines, err := NewBuilder().
+		State(up).On(shutdown).To(down).Do(stop).
+		State(down).On(startup).To(up).
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	a, err := machines.New(up)
+	require.NoError(t, err)
+	require.NoError(t, a.Signal(shutdown))
+	require.Equal(t, a.ID(), <-stopped)
+	require.Equal(t, down, a.State())
+
+	_, err = NewBuilder().State(up).To(down).Build()
+	require.Equal(t, SpecError, ClassOf(err))
+	require.Equal(t, "builder: To(1) before On", err.Error())
+
+	_, err = NewBuilder().Build()
+	require.Error(t, err)
+
+	// checked by Define
+	_, err = NewBuilder().State(up).On(shutdown).To(down).Build()
+	require.Equal(t, SpecError, ClassOf(err))
+}
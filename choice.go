@@ -0,0 +1,88 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"fmt"
+)
+
+// Choice is a transition to one of several states, chosen with the instance when the signal is received, e.g.
+// to route a health check by its result.  The signal must also be in the transitions of the state, whose next
+// state is the default.
+type Choice struct {
+	// To are the candidate next states, besides the default
+	To []Index
+
+	// Select returns the next state, one of To or the default, or NoState for the default.  It's given a
+	// snapshot of the instance, whose Data is the data of the signal, if any.  It's called in the transaction
+	// loop, once the signal reaches its threshold and before the vetoers, so it must not block or call the
+	// Machines.
+	Select func(FSM) Index
+}
+
+// ErrInvalidChoice is the next state chosen by the Select of a Choice that's not one of its candidates.  The
+// instance stays in its state.
+type ErrInvalidChoice struct {
+	spec   *spec
+	ID     ID
+	State  Index
+	Signal Signal
+	Next   Index
+}
+
+func (e ErrInvalidChoice) Error() string {
+	return fmt.Sprintf("invalid choice: instance=%v, state=%v, signal=%v, next=%v", e.ID,
+		e.spec.stateName(e.State), e.spec.signalName(e.Signal), e.spec.stateName(e.Next))
+}
+
+func (e ErrInvalidChoice) Is(target error) bool {
+	return target == UserError
+}
+
+// nextStates returns the states the state transitions to, including on errors and choices
+func (st State) nextStates() []Index {
+	next := []Index{}
+	for _, transfer := range []map[Signal]Index{st.Transitions, st.Errors} {
+		for _, to := range transfer {
+			next = append(next, to)
+		}
+	}
+	for _, choice := range st.Choices {
+		next = append(next, choice.To...)
+	}
+	return next
+}
+
+// choosing is the FSM given to the Select of a choice: a snapshot of the instance with the data of the signal
+type choosing struct {
+	snapshot
+	data interface{}
+}
+
+// Data returns the data of the signal, if any, or else of the instance
+func (c choosing) Data() interface{} {
+	return c.data
+}
+
+// choose returns the next state chosen by the choice of the signal, if any, or the default next state.  This
+// must be called from within the transaction loop.
+func (g *runner) choose(instance *instance, current, next Index, event *event) (Index, error) {
+	choice, has := g.spec().states[current].Choices[event.signal]
+	if !has {
+		return next, nil
+	}
+
+	data := instance.data
+	if event.data != nil {
+		data = event.data
+	}
+	chosen := choice.Select(choosing{snapshot: snapshot{instance}, data: data})
+
+	if chosen == NoState || chosen == next {
+		return next, nil
+	}
+	for _, to := range choice.To {
+		if chosen == to {
+			return chosen, nil
+		}
+	}
+	return next, ErrInvalidChoice{spec: g.spec(), ID: instance.id, State: current, Signal: event.signal, Next: chosen}
+}
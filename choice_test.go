@@ -0,0 +1,194 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChoice(t *testing.T) {
+
+	const (
+		healthy Index = iota
+		degraded
+		down
+	)
+
+	const (
+		healthCheck Signal = iota
+	)
+
+	// the health check carries the number of failed probes
+	classify := Choice{
+		To: []Index{degraded, down},
+		Select: func(f FSM) Index {
+			switch failed := f.Data().([]interface{})[0].(int); {
+			case failed == 0:
+				return healthy
+			case failed < 3:
+				return degraded
+			case failed < 10:
+				return down
+			}
+			return 42
+		},
+	}
+
+	check := map[Signal]Index{healthCheck: healthy}
+	choices := map[Signal]Choice{healthCheck: classify}
+	machines, err := define(
+		State{Index: healthy, Transitions: check, Choices: choices},
+		State{Index: degraded, Transitions: check, Choices: choices},
+		State{Index: down, Transitions: check, Choices: choices},
+	)
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.IgnoreUndefinedStates = false
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	errs, cancel := machines.SubscribeErrors(ErrorFilter{Types: []error{ErrInvalidChoice{}}})
+	defer cancel()
+
+	a, err := machines.New(healthy)
+	require.NoError(t, err)
+
+	require.NoError(t, a.Signal(healthCheck, 1))
+	require.Equal(t, degraded, a.State())
+	require.Equal(t, []interface{}{1}, a.Data())
+
+	require.NoError(t, a.Signal(healthCheck, 5))
+	require.Equal(t, down, a.State())
+
+	require.NoError(t, a.Signal(healthCheck, 0))
+	require.Equal(t, healthy, a.State())
+
+	// not a candidate
+	require.NoError(t, a.Signal(healthCheck, 20))
+	event := <-errs
+	require.Equal(t, ErrInvalidChoice{spec: machines.runner.spec(), ID: a.ID(), State: healthy, Signal: healthCheck,
+		Next: 42}, event.Err)
+	require.Equal(t, healthy, a.State())
+	require.Equal(t, []interface{}{0}, a.Data())
+
+	// the candidates are reachable
+	require.Empty(t, machines.Validate())
+
+	_, err = define(
+		State{
+			Index:       healthy,
+			Transitions: check,
+			Choices:     map[Signal]Choice{healthCheck: {To: []Index{degraded}, Select: classify.Select}},
+		},
+	)
+	require.Equal(t, SpecError, ClassOf(err))
+}
+
+func TestChoiceErrorsFirst(t *testing.T) {
+
+	const (
+		running Index = iota
+		degraded
+		failed
+	)
+
+	const (
+		crash Signal = iota
+	)
+
+	selected := 0
+	machines, err := define(
+		State{
+			Index:       running,
+			Transitions: map[Signal]Index{crash: running},
+			Choices: map[Signal]Choice{
+				crash: {
+					To: []Index{degraded},
+					Select: func(FSM) Index {
+						selected++
+						return degraded
+					},
+				},
+			},
+			Errors:      map[Signal]Index{crash: failed},
+			ErrorsFirst: true,
+		},
+		State{Index: degraded},
+		State{Index: failed},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	// the crash follows Errors without a choice
+	a, err := machines.New(running)
+	require.NoError(t, err)
+	require.NoError(t, a.Signal(crash))
+	require.Equal(t, failed, a.State())
+	require.Equal(t, 0, selected)
+}
+
+func TestChoiceGuarded(t *testing.T) {
+
+	const (
+		healthy Index = iota
+		degraded
+		down
+	)
+
+	const (
+		healthCheck Signal = iota
+	)
+
+	selected := []Index{}
+	machines, err := define(
+		State{
+			Index:       healthy,
+			Transitions: map[Signal]Index{healthCheck: degraded},
+			Choices: map[Signal]Choice{
+				healthCheck: {
+					To: []Index{down},
+					Select: func(f FSM) Index {
+						// a snapshot, read in the transaction loop
+						selected = append(selected, f.State())
+						if f.Data().([]interface{})[0] == "down" {
+							return down
+						}
+						return degraded
+					},
+				},
+			},
+			Hysteresis: map[Signal]Threshold{healthCheck: {Count: 2}},
+		},
+		State{Index: degraded},
+		State{Index: down},
+	)
+	require.NoError(t, err)
+	machines.AddVetoer(VetoFunc(func(p Proposal) error {
+		if p.To == down {
+			return fmt.Errorf("not down")
+		}
+		return nil
+	}))
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	errs, cancel := machines.SubscribeErrors(ErrorFilter{Types: []error{ErrVetoed{}}})
+	defer cancel()
+
+	a, err := machines.New(healthy)
+	require.NoError(t, err)
+
+	// not chosen below the threshold
+	require.NoError(t, a.Signal(healthCheck, "down"))
+	require.Equal(t, healthy, a.State())
+	require.Empty(t, selected)
+
+	// the vetoers check the state chosen
+	require.NoError(t, a.Signal(healthCheck, "down"))
+	require.IsType(t, ErrVetoed{}, (<-errs).Err)
+	require.Equal(t, healthy, a.State())
+	require.Nil(t, a.Data())
+	require.Equal(t, []Index{healthy}, selected)
+}
@@ -2,6 +2,7 @@ package fsm // import "github.com/orkestr8/fsm"
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,6 +14,52 @@ type Clock struct {
 	start  chan struct{}
 	driver func()
 	lock   sync.Mutex
+
+	delivered int64 // accessed atomically
+	dropped   int64 // accessed atomically
+	last      int64 // unix nanos of the last tick delivered; accessed atomically
+
+	interval time.Duration // of the ticker owned by the clock, if any
+	reset    chan struct{} // notifies the driver of a change in interval
+	jumps    int64         // accessed atomically
+	wall     func() time.Time
+}
+
+// ClockStats are the counters of a clock
+type ClockStats struct {
+	// Delivered is the number of ticks delivered
+	Delivered int64
+
+	// Dropped is the number of wall ticks coalesced into a tick that was waiting to be delivered
+	Dropped int64
+
+	// Jumps is the number of times the wall clock jumped ahead of the ticker, e.g. on resuming from suspend
+	Jumps int64
+
+	// LastTick is the wall time of the last tick delivered
+	LastTick time.Time
+
+	// SinceLastTick is the wall time elapsed since the last tick delivered
+	SinceLastTick time.Duration
+}
+
+// Stats returns the counters of the clock
+func (t *Clock) Stats() ClockStats {
+	stats := ClockStats{
+		Delivered: atomic.LoadInt64(&t.delivered),
+		Dropped:   atomic.LoadInt64(&t.dropped),
+		Jumps:     atomic.LoadInt64(&t.jumps),
+	}
+	if last := atomic.LoadInt64(&t.last); last > 0 {
+		stats.LastTick = time.Unix(0, last)
+		stats.SinceLastTick = time.Since(stats.LastTick)
+	}
+	return stats
+}
+
+func (t *Clock) recordTick() {
+	atomic.AddInt64(&t.delivered, 1)
+	atomic.StoreInt64(&t.last, time.Now().UnixNano())
 }
 
 // NewClock returns a clock
@@ -87,6 +134,7 @@ func (t *Clock) Stop() {
 // Tick makes one tick of the clock
 func (t *Clock) Tick() {
 	t.c <- Tick(1)
+	t.recordTick()
 }
 
 // Ticks makes multiple ticks
@@ -103,7 +151,8 @@ func (t *Clock) run() *Clock {
 	return t
 }
 
-// Wall adapts a regular time.Tick to return a clock
+// Wall adapts a regular time.Tick to return a clock.  The caller owns the source of the ticks and must
+// stop it; see WallTicker for a clock that owns its ticker.
 func Wall(tick <-chan time.Time) *Clock {
 	out := make(chan Tick)
 	stop := make(chan struct{})
@@ -129,10 +178,108 @@ func Wall(tick <-chan time.Time) *Clock {
 			case <-tick:
 				// note that golang's time ticker won't close the channel when stopped.
 				// so we will do the closing ourselves to avoid leaking the goroutine
-				clock.c <- Tick(1)
+				if !clock.deliver(tick, Tick(1)) {
+					return
+				}
 			}
 		}
 	}
 
 	return clock.run()
 }
+
+// deliver sends a tick for a wall tick received, counting the wall ticks that arrive while waiting as dropped.
+// It returns false if the clock is stopped.
+func (t *Clock) deliver(tick <-chan time.Time, value Tick) bool {
+	for {
+		select {
+		case t.c <- value:
+			t.recordTick()
+			return true
+		default:
+		}
+		select {
+		case <-t.stop:
+			close(t.c)
+			return false
+		case t.c <- value:
+			t.recordTick()
+			return true
+		case <-tick:
+			// the pending tick has not been taken; count the coalesced tick
+			atomic.AddInt64(&t.dropped, 1)
+		}
+	}
+}
+
+// WallTicker returns a clock that ticks at the given interval.  The clock owns the ticker and stops it
+// when the clock is stopped.  The ticker runs on monotonic time; if the wall clock jumps ahead of it by
+// more than an interval, e.g. after resuming from suspend or an NTP step, the next tick delivered is
+// 1 plus the number of intervals jumped.  See Options.ClockJump for how the machines handle it.
+func WallTicker(interval time.Duration) *Clock {
+	out := make(chan Tick)
+	clock := &Clock{
+		C:        out,
+		c:        out,
+		stop:     make(chan struct{}),
+		start:    make(chan struct{}),
+		interval: interval,
+		reset:    make(chan struct{}, 1),
+		wall:     time.Now,
+	}
+
+	clock.driver = func() {
+		<-clock.start
+		clock.synchronized(func(c *Clock) { c.start = nil })
+
+		ticker := time.NewTicker(clock.Interval())
+		defer func() { ticker.Stop() }()
+
+		last, lastWall := time.Now(), clock.wall().Round(0)
+
+		for {
+			select {
+			case <-clock.stop:
+				close(clock.c)
+				return
+			case <-clock.reset:
+				ticker.Stop()
+				ticker = time.NewTicker(clock.Interval())
+			case <-ticker.C:
+				now, wall := time.Now(), clock.wall().Round(0) // Round(0) strips the monotonic reading
+				tick := Tick(1)
+				if jump := wall.Sub(lastWall) - now.Sub(last); jump >= clock.Interval() {
+					tick += Tick(jump / clock.Interval())
+					atomic.AddInt64(&clock.jumps, 1)
+				}
+				last, lastWall = now, wall
+
+				if !clock.deliver(ticker.C, tick) {
+					return
+				}
+			}
+		}
+	}
+
+	return clock.run()
+}
+
+// Interval returns the interval of the ticker owned by the clock, or 0 if the clock doesn't own one.
+func (t *Clock) Interval() (interval time.Duration) {
+	t.synchronized(func(c *Clock) { interval = c.interval })
+	return
+}
+
+// SetInterval changes the interval of the ticker owned by the clock.  It returns false if the clock
+// doesn't own a ticker, e.g. one returned by Wall, or if the interval is not positive.
+func (t *Clock) SetInterval(interval time.Duration) bool {
+	if t.reset == nil || interval <= 0 {
+		return false
+	}
+	t.synchronized(func(c *Clock) { c.interval = interval })
+	select {
+	case t.reset <- struct{}{}:
+	default: // a change is already pending and the driver reads the latest interval
+	}
+	return true
+}
@@ -1,6 +1,7 @@
 package fsm // import "github.com/orkestr8/fsm"
 
 import (
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -106,3 +107,71 @@ func TestWallClock2(t *testing.T) {
 	t.Log("count=", total)
 	require.Equal(t, 10, total)
 }
+
+func TestClockStats(t *testing.T) {
+
+	tick := make(chan time.Time)
+	clock := Wall(tick)
+	clock.Start()
+	defer clock.Stop()
+
+	require.Equal(t, ClockStats{}, clock.Stats())
+
+	tick <- time.Now()
+	tick <- time.Now() // coalesced while the first is not taken
+	tick <- time.Now()
+	<-clock.C
+
+	tick <- time.Now()
+	<-clock.C
+	time.Sleep(10 * time.Millisecond)
+
+	stats := clock.Stats()
+	require.Equal(t, int64(2), stats.Delivered)
+	require.Equal(t, int64(2), stats.Dropped)
+	require.False(t, stats.LastTick.IsZero())
+	require.True(t, stats.SinceLastTick >= 10*time.Millisecond)
+}
+
+func TestWallTicker(t *testing.T) {
+
+	clock := WallTicker(time.Hour)
+	require.Equal(t, time.Hour, clock.Interval())
+	require.False(t, clock.SetInterval(0))
+	require.False(t, Wall(nil).SetInterval(time.Second))
+
+	clock.Start()
+
+	require.True(t, clock.SetInterval(10*time.Millisecond))
+	require.Equal(t, 10*time.Millisecond, clock.Interval())
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-clock.C:
+		case <-time.After(time.Second):
+			require.Fail(t, "no tick after changing the interval")
+		}
+	}
+
+	clock.Stop()
+	for range clock.C {
+	}
+}
+
+func TestWallTickerJump(t *testing.T) {
+
+	offset := int64(0)
+	clock := WallTicker(10 * time.Millisecond)
+	clock.wall = func() time.Time {
+		return time.Now().Add(time.Duration(atomic.LoadInt64(&offset)))
+	}
+	clock.Start()
+	defer clock.Stop()
+
+	require.Equal(t, Tick(1), <-clock.C)
+
+	atomic.StoreInt64(&offset, int64(55*time.Millisecond)) // wall clock jumps ahead
+	require.Equal(t, Tick(6), <-clock.C)
+	require.Equal(t, Tick(1), <-clock.C)
+	require.Equal(t, int64(1), clock.Stats().Jumps)
+}
@@ -0,0 +1,64 @@
+// Command fsmgen generates the Go constants of the states and signals of a spec in YAML or JSON, with the maps
+// of their names and a Define function that wires the names into the options.  For example,
+//
+//	//go:generate fsmgen -package lights -o lights_fsm.go lights.yaml
+package main // import "github.com/orkestr8/fsm/cmd/fsmgen"
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/orkestr8/fsm"
+)
+
+func main() {
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "package of the generated code")
+	out := flag.String("o", "", "output file; stdout if empty")
+	statePrefix := flag.String("state-prefix", "", "prefix of the constants of the states")
+	signalPrefix := flag.String("signal-prefix", "", "prefix of the constants of the signals")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: fsmgen [flags] spec.yaml\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 || *pkg == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := generate(flag.Arg(0), *out, fsm.GoOptions{
+		Package:      *pkg,
+		StatePrefix:  *statePrefix,
+		SignalPrefix: *signalPrefix,
+		Source:       filepath.Base(flag.Arg(0)),
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "fsmgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func generate(in, out string, options fsm.GoOptions) error {
+	f, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	config, err := fsm.ReadYAMLConfig(f) // or JSON, which is YAML
+	if err != nil {
+		return fmt.Errorf("%v: %v", in, err)
+	}
+	buff := &bytes.Buffer{}
+	if err := fsm.WriteGo(buff, config, options); err != nil {
+		return fmt.Errorf("%v: %v", in, err)
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(buff.Bytes())
+		return err
+	}
+	return ioutil.WriteFile(out, buff.Bytes(), 0644)
+}
@@ -0,0 +1,89 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+)
+
+// Codec encodes and decodes the data of instances for persistence
+type Codec interface {
+	Encode(data interface{}) ([]byte, error)
+	Decode(buff []byte) (interface{}, error)
+}
+
+// JSONCodec encodes the data as JSON.  If New is set, data is decoded into the value it returns, which
+// must be a pointer; the value pointed to is returned unless Pointer is true.  Otherwise, data is decoded
+// into generic maps and slices.
+type JSONCodec struct {
+	New     func() interface{}
+	Pointer bool
+}
+
+// Encode implements Codec
+func (c JSONCodec) Encode(data interface{}) ([]byte, error) {
+	return json.Marshal(data)
+}
+
+// Decode implements Codec
+func (c JSONCodec) Decode(buff []byte) (interface{}, error) {
+	if c.New == nil {
+		var data interface{}
+		err := json.Unmarshal(buff, &data)
+		return data, err
+	}
+	v := c.New()
+	if err := json.Unmarshal(buff, v); err != nil {
+		return nil, err
+	}
+	return deref(v, c.Pointer), nil
+}
+
+// GobCodec encodes the data with encoding/gob.  If New is set, data is decoded into the value it returns,
+// which must be a pointer; the value pointed to is returned unless Pointer is true.  Otherwise, the
+// concrete types of the data must be registered with gob.Register.
+type GobCodec struct {
+	New     func() interface{}
+	Pointer bool
+}
+
+// Encode implements Codec
+func (c GobCodec) Encode(data interface{}) ([]byte, error) {
+	buff := &bytes.Buffer{}
+	var err error
+	if c.New == nil {
+		err = gob.NewEncoder(buff).Encode(&data) // as interface, with the type registered
+	} else {
+		err = gob.NewEncoder(buff).Encode(data)
+	}
+	return buff.Bytes(), err
+}
+
+// Decode implements Codec
+func (c GobCodec) Decode(buff []byte) (interface{}, error) {
+	if c.New == nil {
+		var data interface{}
+		err := gob.NewDecoder(bytes.NewReader(buff)).Decode(&data)
+		return data, err
+	}
+	v := c.New()
+	if err := gob.NewDecoder(bytes.NewReader(buff)).Decode(v); err != nil {
+		return nil, err
+	}
+	return deref(v, c.Pointer), nil
+}
+
+func deref(v interface{}, pointer bool) interface{} {
+	if pointer {
+		return v
+	}
+	return reflect.ValueOf(v).Elem().Interface()
+}
+
+func (g *runner) codec() Codec {
+	if g.options.Codec != nil {
+		return g.options.Codec
+	}
+	return JSONCodec{}
+}
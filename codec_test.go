@@ -0,0 +1,48 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type codecTarget struct {
+	URL   string
+	Tries int
+}
+
+func init() {
+	gob.Register(codecTarget{})
+}
+
+func TestCodecs(t *testing.T) {
+
+	target := codecTarget{URL: "http://localhost:8080", Tries: 3}
+
+	for _, codec := range []Codec{
+		JSONCodec{New: func() interface{} { return &codecTarget{} }},
+		GobCodec{New: func() interface{} { return &codecTarget{} }},
+		GobCodec{},
+	} {
+		buff, err := codec.Encode(target)
+		require.NoError(t, err)
+		decoded, err := codec.Decode(buff)
+		require.NoError(t, err)
+		require.Equal(t, target, decoded)
+	}
+
+	pointer := JSONCodec{New: func() interface{} { return &codecTarget{} }, Pointer: true}
+	buff, err := pointer.Encode(&target)
+	require.NoError(t, err)
+	decoded, err := pointer.Decode(buff)
+	require.NoError(t, err)
+	require.Equal(t, &target, decoded)
+
+	generic := JSONCodec{}
+	buff, err = generic.Encode(target)
+	require.NoError(t, err)
+	decoded, err = generic.Decode(buff)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"URL": "http://localhost:8080", "Tries": float64(3)}, decoded)
+}
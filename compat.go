@@ -0,0 +1,160 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Migration maps the states and signals persisted with an older spec to those of the current spec.
+// States and signals not in the maps are kept as they are.
+type Migration struct {
+	States  map[Index]Index
+	Signals map[Signal]Signal
+}
+
+func (m Migration) state(i Index) Index {
+	if to, has := m.States[i]; has {
+		return to
+	}
+	return i
+}
+
+func (m Migration) signal(s Signal) Signal {
+	if to, has := m.Signals[s]; has {
+		return to
+	}
+	return s
+}
+
+func (m Migration) instance(s InstanceSnapshot) InstanceSnapshot {
+	s.State = m.state(s.State)
+	if s.Visits != nil {
+		visits := map[Index]int{}
+		for index, count := range s.Visits {
+			visits[m.state(index)] += count
+		}
+		s.Visits = visits
+	}
+	return s
+}
+
+// snapshot returns a copy of the snapshot with the states migrated
+func (m Migration) snapshot(snapshot Snapshot) Snapshot {
+	if len(m.States) == 0 {
+		return snapshot
+	}
+	instances := make([]InstanceSnapshot, len(snapshot.Instances))
+	for i, s := range snapshot.Instances {
+		instances[i] = m.instance(s)
+	}
+	snapshot.Instances = instances
+	return snapshot
+}
+
+// records returns a copy of the records with the states and signals migrated
+func (m Migration) records(records []Record) []Record {
+	if len(m.States) == 0 && len(m.Signals) == 0 {
+		return records
+	}
+	migrated := make([]Record, len(records))
+	for i, r := range records {
+		if r.Snapshot != nil {
+			s := m.instance(*r.Snapshot)
+			r.Snapshot = &s
+		}
+		if r.Transition != nil {
+			t := *r.Transition
+			t.From, t.To, t.Signal = m.state(t.From), m.state(t.To), m.signal(t.Signal)
+			r.Transition = &t
+		}
+		migrated[i] = r
+	}
+	return migrated
+}
+
+// Incompatibility is a state or signal of a persisted instance that's not in the spec
+type Incompatibility struct {
+	ID ID
+
+	// Field is where the state or signal was found, e.g. "state", "visits", "transition.signal"
+	Field string
+
+	// State is the unknown state, if the field is a state
+	State Index
+
+	// Signal is the unknown signal, if the field is a signal
+	Signal Signal
+}
+
+func (i Incompatibility) String() string {
+	if strings.HasSuffix(i.Field, "signal") {
+		return fmt.Sprintf("id=%v %v=%v", i.ID, i.Field, i.Signal)
+	}
+	return fmt.Sprintf("id=%v %v=%v", i.ID, i.Field, i.State)
+}
+
+// ErrIncompatible is returned when restoring instances with states or signals that are not in the spec
+type ErrIncompatible []Incompatibility
+
+func (e ErrIncompatible) Error() string {
+	details := []string{}
+	for _, i := range e {
+		details = append(details, i.String())
+	}
+	return fmt.Sprintf("incompatible with the spec: %v", strings.Join(details, ", "))
+}
+
+func (e ErrIncompatible) Is(target error) bool {
+	return target == SpecError
+}
+
+func (m *machines) CheckCompatibility(snapshot Snapshot) (err error) {
+	m.runner.do(func(g *runner) {
+		if incompatible := g.incompatible(g.options.Migration.snapshot(snapshot), nil); len(incompatible) > 0 {
+			err = incompatible
+		}
+	})
+	return
+}
+
+// incompatible returns the states and signals of the snapshot and the records that are not in the spec
+func (g *runner) incompatible(snapshot Snapshot, records []Record) ErrIncompatible {
+	var found ErrIncompatible
+	state := func(id ID, field string, i Index) {
+		if _, has := g.spec().states[i]; !has {
+			found = append(found, Incompatibility{ID: id, Field: field, State: i, Signal: NoSignal})
+		}
+	}
+	signal := func(id ID, field string, s Signal) {
+		if _, has := g.spec().signals[s]; !has {
+			found = append(found, Incompatibility{ID: id, Field: field, State: NoState, Signal: s})
+		}
+	}
+	instance := func(s InstanceSnapshot) {
+		state(s.ID, "state", s.State)
+		visited := []Index{}
+		for i := range s.Visits {
+			visited = append(visited, i)
+		}
+		sort.Slice(visited, func(i, j int) bool { return visited[i] < visited[j] })
+		for _, i := range visited {
+			state(s.ID, "visits", i)
+		}
+	}
+
+	for _, s := range snapshot.Instances {
+		instance(s)
+	}
+	for _, r := range records {
+		if r.Snapshot != nil {
+			instance(*r.Snapshot)
+		}
+		if t := r.Transition; t != nil {
+			state(r.ID, "transition.from", t.From)
+			state(r.ID, "transition.to", t.To)
+			signal(r.ID, "transition.signal", t.Signal)
+		}
+	}
+	return found
+}
@@ -0,0 +1,189 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompatibility(t *testing.T) {
+
+	// version 1
+	const (
+		pending Index = iota
+		running
+		stopped
+	)
+
+	const (
+		start Signal = iota
+		stop
+	)
+
+	// version 2 renumbers the states and signals
+	const (
+		queued   Index = 10
+		active   Index = 11
+		stopped2 Index = 12
+	)
+
+	const (
+		launch Signal = 10
+		halt   Signal = 11
+	)
+
+	wal := &MemoryWAL{}
+	options := DefaultOptions()
+	options.WAL = wal
+
+	v1, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+		},
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				stop: stopped,
+			},
+		},
+		State{
+			Index: stopped,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, v1.Run(NewClock(), options))
+
+	a, err := v1.New(pending)
+	require.NoError(t, err)
+	require.NoError(t, a.Signal(start))
+	_, err = v1.New(pending)
+	require.NoError(t, err)
+	require.Equal(t, running, a.State())
+
+	snapshot, err := v1.Snapshot()
+	require.NoError(t, err)
+	v1.Done()
+
+	define2 := func() Machines {
+		v2, err := define(
+			State{
+				Index: queued,
+				Transitions: map[Signal]Index{
+					launch: active,
+				},
+			},
+			State{
+				Index: active,
+				Transitions: map[Signal]Index{
+					halt: stopped2,
+				},
+			},
+			State{
+				Index: stopped2,
+			},
+		)
+		require.NoError(t, err)
+		return v2
+	}
+
+	// without a migration
+	v2 := define2()
+	require.NoError(t, v2.Run(NewClock(), options))
+	err = v2.CheckCompatibility(snapshot)
+	require.Equal(t, ErrIncompatible{
+		{ID: a.ID(), Field: "state", State: running, Signal: NoSignal},
+		{ID: a.ID(), Field: "visits", State: pending, Signal: NoSignal},
+		{ID: a.ID(), Field: "visits", State: running, Signal: NoSignal},
+		{ID: a.ID() + 1, Field: "state", State: pending, Signal: NoSignal},
+		{ID: a.ID() + 1, Field: "visits", State: pending, Signal: NoSignal},
+	}, err)
+	require.Equal(t, err, v2.Restore(snapshot))
+	require.Equal(t, 0, v2.Count())
+
+	recoverErr := v2.Recover()
+	require.IsType(t, ErrIncompatible{}, recoverErr)
+	require.Contains(t, recoverErr.(ErrIncompatible), Incompatibility{ID: a.ID(), Field: "transition.signal", State: NoState, Signal: start})
+	require.Equal(t, 0, v2.Count())
+	v2.Done()
+
+	// with a migration
+	options.Migration = Migration{
+		States:  map[Index]Index{pending: queued, running: active, stopped: stopped2},
+		Signals: map[Signal]Signal{start: launch, stop: halt},
+	}
+	v2 = define2()
+	require.NoError(t, v2.Run(NewClock(), options))
+	defer v2.Done()
+	require.NoError(t, v2.CheckCompatibility(snapshot))
+	require.NoError(t, v2.Recover())
+	require.Equal(t, 1, v2.CountIn(queued))
+	require.Equal(t, 1, v2.CountIn(active))
+}
+
+func TestRecoverMigratesOnce(t *testing.T) {
+
+	// version 2 shifts the states of version 1 by one, so migrating twice moves the instances one state too far
+	const (
+		off Index = iota
+		on
+		broken
+	)
+
+	const (
+		flip Signal = iota
+	)
+
+	wal := &MemoryWAL{}
+	options := DefaultOptions()
+	options.WAL = wal
+
+	v1, err := define(
+		State{
+			Index: off,
+			Transitions: map[Signal]Index{
+				flip: on,
+			},
+		},
+		State{
+			Index: on,
+			Transitions: map[Signal]Index{
+				flip: off,
+			},
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, v1.Run(NewClock(), options))
+	a, err := v1.New(off)
+	require.NoError(t, err)
+	_, err = v1.New(off)
+	require.NoError(t, err)
+	require.NoError(t, a.Signal(flip))
+	require.Equal(t, on, a.State())
+	v1.Done()
+
+	v2, err := define(
+		State{
+			Index: on,
+			Transitions: map[Signal]Index{
+				flip: broken,
+			},
+		},
+		State{
+			Index: broken,
+			Transitions: map[Signal]Index{
+				flip: on,
+			},
+		},
+	)
+	require.NoError(t, err)
+	options.Migration = Migration{States: map[Index]Index{off: on, on: broken}}
+	require.NoError(t, v2.Run(NewClock(), options))
+	defer v2.Done()
+
+	require.NoError(t, v2.Recover())
+	require.Equal(t, 1, v2.CountIn(on))
+	require.Equal(t, 1, v2.CountIn(broken))
+}
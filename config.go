@@ -0,0 +1,290 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Config is a spec in the form of a config file, where states and signals are referenced by name.
+// States are numbered in the order they are listed.
+type Config struct {
+	States []StateConfig `json:"states" yaml:"states"`
+
+	// Signals numbers the signals in the order they are listed.  If empty, the signals referenced
+	// are numbered in alphabetical order.
+	Signals []string `json:"signals,omitempty" yaml:"signals,omitempty"`
+
+	// Limits are the flap limits between pairs of states
+	Limits []FlapConfig `json:"limits,omitempty" yaml:"limits,omitempty"`
+}
+
+// StateConfig is a state in a Config
+type StateConfig struct {
+	Name        string            `json:"name" yaml:"name"`
+	Transitions map[string]string `json:"transitions,omitempty" yaml:"transitions,omitempty"`
+	Errors      map[string]string `json:"errors,omitempty" yaml:"errors,omitempty"`
+	ErrorsFirst bool              `json:"errorsFirst,omitempty" yaml:"errorsFirst,omitempty"`
+
+	// Actions are the names of the actions for each signal, bound at Run from Options.Actions
+	Actions map[string]string `json:"actions,omitempty" yaml:"actions,omitempty"`
+
+	TTL   *ExpiryConfig     `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+	Visit *LimitConfig      `json:"visit,omitempty" yaml:"visit,omitempty"`
+	Rearm []string          `json:"rearm,omitempty" yaml:"rearm,omitempty"`
+	Meta  map[string]string `json:"meta,omitempty" yaml:"meta,omitempty"`
+}
+
+// ExpiryConfig is the TTL of a state in a Config
+type ExpiryConfig struct {
+	Ticks Tick   `json:"ticks" yaml:"ticks"`
+	Raise string `json:"raise" yaml:"raise"`
+}
+
+// LimitConfig is the visit limit of a state in a Config
+type LimitConfig struct {
+	Value int    `json:"value" yaml:"value"`
+	Raise string `json:"raise" yaml:"raise"`
+}
+
+// FlapConfig is a flap limit in a Config
+type FlapConfig struct {
+	States [2]string `json:"states" yaml:"states"`
+	Count  int       `json:"count" yaml:"count"`
+	Raise  string    `json:"raise" yaml:"raise"`
+}
+
+// compiledConfig is a config with its names resolved
+type compiledConfig struct {
+	states      []State
+	stateNames  map[Index]string
+	signalNames map[Signal]string
+	limits      []Flap
+}
+
+// compile resolves the names of the states and signals.  Unknown names are reported as issues.
+func (c Config) compile() (compiledConfig, []Issue) {
+	issues := []Issue{}
+	report := func(field, format string, args ...interface{}) {
+		issues = append(issues, Issue{
+			Severity: LintError,
+			Rule:     IssueReference,
+			Field:    field,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	out := compiledConfig{
+		stateNames:  map[Index]string{},
+		signalNames: map[Signal]string{},
+	}
+
+	if len(c.States) == 0 {
+		report("states", "no states")
+		return out, issues
+	}
+
+	states := map[string]Index{}
+	for i, st := range c.States {
+		field := fmt.Sprintf("states[%d].name", i)
+		if st.Name == "" {
+			report(field, "state has no name")
+			continue
+		}
+		if _, has := states[st.Name]; has {
+			report(field, "duplicate state %v", st.Name)
+			continue
+		}
+		states[st.Name] = Index(i)
+		out.stateNames[Index(i)] = st.Name
+	}
+
+	signals := map[string]Signal{}
+	if len(c.Signals) > 0 {
+		for i, name := range c.Signals {
+			if _, has := signals[name]; has {
+				report(fmt.Sprintf("signals[%d]", i), "duplicate signal %v", name)
+				continue
+			}
+			signals[name] = Signal(i)
+		}
+	} else {
+		names := map[string]bool{}
+		for _, st := range c.States {
+			for _, m := range []map[string]string{st.Transitions, st.Errors, st.Actions} {
+				for signal := range m {
+					names[signal] = true
+				}
+			}
+			if st.TTL != nil {
+				names[st.TTL.Raise] = true
+			}
+			if st.Visit != nil {
+				names[st.Visit.Raise] = true
+			}
+			for _, signal := range st.Rearm {
+				names[signal] = true
+			}
+		}
+		for _, limit := range c.Limits {
+			names[limit.Raise] = true
+		}
+		sorted := []string{}
+		for name := range names {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+		for i, name := range sorted {
+			signals[name] = Signal(i)
+		}
+	}
+	for name, signal := range signals {
+		out.signalNames[signal] = name
+	}
+
+	state := func(field, name string) Index {
+		index, has := states[name]
+		if !has {
+			report(field, "unknown state %v", name)
+		}
+		return index
+	}
+	signal := func(field, name string) Signal {
+		s, has := signals[name]
+		if !has {
+			report(field, "unknown signal %v", name)
+		}
+		return s
+	}
+	sortedKeys := func(m map[string]string) []string {
+		keys := []string{}
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	for i, st := range c.States {
+		field := fmt.Sprintf("states[%d]", i)
+		s := State{
+			Index:       Index(i),
+			ErrorsFirst: st.ErrorsFirst,
+			Meta:        copyMeta(st.Meta),
+		}
+		for _, m := range []struct {
+			name   string
+			config map[string]string
+			target *map[Signal]Index
+		}{
+			{"transitions", st.Transitions, &s.Transitions},
+			{"errors", st.Errors, &s.Errors},
+		} {
+			if len(m.config) == 0 {
+				continue
+			}
+			*m.target = map[Signal]Index{}
+			for _, k := range sortedKeys(m.config) {
+				f := fmt.Sprintf("%v.%v.%v", field, m.name, k)
+				(*m.target)[signal(f, k)] = state(f, m.config[k])
+			}
+		}
+		if len(st.Actions) > 0 {
+			s.ActionNames = map[Signal]string{}
+			for _, k := range sortedKeys(st.Actions) {
+				s.ActionNames[signal(fmt.Sprintf("%v.actions.%v", field, k), k)] = st.Actions[k]
+			}
+		}
+		if st.TTL != nil {
+			s.TTL = Expiry{st.TTL.Ticks, signal(field+".ttl.raise", st.TTL.Raise)}
+		}
+		if st.Visit != nil {
+			s.Visit = Limit{st.Visit.Value, signal(field+".visit.raise", st.Visit.Raise)}
+		}
+		for j, name := range st.Rearm {
+			s.Rearm = append(s.Rearm, signal(fmt.Sprintf("%v.rearm[%d]", field, j), name))
+		}
+		out.states = append(out.states, s)
+	}
+
+	for i, limit := range c.Limits {
+		field := fmt.Sprintf("limits[%d]", i)
+		out.limits = append(out.limits, Flap{
+			States: [2]Index{
+				state(field+".states[0]", limit.States[0]),
+				state(field+".states[1]", limit.States[1]),
+			},
+			Count: limit.Count,
+			Raise: signal(field+".raise", limit.Raise),
+		})
+	}
+	return out, issues
+}
+
+// ErrInvalidConfig is a config with references that can't be resolved
+type ErrInvalidConfig []Issue
+
+func (e ErrInvalidConfig) Error() string {
+	messages := []string{}
+	for _, issue := range e {
+		messages = append(messages, issue.String())
+	}
+	return fmt.Sprintf("invalid config: %v", strings.Join(messages, "; "))
+}
+
+func (e ErrInvalidConfig) Is(target error) bool {
+	return target == SpecError
+}
+
+// defineConfig compiles the config to a spec, with the names of the states and signals and the flap limits
+// of the config.  Options.StateNames, SignalNames and Limits still override them at Run.
+func defineConfig(config Config) (*machines, compiledConfig, error) {
+	compiled, issues := config.compile()
+	if len(issues) > 0 {
+		return nil, compiled, ErrInvalidConfig(issues)
+	}
+	spec := newSpec()
+	spec.stateNames = compiled.stateNames
+	spec.signalNames = compiled.signalNames
+	spec, err := spec.build(compiled.states[0], compiled.states[1:]...)
+	if err != nil {
+		return nil, compiled, err
+	}
+	if _, err := spec.compileFlapping(compiled.limits); err != nil {
+		return nil, compiled, err
+	}
+	return &machines{
+		spec:   spec,
+		States: compiled.states,
+	}, compiled, nil
+}
+
+// DefineFromJSON compiles a Config in JSON, where states and signals are referenced by name, into a spec.
+// The options returned are the default options with the StateNames, SignalNames and Limits of the config,
+// to be customized and passed to Run.  See DefineFromYAML for configs in YAML.
+func DefineFromJSON(r io.Reader) (Machines, Options, error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, Options{}, err
+	}
+	config, err := decodeJSONConfig(raw)
+	if err != nil {
+		return nil, Options{}, err
+	}
+	return DefineFromConfig(config)
+}
+
+// DefineFromConfig compiles a Config decoded by the caller, e.g. from another format, as DefineFromJSON does.
+func DefineFromConfig(config Config) (Machines, Options, error) {
+	m, compiled, err := defineConfig(config)
+	if err != nil {
+		return nil, Options{}, err
+	}
+	options := DefaultOptions()
+	options.StateNames = compiled.stateNames
+	options.SignalNames = compiled.signalNames
+	options.Limits = compiled.limits
+	return m, options, nil
+}
@@ -0,0 +1,45 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefineFromJSON(t *testing.T) {
+
+	m, options, err := DefineFromJSON(strings.NewReader(`{
+  "states": [
+    {"name": "pending", "transitions": {"start": "running"}},
+    {"name": "running", "transitions": {"stop": "stopped", "poll": "running"}, "ttl": {"ticks": 5, "raise": "poll"},
+      "visit": {"value": 3, "raise": "stop"}},
+    {"name": "stopped", "transitions": {"start": "running"}}
+  ],
+  "limits": [{"states": ["running", "stopped"], "count": 2, "raise": "stop"}]
+}`))
+	require.NoError(t, err)
+
+	// signals are numbered alphabetically when not listed
+	require.Equal(t, map[Index]string{0: "pending", 1: "running", 2: "stopped"}, options.StateNames)
+	require.Equal(t, map[Signal]string{0: "poll", 1: "start", 2: "stop"}, options.SignalNames)
+	require.Equal(t, []Flap{{States: [2]Index{1, 2}, Count: 2, Raise: 2}}, options.Limits)
+	require.Equal(t, Expiry{5, 0}, m.(*machines).States[1].TTL)
+	require.Equal(t, Limit{3, 2}, m.(*machines).States[1].Visit)
+
+	require.NoError(t, m.Run(NewClock(), options))
+	defer m.Done()
+
+	a, err := m.New(0)
+	require.NoError(t, err)
+	require.NoError(t, a.Signal(1))
+	require.Equal(t, Index(1), a.State())
+
+	_, _, err = DefineFromJSON(strings.NewReader(`{"states": [{"name": "pending", "transitions": {"start": "runing"}}]}`))
+	require.Equal(t, ErrInvalidConfig{
+		{Severity: LintError, Rule: IssueReference, Field: "states[0].transitions.start", Message: "unknown state runing"},
+	}, err)
+
+	_, _, err = DefineFromJSON(strings.NewReader(`{"states": [{"name": "pending", "ttl": 5}]}`))
+	require.Error(t, err)
+}
@@ -0,0 +1,73 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"reflect"
+)
+
+// DeepCopy returns a copy of the value with its slices, maps, arrays, pointers and the exported
+// fields of its structs copied recursively.  Unexported fields, channels and functions are shared
+// with the original.  It's meant for Options.CopyData, and does not handle cyclic values.
+func DeepCopy(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return deepCopy(reflect.ValueOf(v)).Interface()
+}
+
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		copy := reflect.New(v.Elem().Type())
+		copy.Elem().Set(deepCopy(v.Elem()))
+		return copy
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		copy := reflect.New(v.Type()).Elem()
+		copy.Set(deepCopy(v.Elem()))
+		return copy
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		copy := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			copy.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return copy
+
+	case reflect.Array:
+		copy := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			copy.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return copy
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		copy := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, key := range v.MapKeys() {
+			copy.SetMapIndex(deepCopy(key), deepCopy(v.MapIndex(key)))
+		}
+		return copy
+
+	case reflect.Struct:
+		copy := reflect.New(v.Type()).Elem()
+		copy.Set(v) // shallow copy of all the fields, including the unexported ones
+		for i := 0; i < v.NumField(); i++ {
+			if field := copy.Field(i); field.CanSet() {
+				field.Set(deepCopy(v.Field(i)))
+			}
+		}
+		return copy
+	}
+	return v
+}
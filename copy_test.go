@@ -0,0 +1,91 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type copyTarget struct {
+	Name    string
+	Tags    []string
+	Labels  map[string]string
+	Next    *copyTarget
+	private []int
+}
+
+func TestDeepCopy(t *testing.T) {
+
+	require.Nil(t, DeepCopy(nil))
+	require.Equal(t, 1, DeepCopy(1))
+
+	buff := []byte("hello")
+	copied := DeepCopy(buff).([]byte)
+	buff[0] = 'j'
+	require.Equal(t, []byte("hello"), copied)
+
+	private := []int{1}
+	v := &copyTarget{
+		Name:    "a",
+		Tags:    []string{"x"},
+		Labels:  map[string]string{"k": "v"},
+		Next:    &copyTarget{Name: "b"},
+		private: private,
+	}
+	c := DeepCopy(v).(*copyTarget)
+	require.Equal(t, v, c)
+
+	v.Tags[0] = "y"
+	v.Labels["k"] = "w"
+	v.Next.Name = "c"
+	require.Equal(t, []string{"x"}, c.Tags)
+	require.Equal(t, map[string]string{"k": "v"}, c.Labels)
+	require.Equal(t, "b", c.Next.Name)
+
+	private[0] = 2
+	require.Equal(t, []int{2}, c.private) // unexported fields are shared
+
+	m := DeepCopy(map[string]interface{}{"list": []interface{}{"a"}}).(map[string]interface{})
+	require.Equal(t, map[string]interface{}{"list": []interface{}{"a"}}, m)
+}
+
+func TestCopyData(t *testing.T) {
+
+	const (
+		waiting Index = iota
+		polled
+	)
+
+	const (
+		poll Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: waiting,
+			Transitions: map[Signal]Index{
+				poll: polled,
+			},
+		},
+		State{
+			Index: polled,
+			Transitions: map[Signal]Index{
+				poll: polled,
+			},
+		},
+	)
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.CopyData = DeepCopy
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	instance, err := machines.New(waiting)
+	require.NoError(t, err)
+
+	buff := []byte("response")
+	require.NoError(t, instance.Signal(poll, buff))
+	copy(buff, "reused!!")
+	require.Equal(t, polled, instance.State())
+	require.Equal(t, []interface{}{[]byte("response")}, instance.Data())
+}
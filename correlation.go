@@ -0,0 +1,65 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"time"
+)
+
+// CorrelationID identifies the external request, e.g. of an API call, that caused a signal.  It's sent as
+// one of the optional data of the signal, and taken out of the data, so that the transition, its WAL record
+// and the context of its action carry it.  Signals sent by a context action to the FSM it's given, and signals
+// routed by a Router, carry it on.
+type CorrelationID string
+
+// correlation takes the correlation ID, if any, out of the data of a signal
+func correlation(data []interface{}) ([]interface{}, CorrelationID) {
+	found := -1
+	for i, d := range data {
+		if _, is := d.(CorrelationID); is {
+			found = i
+			break
+		}
+	}
+	if found < 0 {
+		return data, ""
+	}
+	id := data[found].(CorrelationID)
+	rest := []interface{}{}
+	for i, d := range data {
+		if _, is := d.(CorrelationID); !is && i != found {
+			rest = append(rest, d)
+		}
+	}
+	if len(rest) == 0 {
+		return nil, id
+	}
+	return rest, id
+}
+
+// correlated adds the correlation ID of the transition to the data of a signal sent by its action, unless
+// the data has one
+func (c contextual) correlated(data []interface{}) []interface{} {
+	if c.ctx.CorrelationID == "" {
+		return data
+	}
+	for _, d := range data {
+		if _, is := d.(CorrelationID); is {
+			return data
+		}
+	}
+	return append(append([]interface{}{}, data...), c.ctx.CorrelationID)
+}
+
+// Signal implements FSM
+func (c contextual) Signal(s Signal, optionalData ...interface{}) error {
+	return c.instance.Signal(s, c.correlated(optionalData)...)
+}
+
+// SignalFrom implements FSM
+func (c contextual) SignalFrom(origin Origin, s Signal, optionalData ...interface{}) error {
+	return c.instance.SignalFrom(origin, s, c.correlated(optionalData)...)
+}
+
+// SignalAt implements FSM
+func (c contextual) SignalAt(at time.Time, s Signal, optionalData ...interface{}) error {
+	return c.instance.SignalAt(at, s, c.correlated(optionalData)...)
+}
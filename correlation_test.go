@@ -0,0 +1,76 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrelationID(t *testing.T) {
+
+	const (
+		pending Index = iota
+		provisioning
+		running
+	)
+
+	const (
+		start Signal = iota
+		ready
+	)
+
+	contexts := make(chan TransitionContext, 2)
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: provisioning,
+			},
+			ContextActions: map[Signal]ActionCtx{
+				start: func(ctx TransitionContext, f FSM) error {
+					contexts <- ctx
+					go f.Signal(ready) // as a callback of the provisioner would
+					return nil
+				},
+			},
+		},
+		State{
+			Index: provisioning,
+			Transitions: map[Signal]Index{
+				ready: running,
+			},
+		},
+		State{
+			Index: running,
+		},
+	)
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.WAL = &MemoryWAL{}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	transitions, cancel := machines.Watch(2)
+	defer cancel()
+
+	a, err := machines.New(pending)
+	require.NoError(t, err)
+	require.NoError(t, a.Signal(start, CorrelationID("req-1"), "payload"))
+
+	ctx := <-contexts
+	require.Equal(t, CorrelationID("req-1"), ctx.CorrelationID)
+	require.Equal(t, []interface{}{"payload"}, ctx.Data)
+
+	first, second := <-transitions, <-transitions
+	require.Equal(t, CorrelationID("req-1"), first.CorrelationID)
+	require.Equal(t, start, first.Signal)
+	require.Equal(t, CorrelationID("req-1"), second.CorrelationID)
+	require.Equal(t, ready, second.Signal)
+	require.Equal(t, []interface{}{"payload"}, a.Data())
+
+	it, err := machines.HistoryIter(HistoryFilter{IDs: []ID{a.ID()}}, "")
+	require.NoError(t, err)
+	logged, ok := it.Next()
+	require.True(t, ok)
+	require.Equal(t, CorrelationID("req-1"), logged.CorrelationID)
+}
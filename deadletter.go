@@ -0,0 +1,66 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// TerminalPolicy is what happens to a signal for an instance that is in a terminal state, i.e. a state
+// without transitions, or that has been freed.
+type TerminalPolicy int
+
+const (
+	// TerminalError reports ErrTerminal or ErrUnknownFSM on Errors.  This is the default.
+	TerminalError TerminalPolicy = iota
+
+	// TerminalIgnore drops the signal silently
+	TerminalIgnore
+
+	// TerminalDeadLetter keeps the signal in the list of dead letters
+	TerminalDeadLetter
+)
+
+// DeadLetter is a signal that could not be delivered because the instance is in a terminal state or freed
+type DeadLetter struct {
+	ID     ID
+	State  Index
+	Signal Signal
+	Data   []interface{}
+	Freed  bool
+	Tick   Time
+}
+
+// terminal applies the terminal policy to the event if the instance is freed or in a terminal state.
+// It returns true if the event is handled.
+func (g *runner) terminal(instance *instance, event *event) (bool, error) {
+	freed := g.members[instance.id] != instance
+	if !freed && len(g.spec().states[instance.state].Transitions) > 0 {
+		return false, nil
+	}
+
+	switch g.options.OnTerminal {
+	case TerminalIgnore:
+		return true, nil
+	case TerminalDeadLetter:
+		g.deadLetters = append(g.deadLetters, DeadLetter{
+			ID:     instance.id,
+			State:  instance.state,
+			Signal: event.signal,
+			Data:   event.data,
+			Freed:  freed,
+			Tick:   g.ct(),
+		})
+		if over := len(g.deadLetters) - g.options.DeadLetterLimit; over > 0 {
+			g.deadLetters = append([]DeadLetter(nil), g.deadLetters[over:]...) // drops the oldest
+			g.deadLettersDropped += int64(over)
+		}
+		return true, nil
+	}
+
+	if freed {
+		return true, ErrUnknownFSM(instance.id)
+	}
+	return true, ErrTerminal{spec: g.spec(), ID: instance.id, State: instance.state, Signal: event.signal}
+}
+
+// drainDeadLetters returns and clears the dead letters
+func (g *runner) drainDeadLetters() []DeadLetter {
+	letters := g.deadLetters
+	g.deadLetters = nil
+	return letters
+}
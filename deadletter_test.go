@@ -0,0 +1,124 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerminalPolicy(t *testing.T) {
+
+	const (
+		running Index = iota
+		terminated
+	)
+
+	const (
+		terminate Signal = iota
+		ping
+	)
+
+	run := func(policy TerminalPolicy) (*machines, FSM, FSM) {
+		machines, err := define(
+			State{
+				Index: running,
+				Transitions: map[Signal]Index{
+					terminate: terminated,
+					ping:      running,
+				},
+			},
+			State{
+				Index: terminated,
+			},
+		)
+		require.NoError(t, err)
+		options := DefaultOptions()
+		options.OnTerminal = policy
+		require.NoError(t, machines.Run(NewClock(), options))
+
+		a, err := machines.New(terminated)
+		require.NoError(t, err)
+		b, err := machines.New(running)
+		require.NoError(t, err)
+		require.NoError(t, machines.Free(b.ID()))
+		return machines, a, b
+	}
+
+	// handles signals that were queued for the instance before it was freed
+	inflight := func(machines *machines, f FSM, signal Signal) (err error) {
+		machines.runner.do(func(g *runner) {
+			i := f.(*instance)
+			err = g.handleEvent(g.tid(), i, &event{instance: i.id, ref: i, signal: signal})
+		})
+		return
+	}
+
+	machines, a, b := run(TerminalError)
+	errs := machines.Errors()
+	require.NoError(t, a.Signal(ping))
+	require.Equal(t, ErrTerminal{spec: machines.runner.spec(), ID: a.ID(), State: terminated, Signal: ping}, <-errs)
+	require.Equal(t, ErrFreed(b.ID()), b.Signal(ping))
+	require.Equal(t, ErrUnknownFSM(b.ID()), inflight(machines, b, ping)) // queued before the instance was freed
+	machines.Done()
+
+	machines, a, b = run(TerminalIgnore)
+	require.NoError(t, a.Signal(ping))
+	require.NoError(t, inflight(machines, b, ping))
+	require.Empty(t, machines.DeadLetters())
+	machines.Done()
+
+	machines, a, b = run(TerminalDeadLetter)
+	defer machines.Done()
+	require.NoError(t, a.Signal(ping, "hello"))
+	require.NoError(t, inflight(machines, b, terminate))
+	require.Equal(t, []DeadLetter{
+		{ID: a.ID(), State: terminated, Signal: ping, Data: []interface{}{"hello"}},
+		{ID: b.ID(), State: running, Signal: terminate, Freed: true},
+	}, machines.DeadLetters())
+	require.Empty(t, machines.DeadLetters())
+}
+
+func TestDeadLetterLimit(t *testing.T) {
+
+	const (
+		running Index = iota
+		terminated
+	)
+
+	const (
+		terminate Signal = iota
+		ping
+	)
+
+	machines, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				terminate: terminated,
+				ping:      running,
+			},
+		},
+		State{
+			Index: terminated,
+		},
+	)
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.OnTerminal = TerminalDeadLetter
+	options.DeadLetterLimit = 2
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	a, err := machines.New(terminated)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, a.Signal(ping, i))
+	}
+
+	// keeps the newest
+	require.Equal(t, []DeadLetter{
+		{ID: a.ID(), State: terminated, Signal: ping, Data: []interface{}{3}},
+		{ID: a.ID(), State: terminated, Signal: ping, Data: []interface{}{4}},
+	}, machines.DeadLetters())
+	require.Equal(t, int64(3), machines.Stats().DeadLettersDropped)
+}
@@ -0,0 +1,253 @@
+// Package docker drives fsm instances from the lifecycle and health events of Docker containers.
+package docker // import "github.com/orkestr8/fsm/docker"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orkestr8/fsm"
+)
+
+const (
+	// DefaultHost is the address of the local Docker engine
+	DefaultHost = "unix:///var/run/docker.sock"
+
+	defaultRetryInterval = 1 * time.Second
+)
+
+// Event is a container event from the Docker engine's events stream
+type Event struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+
+	// TimeNano is when the event happened, in nanoseconds since the epoch
+	TimeNano int64 `json:"timeNano"`
+}
+
+// Adapter is a fsm.Source that maintains an instance per container, created when the container starts
+// and freed when the container is destroyed, and signals the instance as the container runs, changes
+// health, and exits.  The exit code is sent as the data of the Exited signal.
+type Adapter struct {
+
+	// Machines allocates the instances of the containers
+	Machines fsm.Machines
+
+	// Initial is the state of new instances
+	Initial fsm.Index
+
+	// Running is raised when the container starts or becomes healthy
+	Running fsm.Signal
+
+	// Unhealthy is raised when the container's health check fails
+	Unhealthy fsm.Signal
+
+	// Exited is raised when the container exits
+	Exited fsm.Signal
+
+	// Host is the address of the Docker engine.  Defaults to DefaultHost.
+	Host string
+
+	// RetryInterval is the wait before reconnecting to the events stream.  Defaults to 1 second.  The stream
+	// resumes from the last event handled, so that the events in between are not lost.
+	RetryInterval time.Duration
+
+	// OnError is called with the errors of the events stream and of handling its events, if set.  An event
+	// that can't be handled is skipped.
+	OnError func(error)
+
+	containers map[string]fsm.FSM
+	last       int64 // the time of the last event handled, in nanoseconds
+	lock       sync.RWMutex
+}
+
+// Instance returns the instance of the container
+func (a *Adapter) Instance(container string) (fsm.FSM, bool) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	f, has := a.containers[container]
+	return f, has
+}
+
+// Run implements fsm.Source
+func (a *Adapter) Run(ctx context.Context, emit func(fsm.ID, fsm.Signal, ...interface{})) {
+	retry := a.RetryInterval
+	if retry == 0 {
+		retry = defaultRetryInterval
+	}
+
+	for {
+		if err := a.stream(ctx, emit); err != nil && ctx.Err() == nil {
+			a.error(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+// stream handles the events from the engine until the stream ends or the context is cancelled.
+func (a *Adapter) stream(ctx context.Context, emit func(fsm.ID, fsm.Signal, ...interface{})) error {
+	client, base, err := a.client()
+	if err != nil {
+		return err
+	}
+
+	filters, _ := json.Marshal(map[string][]string{"type": {"container"}})
+	query := url.Values{"filters": {string(filters)}}
+	if a.last > 0 {
+		query.Set("since", fmt.Sprintf("%d.%09d", a.last/int64(time.Second), a.last%int64(time.Second)))
+	}
+	req, err := http.NewRequest(http.MethodGet, base+"/events?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("docker events: %v", resp.Status)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		event := Event{}
+		if err := decoder.Decode(&event); err != nil {
+			return err
+		}
+		if event.TimeNano > 0 {
+			if event.TimeNano <= a.last {
+				continue // handled before the stream was resumed
+			}
+			a.last = event.TimeNano
+		}
+		if err := a.Handle(event, emit); err != nil {
+			a.error(fmt.Errorf("docker event %v of %v: %w", event.Action, event.Actor.ID, err))
+		}
+	}
+}
+
+func (a *Adapter) error(err error) {
+	if a.OnError != nil {
+		a.OnError(err)
+	}
+}
+
+// Handle applies a container event, allocating or freeing the container's instance as needed.
+func (a *Adapter) Handle(event Event, emit func(fsm.ID, fsm.Signal, ...interface{})) error {
+	if event.Type != "" && event.Type != "container" {
+		return nil
+	}
+
+	container := event.Actor.ID
+
+	switch event.Action {
+
+	case "start":
+		f, err := a.instance(container)
+		if err != nil {
+			return err
+		}
+		emit(f.ID(), a.Running)
+
+	case "health_status: healthy":
+		if f, has := a.Instance(container); has {
+			emit(f.ID(), a.Running)
+		}
+
+	case "health_status: unhealthy":
+		if f, has := a.Instance(container); has {
+			emit(f.ID(), a.Unhealthy)
+		}
+
+	case "die":
+		if f, has := a.Instance(container); has {
+			code, err := strconv.Atoi(event.Actor.Attributes["exitCode"])
+			if err != nil {
+				code = -1
+			}
+			emit(f.ID(), a.Exited, code)
+		}
+
+	case "destroy":
+		a.lock.Lock()
+		defer a.lock.Unlock()
+
+		f, has := a.containers[container]
+		if !has {
+			return nil
+		}
+		// a pinned instance is kept, with the container, to be freed when it's destroyed again
+		err := a.Machines.Free(f.ID())
+		if _, pinned := err.(fsm.ErrPinned); pinned {
+			return err
+		}
+		delete(a.containers, container)
+		return err
+	}
+	return nil
+}
+
+// instance returns the instance of the container, allocating one if the container is new.
+func (a *Adapter) instance(container string) (fsm.FSM, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if f, has := a.containers[container]; has {
+		return f, nil
+	}
+	f, err := a.Machines.New(a.Initial)
+	if err != nil {
+		return nil, err
+	}
+	if a.containers == nil {
+		a.containers = map[string]fsm.FSM{}
+	}
+	a.containers[container] = f
+	return f, nil
+}
+
+// client returns the http client and base URL for the engine
+func (a *Adapter) client() (*http.Client, string, error) {
+	host := a.Host
+	if host == "" {
+		host = DefaultHost
+	}
+
+	if strings.HasPrefix(host, "unix://") {
+		path := strings.TrimPrefix(host, "unix://")
+		return &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", path)
+				},
+			},
+		}, "http://docker", nil
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, "", err
+	}
+	if u.Scheme == "tcp" {
+		u.Scheme = "http"
+	}
+	return http.DefaultClient, strings.TrimSuffix(u.String(), "/"), nil
+}
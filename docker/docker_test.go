@@ -0,0 +1,282 @@
+package docker // import "github.com/orkestr8/fsm/docker"
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/orkestr8/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdapter(t *testing.T) {
+
+	const (
+		created fsm.Index = iota
+		running
+		unhealthy
+		exited
+	)
+
+	const (
+		start fsm.Signal = iota
+		fail
+		exit
+	)
+
+	machines, err := fsm.Define(
+		fsm.State{
+			Index: created,
+			Transitions: map[fsm.Signal]fsm.Index{
+				start: running,
+			},
+		},
+		fsm.State{
+			Index: running,
+			Transitions: map[fsm.Signal]fsm.Index{
+				fail: unhealthy,
+				exit: exited,
+			},
+		},
+		fsm.State{
+			Index: unhealthy,
+			Transitions: map[fsm.Signal]fsm.Index{
+				start: running,
+				exit:  exited,
+			},
+		},
+		fsm.State{
+			Index: exited,
+			Transitions: map[fsm.Signal]fsm.Index{
+				start: running,
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	events := make(chan string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/events", r.URL.Path)
+		require.Equal(t, `{"type":["container"]}`, r.URL.Query().Get("filters"))
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case e := <-events:
+				fmt.Fprintln(w, e)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	adapter := &Adapter{
+		Machines:  machines,
+		Initial:   created,
+		Running:   start,
+		Unhealthy: fail,
+		Exited:    exit,
+		Host:      server.URL,
+	}
+	machines.AddSource(adapter)
+	require.NoError(t, machines.Run(fsm.NewClock(), fsm.DefaultOptions()))
+	defer machines.Done()
+
+	send := func(action, id string, attributes string) {
+		events <- fmt.Sprintf(`{"Type":"container","Action":%q,"Actor":{"ID":%q,"Attributes":{%s}}}`,
+			action, id, attributes)
+	}
+
+	// waits for the event to be processed
+	eventually := func(check func() bool) {
+		for i := 0; i < 100 && !check(); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		require.True(t, check())
+	}
+
+	send("start", "abc", "")
+	eventually(func() bool { return machines.CountIn(running) == 1 })
+
+	f, has := adapter.Instance("abc")
+	require.True(t, has)
+
+	send("health_status: unhealthy", "abc", "")
+	eventually(func() bool { return f.State() == unhealthy })
+
+	send("health_status: healthy", "abc", "")
+	eventually(func() bool { return f.State() == running })
+
+	send("die", "abc", `"exitCode":"137"`)
+	eventually(func() bool { return f.State() == exited })
+	require.Equal(t, []interface{}{137}, f.Data())
+
+	send("destroy", "abc", "")
+	eventually(func() bool { return machines.Count() == 0 })
+	_, has = adapter.Instance("abc")
+	require.False(t, has)
+}
+
+func TestAdapterClient(t *testing.T) {
+	client, base, err := (&Adapter{}).client()
+	require.NoError(t, err)
+	require.NotNil(t, client)
+	require.Equal(t, "http://docker", base)
+
+	_, base, err = (&Adapter{Host: "tcp://127.0.0.1:2375"}).client()
+	require.NoError(t, err)
+	require.Equal(t, "http://127.0.0.1:2375", base)
+
+	done := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer close(done)
+		(&Adapter{Host: "unix:///nonexistent.sock", RetryInterval: time.Millisecond}).Run(ctx,
+			func(fsm.ID, fsm.Signal, ...interface{}) {})
+	}()
+	cancel()
+	<-done
+}
+
+func TestAdapterResume(t *testing.T) {
+
+	const (
+		running fsm.Index = iota
+	)
+
+	const (
+		start fsm.Signal = iota
+	)
+
+	machines, err := fsm.Define(
+		fsm.State{
+			Index: running,
+			Transitions: map[fsm.Signal]fsm.Index{
+				start: running,
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	events := make(chan string)
+	since := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since <- r.URL.Query().Get("since")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case e := <-events:
+				if e == "" {
+					return // ends the stream
+				}
+				fmt.Fprintln(w, e)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	errs := make(chan error, 10)
+	adapter := &Adapter{
+		Machines:      machines,
+		Initial:       running,
+		Running:       start,
+		Host:          server.URL,
+		RetryInterval: time.Millisecond,
+		OnError:       func(err error) { errs <- err },
+	}
+	machines.AddSource(adapter)
+	require.NoError(t, machines.Run(fsm.NewClock(), fsm.DefaultOptions()))
+	defer machines.Done()
+
+	send := func(action, id string, at int64) {
+		events <- fmt.Sprintf(`{"Type":"container","Action":%q,"Actor":{"ID":%q},"timeNano":%d}`, action, id, at)
+	}
+
+	require.Equal(t, "", <-since)
+
+	send("start", "abc", 1000000001)
+	var f fsm.FSM
+	has := false
+	for i := 0; i < 100 && !has; i++ {
+		time.Sleep(10 * time.Millisecond)
+		f, has = adapter.Instance("abc")
+	}
+	require.True(t, has)
+
+	// the instance is already freed, so the event fails but the stream goes on
+	require.NoError(t, machines.Free(f.ID()))
+	send("destroy", "abc", 1000000002)
+	require.Error(t, <-errs)
+
+	send("start", "def", 1000000003)
+	events <- "" // the stream ends, and the adapter reconnects from the last event
+	require.Equal(t, "1.000000003", <-since)
+
+	send("start", "def", 1000000003) // already handled
+	send("start", "ghi", 1000000004)
+	for i := 0; i < 100 && machines.Count() < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(t, 2, machines.Count())
+	require.Len(t, errs, 1) // the end of the stream
+}
+
+func TestAdapterPinned(t *testing.T) {
+
+	const (
+		running fsm.Index = iota
+	)
+
+	const (
+		start fsm.Signal = iota
+	)
+
+	machines, err := fsm.Define(
+		fsm.State{
+			Index: running,
+			Transitions: map[fsm.Signal]fsm.Index{
+				start: running,
+			},
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(fsm.NewClock(), fsm.DefaultOptions()))
+	defer machines.Done()
+
+	adapter := &Adapter{
+		Machines: machines,
+		Initial:  running,
+		Running:  start,
+	}
+	emit := func(fsm.ID, fsm.Signal, ...interface{}) {}
+	event := func(action string) Event {
+		e := Event{Type: "container", Action: action}
+		e.Actor.ID = "abc"
+		return e
+	}
+
+	require.NoError(t, adapter.Handle(event("start"), emit))
+	f, has := adapter.Instance("abc")
+	require.True(t, has)
+
+	// the container is kept with its instance until it can be freed
+	f.Pin()
+	require.Equal(t, fsm.ErrPinned(f.ID()), adapter.Handle(event("destroy"), emit))
+	_, has = adapter.Instance("abc")
+	require.True(t, has)
+
+	f.Unpin()
+	require.NoError(t, adapter.Handle(event("destroy"), emit))
+	_, has = adapter.Instance("abc")
+	require.False(t, has)
+	require.Equal(t, 0, machines.Count())
+}
@@ -0,0 +1,89 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// writeDOT writes the spec as a Graphviz digraph.  Terminal states are double circles, and the TTL and visit
+// limit of a state are in its label.  Transitions are labeled with their signals and actions, those raised on
+// expiry are bold, those to the candidates of a choice have diamond heads, and those on action error are dashed.  The descriptions of transitions are the tooltips of their
+// edges.  Flap limits are dotted red edges between their states.
+func (s *spec) writeDOT(w io.Writer) error {
+	indexes := []Index{}
+	for index := range s.states {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	buff := &bytes.Buffer{}
+	fmt.Fprintf(buff, "digraph fsm {\n")
+	for _, index := range indexes {
+		st := s.states[index]
+		label := []string{s.stateName(index)}
+		if st.TTL.TTL > 0 {
+			label = append(label, fmt.Sprintf("ttl %d: %v", st.TTL.TTL, s.signalName(st.TTL.Raise)))
+		}
+		if st.Visit.Value > 0 {
+			label = append(label, fmt.Sprintf("visit %d: %v", st.Visit.Value, s.signalName(st.Visit.Raise)))
+		}
+		shape := "circle"
+		if len(st.Transitions) == 0 {
+			shape = "doublecircle"
+		}
+		fmt.Fprintf(buff, "  %q [shape=%v label=%q];\n", s.stateName(index), shape, strings.Join(label, "\n"))
+	}
+
+	for _, e := range s.edges() {
+		st := s.states[e.from]
+		label := s.signalName(e.signal)
+		attrs := []string{}
+		if e.onError {
+			label += " (error)"
+			attrs = append(attrs, "style=dashed")
+		} else if e.choice {
+			label += " (choice)"
+			attrs = append(attrs, "arrowhead=diamond")
+		} else {
+			if action, has := st.ContextActions[e.signal]; has {
+				label += " / " + actionName(action)
+			} else if action, has := st.Actions[e.signal]; has {
+				label += " / " + actionName(action)
+			} else if name, has := st.ActionNames[e.signal]; has {
+				label += " / " + name
+			}
+			if st.TTL.TTL > 0 && st.TTL.Raise == e.signal {
+				attrs = append(attrs, "style=bold")
+			}
+		}
+		if description, has := st.Descriptions[e.signal]; has {
+			attrs = append(attrs, fmt.Sprintf("tooltip=%q", description))
+		}
+		attrs = append([]string{fmt.Sprintf("label=%q", label)}, attrs...)
+		fmt.Fprintf(buff, "  %q -> %q [%v];\n", s.stateName(e.from), s.stateName(e.to), strings.Join(attrs, " "))
+	}
+
+	flaps := []*Flap{}
+	for _, f := range s.flaps {
+		flaps = append(flaps, f)
+	}
+	sort.Slice(flaps, func(i, j int) bool {
+		a, b := flaps[i].States, flaps[j].States
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		return a[1] < b[1]
+	})
+	for _, f := range flaps {
+		fmt.Fprintf(buff, "  %q -> %q [dir=both style=dotted color=red label=%q];\n",
+			s.stateName(f.States[0]), s.stateName(f.States[1]),
+			fmt.Sprintf("flap %d: %v", f.Count, s.signalName(f.Raise)))
+	}
+	fmt.Fprintf(buff, "}\n")
+
+	_, err := w.Write(buff.Bytes())
+	return err
+}
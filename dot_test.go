@@ -0,0 +1,86 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteDOT(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+		failed
+		deleted
+	)
+
+	const (
+		start Signal = iota
+		fail
+		retry
+		remove
+	)
+
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			Actions: map[Signal]Action{
+				start: provision,
+			},
+			Errors: map[Signal]Index{
+				start: failed,
+			},
+			TTL: Expiry{5, start},
+		},
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				fail: failed,
+			},
+			Choices: map[Signal]Choice{
+				fail: {To: []Index{deleted}, Select: func(FSM) Index { return failed }},
+			},
+		},
+		State{
+			Index: failed,
+			Transitions: map[Signal]Index{
+				retry:  running,
+				remove: deleted,
+			},
+			Visit: Limit{3, remove},
+			Descriptions: map[Signal]string{
+				retry: "retried by the operator",
+			},
+		},
+		State{
+			Index: deleted,
+		},
+	)
+	require.NoError(t, err)
+	machines.spec.stateNames = map[Index]string{pending: "pending", running: "running", failed: "failed",
+		deleted: "deleted"}
+	machines.spec.signalNames = map[Signal]string{start: "start", fail: "fail", retry: "retry", remove: "remove"}
+	machines.spec.compileFlappingMust([]Flap{{States: [2]Index{failed, running}, Count: 2, Raise: remove}})
+
+	buff := &bytes.Buffer{}
+	require.NoError(t, machines.WriteDOT(buff))
+	require.Equal(t, `digraph fsm {
+  "pending" [shape=circle label="pending\nttl 5: start"];
+  "running" [shape=circle label="running"];
+  "failed" [shape=circle label="failed\nvisit 3: remove"];
+  "deleted" [shape=doublecircle label="deleted"];
+  "pending" -> "running" [label="start / fsm.provision" style=bold];
+  "pending" -> "failed" [label="start (error)" style=dashed];
+  "running" -> "failed" [label="fail"];
+  "running" -> "deleted" [label="fail (choice)" arrowhead=diamond];
+  "failed" -> "running" [label="retry" tooltip="retried by the operator"];
+  "failed" -> "deleted" [label="remove"];
+  "failed" -> "running" [dir=both style=dotted color=red label="flap 2: remove"];
+}
+`, buff.String())
+}
@@ -0,0 +1,91 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// Work is a unit of processing of the state machines: a clock tick, a signal or a read
+type Work struct {
+	tx *txn
+}
+
+// Driver processes the work of the state machines on the caller's goroutine, for embedding
+// in an event loop of its own (e.g. the frame loop of a game server).  Signals of the instances
+// are buffered up to Options.BufferSize, so they can be sent from the driving goroutine.  Other
+// methods of Machines (e.g. New, Count) wait for the driver to process them, so they must be
+// called from another goroutine.  Options.ClockStall is not supported.
+type Driver interface {
+	// Next returns the next unit of work without blocking.  It returns false if there's nothing to do
+	// or if the machines are done.
+	Next() (Work, bool)
+
+	// Process processes the work returned by Next
+	Process(Work)
+
+	// Tick advances the logical time by one tick and processes it, without going through the clock
+	Tick()
+}
+
+type driver struct {
+	*runner
+}
+
+func (m *machines) Drive(clock *Clock, options Options) (Driver, error) {
+
+	m.Options = options
+
+	if clock == nil {
+		clock = NewClock()
+	}
+	m.clock = clock
+	runner, err := newRunner(m.spec, m.clock, m.Options)
+	if err != nil {
+		return nil, err
+	}
+	runner.events = make(chan *event, runner.options.BufferSize)
+	m.runner = runner
+	m.runner.vetoers = m.vetoers
+	m.runner.running = true
+
+	m.clock.Start()
+	m.sources.run(m.runner)
+	return &driver{runner: runner}, nil
+}
+
+func (d *driver) Next() (Work, bool) {
+	select {
+	case <-d.stop:
+		return Work{}, false
+	default:
+	}
+
+	// signals raised while processing come first
+	select {
+	case tx := <-d.transactions:
+		return Work{tx: tx}, true
+	default:
+	}
+
+	if tx := d.prioritized(d.tid(), d.tickTxn); tx != nil {
+		return Work{tx: tx}, true
+	}
+
+	select {
+	case tick, ok := <-d.clock.C:
+		if ok {
+			return Work{tx: d.tickTxn(tick)}, true
+		}
+	case event := <-d.events:
+		return Work{tx: d.eventTxn(d.tid(), event)}, true
+	case reader := <-d.reads:
+		return Work{tx: d.readTxn(d.tid(), reader)}, true
+	default:
+	}
+	return Work{}, false
+}
+
+func (d *driver) Process(work Work) {
+	if work.tx != nil {
+		d.process(work.tx)
+	}
+}
+
+func (d *driver) Tick() {
+	d.process(d.tickTxn(Tick(1)))
+}
@@ -0,0 +1,103 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDriver(t *testing.T) {
+
+	const (
+		waiting Index = iota
+		running
+		stopped
+	)
+
+	const (
+		start Signal = iota
+		stop
+	)
+
+	machines, err := define(
+		State{
+			Index: waiting,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			TTL: Expiry{2, start},
+		},
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				stop: stopped,
+			},
+		},
+		State{
+			Index: stopped,
+		},
+	)
+	require.NoError(t, err)
+
+	driver, err := machines.Drive(nil, DefaultOptions())
+	require.NoError(t, err)
+	defer machines.Done()
+
+	// nothing to do yet
+	_, has := driver.Next()
+	require.False(t, has)
+
+	// calls other than signals are made from another goroutine while the loop drives
+	call := func(f func()) {
+		drive(driver, f)
+	}
+
+	var a, b FSM
+	call(func() {
+		a, err = machines.New(waiting)
+		require.NoError(t, err)
+		b, err = machines.New(running)
+		require.NoError(t, err)
+	})
+
+	// signals are buffered so they can be sent from the driving goroutine
+	require.NoError(t, b.Signal(stop))
+	work, has := driver.Next()
+	require.True(t, has)
+	driver.Process(work)
+
+	driver.Tick()
+	driver.Tick()
+	work, has = driver.Next() // raised on the expiry of the TTL
+	require.True(t, has)
+	driver.Process(work)
+
+	counts := 0
+	call(func() {
+		counts = machines.CountIn(stopped)
+	})
+	require.Equal(t, 1, counts)
+
+	var state Index
+	call(func() { state = a.State() })
+	require.Equal(t, running, state)
+}
+
+// drive processes the work of the driver until the function, called on another goroutine, returns
+func drive(driver Driver, f func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if work, has := driver.Next(); has {
+			driver.Process(work)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// SkippedAction is an action that was not executed because of Options.DryRun
+type SkippedAction struct {
+	// Name is the name of the action, from State.ActionNames or the name of its function
+	Name string `json:"name"`
+
+	// Data is the optional data of the signal that the action would have been called with
+	Data []interface{} `json:"data,omitempty"`
+}
+
+type actionKey struct {
+	state  Index
+	signal Signal
+}
+
+// actionNames returns the names of the actions, before they are wrapped by Options.WrapAction
+func (s *spec) actionNames() map[actionKey]string {
+	names := map[actionKey]string{}
+	for index, state := range s.states {
+		for signal, action := range state.Actions {
+			name, has := state.ActionNames[signal]
+			if ctx, is := state.ContextActions[signal]; is {
+				name = actionName(ctx)
+			} else if !has {
+				name = actionName(action)
+			}
+			names[actionKey{index, signal}] = name
+		}
+	}
+	return names
+}
+
+// skipAction returns the record of the action skipped in dry run
+func (g *runner) skipAction(current Index, event *event) *SkippedAction {
+	return &SkippedAction{Name: g.actionNames[actionKey{current, event.signal}], Data: event.data}
+}
@@ -0,0 +1,77 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRun(t *testing.T) {
+
+	const (
+		unhealthy Index = iota
+		rebooting
+		running
+	)
+
+	const (
+		reboot Signal = iota
+		ready
+	)
+
+	executed := 0
+	machines, err := define(
+		State{
+			Index: unhealthy,
+			Transitions: map[Signal]Index{
+				reboot: rebooting,
+			},
+			ActionNames: map[Signal]string{
+				reboot: "reboot",
+			},
+			Prepare: map[Signal]Action{
+				reboot: func(FSM) error {
+					executed++
+					return nil
+				},
+			},
+		},
+		State{
+			Index: rebooting,
+			Transitions: map[Signal]Index{
+				ready: running,
+			},
+			Actions: map[Signal]Action{
+				ready: provision,
+			},
+		},
+		State{
+			Index: running,
+		},
+	)
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	options.DryRun = true
+	options.Actions = ActionRegistry{
+		"reboot": func(FSM) error {
+			executed++
+			return nil
+		},
+	}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	transitions, cancel := machines.Watch(10)
+	defer cancel()
+
+	instance, err := machines.New(unhealthy)
+	require.NoError(t, err)
+	require.NoError(t, instance.Signal(reboot, "node-1"))
+	require.NoError(t, instance.Signal(ready))
+	require.Equal(t, running, instance.State())
+	require.Equal(t, 0, executed)
+
+	require.Equal(t, &SkippedAction{Name: "reboot", Data: []interface{}{"node-1"}}, (<-transitions).Skipped)
+	require.Equal(t, &SkippedAction{Name: "fsm.provision"}, (<-transitions).Skipped)
+}
@@ -0,0 +1,87 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"fmt"
+)
+
+// Enum is a range of the constants of a spec, from Min to Max inclusive
+type Enum struct {
+	Min int
+	Max int
+}
+
+func (e Enum) has(v int) bool {
+	return v >= e.Min && v <= e.Max
+}
+
+// Enums registers the ranges of the state and signal constants of a spec, so that constants of other specs are
+// rejected instead of silently matching a state or signal with the same value.  For it to work, each spec numbers
+// its constants from a different base, e.g.
+//
+//	const (
+//		nodeUp Index = iota + 100
+//		nodeDown
+//	)
+//
+// An empty range is not checked.
+type Enums struct {
+	// Name is the name of the spec, for the errors
+	Name string
+
+	States  Enum
+	Signals Enum
+}
+
+// ErrForeignConstant is a state or signal outside of the range of the spec registered in Options.Enums,
+// e.g. a constant of another spec
+type ErrForeignConstant struct {
+	Spec  string
+	Kind  string // state or signal
+	Value int
+
+	defining bool
+}
+
+func (e ErrForeignConstant) Error() string {
+	return fmt.Sprintf("%v %v is not of spec %v", e.Kind, e.Value, e.Spec)
+}
+
+func (e ErrForeignConstant) Is(target error) bool {
+	if e.defining {
+		return target == SpecError
+	}
+	return target == UserError
+}
+
+func (e *Enums) checkState(index Index) error {
+	if e == nil || e.States == (Enum{}) || e.States.has(int(index)) {
+		return nil
+	}
+	return ErrForeignConstant{Spec: e.Name, Kind: "state", Value: int(index)}
+}
+
+func (e *Enums) checkSignal(signal Signal) error {
+	if e == nil || e.Signals == (Enum{}) || e.Signals.has(int(signal)) {
+		return nil
+	}
+	return ErrForeignConstant{Spec: e.Name, Kind: "signal", Value: int(signal)}
+}
+
+// check checks that the states and signals of the spec are in the ranges
+func (e *Enums) check(s *spec) error {
+	for index := range s.states {
+		if err := e.checkState(index); err != nil {
+			err := err.(ErrForeignConstant)
+			err.defining = true
+			return err
+		}
+	}
+	for signal := range s.signals {
+		if err := e.checkSignal(signal); err != nil {
+			err := err.(ErrForeignConstant)
+			err.defining = true
+			return err
+		}
+	}
+	return nil
+}
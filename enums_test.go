@@ -0,0 +1,77 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnums(t *testing.T) {
+
+	const (
+		nodeUp Index = iota + 100
+		nodeDown
+	)
+
+	const (
+		nodeFail Signal = iota + 100
+		nodeRecover
+	)
+
+	const (
+		volumeDetached Index = iota + 200
+	)
+
+	const (
+		volumeAttach Signal = iota + 200
+	)
+
+	node := []State{
+		{
+			Index: nodeUp,
+			Transitions: map[Signal]Index{
+				nodeFail: nodeDown,
+			},
+		},
+		{
+			Index: nodeDown,
+			Transitions: map[Signal]Index{
+				nodeRecover: nodeUp,
+			},
+		},
+	}
+
+	machines, err := define(node[0], node[1:]...)
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.Enums = &Enums{
+		Name:    "node",
+		States:  Enum{Min: 100, Max: 199},
+		Signals: Enum{Min: 100, Max: 199},
+	}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	_, err = machines.New(volumeDetached)
+	require.Equal(t, ErrForeignConstant{Spec: "node", Kind: "state", Value: 200}, err)
+	require.Equal(t, UserError, ClassOf(err))
+
+	a, err := machines.New(nodeUp)
+	require.NoError(t, err)
+	require.Equal(t, ErrForeignConstant{Spec: "node", Kind: "signal", Value: 200}, a.Signal(volumeAttach))
+	require.Equal(t, []error{ErrForeignConstant{Spec: "node", Kind: "signal", Value: 200}},
+		machines.SignalBatch([]SignalRequest{{ID: a.ID(), Signal: volumeAttach}}))
+	require.NoError(t, a.Signal(nodeFail))
+	require.Equal(t, nodeDown, a.State())
+
+	// the spec must be in the ranges
+	other, err := define(node[0], node[1:]...)
+	require.NoError(t, err)
+	options.Enums = &Enums{
+		Name:    "volume",
+		States:  Enum{Min: 200, Max: 299},
+		Signals: Enum{Min: 200, Max: 299},
+	}
+	err = other.Run(NewClock(), options)
+	require.Equal(t, SpecError, ClassOf(err))
+}
@@ -1,9 +1,107 @@
 package fsm // import "github.com/orkestr8/fsm"
 
 import (
+	"errors"
 	"fmt"
+	"time"
 )
 
+// The classes of the errors of this package, for errors.Is and ClassOf.  Every error of the package is of
+// exactly one class.  Errors from outside it, e.g. of actions, are reported as they are, with their class in
+// ErrorEvent.Class.
+var (
+	// SpecError is the class of errors in a spec or its options, found by Define, Run or the loaders of
+	// configs.  They don't go away on retry.
+	SpecError error = errorClass("spec error")
+
+	// RuntimeError is the class of errors of the machines while running, e.g. a failed action, a vetoed
+	// transition or a full queue.  They may go away on retry.
+	RuntimeError error = errorClass("runtime error")
+
+	// UserError is the class of errors in the use of the API, e.g. signaling a freed instance or sending a
+	// signal the current state doesn't take.
+	UserError error = errorClass("user error")
+)
+
+type errorClass string
+
+func (c errorClass) Error() string {
+	return string(c)
+}
+
+// ClassOf returns the class of the error, or of the first error it wraps that has one, or nil if none has.
+func ClassOf(err error) error {
+	for ; err != nil; err = errors.Unwrap(err) {
+		for _, class := range []error{SpecError, RuntimeError, UserError} {
+			if c, is := err.(interface{ Is(error) bool }); is && c.Is(class) {
+				return class
+			}
+		}
+	}
+	return nil
+}
+
+// Errorf returns an error of the class, formatted as with fmt.Errorf, e.g. for actions to classify their errors
+func Errorf(class error, format string, args ...interface{}) error {
+	return WithClass(class, fmt.Errorf(format, args...))
+}
+
+// WithClass returns the error as an error of the class.  It unwraps to the error.
+func WithClass(class error, err error) error {
+	return classified{class: class, err: err}
+}
+
+type classified struct {
+	class error
+	err   error
+}
+
+func (e classified) Error() string {
+	return e.err.Error()
+}
+
+func (e classified) Unwrap() error {
+	return e.err
+}
+
+func (e classified) Is(target error) bool {
+	return target == e.class
+}
+
+// defining marks the errors of references to states and signals, returned when defining a spec, as SpecErrors.
+// They are UserErrors otherwise.
+func defining(err error) error {
+	switch e := err.(type) {
+	case ErrUnknownState:
+		e.defining = true
+		return e
+	case ErrUnknownTransition:
+		e.defining = true
+		return e
+	case ErrUnknownSignal:
+		e.defining = true
+		return e
+	}
+	return err
+}
+
+// ErrQueueFull is raised when a signal raised by the machines is dropped because the queue of transactions
+// is full.  See Options.BufferSize.
+type ErrQueueFull struct {
+	spec   *spec
+	ID     ID
+	Signal Signal
+	Origin Origin
+}
+
+func (e ErrQueueFull) Error() string {
+	return fmt.Sprintf("queue full: instance=%v, signal=%v, origin=%v", e.ID, e.spec.signalName(e.Signal), e.Origin)
+}
+
+func (e ErrQueueFull) Is(target error) bool {
+	return target == RuntimeError
+}
+
 // ErrDuplicateState is thrown when there are indexes of the same value
 type ErrDuplicateState struct {
 	*spec
@@ -14,45 +112,139 @@ func (e ErrDuplicateState) Error() string {
 	return fmt.Sprintf("duplicated state index: %v", e.spec.stateName(e.Index))
 }
 
+func (e ErrDuplicateState) Is(target error) bool {
+	return target == SpecError
+}
+
 // ErrUnknownState indicates the state referenced does not match a known state index
 type ErrUnknownState struct {
 	*spec
 	Index
+	defining bool
 }
 
 func (e ErrUnknownState) Error() string {
 	return fmt.Sprintf("unknown state: %v", e.spec.stateName(e.Index))
 }
 
+func (e ErrUnknownState) Is(target error) bool {
+	if e.defining {
+		return target == SpecError
+	}
+	return target == UserError
+}
+
 // ErrUnknownTransition indicates an unknown signal while in given state is raised
 type ErrUnknownTransition struct {
-	spec   *spec
-	Signal Signal
-	State  Index
-	Help   string
+	spec     *spec
+	Signal   Signal
+	State    Index
+	Help     string
+	defining bool
 }
 
 func (e ErrUnknownTransition) Error() string {
 	return fmt.Sprintf("unknown stransition: signal=%v, state=%v", e.spec.signalName(e.Signal), e.spec.stateName(e.State))
 }
 
+func (e ErrUnknownTransition) Is(target error) bool {
+	if e.defining {
+		return target == SpecError
+	}
+	return target == UserError
+}
+
 // ErrUnknownSignal is raised when a undefined signal is received in the given state
 type ErrUnknownSignal struct {
 	spec *spec
 	Signal
 	Index
-	Help string
+	Help     string
+	defining bool
 }
 
 func (e ErrUnknownSignal) Error() string {
 	return fmt.Sprintf("unknown signal: signal=%v, state=%v", e.spec.signalName(e.Signal), e.spec.stateName(e.Index))
 }
 
+func (e ErrUnknownSignal) Is(target error) bool {
+	if e.defining {
+		return target == SpecError
+	}
+	return target == UserError
+}
+
 // ErrUnknownFSM is raised when the ID is does not match any thing in the set
 type ErrUnknownFSM ID
 
 func (e ErrUnknownFSM) Error() string {
-	return fmt.Sprintf("unknown instance: %v", e)
+	return fmt.Sprintf("unknown instance: %v", ID(e))
+}
+
+func (e ErrUnknownFSM) Is(target error) bool {
+	return target == UserError
+}
+
+// ErrFreed is returned by the FSM handle of an instance that has been freed
+type ErrFreed ID
+
+func (e ErrFreed) Error() string {
+	return fmt.Sprintf("instance freed: %v", ID(e))
+}
+
+func (e ErrFreed) Is(target error) bool {
+	return target == UserError
+}
+
+// ErrUnknownExternalID is returned when signaling an external ID that matches no instance
+type ErrUnknownExternalID string
+
+func (e ErrUnknownExternalID) Error() string {
+	return fmt.Sprintf("unknown external id: %v", string(e))
+}
+
+func (e ErrUnknownExternalID) Is(target error) bool {
+	return target == UserError
+}
+
+// ErrPinned is returned when freeing an instance that is pinned
+type ErrPinned ID
+
+func (e ErrPinned) Error() string {
+	return fmt.Sprintf("instance pinned: %v", ID(e))
+}
+
+func (e ErrPinned) Is(target error) bool {
+	return target == UserError
+}
+
+// ErrRateAnomaly is reported when the rate of transitions between two states surges above its baseline
+type ErrRateAnomaly struct {
+	spec     *spec
+	From     Index
+	To       Index
+	Rate     int
+	Baseline float64
+}
+
+func (e ErrRateAnomaly) Error() string {
+	return fmt.Sprintf("transition rate anomaly: %v -> %v: %d in window, baseline %.2f",
+		e.spec.stateName(e.From), e.spec.stateName(e.To), e.Rate, e.Baseline)
+}
+
+func (e ErrRateAnomaly) Is(target error) bool {
+	return target == RuntimeError
+}
+
+// ErrDuplicateID is raised when an instance is added with an ID that's in use
+type ErrDuplicateID ID
+
+func (e ErrDuplicateID) Error() string {
+	return fmt.Sprintf("duplicated instance id: %v", ID(e))
+}
+
+func (e ErrDuplicateID) Is(target error) bool {
+	return target == UserError
 }
 
 // ErrNilAction is raised when an action is nil
@@ -62,9 +254,261 @@ func (e ErrNilAction) Error() string {
 	return fmt.Sprintf("nil action corresponding to signal %d", e)
 }
 
+func (e ErrNilAction) Is(target error) bool {
+	return target == SpecError
+}
+
 // ErrNoTransitions is raised when there are no transitions defined
 type ErrNoTransitions spec
 
 func (e ErrNoTransitions) Error() string {
 	return fmt.Sprintf("no transitions defined: count(states)=%d", len(e.states))
 }
+
+func (e ErrNoTransitions) Is(target error) bool {
+	return target == SpecError
+}
+
+// ErrDuplicateAction is raised when a signal of a state has both an action and a named action
+type ErrDuplicateAction struct {
+	spec   *spec
+	State  Index
+	Signal Signal
+}
+
+func (e ErrDuplicateAction) Error() string {
+	return fmt.Sprintf("action and named action for the same signal: signal=%v, state=%v",
+		e.spec.signalName(e.Signal), e.spec.stateName(e.State))
+}
+
+func (e ErrDuplicateAction) Is(target error) bool {
+	return target == SpecError
+}
+
+// ErrUnboundAction is raised when a named action is not in the registry of actions
+type ErrUnboundAction struct {
+	spec   *spec
+	State  Index
+	Signal Signal
+	Name   string
+}
+
+func (e ErrUnboundAction) Error() string {
+	return fmt.Sprintf("unbound action %q: signal=%v, state=%v",
+		e.Name, e.spec.signalName(e.Signal), e.spec.stateName(e.State))
+}
+
+func (e ErrUnboundAction) Is(target error) bool {
+	return target == SpecError
+}
+
+// ErrTerminal is raised when a signal is sent to an instance in a state without transitions
+type ErrTerminal struct {
+	spec   *spec
+	ID     ID
+	State  Index
+	Signal Signal
+}
+
+func (e ErrTerminal) Error() string {
+	return fmt.Sprintf("instance in terminal state: instance=%v, state=%v, signal=%v",
+		e.ID, e.spec.stateName(e.State), e.spec.signalName(e.Signal))
+}
+
+func (e ErrTerminal) Is(target error) bool {
+	return target == UserError
+}
+
+// ErrUnacknowledged is raised when a signal raised by the machines is dropped because the instance is in a
+// sticky state that has not been acknowledged
+type ErrUnacknowledged struct {
+	spec   *spec
+	ID     ID
+	State  Index
+	Signal Signal
+	Origin Origin
+}
+
+func (e ErrUnacknowledged) Error() string {
+	return fmt.Sprintf("unacknowledged sticky state: instance=%v, state=%v, signal=%v, origin=%v",
+		e.ID, e.spec.stateName(e.State), e.spec.signalName(e.Signal), e.Origin)
+}
+
+func (e ErrUnacknowledged) Is(target error) bool {
+	return target == RuntimeError
+}
+
+// ErrDataQuota is raised when the data attached to an instance is larger than the MaxData of the state it
+// enters, and the instance stays in its state.  If the instance follows Errors after its action fails, it
+// enters the state of Errors with the data it had instead.
+type ErrDataQuota struct {
+	spec   *spec
+	ID     ID
+	State  Index
+	Signal Signal
+	Size   int
+	Max    int
+}
+
+func (e ErrDataQuota) Error() string {
+	return fmt.Sprintf("data over quota: instance=%v, state=%v, signal=%v, size=%v, max=%v",
+		e.ID, e.spec.stateName(e.State), e.spec.signalName(e.Signal), e.Size, e.Max)
+}
+
+func (e ErrDataQuota) Is(target error) bool {
+	return target == UserError
+}
+
+// ErrVetoed is raised when the prepare step of a transition fails, or a Vetoer rejects it, and the
+// instance stays in its state
+type ErrVetoed struct {
+	spec   *spec
+	ID     ID
+	State  Index
+	Signal Signal
+	Err    error
+}
+
+func (e ErrVetoed) Error() string {
+	return fmt.Sprintf("transition vetoed: instance=%v, state=%v, signal=%v: %v",
+		e.ID, e.spec.stateName(e.State), e.spec.signalName(e.Signal), e.Err)
+}
+
+func (e ErrVetoed) Unwrap() error {
+	return e.Err
+}
+
+func (e ErrVetoed) Is(target error) bool {
+	return target == RuntimeError
+}
+
+// ErrCommit is raised when the commit step of a transition fails, after the instance is in the next state
+type ErrCommit struct {
+	spec   *spec
+	ID     ID
+	State  Index
+	Signal Signal
+	Err    error
+}
+
+func (e ErrCommit) Error() string {
+	return fmt.Sprintf("commit failed: instance=%v, state=%v, signal=%v: %v",
+		e.ID, e.spec.stateName(e.State), e.spec.signalName(e.Signal), e.Err)
+}
+
+func (e ErrCommit) Unwrap() error {
+	return e.Err
+}
+
+func (e ErrCommit) Is(target error) bool {
+	return target == RuntimeError
+}
+
+// ErrRolledBack is raised when an action fails and the instance stays in its state
+type ErrRolledBack struct {
+	spec   *spec
+	ID     ID
+	State  Index
+	Signal Signal
+	Err    error
+}
+
+func (e ErrRolledBack) Error() string {
+	return fmt.Sprintf("action failed, rolled back: instance=%v, state=%v, signal=%v: %v",
+		e.ID, e.spec.stateName(e.State), e.spec.signalName(e.Signal), e.Err)
+}
+
+func (e ErrRolledBack) Unwrap() error {
+	return e.Err
+}
+
+func (e ErrRolledBack) Is(target error) bool {
+	return target == RuntimeError
+}
+
+// ErrSignalRejected is returned when a signal is not admitted by the OnSignal hook
+type ErrSignalRejected struct {
+	spec   *spec
+	ID     ID
+	Signal Signal
+}
+
+func (e ErrSignalRejected) Error() string {
+	return fmt.Sprintf("signal rejected: signal=%v, instance=%v", e.spec.signalName(e.Signal), e.ID)
+}
+
+func (e ErrSignalRejected) Is(target error) bool {
+	return target == UserError
+}
+
+// ErrTickLag is raised when the processing of events falls behind the clock by more than the allowed ticks
+type ErrTickLag int64
+
+func (e ErrTickLag) Error() string {
+	return fmt.Sprintf("processing is behind the clock: lag=%d ticks", int64(e))
+}
+
+func (e ErrTickLag) Is(target error) bool {
+	return target == RuntimeError
+}
+
+// ErrClockStalled is raised when no clock tick has been received for the given duration
+type ErrClockStalled time.Duration
+
+func (e ErrClockStalled) Error() string {
+	return fmt.Sprintf("clock stalled: no ticks for %v", time.Duration(e))
+}
+
+func (e ErrClockStalled) Is(target error) bool {
+	return target == RuntimeError
+}
+
+// ErrActionTimeout is raised when an action does not complete within the action timeout of the state
+type ErrActionTimeout struct {
+	spec    *spec
+	ID      ID
+	State   Index
+	Signal  Signal
+	Timeout time.Duration
+}
+
+func (e ErrActionTimeout) Error() string {
+	return fmt.Sprintf("action timed out after %v: instance=%v, state=%v, signal=%v",
+		e.Timeout, e.ID, e.spec.stateName(e.State), e.spec.signalName(e.Signal))
+}
+
+func (e ErrActionTimeout) Is(target error) bool {
+	return target == RuntimeError
+}
+
+// ErrAbsorbed is returned when a signal of a sequence is handled without a transition, e.g. below the threshold
+// of the transition, held by a sticky state or flapping
+type ErrAbsorbed struct {
+	spec   *spec
+	ID     ID
+	State  Index
+	Signal Signal
+}
+
+func (e ErrAbsorbed) Error() string {
+	return fmt.Sprintf("signal absorbed: instance=%v, state=%v, signal=%v", e.ID, e.spec.stateName(e.State),
+		e.spec.signalName(e.Signal))
+}
+
+func (e ErrAbsorbed) Is(target error) bool {
+	return target == UserError
+}
+
+// ErrSequence is returned when a sequence of signals stops before all the signals are applied
+type ErrSequence struct {
+	Applied int
+	Err     error
+}
+
+func (e ErrSequence) Error() string {
+	return fmt.Sprintf("sequence stopped after %d signals: %v", e.Applied, e.Err)
+}
+
+func (e ErrSequence) Unwrap() error {
+	return e.Err
+}
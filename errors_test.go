@@ -0,0 +1,131 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorClasses(t *testing.T) {
+
+	const (
+		running Index = iota
+		stopped
+	)
+
+	const (
+		stop Signal = iota
+		start
+	)
+
+	_, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				stop: stopped,
+			},
+		},
+	)
+	require.Error(t, err)
+	require.Equal(t, SpecError, ClassOf(err))
+	require.True(t, errors.Is(err, SpecError))
+	require.False(t, errors.Is(err, UserError))
+
+	require.Equal(t, UserError, ClassOf(ErrFreed(1)))
+	require.Equal(t, UserError, ClassOf(ErrUnknownTransition{Signal: start, State: running}))
+	require.Equal(t, RuntimeError, ClassOf(ErrQueueFull{ID: 1, Signal: start}))
+	require.Nil(t, ClassOf(fmt.Errorf("plain")))
+
+	cause := fmt.Errorf("boom")
+	vetoed := ErrVetoed{ID: 1, Signal: start, Err: cause}
+	require.Equal(t, cause, errors.Unwrap(vetoed))
+	require.True(t, errors.Is(vetoed, RuntimeError))
+
+	wrapped := WithClass(UserError, cause)
+	require.Equal(t, "boom", wrapped.Error())
+	require.Equal(t, cause, errors.Unwrap(wrapped))
+	require.True(t, errors.Is(wrapped, UserError))
+	require.True(t, errors.Is(wrapped, cause))
+
+	formatted := Errorf(SpecError, "bad state %v", running)
+	require.Equal(t, "bad state 0", formatted.Error())
+	require.Equal(t, SpecError, ClassOf(formatted))
+}
+
+func TestErrorsReportedAsTheyAre(t *testing.T) {
+
+	const (
+		running Index = iota
+	)
+
+	cause := fmt.Errorf("boom")
+	machines, err := define(State{Index: running})
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.WAL = &failingWAL{err: cause}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	events, cancel := machines.SubscribeErrors(ErrorFilter{})
+	defer cancel()
+
+	_, err = machines.New(running)
+	require.NoError(t, err)
+
+	event := <-events
+	require.Equal(t, cause, event.Err)
+	require.Equal(t, RuntimeError, event.Class)
+}
+
+type failingWAL struct {
+	MemoryWAL
+	err error
+}
+
+func (w *failingWAL) Append(Record) error {
+	return w.err
+}
+
+func TestExpiryQueueFull(t *testing.T) {
+
+	const (
+		waiting Index = iota
+		running
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: waiting,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			TTL: Expiry{1, start},
+		},
+		State{
+			Index: running,
+		},
+	)
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.BufferSize = 2
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, options))
+	defer machines.Done()
+
+	for i := 0; i < 10; i++ {
+		_, err := machines.New(waiting)
+		require.NoError(t, err)
+	}
+
+	// the expiries dropped by the full queue are raised on the next ticks
+	for i := 0; i < 100 && machines.CountIn(running) < 10; i++ {
+		clock.Tick()
+	}
+	require.Equal(t, 10, machines.CountIn(running))
+}
@@ -0,0 +1,24 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// errorsFirst returns true if the signal follows the Errors of the state without running the action
+func (s *spec) errorsFirst(current Index, signal Signal) bool {
+	state, has := s.states[current]
+	if !has || !state.ErrorsFirst {
+		return false
+	}
+	_, has = state.Errors[signal]
+	return has
+}
+
+// onError runs the action of the Errors of the state for the signal, if any, as the instance follows Errors to
+// the next state.  The error of the action is reported and doesn't stop the transition.  This must be called from
+// within the transaction loop.
+func (g *runner) onError(tid int64, instance *instance, current Index, event *event) {
+	action, has := g.spec().states[current].ErrorsAction[event.signal]
+	if !has || g.options.DryRun {
+		return
+	}
+	if err := g.invoke(instance, current, event.signal, action); err != nil {
+		g.handleError(tid, err, []interface{}{current, event, instance})
+	}
+}
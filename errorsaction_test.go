@@ -0,0 +1,147 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorsAction(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+		failed
+	)
+
+	const (
+		start Signal = iota
+		crash
+	)
+
+	starts := make(chan Index, 10)
+	cleanups := make(chan Signal, 10)
+
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			Actions: map[Signal]Action{
+				start: func(FSM) error {
+					starts <- pending
+					return fmt.Errorf("no capacity")
+				},
+			},
+			Errors: map[Signal]Index{
+				start: failed,
+			},
+			ErrorsAction: map[Signal]Action{
+				start: func(f FSM) error {
+					cleanups <- start
+					return nil
+				},
+			},
+		},
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				crash: running,
+				start: running,
+			},
+			Actions: map[Signal]Action{
+				start: func(FSM) error {
+					starts <- running
+					return nil
+				},
+			},
+			Errors: map[Signal]Index{
+				crash: failed,
+			},
+			ErrorsAction: map[Signal]Action{
+				crash: func(f FSM) error {
+					cleanups <- crash
+					return fmt.Errorf("cleanup failed")
+				},
+			},
+			ErrorsFirst: true,
+		},
+		State{
+			Index: failed,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	// the action fails and the instance follows Errors, with the cleanup
+	a, err := machines.New(pending)
+	require.NoError(t, err)
+	require.NoError(t, a.Signal(start))
+	require.Equal(t, failed, a.State())
+	require.Equal(t, pending, <-starts)
+	require.Equal(t, start, <-cleanups)
+
+	// errors first: the crash follows Errors, even if the state has a transition on it
+	b, err := machines.New(running)
+	require.NoError(t, err)
+	require.NoError(t, b.Signal(start))
+	require.Equal(t, running, <-starts)
+	require.NoError(t, b.Signal(crash))
+	require.Equal(t, failed, b.State())
+	require.Equal(t, crash, <-cleanups)
+	require.Len(t, starts, 0)
+
+	// the errors action must be for a signal in Errors
+	_, err = define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			ErrorsAction: map[Signal]Action{
+				start: func(FSM) error { return nil },
+			},
+		},
+		State{
+			Index: running,
+		},
+	)
+	require.Error(t, err)
+	require.Equal(t, SpecError, ClassOf(err))
+
+	findings := Linter{}.Lint(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			Errors: map[Signal]Index{
+				start: failed,
+				crash: failed,
+			},
+		},
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				crash: failed,
+			},
+			Errors: map[Signal]Index{
+				crash: failed,
+			},
+			ErrorsFirst: true,
+		},
+		State{
+			Index: failed,
+		},
+	)
+	dead := []Index{}
+	for _, finding := range findings {
+		if finding.Rule == RuleDeadErrorRoute {
+			dead = append(dead, finding.State)
+		}
+	}
+	require.Equal(t, []Index{pending, pending, running}, dead)
+}
@@ -0,0 +1,155 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"errors"
+	"reflect"
+)
+
+// ErrorEvent is an error reported to the subscribers of the error stream
+type ErrorEvent struct {
+	Err error
+
+	// Class is the class of the error, e.g. RuntimeError.  Errors from outside the package, e.g. of actions, the
+	// WAL or a notifier, are reported as they are, and are RuntimeErrors unless they have a class.
+	Class error
+
+	// HasID is true if the error is about an instance, given by ID
+	HasID bool
+	ID    ID
+
+	// State is the state of the instance when the error occurred, or NoState if unknown
+	State Index
+
+	// Tick is the time of the error in ticks of the clock
+	Tick Time
+}
+
+// ErrorFilter selects the errors for a subscriber.  Empty fields match all errors.
+type ErrorFilter struct {
+
+	// Types are the types of errors to match, given as values, e.g. ErrActionTimeout{}.  Wrapped errors match too.
+	Types []error
+
+	// States are the states of the instances to match
+	States []Index
+
+	// IDs are the instances to match
+	IDs []ID
+
+	// Buffer is the size of the channel.  Errors are dropped if the channel is full.  Defaults to 256.
+	Buffer int
+}
+
+func (f ErrorFilter) match(e ErrorEvent) bool {
+	if len(f.Types) > 0 {
+		match := false
+		for err := e.Err; err != nil; err = errors.Unwrap(err) {
+			for _, t := range f.Types {
+				match = match || reflect.TypeOf(t) == reflect.TypeOf(err)
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	if len(f.States) > 0 {
+		match := false
+		for _, state := range f.States {
+			match = match || state == e.State
+		}
+		if !match {
+			return false
+		}
+	}
+	if len(f.IDs) > 0 {
+		match := false
+		for _, id := range f.IDs {
+			match = match || (e.HasID && id == e.ID)
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
+
+type errorSubscriber struct {
+	filter ErrorFilter
+	ch     chan ErrorEvent
+}
+
+// errorEvent returns the error event for the error and the context it was reported with.
+func (g *runner) errorEvent(err error, ctx interface{}) ErrorEvent {
+	e := ErrorEvent{Err: err, Class: ClassOf(err), State: NoState, Tick: g.ct()}
+	if e.Class == nil {
+		e.Class = RuntimeError
+	}
+	switch ctx := ctx.(type) {
+	case ID:
+		e.HasID, e.ID = true, ctx
+	case *event:
+		e.HasID, e.ID = true, ctx.instance
+		if ctx.ref != nil {
+			e.State = ctx.ref.state // events are handled in the transaction loop
+		}
+	case []interface{}:
+		if len(ctx) == 3 {
+			if current, is := ctx[0].(Index); is {
+				e.State = current
+			}
+			if i, is := ctx[2].(*instance); is {
+				e.HasID, e.ID = true, i.id
+			}
+		}
+	case Transition:
+		e.HasID, e.ID, e.State = true, ctx.ID, ctx.From
+	}
+	return e
+}
+
+// publishError sends the error to the subscribers whose filter matches, without blocking.
+// Errors are also reported outside of the transaction loop, so the subscribers are guarded by errorLock.
+func (g *runner) publishError(err error, ctx interface{}) {
+	g.errorLock.RLock()
+	defer g.errorLock.RUnlock()
+
+	if len(g.errorSubscribers) == 0 {
+		return
+	}
+	e := g.errorEvent(err, ctx)
+	for _, s := range g.errorSubscribers {
+		if !s.filter.match(e) {
+			continue
+		}
+		select {
+		case s.ch <- e: // non-blocking send
+		default:
+		}
+	}
+}
+
+// subscribeErrors registers a subscriber to the errors that match the filter
+func (g *runner) subscribeErrors(filter ErrorFilter) (<-chan ErrorEvent, int) {
+	g.errorLock.Lock()
+	defer g.errorLock.Unlock()
+
+	buffer := filter.Buffer
+	if buffer <= 0 {
+		buffer = defaultBufferSize
+	}
+	ch := make(chan ErrorEvent, buffer)
+	g.errorSubscriber++
+	g.errorSubscribers[g.errorSubscriber] = errorSubscriber{filter: filter, ch: ch}
+	return ch, g.errorSubscriber
+}
+
+// unsubscribeErrors removes the subscriber and closes its channel
+func (g *runner) unsubscribeErrors(id int) {
+	g.errorLock.Lock()
+	defer g.errorLock.Unlock()
+
+	if s, has := g.errorSubscribers[id]; has {
+		delete(g.errorSubscribers, id)
+		close(s.ch)
+	}
+}
@@ -0,0 +1,80 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeErrors(t *testing.T) {
+
+	const (
+		running Index = iota
+		stopped
+	)
+
+	const (
+		stop Signal = iota
+		start
+	)
+
+	machines, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				stop: stopped,
+			},
+		},
+		State{
+			Index: stopped,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+		},
+	)
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.IgnoreUndefinedTransitions = false
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	a, err := machines.New(running)
+	require.NoError(t, err)
+	b, err := machines.New(stopped)
+	require.NoError(t, err)
+
+	all, cancelAll := machines.SubscribeErrors(ErrorFilter{})
+	byID, cancelByID := machines.SubscribeErrors(ErrorFilter{IDs: []ID{b.ID()}})
+	byType, cancelByType := machines.SubscribeErrors(ErrorFilter{Types: []error{ErrUnknownFSM(0)}})
+	defer cancelByID()
+	defer cancelByType()
+
+	require.NoError(t, a.Signal(start))
+	require.Equal(t, ErrorEvent{
+		Err:   ErrUnknownTransition{Signal: start, State: running},
+		Class: UserError,
+		HasID: true,
+		ID:    a.ID(),
+		State: running,
+	}, <-all)
+
+	require.NoError(t, b.Signal(stop))
+	require.Equal(t, b.ID(), (<-all).ID)
+	require.Equal(t, ErrorEvent{
+		Err:   ErrUnknownTransition{Signal: stop, State: stopped},
+		Class: UserError,
+		HasID: true,
+		ID:    b.ID(),
+		State: stopped,
+	}, <-byID)
+
+	require.NoError(t, machines.Free(a.ID()))
+	machines.runner.emit(a.ID(), stop) // as a source would
+	require.Equal(t, ErrUnknownFSM(a.ID()), (<-all).Err)
+	require.Equal(t, ErrUnknownFSM(a.ID()), (<-byType).Err)
+	require.Len(t, byID, 0)
+
+	cancelAll()
+	_, open := <-all
+	require.False(t, open)
+}
@@ -0,0 +1,33 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"time"
+)
+
+// EventTime converts the time of events, as given with FSM.SignalAt, to ticks, for sources that batch and
+// deliver signals late.  It's disabled if Tick is 0.
+type EventTime struct {
+	// Tick is the wall time of one tick of the clock
+	Tick time.Duration
+
+	// MaxLateness bounds how far behind the current tick an event can be.  Events that are later are
+	// counted as if they happened MaxLateness ticks ago.  No bound if 0.
+	MaxLateness Tick
+}
+
+// eventTime returns the tick when the event happened, or now if it's not timestamped or event time
+// is not enabled.  Events are never in the future.
+func (g *runner) eventTime(event *event, now Time) Time {
+	tick := g.options.EventTime.Tick
+	if tick <= 0 || event.at.IsZero() {
+		return now
+	}
+	late := Time(time.Since(event.at) / tick)
+	if late < 0 {
+		late = 0
+	}
+	if max := Time(g.options.EventTime.MaxLateness); max > 0 && late > max {
+		late = max
+	}
+	return now - late
+}
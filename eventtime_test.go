@@ -0,0 +1,103 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventTime(t *testing.T) {
+
+	const (
+		healthy Index = iota
+		unhealthy
+	)
+
+	const (
+		fail Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: healthy,
+			Transitions: map[Signal]Index{
+				fail: unhealthy,
+			},
+			Hysteresis: map[Signal]Threshold{
+				fail: {Count: 2, Within: 5},
+			},
+		},
+		State{
+			Index: unhealthy,
+		},
+	)
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	options.EventTime = EventTime{Tick: time.Second, MaxLateness: 15}
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, options))
+	defer machines.Done()
+
+	a, err := machines.New(healthy)
+	require.NoError(t, err)
+	clock.Ticks(20)
+
+	now := time.Now()
+
+	// delivered together, but 10 ticks apart when they happened
+	require.NoError(t, a.SignalAt(now.Add(-10*time.Second), fail))
+	require.NoError(t, a.SignalAt(now, fail))
+	require.Equal(t, healthy, a.State())
+
+	// out of order, 8 ticks before the latest
+	require.NoError(t, a.SignalAt(now.Add(-8*time.Second), fail))
+	require.Equal(t, healthy, a.State())
+
+	// later than the bound, so counted as 15 ticks ago
+	require.NoError(t, a.SignalAt(now.Add(-time.Hour), fail))
+	require.Equal(t, healthy, a.State())
+
+	// within the window of the latest
+	require.NoError(t, a.SignalAt(now.Add(-2*time.Second), fail))
+	require.Equal(t, unhealthy, a.State())
+}
+
+func TestEventTimeDisabled(t *testing.T) {
+
+	const (
+		healthy Index = iota
+		unhealthy
+	)
+
+	const (
+		fail Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: healthy,
+			Transitions: map[Signal]Index{
+				fail: unhealthy,
+			},
+			Hysteresis: map[Signal]Threshold{
+				fail: {Count: 2, Within: 5},
+			},
+		},
+		State{
+			Index: unhealthy,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	a, err := machines.New(healthy)
+	require.NoError(t, err)
+
+	// arrival time
+	require.NoError(t, a.SignalAt(time.Now().Add(-time.Hour), fail))
+	require.NoError(t, a.SignalAt(time.Now(), fail))
+	require.Equal(t, unhealthy, a.State())
+}
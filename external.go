@@ -0,0 +1,73 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// DefaultExternalIDLabel is the label that holds the external ID of an instance, if not set in the options
+const DefaultExternalIDLabel = "external-id"
+
+func (m *machines) SignalExternal(externalID string, signal Signal, optionalData ...interface{}) (FSM, error) {
+	return m.signalExternal(OriginAPI, externalID, signal, optionalData...)
+}
+
+func (m *machines) signalExternal(origin Origin, externalID string, signal Signal,
+	optionalData ...interface{}) (FSM, error) {
+	var instance *instance
+	var err error
+	m.runner.do(func(g *runner) {
+		instance, err = g.external(externalID, signal)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return instance, m.runner.signal(origin, signal, instance, optionalData...)
+}
+
+func (g *runner) externalIDLabel() string {
+	if g.options.ExternalIDLabel != "" {
+		return g.options.ExternalIDLabel
+	}
+	return DefaultExternalIDLabel
+}
+
+// external returns the instance of the external ID, creating it with Options.AutoCreate if there's none.
+// This must be called from within the transaction loop.
+func (g *runner) external(externalID string, signal Signal) (*instance, error) {
+	if instance, has := g.externals[externalID]; has {
+		return instance, nil
+	}
+	if g.options.AutoCreate == nil {
+		return nil, ErrUnknownExternalID(externalID)
+	}
+	// don't create an instance for a signal that can't be sent
+	if _, has := g.spec().signals[signal]; !has {
+		return nil, ErrUnknownSignal{Signal: signal}
+	}
+	initial, create := g.options.AutoCreate(externalID)
+	if !create {
+		return nil, ErrUnknownExternalID(externalID)
+	}
+	if _, has := g.spec().states[initial]; !has {
+		return nil, ErrUnknownState{spec: g.spec(), Index: initial}
+	}
+	instance, err := g.add(g.tid(), SeedItem{
+		State:  initial,
+		Labels: map[string]string{g.externalIDLabel(): externalID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	g.log.Info("Created for external id", g.withFields(snapshot{instance},
+		"externalID", externalID, "instance", instance.id, "state", g.spec().stateName(initial))...)
+	return instance, nil
+}
+
+// indexExternal indexes the instance by its external ID, if labeled with one
+func (g *runner) indexExternal(i *instance) {
+	if externalID, has := i.labels[g.externalIDLabel()]; has {
+		g.externals[externalID] = i
+	}
+}
+
+func (g *runner) unindexExternal(i *instance) {
+	if externalID, has := i.labels[g.externalIDLabel()]; has && g.externals[externalID] == i {
+		delete(g.externals, externalID)
+	}
+}
@@ -0,0 +1,84 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignalExternal(t *testing.T) {
+
+	const (
+		unregistered Index = iota
+		online
+		offline
+	)
+
+	const (
+		heartbeat Signal = iota
+		disconnect
+		register
+	)
+
+	machines, err := define(
+		State{
+			Index: unregistered,
+			Transitions: map[Signal]Index{
+				heartbeat: online,
+				register:  online,
+			},
+		},
+		State{
+			Index: online,
+			Transitions: map[Signal]Index{
+				disconnect: offline,
+			},
+		},
+		State{
+			Index: offline,
+			Transitions: map[Signal]Index{
+				heartbeat: online,
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	options.AutoCreate = func(externalID string) (Index, bool) {
+		return unregistered, externalID != "rogue"
+	}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	seeded, err := machines.Seed([]SeedItem{{State: online, Labels: map[string]string{DefaultExternalIDLabel: "sn-1"}}})
+	require.NoError(t, err)
+
+	a, err := machines.SignalExternal("sn-1", disconnect)
+	require.NoError(t, err)
+	require.Equal(t, seeded[0].ID(), a.ID())
+	require.Equal(t, offline, a.State())
+
+	// hardware that appears before registration
+	b, err := machines.SignalExternal("sn-2", heartbeat)
+	require.NoError(t, err)
+	require.Equal(t, online, b.State())
+	require.Equal(t, map[string]string{DefaultExternalIDLabel: "sn-2"}, b.Labels())
+	require.Equal(t, 2, machines.Count())
+
+	again, err := machines.SignalExternal("sn-2", disconnect)
+	require.NoError(t, err)
+	require.Equal(t, b.ID(), again.ID())
+	require.Equal(t, offline, b.State())
+
+	_, err = machines.SignalExternal("rogue", heartbeat)
+	require.Equal(t, ErrUnknownExternalID("rogue"), err)
+	_, err = machines.SignalExternal("sn-3", Signal(100))
+	require.Equal(t, ErrUnknownSignal{Signal: Signal(100)}, err)
+	require.Equal(t, 2, machines.Count())
+
+	require.NoError(t, machines.Free(b.ID()))
+	c, err := machines.SignalExternal("sn-2", register)
+	require.NoError(t, err)
+	require.NotEqual(t, b.ID(), c.ID())
+	require.Equal(t, online, c.State())
+}
@@ -31,7 +31,9 @@ func (s *spec) compileFlappingMust(checks []Flap) *spec {
 // compileFlapping - Limit is the maximum of a->b b->a transitions allowable.  For detecting
 // oscillations between two adjacent states (no hops).  This method simply checks in the
 // input configuraton and updates the spec.
-func (s *spec) compileFlapping(checks []Flap) (*spec, error) {
+func (s *spec) compileFlapping(checks []Flap) (_ *spec, err error) {
+	defer func() { err = defining(err) }()
+
 	flaps := map[[2]Index]*Flap{}
 	for _, check := range checks {
 
@@ -64,10 +66,12 @@ func newFlaps() *flaps {
 
 type flaps struct {
 	history []Index
+	ticks   []Time // when each state in the history was entered, if recorded with recordAt
 }
 
 func (f *flaps) reset() {
 	f.history = []Index{}
+	f.ticks = nil
 }
 
 func equals(i, j []Index) bool {
@@ -98,6 +102,52 @@ func (f *flaps) record(a, b Index) {
 	}
 }
 
+// recordAt records the transition from a, entered at the given tick, to b at tick now
+func (f *flaps) recordAt(a, b Index, entered, now Time) {
+	f.record(a, b)
+	switch len(f.history) {
+	case 0:
+		f.ticks = nil
+	case 2:
+		f.ticks = []Time{entered, now}
+	default:
+		f.ticks = append(f.ticks, now)
+	}
+}
+
+// FlapHistory is the oscillation of an instance between two states, for tuning the flap limits
+type FlapHistory struct {
+	ID ID
+
+	// States are the states of the oscillation in the order they were visited.  Empty if the instance is
+	// not oscillating between states with a flap limit.
+	States []Index
+
+	// Ticks are when each of the states was entered
+	Ticks []Time
+
+	// Count is the number of flaps counted against the flap limit
+	Count int
+}
+
+// flapHistory returns the flap history of the instance.  This must be called from within the transaction loop.
+func (g *runner) flapHistory(id ID) (FlapHistory, error) {
+	instance, has := g.members[id]
+	if !has {
+		return FlapHistory{}, ErrUnknownFSM(id)
+	}
+	history := instance.flaps.history
+	h := FlapHistory{
+		ID:     id,
+		States: append([]Index{}, history...),
+		Ticks:  append([]Time{}, instance.flaps.ticks...),
+	}
+	if n := len(history); n >= 2 {
+		h.Count = instance.flaps.count(history[n-2], history[n-1])
+	}
+	return h, nil
+}
+
 func (f *flaps) count(a, b Index) int {
 	if len(f.history) < 2 {
 		return 0
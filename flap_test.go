@@ -48,3 +48,68 @@ func TestFlap(t *testing.T) {
 
 	require.Equal(t, 3, counter.count(a, b))
 }
+
+func TestFlapHistory(t *testing.T) {
+
+	const (
+		up Index = iota
+		down
+		cordoned
+	)
+
+	const (
+		fail Signal = iota
+		recover
+		cordon
+	)
+
+	machines, err := define(
+		State{
+			Index: up,
+			Transitions: map[Signal]Index{
+				fail: down,
+			},
+		},
+		State{
+			Index: down,
+			Transitions: map[Signal]Index{
+				recover: up,
+				cordon:  cordoned,
+			},
+		},
+		State{
+			Index: cordoned,
+		},
+	)
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	options.Limits = []Flap{{States: [2]Index{up, down}, Count: 5, Raise: cordon}}
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, options))
+	defer machines.Done()
+
+	instance, err := machines.New(up)
+	require.NoError(t, err)
+
+	history, err := machines.FlapHistory(instance.ID())
+	require.NoError(t, err)
+	require.Equal(t, FlapHistory{ID: instance.ID(), States: []Index{}, Ticks: []Time{}}, history)
+
+	for _, signal := range []Signal{fail, recover, fail} {
+		clock.Ticks(2)
+		require.NoError(t, instance.Sequence(signal))
+	}
+
+	history, err = machines.FlapHistory(instance.ID())
+	require.NoError(t, err)
+	require.Equal(t, FlapHistory{
+		ID:     instance.ID(),
+		States: []Index{up, down, up, down},
+		Ticks:  []Time{0, 2, 4, 6},
+		Count:  1,
+	}, history)
+
+	_, err = machines.FlapHistory(ID(100))
+	require.Equal(t, ErrUnknownFSM(100), err)
+}
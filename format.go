@@ -0,0 +1,27 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"bytes"
+	"text/template"
+)
+
+// parseTransitionLogFormat compiles the template of the transition log line.  Returns nil if no format.
+func parseTransitionLogFormat(format string) (*template.Template, error) {
+	if format == "" {
+		return nil, nil
+	}
+	return template.New("transition").Parse(format)
+}
+
+// logTransition writes a single Info line for a committed transition, if a format is configured.
+func (g *runner) logTransition(transition Transition) {
+	if g.transitionLog == nil {
+		return
+	}
+	var buff bytes.Buffer
+	if err := g.transitionLog.Execute(&buff, transition); err != nil {
+		g.log.Error("transition log format", "err", err)
+		return
+	}
+	g.log.Info(buff.String())
+}
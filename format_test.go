@@ -0,0 +1,76 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type infoLogger struct {
+	nilLogger
+	lines []string
+	lock  sync.Mutex
+}
+
+func (l *infoLogger) Info(m string, args ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lines = append(l.lines, m)
+}
+
+func TestTransitionLogFormat(t *testing.T) {
+
+	const (
+		on Index = iota
+		off
+	)
+
+	const (
+		turnOn Signal = iota
+		turnOff
+	)
+
+	machines, err := define(
+		State{
+			Index: on,
+			Transitions: map[Signal]Index{
+				turnOff: off,
+			},
+		},
+		State{
+			Index: off,
+			Transitions: map[Signal]Index{
+				turnOn: on,
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	logger := &infoLogger{}
+	options := DefaultOptions()
+	options.Logger = logger
+	options.StateNames = map[Index]string{on: "ON", off: "OFF"}
+	options.SignalNames = map[Signal]string{turnOn: "turn_on", turnOff: "turn_off"}
+	options.TransitionLogFormat = "id={{.ID}} {{.Names.From}} -[{{.Names.Signal}}]-> {{.Names.To}} t={{.Tick}}"
+	require.NoError(t, machines.Run(NewClock(), options))
+
+	instance, err := machines.New(off)
+	require.NoError(t, err)
+	require.NoError(t, instance.Signal(turnOn))
+	require.NoError(t, instance.Signal(turnOff))
+	require.Equal(t, off, instance.State())
+
+	machines.Done()
+
+	logger.lock.Lock()
+	defer logger.lock.Unlock()
+	require.Equal(t, []string{
+		"id=0 OFF -[turn_on]-> ON t=0",
+		"id=0 ON -[turn_off]-> OFF t=0",
+	}, logger.lines[:2])
+
+	options.TransitionLogFormat = "{{.Bad"
+	_, err = newRunner(machines.spec, NewClock(), options)
+	require.Error(t, err)
+}
@@ -0,0 +1,232 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GoOptions are the options of the Go code generated from a Config
+type GoOptions struct {
+	// Package is the name of the package of the generated code
+	Package string
+
+	// StatePrefix and SignalPrefix are prepended to the names of the constants of the states and signals,
+	// e.g. to tell apart a state and a signal with the same name.
+	StatePrefix  string
+	SignalPrefix string
+
+	// Source is the name of the spec file, for the header of the generated code
+	Source string
+}
+
+// ReadConfig reads a Config in JSON.  See ReadYAMLConfig for configs in YAML.
+func ReadConfig(r io.Reader) (Config, error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Config{}, err
+	}
+	return decodeJSONConfig(raw)
+}
+
+// goIdent returns the exported Go identifier of a name, e.g. shut-down is ShutDown
+func goIdent(prefix, name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	ident := prefix
+	for _, word := range words {
+		runes := []rune(word)
+		ident += string(unicode.ToUpper(runes[0])) + string(runes[1:])
+	}
+	return ident
+}
+
+// WriteGo writes Go code with constants for the states and signals of the config, in iota blocks, the maps
+// of their names and a Define function that defines the machines and returns the options with the names
+// and flap limits of the config.
+func WriteGo(w io.Writer, config Config, options GoOptions) error {
+	compiled, issues := config.compile()
+	if len(issues) > 0 {
+		return ErrInvalidConfig(issues)
+	}
+
+	// the names of the declarations of the generated code, to catch names that map to the same identifier
+	declared := map[string]string{
+		"Define":      "function Define",
+		"StateNames":  "variable StateNames",
+		"SignalNames": "variable SignalNames",
+	}
+	declare := func(field, kind, prefix, name string) string {
+		ident := goIdent(prefix, name)
+		switch {
+		case !token.IsIdentifier(ident) || !token.IsExported(ident):
+			issues = append(issues, Issue{Severity: LintError, Rule: IssueDuplicateName, Field: field,
+				Message: fmt.Sprintf("%v %v is not a valid Go identifier", kind, strconv.Quote(name))})
+		case declared[ident] != "":
+			issues = append(issues, Issue{Severity: LintError, Rule: IssueDuplicateName, Field: field,
+				Message: fmt.Sprintf("%v %v is %v, same as %v", kind, strconv.Quote(name), ident, declared[ident])})
+		default:
+			declared[ident] = fmt.Sprintf("%v %v", kind, strconv.Quote(name))
+		}
+		return ident
+	}
+
+	states := map[Index]string{}
+	for i, st := range config.States {
+		states[Index(i)] = declare(fmt.Sprintf("states[%d].name", i), "state", options.StatePrefix, st.Name)
+	}
+	signals := map[Signal]string{}
+	for i := 0; i < len(compiled.signalNames); i++ {
+		signals[Signal(i)] = declare("signals", "signal", options.SignalPrefix, compiled.signalNames[Signal(i)])
+	}
+	if len(issues) > 0 {
+		return ErrInvalidConfig(issues)
+	}
+
+	sortedSignals := func(m map[Signal]Index) []Signal {
+		keys := []Signal{}
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		return keys
+	}
+
+	buff := &bytes.Buffer{}
+	if options.Source != "" {
+		fmt.Fprintf(buff, "// Code generated by fsmgen from %v. DO NOT EDIT.\n\n", options.Source)
+	} else {
+		fmt.Fprintf(buff, "// Code generated by fsmgen. DO NOT EDIT.\n\n")
+	}
+	fmt.Fprintf(buff, "package %v\n\n", options.Package)
+	fmt.Fprintf(buff, "import \"github.com/orkestr8/fsm\"\n\n")
+
+	fmt.Fprintf(buff, "// The states\nconst (\n")
+	for i := range config.States {
+		if i == 0 {
+			fmt.Fprintf(buff, "\t%v fsm.Index = iota\n", states[Index(i)])
+			continue
+		}
+		fmt.Fprintf(buff, "\t%v\n", states[Index(i)])
+	}
+	fmt.Fprintf(buff, ")\n\n")
+
+	if len(signals) > 0 {
+		fmt.Fprintf(buff, "// The signals\nconst (\n")
+		for i := 0; i < len(signals); i++ {
+			if i == 0 {
+				fmt.Fprintf(buff, "\t%v fsm.Signal = iota\n", signals[Signal(i)])
+				continue
+			}
+			fmt.Fprintf(buff, "\t%v\n", signals[Signal(i)])
+		}
+		fmt.Fprintf(buff, ")\n\n")
+	}
+
+	fmt.Fprintf(buff, "// StateNames are the names of the states, for Options.StateNames\n")
+	fmt.Fprintf(buff, "var StateNames = map[fsm.Index]string{\n")
+	for i, st := range config.States {
+		fmt.Fprintf(buff, "\t%v: %v,\n", states[Index(i)], strconv.Quote(st.Name))
+	}
+	fmt.Fprintf(buff, "}\n\n")
+
+	fmt.Fprintf(buff, "// SignalNames are the names of the signals, for Options.SignalNames\n")
+	fmt.Fprintf(buff, "var SignalNames = map[fsm.Signal]string{\n")
+	for i := 0; i < len(signals); i++ {
+		fmt.Fprintf(buff, "\t%v: %v,\n", signals[Signal(i)], strconv.Quote(compiled.signalNames[Signal(i)]))
+	}
+	fmt.Fprintf(buff, "}\n\n")
+
+	fmt.Fprintf(buff, "// Define defines the machines of the spec.  The options returned are the default options with\n")
+	fmt.Fprintf(buff, "// the names of the states and signals and the flap limits of the spec, to be customized and passed to Run.\n")
+	fmt.Fprintf(buff, "func Define() (fsm.Machines, fsm.Options, error) {\n")
+	fmt.Fprintf(buff, "\tmachines, err := fsm.Define(\n")
+	for _, st := range compiled.states {
+		fmt.Fprintf(buff, "\t\tfsm.State{\n\t\t\tIndex: %v,\n", states[st.Index])
+		for _, m := range []struct {
+			field string
+			edges map[Signal]Index
+		}{
+			{"Transitions", st.Transitions},
+			{"Errors", st.Errors},
+		} {
+			if len(m.edges) == 0 {
+				continue
+			}
+			fmt.Fprintf(buff, "\t\t\t%v: map[fsm.Signal]fsm.Index{\n", m.field)
+			for _, signal := range sortedSignals(m.edges) {
+				fmt.Fprintf(buff, "\t\t\t\t%v: %v,\n", signals[signal], states[m.edges[signal]])
+			}
+			fmt.Fprintf(buff, "\t\t\t},\n")
+		}
+		if st.ErrorsFirst {
+			fmt.Fprintf(buff, "\t\t\tErrorsFirst: true,\n")
+		}
+		if len(st.ActionNames) > 0 {
+			keys := []Signal{}
+			for k := range st.ActionNames {
+				keys = append(keys, k)
+			}
+			sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+			fmt.Fprintf(buff, "\t\t\tActionNames: map[fsm.Signal]string{\n")
+			for _, signal := range keys {
+				fmt.Fprintf(buff, "\t\t\t\t%v: %v,\n", signals[signal], strconv.Quote(st.ActionNames[signal]))
+			}
+			fmt.Fprintf(buff, "\t\t\t},\n")
+		}
+		if config.States[st.Index].TTL != nil {
+			fmt.Fprintf(buff, "\t\t\tTTL: fsm.Expiry{TTL: %d, Raise: %v},\n", st.TTL.TTL, signals[st.TTL.Raise])
+		}
+		if config.States[st.Index].Visit != nil {
+			fmt.Fprintf(buff, "\t\t\tVisit: fsm.Limit{Value: %d, Raise: %v},\n", st.Visit.Value, signals[st.Visit.Raise])
+		}
+		if len(st.Rearm) > 0 {
+			rearm := []string{}
+			for _, signal := range st.Rearm {
+				rearm = append(rearm, signals[signal])
+			}
+			fmt.Fprintf(buff, "\t\t\tRearm: []fsm.Signal{%v},\n", strings.Join(rearm, ", "))
+		}
+		if len(st.Meta) > 0 {
+			keys := []string{}
+			for k := range st.Meta {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			fmt.Fprintf(buff, "\t\t\tMeta: map[string]string{\n")
+			for _, k := range keys {
+				fmt.Fprintf(buff, "\t\t\t\t%v: %v,\n", strconv.Quote(k), strconv.Quote(st.Meta[k]))
+			}
+			fmt.Fprintf(buff, "\t\t\t},\n")
+		}
+		fmt.Fprintf(buff, "\t\t},\n")
+	}
+	fmt.Fprintf(buff, "\t)\n\tif err != nil {\n\t\treturn nil, fsm.Options{}, err\n\t}\n")
+	fmt.Fprintf(buff, "\toptions := fsm.DefaultOptions()\n")
+	fmt.Fprintf(buff, "\toptions.StateNames = StateNames\n")
+	fmt.Fprintf(buff, "\toptions.SignalNames = SignalNames\n")
+	if len(compiled.limits) > 0 {
+		fmt.Fprintf(buff, "\toptions.Limits = []fsm.Flap{\n")
+		for _, limit := range compiled.limits {
+			fmt.Fprintf(buff, "\t\t{States: [2]fsm.Index{%v, %v}, Count: %d, Raise: %v},\n",
+				states[limit.States[0]], states[limit.States[1]], limit.Count, signals[limit.Raise])
+		}
+		fmt.Fprintf(buff, "\t}\n")
+	}
+	fmt.Fprintf(buff, "\treturn machines, options, nil\n}\n")
+
+	formatted, err := format.Source(buff.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(formatted)
+	return err
+}
@@ -0,0 +1,60 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteGo(t *testing.T) {
+
+	config, err := ReadConfig(strings.NewReader(`{
+  "states": [
+    {
+      "name": "off",
+      "transitions": {"switch-on": "on"},
+      "ttl": {"ticks": 5, "raise": "switch-on"}
+    },
+    {
+      "name": "on",
+      "transitions": {"switch-off": "off"},
+      "actions": {"switch-off": "log"}
+    }
+  ],
+  "limits": [{"states": ["on", "off"], "count": 3, "raise": "switch-off"}]
+}`))
+	require.NoError(t, err)
+
+	buff := &bytes.Buffer{}
+	require.NoError(t, WriteGo(buff, config, GoOptions{Package: "lights", Source: "lights.yaml"}))
+
+	_, err = parser.ParseFile(token.NewFileSet(), "lights.go", buff.Bytes(), 0)
+	require.NoError(t, err)
+
+	code := buff.String()
+	require.True(t, strings.HasPrefix(code, "// Code generated by fsmgen from lights.yaml. DO NOT EDIT.\n"))
+	for _, s := range []string{
+		"Off fsm.Index = iota\n\tOn\n",
+		"SwitchOff fsm.Signal = iota\n\tSwitchOn\n",
+		`SwitchOff: "switch-off",`,
+		"SwitchOn: On,",
+		`SwitchOff: "log",`,
+		"TTL: fsm.Expiry{TTL: 5, Raise: SwitchOn},",
+		"{States: [2]fsm.Index{On, Off}, Count: 3, Raise: SwitchOff},",
+		"func Define() (fsm.Machines, fsm.Options, error) {",
+	} {
+		require.Contains(t, code, s)
+	}
+
+	// a state and a signal with the same name need a prefix
+	config, err = ReadConfig(strings.NewReader(`{"states": [{"name": "start", "transitions": {"start": "running"}}, {"name": "running"}]}`))
+	require.NoError(t, err)
+	err = WriteGo(&bytes.Buffer{}, config, GoOptions{Package: "jobs"})
+	require.Error(t, err)
+	require.Equal(t, IssueDuplicateName, err.(ErrInvalidConfig)[0].Rule)
+	require.NoError(t, WriteGo(&bytes.Buffer{}, config, GoOptions{Package: "jobs", SignalPrefix: "Signal"}))
+}
@@ -1,5 +1,17 @@
 module github.com/orkestr8/fsm
 
-go 1.12
+// Go 1.18 or later is required, for the generics of package typed.  This is a breaking change for builds
+// with older versions of Go, which were supported since go 1.12.
+go 1.18
 
-require github.com/stretchr/testify v1.3.0
+require (
+	github.com/fsnotify/fsnotify v1.8.0
+	github.com/stretchr/testify v1.3.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+)
@@ -0,0 +1,147 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"sort"
+)
+
+// GraphStats are metrics of the complexity of a spec, seen as a graph of states with the transitions
+// and error transitions as edges.
+type GraphStats struct {
+	// States is the number of states
+	States int
+
+	// Signals is the number of signals, including those raised by TTLs, visit limits and watchdogs
+	Signals int
+
+	// Edges is the number of transitions, including error transitions and transitions to the same state
+	Edges int
+
+	// StronglyConnectedComponents is the number of groups of states that can all reach each other
+	StronglyConnectedComponents int
+
+	// LongestAcyclicPath is the number of edges in the longest path once each strongly connected
+	// component is collapsed to a single node, i.e. the depth of the spec ignoring cycles.
+	LongestAcyclicPath int
+
+	// TTLCycles is the number of TTL raises whose transition is part of a cycle, so that the
+	// fsm can go around the cycle without any external signal.
+	TTLCycles int
+}
+
+// graphStats computes the graph metrics of the spec
+func (s *spec) graphStats() GraphStats {
+	stats := GraphStats{
+		States:  len(s.states),
+		Signals: len(s.signals),
+	}
+
+	indexes := []Index{}
+	for index := range s.states {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	edges := map[Index][]Index{}
+	for _, index := range indexes {
+		st := s.states[index]
+		seen := map[Index]bool{}
+		for _, transfer := range []map[Signal]Index{st.Transitions, st.Errors} {
+			stats.Edges += len(transfer)
+			for _, next := range transfer {
+				if !seen[next] {
+					seen[next] = true
+					edges[index] = append(edges[index], next)
+				}
+			}
+		}
+		sort.Slice(edges[index], func(i, j int) bool { return edges[index][i] < edges[index][j] })
+	}
+
+	components := stronglyConnected(indexes, edges)
+	stats.StronglyConnectedComponents = len(components)
+
+	component := map[Index]int{}
+	for c, members := range components {
+		for _, index := range members {
+			component[index] = c
+		}
+	}
+
+	// Components are found in reverse topological order: successors of a component come before it.
+	depth := make([]int, len(components))
+	for c, members := range components {
+		for _, index := range members {
+			for _, next := range edges[index] {
+				if n := component[next]; n != c && depth[n]+1 > depth[c] {
+					depth[c] = depth[n] + 1
+				}
+			}
+		}
+		if depth[c] > stats.LongestAcyclicPath {
+			stats.LongestAcyclicPath = depth[c]
+		}
+	}
+
+	for _, index := range indexes {
+		st := s.states[index]
+		if st.TTL.TTL <= 0 {
+			continue
+		}
+		// a transition to the same state, or to a state in the same component, is part of a cycle
+		if next, has := st.Transitions[st.TTL.Raise]; has && component[next] == component[index] {
+			stats.TTLCycles++
+		}
+	}
+	return stats
+}
+
+// stronglyConnected returns the strongly connected components of the graph using Tarjan's algorithm.
+func stronglyConnected(nodes []Index, edges map[Index][]Index) [][]Index {
+	var (
+		order      = map[Index]int{}
+		low        = map[Index]int{}
+		onStack    = map[Index]bool{}
+		stack      = []Index{}
+		components = [][]Index{}
+		visit      func(Index)
+	)
+
+	visit = func(v Index) {
+		order[v] = len(order)
+		low[v] = order[v]
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range edges[v] {
+			if _, visited := order[w]; !visited {
+				visit(w)
+				if low[w] < low[v] {
+					low[v] = low[w]
+				}
+			} else if onStack[w] && order[w] < low[v] {
+				low[v] = order[w]
+			}
+		}
+
+		if low[v] == order[v] {
+			component := []Index{}
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				component = append(component, w)
+				if w == v {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+
+	for _, v := range nodes {
+		if _, visited := order[v]; !visited {
+			visit(v)
+		}
+	}
+	return components
+}
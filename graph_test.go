@@ -0,0 +1,73 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphStats(t *testing.T) {
+
+	const (
+		pending Index = iota
+		provisioning
+		running
+		retrying
+		stopped
+	)
+
+	const (
+		start Signal = iota
+		ready
+		fail
+		retry
+		stop
+		poll
+	)
+
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: provisioning,
+			},
+			TTL: Expiry{5, start},
+		},
+		State{
+			Index: provisioning,
+			Transitions: map[Signal]Index{
+				ready: running,
+				fail:  retrying,
+			},
+		},
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				poll: running,
+				stop: stopped,
+			},
+			TTL: Expiry{10, poll},
+		},
+		State{
+			Index: retrying,
+			Transitions: map[Signal]Index{
+				retry: provisioning,
+				stop:  stopped,
+			},
+			TTL: Expiry{3, retry},
+		},
+		State{
+			Index: stopped,
+		},
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, GraphStats{
+		States:                      5,
+		Signals:                     6,
+		Edges:                       7,
+		StronglyConnectedComponents: 4, // {provisioning, retrying}, {pending}, {running}, {stopped}
+		LongestAcyclicPath:          3, // pending -> provisioning/retrying -> running -> stopped
+		TTLCycles:                   2, // running poll, retrying retry
+	}, machines.GraphStats())
+}
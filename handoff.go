@@ -0,0 +1,229 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"sync"
+)
+
+// handoff is the move of an instance to another runner.  The signals to the instance while it's moved, and
+// those queued before, are kept in order and sent on to the instance it moved to once the move is done.
+type handoff struct {
+	lock      sync.Mutex
+	queue     []*event        // in the order the signals were sent
+	taken     map[*event]bool // events queued in the runner when the move started, and already in queue
+	completed bool            // the move is done
+	draining  bool            // the queue is being sent on
+	to        *instance       // nil if the move failed and the instance could not be put back
+}
+
+// startHandoff marks the instance as moving, taking the signals queued for it in order.  This must be called
+// from within the transaction loop.
+func (i *instance) startHandoff() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	h := &handoff{taken: map[*event]bool{}}
+	for _, e := range i.pending {
+		h.queue = append(h.queue, e)
+		h.taken[e] = true
+	}
+	i.pending = nil
+	i.handoff = h
+}
+
+// admit tracks the event as pending, or returns the handoff of the instance if it's moving
+func (i *instance) admit(e *event) *handoff {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.handoff != nil {
+		return i.handoff
+	}
+	e.deferred = false
+	i.pending = append(i.pending, e)
+	return nil
+}
+
+func (i *instance) handedOff() *handoff {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return i.handoff
+}
+
+// completeHandoff frees the handle and sends on the signals queued while the instance moved
+func (i *instance) completeHandoff(to *instance) {
+	i.lock.Lock()
+	h := i.handoff
+	i.freed = true
+	i.lock.Unlock()
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.to = to
+	h.completed = true
+	h.start(i)
+}
+
+// follow queues the event while the instance moves or its queue is sent on, and otherwise sends it
+// directly to the instance it moved to
+func (h *handoff) follow(e *event) error {
+	h.lock.Lock()
+	if !h.completed || h.draining {
+		h.queue = append(h.queue, e)
+		h.lock.Unlock()
+		return nil
+	}
+	to := h.to
+	h.lock.Unlock()
+
+	if to == nil {
+		return ErrFreed(e.instance)
+	}
+	return to.parent.signalAt(e.origin, e.at, e.signal, to, e.forwarded()...)
+}
+
+// forwarded returns the data of the event to send on, with its correlation ID
+func (e *event) forwarded() []interface{} {
+	if e.corrID == "" {
+		return e.data
+	}
+	return append(append([]interface{}{}, e.data...), e.corrID)
+}
+
+// start sends on the queue, if the move is done.  It must be called with the lock held.
+func (h *handoff) start(from *instance) {
+	if h.completed && !h.draining && len(h.queue) > 0 {
+		h.draining = true
+		go h.drain(from)
+	}
+}
+
+// drain sends the queued signals, one at a time in order, to the instance moved to
+func (h *handoff) drain(from *instance) {
+	for {
+		h.lock.Lock()
+		if len(h.queue) == 0 {
+			h.draining = false
+			h.lock.Unlock()
+			return
+		}
+		e := h.queue[0]
+		h.queue = h.queue[1:]
+		to := h.to
+		h.lock.Unlock()
+
+		if to == nil {
+			from.parent.log.Error("Forward failed", "instance", from.id, "err", ErrFreed(from.id))
+			continue
+		}
+		if err := to.parent.signalAt(e.origin, e.at, e.signal, to, e.forwarded()...); err != nil {
+			from.parent.log.Error("Forward failed", "instance", from.id, "err", err)
+		}
+	}
+}
+
+// forward sends the event, queued before the instance was moved, to where the instance moved.  It returns
+// false if the instance hasn't been moved.  This must be called from within the transaction loop.
+func (g *runner) forward(instance *instance, event *event) bool {
+	h := instance.handedOff()
+	if h == nil {
+		return false
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.taken[event] {
+		delete(h.taken, event) // already in the queue, in the order it was sent
+		return true
+	}
+	h.queue = append(h.queue, event)
+	h.start(instance)
+	return true
+}
+
+// handOff removes up to n instances, or all if n < 1, in the order of ID and returns their snapshot.
+// Signals to them are queued until the handoff is completed.  This must be called from within the transaction loop.
+func (g *runner) handOff(n int) (Snapshot, []*instance, error) {
+	snapshot := Snapshot{Now: g.now, Instances: []InstanceSnapshot{}}
+	moving := []*instance{}
+	var err error
+	g.forEach(func(i *instance) bool {
+		if n > 0 && len(moving) == n {
+			return false
+		}
+		var s InstanceSnapshot
+		if s, err = g.snapshot(i); err != nil {
+			return false
+		}
+		snapshot.Instances = append(snapshot.Instances, s)
+		moving = append(moving, i)
+		return true
+	})
+	if err != nil {
+		return snapshot, nil, err
+	}
+
+	tid := g.tid()
+	for _, i := range moving {
+		i.startHandoff()
+		if i.index > -1 {
+			g.deadlines.remove(i)
+		}
+		delete(g.members, i.id)
+		delete(g.bystate[i.state], i.id)
+		g.unindexExternal(i)
+		g.exitSub(i) // the instance gets a new child where it's loaded
+		g.logFree(tid, i.id)
+	}
+	return snapshot, moving, nil
+}
+
+// Handoff moves the instances to the machines, which must be running, in batches of the given size, e.g. to
+// reconfigure with a new spec or options without stopping.  Each batch is moved as with Snapshot and Restore,
+// so the counts of flaps and the like start over.  The signals to the instances of a batch are queued while
+// it's moved, and then sent on in order, and the handles of the moved instances send their signals on to the
+// new instances.  Instances added during the handoff are moved as well.  If a batch can't be restored, it's
+// put back and the error returned, with the number of instances moved before it.  The machines must be of
+// this package, e.g. not a wrapper of them.
+func (m *machines) Handoff(to Machines, batch int) (moved int, err error) {
+	target, ok := to.(*machines)
+	if !ok {
+		return 0, Errorf(UserError, "handoff to machines of type %T is not supported", to)
+	}
+	for {
+		var snapshot Snapshot
+		var moving []*instance
+		m.runner.do(func(g *runner) {
+			snapshot, moving, err = g.handOff(batch)
+		})
+		if err != nil || len(moving) == 0 {
+			return
+		}
+
+		restored := make([]*instance, len(moving))
+		target.runner.do(func(g *runner) {
+			if err = g.restore(snapshot); err != nil {
+				return
+			}
+			for k, i := range moving {
+				restored[k] = g.members[i.id]
+				restored[k].pinned = i.Pinned()
+			}
+		})
+		if err != nil {
+			m.runner.do(func(g *runner) {
+				if g.load(snapshot) != nil {
+					return
+				}
+				for k, i := range moving {
+					restored[k] = g.members[i.id]
+					restored[k].pinned = i.Pinned()
+				}
+			})
+		}
+
+		for k, i := range moving {
+			i.completeHandoff(restored[k])
+		}
+		if err != nil {
+			return
+		}
+		moved += len(moving)
+	}
+}
@@ -0,0 +1,181 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandoff(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+		stopped
+	)
+
+	const (
+		start Signal = iota
+		stop
+	)
+
+	states := []State{
+		{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			TTL: Expiry{10, start},
+		},
+		{
+			Index: running,
+			Transitions: map[Signal]Index{
+				stop: stopped,
+			},
+		},
+		{
+			Index: stopped,
+		},
+	}
+
+	old, err := define(states[0], states[1:]...)
+	require.NoError(t, err)
+	require.NoError(t, old.Run(NewClock(), DefaultOptions()))
+	defer old.Done()
+
+	handles := []FSM{}
+	for i := 0; i < 5; i++ {
+		instance, err := old.New(pending)
+		require.NoError(t, err)
+		handles = append(handles, instance)
+	}
+	require.NoError(t, handles[1].Signal(start))
+	handles[2].Pin()
+
+	next, err := define(states[0], states[1:]...)
+	require.NoError(t, err)
+	require.NoError(t, next.Run(NewClock(), DefaultOptions()))
+	defer next.Done()
+
+	moved, err := old.Handoff(next, 2)
+	require.NoError(t, err)
+	require.Equal(t, 5, moved)
+	require.Equal(t, 0, old.Count())
+	require.Equal(t, 5, next.Count())
+	require.Equal(t, 4, next.CountIn(pending))
+	require.Equal(t, 1, next.CountIn(running))
+	require.Len(t, next.PendingDeadlines(), 4)
+
+	// the old handles are freed but their signals follow the instances
+	require.NoError(t, handles[1].Signal(stop))
+	require.NoError(t, handles[0].Signal(start))
+	require.Equal(t, NoState, handles[1].State())
+	require.Equal(t, 1, next.CountIn(stopped))
+	require.Equal(t, 1, next.CountIn(running))
+	require.Equal(t, ErrPinned(handles[2].ID()), next.Free(handles[2].ID()))
+
+	// a batch that can't be restored is put back
+	other, err := define(states[0], states[1:]...)
+	require.NoError(t, err)
+	require.NoError(t, other.Run(NewClock(), DefaultOptions()))
+	defer other.Done()
+	_, err = other.New(pending)
+	require.NoError(t, err)
+
+	moved, err = next.Handoff(other, 2)
+	require.Equal(t, ErrDuplicateID(0), err)
+	require.Equal(t, 0, moved)
+	require.Equal(t, 5, next.Count())
+	require.NoError(t, handles[3].Signal(start))
+	require.Equal(t, 2, next.CountIn(running))
+}
+
+func TestHandoffQueuesSignals(t *testing.T) {
+
+	const (
+		idle Index = iota
+		busy
+	)
+
+	const (
+		work Signal = iota
+		poke
+	)
+
+	got := make(chan interface{}, 3)
+	states := func(action Action) []State {
+		return []State{
+			{
+				Index: idle,
+				Transitions: map[Signal]Index{
+					work: busy,
+					poke: idle,
+				},
+				Actions: map[Signal]Action{
+					work: action,
+					poke: func(f FSM) error {
+						got <- f.Data()
+						return nil
+					},
+				},
+			},
+			{
+				Index: busy,
+				Transitions: map[Signal]Index{
+					work: idle,
+				},
+			},
+		}
+	}
+
+	noop := states(func(FSM) error { return nil })
+	old, err := define(noop[0], noop[1:]...)
+	require.NoError(t, err)
+	require.NoError(t, old.Run(NewClock(), DefaultOptions()))
+	defer old.Done()
+	freed, err := old.New(idle) // to tell apart the IDs of the two machines
+	require.NoError(t, err)
+	require.NoError(t, old.Free(freed.ID()))
+	moving, err := old.New(idle)
+	require.NoError(t, err)
+
+	// an action of the target signals the instance while it's moved, in the loop the move waits on
+	started := make(chan struct{})
+	spec := states(func(f FSM) error {
+		<-started
+		for _, data := range []string{"a", "b", "c"} {
+			if err := moving.Signal(poke, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	next, err := define(spec[0], spec[1:]...)
+	require.NoError(t, err)
+	require.NoError(t, next.Run(NewClock(), DefaultOptions()))
+	defer next.Done()
+
+	other, err := next.New(idle)
+	require.NoError(t, err)
+	require.NoError(t, other.Signal(work))
+
+	moved := make(chan error)
+	go func() {
+		_, err := old.Handoff(next, 0)
+		moved <- err
+	}()
+	for old.Count() > 0 {
+	}
+	close(started)
+	require.NoError(t, <-moved)
+
+	// the signals follow the instance in the order they were sent
+	for _, data := range []string{"a", "b", "c"} {
+		require.Equal(t, []interface{}{data}, <-got)
+	}
+
+	_, err = old.Handoff(struct{ Machines }{next}, 0)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, UserError))
+}
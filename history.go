@@ -0,0 +1,169 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultHistoryLimit = 100
+
+// HistoryFilter selects the past transitions read by Machines.HistoryIter.  Empty fields match all.
+type HistoryFilter struct {
+	// IDs are the instances
+	IDs []ID
+
+	// States match transitions from or to any of them
+	States []Index
+
+	// Signals are the signals of the transitions
+	Signals []Signal
+
+	// Since and Until bound the wall time of the transitions, inclusive of Since and exclusive of Until
+	Since time.Time
+	Until time.Time
+
+	// Limit is the number of transitions in a page.  The default is 100.
+	Limit int
+}
+
+func (f HistoryFilter) match(t Transition) bool {
+	if len(f.IDs) > 0 {
+		match := false
+		for _, id := range f.IDs {
+			match = match || id == t.ID
+		}
+		if !match {
+			return false
+		}
+	}
+	if len(f.States) > 0 {
+		match := false
+		for _, state := range f.States {
+			match = match || state == t.From || state == t.To
+		}
+		if !match {
+			return false
+		}
+	}
+	if len(f.Signals) > 0 {
+		match := false
+		for _, signal := range f.Signals {
+			match = match || signal == t.Signal
+		}
+		if !match {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && t.WallTime.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !t.WallTime.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// ErrCursor is returned for a cursor that's not one returned by HistoryIter.Cursor, or whose transition has
+// since been compacted out of the WAL
+type ErrCursor string
+
+func (e ErrCursor) Error() string {
+	return fmt.Sprintf("invalid cursor: %q", string(e))
+}
+
+func (e ErrCursor) Is(target error) bool {
+	return target == UserError
+}
+
+// HistoryIter iterates over a page of the past transitions in the WAL, in the order they were committed.
+type HistoryIter struct {
+	records  []Record
+	filter   HistoryFilter
+	pos      int
+	returned int
+}
+
+// Next returns the next transition of the page, or false at the end of the page
+func (it *HistoryIter) Next() (Transition, bool) {
+	for it.returned < it.filter.Limit && it.pos < len(it.records) {
+		record := it.records[it.pos]
+		it.pos++
+		if record.Kind != RecordTransition || record.Transition == nil || !it.filter.match(*record.Transition) {
+			continue
+		}
+		it.returned++
+		return *record.Transition, true
+	}
+	return Transition{}, false
+}
+
+// Cursor returns the cursor of the next page, or an empty string if there are no more transitions.  It's
+// valid once the page has been read to the end.  The cursor is the position of the first transition of the
+// next page, with the instance and wall time of the transition to find it where compactions have moved it.
+func (it *HistoryIter) Cursor() string {
+	for it.pos < len(it.records) {
+		record := it.records[it.pos]
+		if record.Kind == RecordTransition && record.Transition != nil && it.filter.match(*record.Transition) {
+			return fmt.Sprintf("%d.%s", it.pos, cursorKey(*record.Transition))
+		}
+		it.pos++
+	}
+	return ""
+}
+
+// cursorKey identifies the transition in the WAL
+func cursorKey(t Transition) string {
+	return fmt.Sprintf("%d.%d", t.ID, t.WallTime.UnixNano())
+}
+
+// historyIter returns the iterator over the records from the cursor
+func historyIter(records []Record, filter HistoryFilter, cursor string) (*HistoryIter, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultHistoryLimit
+	}
+	it := &HistoryIter{records: records, filter: filter}
+	if cursor == "" {
+		return it, nil
+	}
+	parts := strings.SplitN(cursor, ".", 2)
+	pos, err := strconv.Atoi(parts[0])
+	if err != nil || pos < 0 || len(parts) < 2 {
+		return nil, ErrCursor(cursor)
+	}
+	found := func(p int) bool {
+		if p < 0 || p >= len(records) {
+			return false
+		}
+		record := records[p]
+		return record.Kind == RecordTransition && record.Transition != nil &&
+			cursorKey(*record.Transition) == parts[1]
+	}
+	if found(pos) {
+		it.pos = pos
+		return it, nil
+	}
+	// compactions since remove records, so the transition is at most as far as it was
+	for p := pos - 1; p >= 0; p-- {
+		if found(p) {
+			it.pos = p
+			return it, nil
+		}
+	}
+	return nil, ErrCursor(cursor) // compacted out
+}
+
+// HistoryIter returns a page of the past transitions in the WAL that match the filter, starting at the
+// cursor, which is empty for the first page.  Only the transitions not yet compacted are in the WAL, and
+// the cursor of a transition compacted between pages is an ErrCursor.  There's no history without a WAL.
+func (m *machines) HistoryIter(filter HistoryFilter, cursor string) (*HistoryIter, error) {
+	records := []Record{}
+	if m.Options.WAL != nil {
+		var err error
+		if records, err = m.Options.WAL.Records(); err != nil {
+			return nil, err
+		}
+	}
+	return historyIter(records, filter, cursor)
+}
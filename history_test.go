@@ -0,0 +1,102 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryIter(t *testing.T) {
+
+	const (
+		off Index = iota
+		on
+	)
+
+	const (
+		turnOn Signal = iota
+		turnOff
+	)
+
+	machines, err := define(
+		State{
+			Index: off,
+			Transitions: map[Signal]Index{
+				turnOn: on,
+			},
+		},
+		State{
+			Index: on,
+			Transitions: map[Signal]Index{
+				turnOff: off,
+			},
+		},
+	)
+	require.NoError(t, err)
+	wal := &MemoryWAL{}
+	options := DefaultOptions()
+	options.WAL = wal
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	a, err := machines.New(off)
+	require.NoError(t, err)
+	b, err := machines.New(off)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, a.Signal(turnOn))
+		require.NoError(t, b.Signal(turnOn))
+		require.NoError(t, a.Signal(turnOff))
+	}
+	require.Equal(t, 1, machines.CountIn(on)) // all the signals are processed
+
+	read := func(it *HistoryIter) []Transition {
+		out := []Transition{}
+		for t, ok := it.Next(); ok; t, ok = it.Next() {
+			out = append(out, t)
+		}
+		return out
+	}
+
+	// the transitions of a, two at a time
+	filter := HistoryFilter{IDs: []ID{a.ID()}, Limit: 2}
+	all := []Transition{}
+	pages := 0
+	for cursor := ""; pages == 0 || cursor != ""; pages++ {
+		it, err := machines.HistoryIter(filter, cursor)
+		require.NoError(t, err)
+		page := read(it)
+		require.True(t, len(page) <= 2)
+		all = append(all, page...)
+		cursor = it.Cursor()
+	}
+	require.Equal(t, 3, pages)
+	require.Len(t, all, 6)
+	for i, transition := range all {
+		require.Equal(t, a.ID(), transition.ID)
+		require.Equal(t, []Signal{turnOn, turnOff}[i%2], transition.Signal)
+	}
+
+	it, err := machines.HistoryIter(HistoryFilter{Signals: []Signal{turnOn}, States: []Index{on}}, "")
+	require.NoError(t, err)
+	require.Len(t, read(it), 4) // b turns on once; the other turnOns are undefined while on
+	require.Equal(t, "", it.Cursor())
+
+	_, err = machines.HistoryIter(filter, "page 2")
+	require.Equal(t, ErrCursor("page 2"), err)
+
+	// the cursor follows its transition as compactions move it, until it's compacted out
+	it, err = machines.HistoryIter(filter, "")
+	require.NoError(t, err)
+	require.Equal(t, all[:2], read(it))
+	cursor := it.Cursor()
+
+	require.NoError(t, wal.Compact(b.ID(), Record{Kind: RecordSnapshot, ID: b.ID()}))
+	it, err = machines.HistoryIter(filter, cursor)
+	require.NoError(t, err)
+	require.Equal(t, all[2:4], read(it))
+
+	require.NoError(t, wal.Compact(a.ID(), Record{Kind: RecordSnapshot, ID: a.ID()}))
+	_, err = machines.HistoryIter(filter, cursor)
+	require.Equal(t, ErrCursor(cursor), err)
+}
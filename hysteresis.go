@@ -0,0 +1,79 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// Threshold is the number of times a signal must be received before its transition fires.  If Within
+// is 0, the signal must be received Count times in a row; otherwise, it must be received Count times
+// within the given number of ticks.
+type Threshold struct {
+	Count  int
+	Within Tick
+}
+
+// returns the threshold for the signal in the given state, if any
+func (s *spec) threshold(current Index, signal Signal) *Threshold {
+	state, has := s.states[current]
+	if !has {
+		return nil
+	}
+	if t, has := state.Hysteresis[signal]; has && t.Count > 1 {
+		return &t
+	}
+	return nil
+}
+
+func newStreaks() *streaks {
+	return &streaks{
+		history: map[Signal][]Time{},
+	}
+}
+
+// streaks tracks the receipt of signals that are subject to thresholds
+type streaks struct {
+	history map[Signal][]Time
+	last    Signal
+	any     bool
+}
+
+func (s *streaks) reset() {
+	s.history = map[Signal][]Time{}
+	s.any = false
+}
+
+// receive records the signal and returns true if the threshold is met, in which case the history
+// for the signal is cleared.  A nil threshold only interrupts any consecutive streaks.
+func (s *streaks) receive(signal Signal, threshold *Threshold, now Time) bool {
+	interrupted := s.any && s.last != signal
+	s.last, s.any = signal, true
+
+	if threshold == nil {
+		return true
+	}
+
+	history := s.history[signal]
+	if threshold.Within == 0 && interrupted {
+		history = nil
+	}
+	history = append(history, now)
+	if threshold.Within > 0 {
+		// events may be out of order in event time, so the window ends at the latest
+		latest := now
+		for _, t := range history {
+			if t > latest {
+				latest = t
+			}
+		}
+		kept := []Time{}
+		for _, t := range history {
+			if latest-t < Time(threshold.Within) {
+				kept = append(kept, t)
+			}
+		}
+		history = kept
+	}
+
+	if len(history) >= threshold.Count {
+		delete(s.history, signal)
+		return true
+	}
+	s.history[signal] = history
+	return false
+}
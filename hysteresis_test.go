@@ -0,0 +1,98 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreaks(t *testing.T) {
+
+	const (
+		a Signal = iota
+		b
+	)
+
+	consecutive := &Threshold{Count: 3}
+
+	s := newStreaks()
+	require.False(t, s.receive(a, consecutive, 1))
+	require.False(t, s.receive(a, consecutive, 2))
+	require.True(t, s.receive(b, nil, 3)) // interrupts
+	require.False(t, s.receive(a, consecutive, 4))
+	require.False(t, s.receive(a, consecutive, 5))
+	require.True(t, s.receive(a, consecutive, 6))
+	require.False(t, s.receive(a, consecutive, 7)) // starts over
+
+	window := &Threshold{Count: 2, Within: 3}
+
+	s = newStreaks()
+	require.False(t, s.receive(a, window, 1))
+	require.True(t, s.receive(b, nil, 2)) // does not interrupt
+	require.True(t, s.receive(a, window, 3))
+	require.False(t, s.receive(a, window, 4))
+	require.False(t, s.receive(a, window, 7)) // 4 is out of the window
+	require.True(t, s.receive(a, window, 8))
+
+	require.True(t, s.receive(b, nil, 9))
+}
+
+func TestHysteresis(t *testing.T) {
+
+	const (
+		up Index = iota
+		down
+	)
+
+	const (
+		foundDown Signal = iota
+		foundUp
+	)
+
+	machines, err := define(
+		State{
+			Index: up,
+			Transitions: map[Signal]Index{
+				foundDown: down,
+				foundUp:   up,
+			},
+			Hysteresis: map[Signal]Threshold{
+				foundDown: {Count: 3},
+			},
+		},
+		State{
+			Index: down,
+			Transitions: map[Signal]Index{
+				foundUp: up,
+			},
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	instance, err := machines.New(up)
+	require.NoError(t, err)
+
+	require.NoError(t, instance.Signal(foundDown))
+	require.NoError(t, instance.Signal(foundDown))
+	require.Equal(t, up, instance.State())
+
+	require.NoError(t, instance.Signal(foundUp))
+	require.NoError(t, instance.Signal(foundDown))
+	require.NoError(t, instance.Signal(foundDown))
+	require.Equal(t, up, instance.State())
+
+	require.NoError(t, instance.Signal(foundDown))
+	require.Equal(t, down, instance.State())
+
+	_, err = define(
+		State{
+			Index: up,
+			Hysteresis: map[Signal]Threshold{
+				foundDown: {Count: 3},
+			},
+		},
+	)
+	require.Error(t, err)
+}
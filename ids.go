@@ -0,0 +1,45 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// IDPolicy is how the IDs of new instances are assigned
+type IDPolicy int
+
+const (
+	// IDSequential assigns increasing IDs, wrapping around on overflow.  This is the default.
+	IDSequential IDPolicy = iota
+
+	// IDReuse assigns the IDs of freed instances, most recently freed first, before new sequential IDs.
+	IDReuse
+
+	// IDRandom assigns random IDs
+	IDRandom
+)
+
+// nextID returns an ID for a new instance according to the policy.  IDs in use are never returned,
+// e.g. after a sequential ID wraps around or when instances are added with explicit IDs.
+func (g *runner) nextID() ID {
+	switch g.options.IDs {
+	case IDReuse:
+		for len(g.freed) > 0 {
+			id := g.freed[len(g.freed)-1]
+			g.freed = g.freed[:len(g.freed)-1]
+			if _, has := g.members[id]; !has {
+				return id
+			}
+		}
+	case IDRandom:
+		for {
+			id := ID(g.random.Uint64())
+			if _, has := g.members[id]; !has {
+				return id
+			}
+		}
+	}
+
+	for {
+		id := g.next
+		g.next++
+		if _, has := g.members[id]; !has {
+			return id
+		}
+	}
+}
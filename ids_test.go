@@ -0,0 +1,58 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDPolicy(t *testing.T) {
+
+	const (
+		idle Index = iota
+	)
+
+	run := func(policy IDPolicy) *machines {
+		machines, err := define(State{Index: idle})
+		require.NoError(t, err)
+		options := DefaultOptions()
+		options.IDs = policy
+		require.NoError(t, machines.Run(NewClock(), options))
+		return machines
+	}
+
+	ids := func(machines *machines, n int) []ID {
+		out := []ID{}
+		for i := 0; i < n; i++ {
+			instance, err := machines.New(idle)
+			require.NoError(t, err)
+			out = append(out, instance.ID())
+		}
+		return out
+	}
+
+	sequential := run(IDSequential)
+	defer sequential.Done()
+	require.Equal(t, []ID{0, 1, 2}, ids(sequential, 3))
+	require.NoError(t, sequential.Free(1))
+	require.Equal(t, []ID{3}, ids(sequential, 1))
+
+	reuse := run(IDReuse)
+	defer reuse.Done()
+	require.Equal(t, []ID{0, 1, 2}, ids(reuse, 3))
+	require.NoError(t, reuse.Free(0))
+	require.NoError(t, reuse.Free(2))
+	require.Equal(t, []ID{2, 0, 3}, ids(reuse, 3))
+
+	random := run(IDRandom)
+	defer random.Done()
+	require.Len(t, ids(random, 10), 10)
+	require.Equal(t, 10, random.Count())
+
+	// wrapping around skips the IDs in use
+	sequential.runner.do(func(g *runner) {
+		g.next = math.MaxUint64
+	})
+	require.Equal(t, []ID{math.MaxUint64, 1, 4}, ids(sequential, 3))
+}
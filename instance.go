@@ -1,7 +1,9 @@
 package fsm // import "github.com/orkestr8/fsm"
 
 import (
+	"encoding/json"
 	"sync"
+	"time"
 )
 
 // implements FSM interface
@@ -12,10 +14,28 @@ type instance struct {
 	parent   *runner
 	error    error
 	flaps    flaps
+	streaks  streaks
 	start    Time
 	deadline Time
+	alive    Time
+	overdue  Tick
 	index    int // index used in the deadlines queue
 	visits   map[Index]int
+	expiries map[Index]int // consecutive expiries of states with backoff
+	failures []Time        // times of action errors counted against the error budget
+	origin   Origin        // of the signal of the last transition
+	created  time.Time
+	changed  time.Time
+	labels   map[string]string
+	freed    bool // set when the instance is freed; the handle is no longer usable
+	pinned   bool
+	acked    bool     // the sticky state has been acknowledged
+	changes  []change // transitions in the window of Options.Stability
+	pending  []*event // signals queued and not yet applied
+	handoff  *handoff // set when the instance is moved to another runner
+	child    FSM      // of the Submachine of the state, if any
+	children Machines // the machines of the child
+	sub      bool     // a child is created, or to be, for the state; only used in the transaction loop
 
 	lock sync.RWMutex
 }
@@ -27,30 +47,141 @@ func (i *instance) ID() ID {
 	return i.id
 }
 
-// Data returns a customer data value attached to this instance
+// Data returns a customer data value attached to this instance, or nil if the instance is freed.
 func (i *instance) Data() interface{} {
 	i.lock.RLock()
 	defer i.lock.RUnlock()
+	if i.freed {
+		return nil
+	}
 	return i.data
 }
 
-const invalidState Index = -99999
+// Pin keeps the instance from being freed until Unpin, e.g. while it's under investigation
+func (i *instance) Pin() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.pinned = true
+}
+
+// Unpin releases the instance so that it can be freed
+func (i *instance) Unpin() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.pinned = false
+}
+
+// Pinned returns true if the instance is pinned
+func (i *instance) Pinned() bool {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return i.pinned
+}
+
+func (i *instance) isFreed() bool {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return i.freed
+}
+
+func (i *instance) setFreed() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.freed = true
+}
+
+// Labels returns a copy of the labels of the instance
+func (i *instance) Labels() map[string]string {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return copyMeta(i.labels)
+}
+
+func (i *instance) setOrigin(origin Origin) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.origin = origin
+}
+
+func (i *instance) setChild(child FSM, machines Machines) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.child, i.children = child, machines
+}
+
+// takeChild removes and returns the child of the instance and its machines
+func (i *instance) takeChild() (FSM, Machines) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	child, machines := i.child, i.children
+	i.child, i.children = nil, nil
+	return child, machines
+}
 
-// IsInvalidState returns true if the index is invalid
-func IsInvalidState(s Index) bool {
-	return s == invalidState
+// Child returns the child instance of the Submachine of the state, or nil if there's none
+func (i *instance) Child() FSM {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return i.child
 }
 
-// State returns the state of the fsm instance
+// CreatedAt returns the wall time when the instance was created
+func (i *instance) CreatedAt() time.Time {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return i.created
+}
+
+// LastTransitionAt returns the wall time of the last transition, or of the creation of the instance.
+func (i *instance) LastTransitionAt() time.Time {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return i.changed
+}
+
+// MarshalJSON implements json.Marshaler
+func (i *instance) MarshalJSON() ([]byte, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return json.Marshal(struct {
+		ID               ID        `json:"id"`
+		State            Index     `json:"state"`
+		CreatedAt        time.Time `json:"createdAt"`
+		LastTransitionAt time.Time `json:"lastTransitionAt"`
+	}{
+		ID:               i.id,
+		State:            i.state,
+		CreatedAt:        i.created,
+		LastTransitionAt: i.changed,
+	})
+}
+
+// Overdue returns the number of ticks past the deadline when the signal being acted on was raised by
+// an expired TTL.  It is 0 outside of actions or when the signal was not raised by a deadline.
+func (i *instance) Overdue() Tick {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return i.overdue
+}
+
+func (i *instance) setOverdue(overdue Tick) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.overdue = overdue
+}
+
+// State returns the state of the fsm instance, or NoState if the instance is freed.
 func (i *instance) State() (result Index) {
 	done := make(chan struct{})
 
-	result = invalidState
+	result = NoState
 
 	// queue this and get a snapshot so that the read is consistent
 	i.parent.reads <- func(view *runner) {
 		defer close(done)
-		result = i.state
+		if !i.freed {
+			result = i.state
+		}
 	}
 	<-done // finish waiting
 	return
@@ -58,13 +189,69 @@ func (i *instance) State() (result Index) {
 
 // Valid returns true if current state can receive the given signal
 func (i *instance) CanReceive(s Signal) bool {
-	_, _, err := i.parent.spec.transition(i.State(), s)
-	return err == nil
+	ok, _, _ := i.Check(s)
+	return ok
+}
+
+// Check returns whether the current state can receive the signal, the next state, and the reason if not.
+func (i *instance) Check(s Signal) (ok bool, next Index, reason error) {
+	i.parent.do(func(g *runner) {
+		if i.freed {
+			ok, next, reason = false, NoState, ErrFreed(i.id)
+			return
+		}
+		ok, next, reason = g.check(i.state, s)
+	})
+	return
 }
 
 // Signal sends a signal to the instance
 func (i *instance) Signal(s Signal, optionalData ...interface{}) (err error) {
-	return i.parent.signal(s, i, optionalData...)
+	return i.parent.signal(OriginAPI, s, i, optionalData...)
+}
+
+// SignalFrom sends a signal to the instance, tagged with its origin
+func (i *instance) SignalFrom(origin Origin, s Signal, optionalData ...interface{}) (err error) {
+	return i.parent.signal(origin, s, i, optionalData...)
+}
+
+// SignalAt sends a signal to the instance with the time the event happened, see Options.EventTime
+func (i *instance) SignalAt(at time.Time, s Signal, optionalData ...interface{}) (err error) {
+	return i.parent.signalAt(OriginAPI, at, s, i, optionalData...)
+}
+
+// Origin returns the origin of the signal of the last transition
+func (i *instance) Origin() Origin {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return i.origin
+}
+
+// Sequence applies the signals in order as a single transaction
+func (i *instance) Sequence(signals ...Signal) error {
+	return i.parent.sequence(i, signals)
+}
+
+// snapshot is a view of the instance used from within the transaction loop, where
+// reading the state does not need to be queued.
+type snapshot struct {
+	*instance
+}
+
+// State returns the state of the instance as seen in the transaction loop
+func (s snapshot) State() Index {
+	return s.instance.state
+}
+
+// CanReceive returns true if the state in the snapshot can receive the given signal
+func (s snapshot) CanReceive(signal Signal) bool {
+	ok, _, _ := s.Check(signal)
+	return ok
+}
+
+// Check returns whether the state in the snapshot can receive the signal, the next state, and the reason if not.
+func (s snapshot) Check(signal Signal) (bool, Index, error) {
+	return s.parent.check(s.instance.state, signal)
 }
 
 func (i *instance) update(next Index, now Time, ttl Tick) {
@@ -76,8 +263,11 @@ func (i *instance) update(next Index, now Time, ttl Tick) {
 	}
 
 	i.visits[next] = i.visits[next] + 1
+	i.streaks.reset()
 	i.state = next
 	i.start = now
+	i.alive = now
+	i.changed = time.Now()
 	if ttl > 0 {
 		i.deadline = now + Time(ttl)
 	} else {
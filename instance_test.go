@@ -0,0 +1,65 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWallTimes(t *testing.T) {
+
+	const (
+		on Index = iota
+		off
+	)
+
+	const (
+		turnOn Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: on,
+		},
+		State{
+			Index: off,
+			Transitions: map[Signal]Index{
+				turnOn: on,
+			},
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	before := time.Now()
+	instance, err := machines.New(off)
+	require.NoError(t, err)
+
+	created := instance.CreatedAt()
+	require.False(t, created.Before(before))
+	require.Equal(t, created, instance.LastTransitionAt())
+
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, instance.Signal(turnOn))
+	require.Equal(t, on, instance.State())
+	require.Equal(t, created, instance.CreatedAt())
+	require.True(t, instance.LastTransitionAt().After(created))
+
+	buff, err := json.Marshal(instance)
+	require.NoError(t, err)
+
+	decoded := struct {
+		ID               ID
+		State            Index
+		CreatedAt        time.Time
+		LastTransitionAt time.Time
+	}{}
+	require.NoError(t, json.Unmarshal(buff, &decoded))
+	require.Equal(t, instance.ID(), decoded.ID)
+	require.Equal(t, on, decoded.State)
+	require.True(t, created.Equal(decoded.CreatedAt))
+	require.True(t, instance.LastTransitionAt().Equal(decoded.LastTransitionAt))
+}
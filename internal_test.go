@@ -0,0 +1,107 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInternalTransition(t *testing.T) {
+
+	const (
+		polling Index = iota
+		done
+		gaveUp
+	)
+
+	const (
+		progress Signal = iota
+		retry
+		finish
+		giveUp
+	)
+
+	progressed := 0
+	machines, err := define(
+		State{
+			Index: polling,
+			Transitions: map[Signal]Index{
+				progress: polling,
+				retry:    polling,
+				finish:   done,
+				giveUp:   gaveUp,
+			},
+			Actions: map[Signal]Action{
+				progress: func(FSM) error {
+					progressed++
+					return nil
+				},
+			},
+			Internal: []Signal{progress},
+			TTL:      Expiry{5, finish},
+			Visit:    Limit{3, giveUp},
+		},
+		State{
+			Index: done,
+		},
+		State{
+			Index: gaveUp,
+		},
+	)
+	require.NoError(t, err)
+
+	notified := make(chan Signal, 10)
+	options := DefaultOptions()
+	options.Notify = map[Index]Notifier{
+		polling: notifierFunc(func(t Transition) error {
+			notified <- t.Signal
+			return nil
+		}),
+	}
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, options))
+	defer machines.Done()
+
+	watch, cancel := machines.Watch(10)
+	defer cancel()
+
+	a, err := machines.New(polling)
+	require.NoError(t, err)
+	clock.Ticks(2)
+
+	before := time.Now()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, a.Signal(progress))
+	}
+	require.Equal(t, polling, a.State())
+	require.Equal(t, 5, progressed)
+
+	// no re-entry: the deadline is as it was and the visit is not counted
+	require.Equal(t, []DeadlineInfo{{ID: a.ID(), State: polling, Due: 5, Raise: finish}}, machines.PendingDeadlines())
+	transition := <-watch
+	require.True(t, transition.Internal)
+	require.Equal(t, 2, transition.Visits) // the initial state counts twice
+	require.False(t, transition.WallTime.Before(before))
+
+	// a regular self-transition re-enters, and notifies
+	require.NoError(t, a.Signal(retry))
+	require.Equal(t, []DeadlineInfo{{ID: a.ID(), State: polling, Due: 7, Raise: finish}}, machines.PendingDeadlines())
+	require.Equal(t, retry, <-notified)
+	time.Sleep(50 * time.Millisecond)
+	require.Len(t, notified, 0)
+
+	_, err = define(
+		State{
+			Index: polling,
+			Transitions: map[Signal]Index{
+				finish: done,
+			},
+			Internal: []Signal{finish},
+		},
+		State{
+			Index: done,
+		},
+	)
+	require.IsType(t, ErrUnknownTransition{}, err)
+}
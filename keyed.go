@@ -0,0 +1,133 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"sync"
+)
+
+// KeyedSet keeps one instance of the machines for each key, e.g. the name of a resource.  The instance of a key
+// is created in the initial state when the key is first used, and freed when it reaches a terminal state, so
+// that the next use of the key starts over.
+type KeyedSet[K comparable] struct {
+	machines Machines
+	initial  Index
+	stop     func()
+
+	lock      sync.Mutex
+	instances map[K]FSM
+	keys      map[ID]K
+}
+
+// NewKeyedSet returns a set of instances of the machines, which must be running, created in the initial state.
+// Close stops the set from freeing the instances that reach terminal states.
+func NewKeyedSet[K comparable](machines Machines, initial Index) *KeyedSet[K] {
+	transitions, stop := machines.Watch(defaultBufferSize)
+	s := &KeyedSet[K]{
+		machines:  machines,
+		initial:   initial,
+		stop:      stop,
+		instances: map[K]FSM{},
+		keys:      map[ID]K{},
+	}
+	go func() {
+		for transition := range transitions {
+			if !machines.Terminal(transition.To) {
+				continue
+			}
+			s.lock.Lock()
+			if key, has := s.keys[transition.ID]; has {
+				// the ID may have been reused by an instance created since the transition
+				instance := s.instances[key]
+				if state := instance.State(); state != NoState && machines.Terminal(state) {
+					s.forget(key, instance)
+				}
+			}
+			s.lock.Unlock()
+		}
+	}()
+	return s
+}
+
+// forget frees the instance of the key and removes it from the set, unless it's pinned.  It must be called
+// with the lock held.
+func (s *KeyedSet[K]) forget(key K, instance FSM) error {
+	if err := s.machines.Free(instance.ID()); err != nil {
+		if _, pinned := err.(ErrPinned); pinned {
+			return err
+		}
+	}
+	delete(s.instances, key)
+	delete(s.keys, instance.ID())
+	return nil
+}
+
+// Get returns the instance of the key, creating it if there's none.  An instance in a terminal state that's
+// yet to be freed is replaced, unless it's pinned, and then ErrPinned is returned.
+func (s *KeyedSet[K]) Get(key K) (FSM, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if instance, has := s.instances[key]; has {
+		// the transitions watched may have been dropped, so check the state as well
+		state := instance.State()
+		if state != NoState && !s.machines.Terminal(state) {
+			return instance, nil
+		}
+		if err := s.forget(key, instance); err != nil {
+			return nil, err
+		}
+	}
+	instance, err := s.machines.New(s.initial)
+	if err != nil {
+		return nil, err
+	}
+	s.instances[key] = instance
+	s.keys[instance.ID()] = key
+	return instance, nil
+}
+
+// Signal signals the instance of the key, creating it if there's none
+func (s *KeyedSet[K]) Signal(key K, signal Signal, optionalData ...interface{}) error {
+	instance, err := s.Get(key)
+	if err != nil {
+		return err
+	}
+	return instance.Signal(signal, optionalData...)
+}
+
+// State returns the state of the instance of the key, and false if there's none
+func (s *KeyedSet[K]) State(key K) (Index, bool) {
+	s.lock.Lock()
+	instance, has := s.instances[key]
+	s.lock.Unlock()
+	if !has {
+		return NoState, false
+	}
+	state := instance.State()
+	return state, state != NoState
+}
+
+// Delete frees the instance of the key, if any.  A pinned instance is kept, and ErrPinned returned.
+func (s *KeyedSet[K]) Delete(key K) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if instance, has := s.instances[key]; has {
+		return s.forget(key, instance)
+	}
+	return nil
+}
+
+// Keys returns the keys with instances
+func (s *KeyedSet[K]) Keys() []K {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	keys := make([]K, 0, len(s.instances))
+	for key := range s.instances {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Close stops watching the transitions of the instances.  The instances are left as they are.
+func (s *KeyedSet[K]) Close() {
+	s.stop()
+}
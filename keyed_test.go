@@ -0,0 +1,100 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyedSet(t *testing.T) {
+
+	const (
+		running Index = iota
+		exited
+	)
+
+	const (
+		exit Signal = iota
+		heartbeat
+	)
+
+	machines, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				exit:      exited,
+				heartbeat: running,
+			},
+		},
+		State{
+			Index: exited,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	require.True(t, machines.Terminal(exited))
+	require.False(t, machines.Terminal(running))
+
+	set := NewKeyedSet[string](machines, running)
+	defer set.Close()
+
+	_, has := set.State("web")
+	require.False(t, has)
+
+	require.NoError(t, set.Signal("web", heartbeat))
+	require.NoError(t, set.Signal("db", heartbeat))
+	state, has := set.State("web")
+	require.True(t, has)
+	require.Equal(t, running, state)
+	require.Equal(t, 2, machines.Count())
+
+	web, err := set.Get("web")
+	require.NoError(t, err)
+	again, err := set.Get("web")
+	require.NoError(t, err)
+	require.Equal(t, web.ID(), again.ID())
+
+	keys := set.Keys()
+	sort.Strings(keys)
+	require.Equal(t, []string{"db", "web"}, keys)
+
+	// the instance is freed in the terminal state
+	require.NoError(t, set.Signal("web", exit))
+	for i := 0; i < 100 && machines.Count() > 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(t, 1, machines.Count())
+	require.Equal(t, []string{"db"}, set.Keys())
+
+	// and the next use of the key starts over
+	restarted, err := set.Get("web")
+	require.NoError(t, err)
+	require.NotEqual(t, web.ID(), restarted.ID())
+	require.Equal(t, running, restarted.State())
+
+	require.NoError(t, set.Delete("db"))
+	require.Equal(t, []string{"web"}, set.Keys())
+	require.Equal(t, 1, machines.Count())
+
+	// a pinned instance is kept in the terminal state, and not replaced
+	restarted.Pin()
+	require.NoError(t, set.Signal("web", exit))
+	for i := 0; i < 100 && restarted.State() != exited; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, err = set.Get("web")
+	require.Equal(t, ErrPinned(restarted.ID()), err)
+	require.Equal(t, ErrPinned(restarted.ID()), set.Delete("web"))
+	require.Equal(t, []string{"web"}, set.Keys())
+	require.Equal(t, 1, machines.Count())
+
+	restarted.Unpin()
+	replaced, err := set.Get("web")
+	require.NoError(t, err)
+	require.NotEqual(t, restarted.ID(), replaced.ID())
+	require.Equal(t, 1, machines.Count())
+}
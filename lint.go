@@ -0,0 +1,232 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Severity is the severity of a lint finding
+type Severity int
+
+const (
+	// LintOff disables a rule
+	LintOff Severity = iota
+
+	// LintWarning reports a finding that is likely a mistake
+	LintWarning
+
+	// LintError reports a finding that makes the spec invalid or unsafe to run
+	LintError
+)
+
+// String returns the name of the severity
+func (s Severity) String() string {
+	switch s {
+	case LintOff:
+		return "off"
+	case LintWarning:
+		return "warning"
+	case LintError:
+		return "error"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// LintRule identifies a rule checked by the Linter
+type LintRule string
+
+const (
+	// RuleActionNotInTransitions is an action defined for a signal that's not in the state's transitions.
+	// Define fails on these.
+	RuleActionNotInTransitions LintRule = "action-not-in-transitions"
+
+	// RuleTTLSelfLoop is a TTL raising a signal that transitions back to the same state, with no visit
+	// limit to break the loop.
+	RuleTTLSelfLoop LintRule = "ttl-self-loop"
+
+	// RuleVisitLimitBeforeFlap is a visit limit that is reached no later than the flap limit of a pair
+	// of states it's part of, so the flap limit never triggers.
+	RuleVisitLimitBeforeFlap LintRule = "visit-limit-before-flap"
+
+	// RuleUnnamedState is a state without a friendly name in Options.StateNames
+	RuleUnnamedState LintRule = "unnamed-state"
+
+	// RuleUnreachableTerminal is a state with no transitions out that no other state transitions into.
+	RuleUnreachableTerminal LintRule = "unreachable-terminal"
+
+	// RuleDeadErrorRoute is a signal in Errors that's never followed: the transition on the signal has no
+	// action to fail, or there's no transition on the signal.  With State.ErrorsFirst, it's a transition on
+	// a signal that's also in Errors, which is never taken.
+	RuleDeadErrorRoute LintRule = "dead-error-route"
+)
+
+// DefaultSeverity is the severity of each rule unless overridden in the Linter
+var DefaultSeverity = map[LintRule]Severity{
+	RuleActionNotInTransitions: LintError,
+	RuleTTLSelfLoop:            LintWarning,
+	RuleVisitLimitBeforeFlap:   LintWarning,
+	RuleUnnamedState:           LintWarning,
+	RuleUnreachableTerminal:    LintWarning,
+	RuleDeadErrorRoute:         LintWarning,
+}
+
+// Finding is a problem found by the Linter
+type Finding struct {
+	Rule     LintRule
+	Severity Severity
+	State    Index
+	Message  string
+}
+
+func (f Finding) Error() string {
+	return fmt.Sprintf("%v: %v: %v", f.Severity, f.Rule, f.Message)
+}
+
+func (f Finding) Is(target error) bool {
+	return target == SpecError
+}
+
+// Findings is a list of findings
+type Findings []Finding
+
+// Errors returns the findings of severity LintError
+func (f Findings) Errors() Findings {
+	out := Findings{}
+	for _, finding := range f {
+		if finding.Severity == LintError {
+			out = append(out, finding)
+		}
+	}
+	return out
+}
+
+// Linter checks the states of a spec for likely mistakes.  Options provides the names of states and
+// signals and the flap limits, as given to Run.  Severity overrides DefaultSeverity of the rules.
+type Linter struct {
+	Options
+	Severity map[LintRule]Severity
+}
+
+// Lint checks the states and returns the findings, ordered by state.  Unlike Define, it does not stop
+// at the first problem.
+func (l Linter) Lint(state State, more ...State) Findings {
+	states := map[Index]State{state.Index: state}
+	for _, st := range more {
+		states[st.Index] = st
+	}
+
+	s := newSpec()
+	s.stateNames = l.StateNames
+	s.signalNames = l.SignalNames
+
+	indexes := []Index{}
+	for index := range states {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	// states that are transitioned into from another state
+	reached := map[Index]bool{}
+	for _, st := range states {
+		for _, next := range st.nextStates() {
+			if next != st.Index {
+				reached[next] = true
+			}
+		}
+	}
+
+	findings := Findings{}
+	report := func(rule LintRule, state Index, format string, args ...interface{}) {
+		severity, has := l.Severity[rule]
+		if !has {
+			severity = DefaultSeverity[rule]
+		}
+		if severity == LintOff {
+			return
+		}
+		findings = append(findings, Finding{
+			Rule:     rule,
+			Severity: severity,
+			State:    state,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, index := range indexes {
+		st := states[index]
+		name := s.stateName(index)
+
+		signals := []Signal{}
+		for signal := range st.Actions {
+			signals = append(signals, signal)
+		}
+		for signal := range st.ActionNames {
+			if _, has := st.Actions[signal]; !has {
+				signals = append(signals, signal)
+			}
+		}
+		sort.Slice(signals, func(i, j int) bool { return signals[i] < signals[j] })
+		for _, signal := range signals {
+			if _, has := st.Transitions[signal]; !has {
+				report(RuleActionNotInTransitions, index,
+					"state %v has an action for signal %v that's not in its transitions", name, s.signalName(signal))
+			}
+		}
+
+		if st.TTL.TTL > 0 && st.Visit.Value == 0 {
+			if next, has := st.Transitions[st.TTL.Raise]; has && next == index {
+				report(RuleTTLSelfLoop, index,
+					"state %v raises %v on expiry which transitions back to %v, with no visit limit", name,
+					s.signalName(st.TTL.Raise), name)
+			}
+		}
+
+		if st.Visit.Value > 0 {
+			for _, flap := range l.Limits {
+				if flap.States[0] != index && flap.States[1] != index {
+					continue
+				}
+				if st.Visit.Value <= flap.Count {
+					report(RuleVisitLimitBeforeFlap, index,
+						"visit limit %d of state %v is reached before the flap limit %d between %v and %v",
+						st.Visit.Value, name, flap.Count, s.stateName(flap.States[0]), s.stateName(flap.States[1]))
+				}
+			}
+		}
+
+		errors := []Signal{}
+		for signal := range st.Errors {
+			errors = append(errors, signal)
+		}
+		sort.Slice(errors, func(i, j int) bool { return errors[i] < errors[j] })
+		for _, signal := range errors {
+			_, transition := st.Transitions[signal]
+			_, action := st.Actions[signal]
+			_, contextAction := st.ContextActions[signal]
+			_, namedAction := st.ActionNames[signal]
+			switch {
+			case st.ErrorsFirst && transition:
+				report(RuleDeadErrorRoute, index,
+					"state %v follows errors first, so its transition on signal %v is never taken", name,
+					s.signalName(signal))
+			case st.ErrorsFirst:
+			case !transition:
+				report(RuleDeadErrorRoute, index,
+					"state %v has an error for signal %v that's not in its transitions", name, s.signalName(signal))
+			case !action && !contextAction && !namedAction:
+				report(RuleDeadErrorRoute, index,
+					"state %v has an error for signal %v whose transition has no action to fail", name,
+					s.signalName(signal))
+			}
+		}
+
+		if _, has := l.StateNames[index]; !has {
+			report(RuleUnnamedState, index, "state %v has no name", index)
+		}
+
+		if len(st.Transitions) == 0 && len(st.Errors) == 0 && !reached[index] && len(states) > 1 {
+			report(RuleUnreachableTerminal, index, "terminal state %v is not reachable from any other state", name)
+		}
+	}
+	return findings
+}
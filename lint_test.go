@@ -0,0 +1,103 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+		retrying
+		gone
+		orphan
+	)
+
+	const (
+		start Signal = iota
+		fail
+		retry
+		stop
+	)
+
+	noop := func(FSM) error { return nil }
+
+	states := []State{
+		{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			Actions: map[Signal]Action{
+				start: noop,
+				stop:  noop,
+			},
+		},
+		{
+			Index: running,
+			Transitions: map[Signal]Index{
+				fail: retrying,
+				stop: gone,
+			},
+			Visit: Limit{2, stop},
+		},
+		{
+			Index: retrying,
+			Transitions: map[Signal]Index{
+				retry: retrying,
+				start: running,
+			},
+			TTL: Expiry{5, retry},
+		},
+		{
+			Index: gone,
+		},
+		{
+			Index: orphan,
+		},
+	}
+
+	linter := Linter{
+		Options: Options{
+			StateNames: map[Index]string{
+				pending:  "pending",
+				running:  "running",
+				retrying: "retrying",
+				gone:     "gone",
+			},
+			Limits: []Flap{
+				{States: [2]Index{running, retrying}, Count: 3, Raise: stop},
+			},
+		},
+	}
+
+	findings := linter.Lint(states[0], states[1:]...)
+	rules := []LintRule{}
+	for _, f := range findings {
+		rules = append(rules, f.Rule)
+	}
+	require.Equal(t, []LintRule{
+		RuleActionNotInTransitions,
+		RuleVisitLimitBeforeFlap,
+		RuleTTLSelfLoop,
+		RuleUnnamedState,
+		RuleUnreachableTerminal,
+	}, rules)
+	require.Equal(t, pending, findings[0].State)
+	require.Equal(t, orphan, findings[4].State)
+	require.Len(t, findings.Errors(), 1)
+
+	linter.Severity = map[LintRule]Severity{
+		RuleActionNotInTransitions: LintWarning,
+		RuleUnnamedState:           LintOff,
+		RuleTTLSelfLoop:            LintError,
+	}
+	findings = linter.Lint(states[0], states[1:]...)
+	require.Len(t, findings, 4)
+	require.Equal(t, LintWarning, findings[0].Severity)
+	require.Equal(t, Findings{findings[2]}, findings.Errors())
+	require.Equal(t, RuleTTLSelfLoop, findings[2].Rule)
+}
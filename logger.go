@@ -5,3 +5,30 @@ type nilLogger struct{}
 func (l *nilLogger) Debug(m string, args ...interface{}) {}
 func (l *nilLogger) Error(m string, args ...interface{}) {}
 func (l *nilLogger) Info(m string, args ...interface{})  {}
+
+// withFields appends the fields of the instance, from Options.Fields, to the key value pairs of a log line
+func (g *runner) withFields(f FSM, kv ...interface{}) []interface{} {
+	if g.options.Fields == nil {
+		return kv
+	}
+	return append(kv, g.options.Fields(f)...)
+}
+
+// debug logs the debug line with the fields of the instance, which are only computed if debug lines are logged
+func (g *runner) debug(m string, f FSM, kv ...interface{}) {
+	if !g.debugging() {
+		return
+	}
+	g.log.Debug(m, g.withFields(f, kv...)...)
+}
+
+// debugging returns false if the logger doesn't log debug lines
+func (g *runner) debugging() bool {
+	switch logger := g.log.(type) {
+	case *nilLogger:
+		return false
+	case LevelLogger:
+		return logger.DebugEnabled()
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLogger struct {
+	lines map[string][]interface{}
+	lock  sync.Mutex
+}
+
+func (l *recordingLogger) record(m string, args ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lines[m] = args
+}
+
+func (l *recordingLogger) line(m string) []interface{} {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.lines[m]
+}
+
+func (l *recordingLogger) Debug(m string, args ...interface{}) { l.record(m, args...) }
+func (l *recordingLogger) Error(m string, args ...interface{}) { l.record(m, args...) }
+func (l *recordingLogger) Info(m string, args ...interface{})  { l.record(m, args...) }
+
+func TestFields(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+		},
+		State{
+			Index: running,
+		},
+	)
+	require.NoError(t, err)
+
+	logger := &recordingLogger{lines: map[string][]interface{}{}}
+	options := DefaultOptions()
+	options.Logger = logger
+	options.NewData = func(id ID) interface{} { return "http://target" }
+	options.Fields = func(f FSM) []interface{} {
+		return []interface{}{"url", f.Data(), "state", f.State()}
+	}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	transitions, cancel := machines.Watch(1)
+	defer cancel()
+
+	instance, err := machines.New(pending)
+	require.NoError(t, err)
+	require.NoError(t, instance.Signal(start))
+
+	require.Equal(t, []interface{}{"url", "http://target", "state", running}, (<-transitions).Fields)
+
+	line := logger.line("Transition")
+	require.Equal(t, []interface{}{"url", "http://target", "state", pending}, line[len(line)-4:])
+	line = logger.line("Signal")
+	require.Equal(t, []interface{}{"url", "http://target", "state", pending}, line[len(line)-4:])
+}
+
+type quietLogger struct {
+	recordingLogger
+}
+
+func (l *quietLogger) DebugEnabled() bool { return false }
+
+func TestFieldsNotDebugging(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+		},
+		State{
+			Index: running,
+		},
+	)
+	require.NoError(t, err)
+
+	logger := &quietLogger{recordingLogger{lines: map[string][]interface{}{}}}
+	called := make(chan FSM, 10)
+	options := DefaultOptions()
+	options.Logger = logger
+	options.Fields = func(f FSM) []interface{} {
+		called <- f
+		return []interface{}{"state", f.State()}
+	}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	transitions, cancel := machines.Watch(1)
+	defer cancel()
+
+	instance, err := machines.New(pending)
+	require.NoError(t, err)
+	require.NoError(t, instance.Signal(start))
+
+	// only for the transition
+	require.Equal(t, []interface{}{"state", running}, (<-transitions).Fields)
+	require.Len(t, called, 1)
+}
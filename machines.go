@@ -2,6 +2,9 @@ package fsm // import "github.com/orkestr8/fsm"
 
 import (
 	"fmt"
+	"io"
+	"math/rand"
+	"sync"
 )
 
 type machines struct {
@@ -9,16 +12,55 @@ type machines struct {
 	Options
 	States []State
 
-	clock  *Clock
-	runner *runner
+	specLock sync.RWMutex // guards spec and States, swapped by Reload
+
+	clock   *Clock
+	runner  *runner
+	sources sources
+	vetoers []Vetoer
+}
+
+// current returns the spec, e.g. for the methods that describe it, which may run concurrently with Reload
+func (m *machines) current() *spec {
+	m.specLock.RLock()
+	defer m.specLock.RUnlock()
+	return m.spec
+}
+
+func (m *machines) Validate() []Issue {
+	return m.current().Validate()
+}
+
+func (m *machines) SetAction(state Index, signal Signal, action Action) error {
+	m.specLock.Lock()
+	defer m.specLock.Unlock()
+	return m.spec.SetAction(state, signal, action)
 }
 
 func (m *machines) New(initial Index) (FSM, error) {
 	return m.runner.alloc(initial)
 }
 
+func (m *machines) NewAt(initial Index, elapsed Tick) (fsm FSM, err error) {
+	m.runner.do(func(g *runner) {
+		fsm, err = g.allocateAt(initial, elapsed)
+	})
+	return
+}
+
+func (m *machines) Free(id ID) (err error) {
+	m.runner.do(func(g *runner) {
+		err = g.free(id)
+	})
+	return
+}
+
 func (m *machines) Run(clock *Clock, options Options) error {
 
+	if err := m.checkSubs(m.spec); err != nil {
+		return err
+	}
+
 	m.Options = options
 
 	m.clock = clock
@@ -27,10 +69,12 @@ func (m *machines) Run(clock *Clock, options Options) error {
 		return err
 	}
 	m.runner = runner
+	m.runner.vetoers = m.vetoers
 	m.runner.run()
 	m.runner.running = true
 
 	m.clock.Start()
+	m.sources.run(m.runner)
 	return nil
 }
 
@@ -39,9 +83,244 @@ func (m *machines) Done() {
 		panic("Programming error. Must call Run() before Done()")
 	}
 
+	m.sources.stop()
 	m.runner.Stop()
 }
 
+func (m *machines) AddSource(source Source) {
+	m.sources.add(source, m.runner) // nil until running, when the sources are only registered
+}
+
+func (m *machines) AddVetoer(vetoer Vetoer) {
+	if m.runner == nil {
+		m.vetoers = append(m.vetoers, vetoer)
+		return
+	}
+	m.runner.do(func(g *runner) {
+		g.vetoers = append(g.vetoers, vetoer)
+	})
+}
+
+func (m *machines) ForEach(f func(FSM) bool) {
+	m.runner.do(func(g *runner) {
+		g.forEach(func(i *instance) bool {
+			return f(snapshot{i})
+		})
+	})
+}
+
+func (m *machines) Count() (count int) {
+	m.runner.do(func(g *runner) {
+		count = len(g.members)
+	})
+	return
+}
+
+func (m *machines) CountIn(states ...Index) (count int) {
+	m.runner.do(func(g *runner) {
+		count = g.count(states...)
+	})
+	return
+}
+
+func (m *machines) AnyIn(states ...Index) bool {
+	return m.CountIn(states...) > 0
+}
+
+func (m *machines) Snapshot() (snapshot Snapshot, err error) {
+	m.runner.do(func(g *runner) {
+		snapshot, err = g.takeSnapshot()
+	})
+	return
+}
+
+func (m *machines) Restore(snapshot Snapshot) (err error) {
+	m.runner.do(func(g *runner) {
+		g.warmup = g.now + Time(g.options.WarmupTicks)
+		err = g.restore(snapshot)
+	})
+	return
+}
+
+func (m *machines) Seed(items []SeedItem) (fsms []FSM, err error) {
+	m.runner.do(func(g *runner) {
+		fsms, err = g.seed(items)
+	})
+	return
+}
+
+func (m *machines) SignalBatch(batch []SignalRequest) []error {
+	var instances []*instance
+	m.runner.do(func(g *runner) {
+		instances = g.lookup(batch)
+	})
+	return m.runner.signalBatch(batch, instances)
+}
+
+func (m *machines) Recover() error {
+	if m.Options.WAL == nil {
+		return nil
+	}
+	records, err := m.Options.WAL.Records()
+	if err != nil {
+		return err
+	}
+	m.runner.do(func(g *runner) {
+		records = g.options.Migration.records(records)
+		if incompatible := g.incompatible(Snapshot{}, records); len(incompatible) > 0 {
+			err = incompatible
+			return
+		}
+		g.warmup = g.now + Time(g.options.WarmupTicks)
+		err = g.load(g.replay(records)) // the records are already migrated
+	})
+	return err
+}
+
+func (m *machines) Ready(pred func(counts map[Index]int) bool) (ready bool) {
+	m.runner.do(func(g *runner) {
+		ready = pred(g.counts())
+	})
+	return
+}
+
+// AllIn returns a predicate for Ready that is true when every instance is in one of the states.
+func AllIn(states ...Index) func(counts map[Index]int) bool {
+	unique := map[Index]bool{}
+	for _, state := range states {
+		unique[state] = true // a state given twice counts once
+	}
+	return func(counts map[Index]int) bool {
+		in := 0
+		for state := range unique {
+			in += counts[state]
+		}
+		total := 0
+		for _, count := range counts {
+			total += count
+		}
+		return in == total
+	}
+}
+
+func (m *machines) SetIgnoreUndefined(ignore IgnoreUndefined) (previous IgnoreUndefined) {
+	// applied between transactions so that a transaction sees the same options throughout
+	m.runner.do(func(g *runner) {
+		g.errorLock.Lock()
+		defer g.errorLock.Unlock()
+		previous, g.ignoreUndefined = g.ignoreUndefined, ignore
+	})
+	return
+}
+
+func (m *machines) SetBlackouts(blackouts []Blackout) {
+	blackouts = append([]Blackout(nil), blackouts...)
+	m.runner.do(func(g *runner) {
+		g.blackouts = blackouts
+	})
+}
+
+func (m *machines) SubscribeErrors(filter ErrorFilter) (errors <-chan ErrorEvent, cancel func()) {
+	errors, id := m.runner.subscribeErrors(filter)
+	cancel = func() {
+		m.runner.unsubscribeErrors(id)
+	}
+	return
+}
+
+func (m *machines) Errors() <-chan error {
+	return m.runner.Errors()
+}
+
+func (m *machines) Watch(buffer int) (transitions <-chan Transition, cancel func()) {
+	var id int
+	m.runner.do(func(g *runner) {
+		transitions, id = g.watch(buffer)
+	})
+	cancel = func() {
+		m.runner.doRunning(func(g *runner) {
+			g.unwatch(id)
+		})
+	}
+	return
+}
+
+// watchTerminal calls the hook with the transitions to terminal states, none dropped as by Watch.  The hook is
+// called in the transaction loop and must not block.
+func (m *machines) watchTerminal(hook func(Transition)) (cancel func()) {
+	var id int
+	m.runner.do(func(g *runner) {
+		id = g.watchTerminal(hook)
+	})
+	return func() {
+		m.runner.doRunning(func(g *runner) {
+			g.unwatch(id)
+		})
+	}
+}
+
+func (m *machines) Stats() (stats Stats) {
+	m.runner.do(func(g *runner) {
+		stats = g.stats()
+	})
+	return
+}
+
+func (m *machines) PendingDeadlines() (pending []DeadlineInfo) {
+	m.runner.do(func(g *runner) {
+		pending = g.pendingDeadlines()
+	})
+	return
+}
+
+func (m *machines) FlapHistory(id ID) (history FlapHistory, err error) {
+	m.runner.do(func(g *runner) {
+		history, err = g.flapHistory(id)
+	})
+	return
+}
+
+func (m *machines) GraphStats() GraphStats {
+	return m.current().graphStats()
+}
+
+func (m *machines) Simulate(sim Simulation) (SimulationReport, error) {
+	if sim.Source == nil && m.runner != nil && m.Options.Random != nil {
+		// seeded from Options.Random in the transaction loop, which is the only user of the source
+		m.runner.do(func(g *runner) {
+			sim.Source = rand.NewSource(g.random.Int63())
+		})
+	}
+	return m.current().simulate(sim)
+}
+
+func (m *machines) WriteTable(w io.Writer) error {
+	return m.current().writeTable(w)
+}
+
+func (m *machines) WriteDOT(w io.Writer) error {
+	return m.current().writeDOT(w)
+}
+
+func (m *machines) WriteTestSkeleton(w io.Writer, pkg string) error {
+	return m.current().writeTestSkeleton(w, pkg)
+}
+
+func (m *machines) Meta(index Index) map[string]string {
+	return m.current().meta(index)
+}
+
+func (m *machines) Terminal(index Index) bool {
+	return m.current().terminal(index)
+}
+
+func (m *machines) DeadLetters() (letters []DeadLetter) {
+	m.runner.do(func(g *runner) {
+		letters = g.drainDeadLetters()
+	})
+	return
+}
+
 type stringer string
 
 func (s stringer) GoString() string {
@@ -49,9 +328,9 @@ func (s stringer) GoString() string {
 }
 
 func (m *machines) StateStringer(i Index) fmt.GoStringer {
-	return stringer(m.spec.stateName(i))
+	return stringer(m.current().stateName(i))
 }
 
 func (m *machines) SignalStringer(s Signal) fmt.GoStringer {
-	return stringer(m.spec.signalName(s))
+	return stringer(m.current().signalName(s))
 }
@@ -0,0 +1,389 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestForEach(t *testing.T) {
+
+	const (
+		on Index = iota
+		off
+	)
+
+	const (
+		turnOn Signal = iota
+		turnOff
+	)
+
+	machines, err := define(
+		State{
+			Index: on,
+			Transitions: map[Signal]Index{
+				turnOff: off,
+			},
+		},
+		State{
+			Index: off,
+			Transitions: map[Signal]Index{
+				turnOn: on,
+			},
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	for i := 0; i < 10; i++ {
+		instance, err := machines.New(off)
+		require.NoError(t, err)
+		if i%2 == 0 {
+			require.NoError(t, instance.Signal(turnOn))
+		}
+	}
+
+	ids := []ID{}
+	states := map[Index]int{}
+	machines.ForEach(func(f FSM) bool {
+		ids = append(ids, f.ID())
+		states[f.State()]++
+		return true
+	})
+	require.Equal(t, []ID{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, ids)
+	require.Equal(t, map[Index]int{on: 5, off: 5}, states)
+
+	// short-circuit
+	visited := 0
+	machines.ForEach(func(f FSM) bool {
+		visited++
+		return f.ID() < 2
+	})
+	require.Equal(t, 3, visited)
+}
+
+func TestCount(t *testing.T) {
+
+	const (
+		up Index = iota
+		down
+		gone
+	)
+
+	const (
+		shutdown Signal = iota
+		startup
+	)
+
+	machines, err := define(
+		State{
+			Index: up,
+			Transitions: map[Signal]Index{
+				shutdown: down,
+			},
+		},
+		State{
+			Index: down,
+			Transitions: map[Signal]Index{
+				startup: up,
+			},
+		},
+		State{
+			Index: gone,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	require.Equal(t, 0, machines.Count())
+	require.False(t, machines.AnyIn(up, down))
+
+	instances := []FSM{}
+	for i := 0; i < 5; i++ {
+		instance, err := machines.New(up)
+		require.NoError(t, err)
+		instances = append(instances, instance)
+	}
+
+	require.Equal(t, 5, machines.Count())
+	require.Equal(t, 5, machines.CountIn(up))
+	require.Equal(t, 0, machines.CountIn(down))
+
+	require.NoError(t, instances[0].Signal(shutdown))
+	require.NoError(t, instances[1].Signal(shutdown))
+
+	require.Equal(t, 3, machines.CountIn(up))
+	require.Equal(t, 2, machines.CountIn(down))
+	require.Equal(t, 5, machines.CountIn(up, down))
+	require.True(t, machines.AnyIn(down))
+	require.False(t, machines.AnyIn(gone))
+
+	require.NoError(t, instances[1].Signal(startup))
+	require.Equal(t, 4, machines.CountIn(up))
+	require.Equal(t, 1, machines.CountIn(down))
+}
+
+func TestPendingDeadlines(t *testing.T) {
+
+	const (
+		waiting Index = iota
+		starting
+		running
+	)
+
+	const (
+		start Signal = iota
+		timeout
+	)
+
+	machines, err := define(
+		State{
+			Index: waiting,
+			Transitions: map[Signal]Index{
+				start: starting,
+			},
+			TTL: Expiry{5, start},
+		},
+		State{
+			Index: starting,
+			Transitions: map[Signal]Index{
+				timeout: running,
+			},
+			TTL: Expiry{2, timeout},
+		},
+		State{
+			Index: running,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	a, err := machines.New(waiting)
+	require.NoError(t, err)
+	b, err := machines.New(starting)
+	require.NoError(t, err)
+	_, err = machines.New(running)
+	require.NoError(t, err)
+
+	require.Equal(t, []DeadlineInfo{
+		{ID: b.ID(), State: starting, Due: 2, Raise: timeout},
+		{ID: a.ID(), State: waiting, Due: 5, Raise: start},
+	}, machines.PendingDeadlines())
+}
+
+func TestFree(t *testing.T) {
+
+	const (
+		waiting Index = iota
+		running
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: waiting,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			TTL: Expiry{5, start},
+		},
+		State{
+			Index: running,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	a, err := machines.New(waiting)
+	require.NoError(t, err)
+	b, err := machines.New(waiting)
+	require.NoError(t, err)
+
+	require.NoError(t, machines.Free(a.ID()))
+	require.Equal(t, ErrUnknownFSM(a.ID()), machines.Free(a.ID()))
+
+	require.Equal(t, 1, machines.Count())
+	require.Equal(t, 1, machines.CountIn(waiting))
+	require.Equal(t, []DeadlineInfo{
+		{ID: b.ID(), State: waiting, Due: 5, Raise: start},
+	}, machines.PendingDeadlines())
+
+	// the handle of a freed instance is no longer usable
+	require.Equal(t, ErrFreed(a.ID()), a.Signal(start))
+	require.Equal(t, ErrFreed(a.ID()), a.SignalFrom(OriginSource, start))
+	require.Equal(t, NoState, a.State())
+	require.Nil(t, a.Data())
+	ok, next, reason := a.Check(start)
+	require.False(t, ok)
+	require.Equal(t, NoState, next)
+	require.Equal(t, ErrFreed(a.ID()), reason)
+	require.Equal(t, waiting, b.State())
+}
+
+func TestPin(t *testing.T) {
+
+	const (
+		running Index = iota
+		terminated
+	)
+
+	const (
+		terminate Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				terminate: terminated,
+			},
+		},
+		State{
+			Index: terminated,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	a, err := machines.New(running)
+	require.NoError(t, err)
+	require.NoError(t, a.Signal(terminate, "crash dump"))
+	require.Equal(t, terminated, a.State())
+
+	a.Pin()
+	require.True(t, a.Pinned())
+	require.Equal(t, ErrPinned(a.ID()), machines.Free(a.ID()))
+	require.Equal(t, 1, machines.CountIn(terminated))
+	require.Equal(t, []interface{}{"crash dump"}, a.Data())
+
+	a.Unpin()
+	require.False(t, a.Pinned())
+	require.NoError(t, machines.Free(a.ID()))
+	require.Equal(t, 0, machines.Count())
+}
+
+func TestReady(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+		failed
+	)
+
+	const (
+		start Signal = iota
+		fail
+	)
+
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+		},
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				fail: failed,
+			},
+		},
+		State{
+			Index: failed,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	require.True(t, machines.Ready(AllIn(running)))
+
+	a, err := machines.New(pending)
+	require.NoError(t, err)
+	b, err := machines.New(pending)
+	require.NoError(t, err)
+	require.False(t, machines.Ready(AllIn(running)))
+	require.True(t, machines.Ready(AllIn(pending, running)))
+
+	require.NoError(t, a.Signal(start))
+	require.NoError(t, b.Signal(start))
+	require.True(t, machines.Ready(AllIn(running)))
+
+	require.NoError(t, b.Signal(fail))
+	require.False(t, machines.Ready(AllIn(running)))
+	require.False(t, machines.Ready(AllIn(running, running)))
+	require.True(t, machines.Ready(func(counts map[Index]int) bool {
+		return counts[running] >= 1 && counts[failed] <= 1
+	}))
+	require.Equal(t, true, machines.Ready(func(counts map[Index]int) bool {
+		return len(counts) == 2 && counts[pending] == 0
+	}))
+}
+
+func TestSetIgnoreUndefined(t *testing.T) {
+
+	const (
+		running Index = iota
+		stopped
+	)
+
+	const (
+		stop Signal = iota
+		start
+	)
+
+	machines, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				stop: stopped,
+			},
+		},
+		State{
+			Index: stopped,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	errs := make(chan error, 10)
+	go func() {
+		for err := range machines.Errors() {
+			errs <- err
+		}
+	}()
+
+	a, err := machines.New(running)
+	require.NoError(t, err)
+
+	require.NoError(t, a.Signal(start))
+	require.Equal(t, running, a.State())
+	require.Len(t, errs, 0)
+
+	previous := machines.SetIgnoreUndefined(IgnoreUndefined{States: true, Signals: true})
+	require.Equal(t, IgnoreUndefined{States: true, Transitions: true, Signals: true}, previous)
+
+	// the send of errors doesn't block, so retry until the reader is ready
+	var reported error
+	for i := 0; i < 10 && reported == nil; i++ {
+		require.NoError(t, a.Signal(start))
+		select {
+		case reported = <-errs:
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	require.Equal(t, ErrUnknownTransition{Signal: start, State: running}, reported)
+}
@@ -0,0 +1,116 @@
+// Package metrics exports the statistics of fsm.Machines in the Prometheus text format, with the
+// friendly names of the states and signals as labels.
+package metrics // import "github.com/orkestr8/fsm/metrics"
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/orkestr8/fsm"
+)
+
+const (
+	// DefaultNamespace is the prefix of the metric names
+	DefaultNamespace = "fsm"
+
+	// Other is the label value of the series that the rare states or signals are grouped under
+	Other = "other"
+)
+
+// Exporter writes the metrics of the machines.  Each state and signal is a series unless capped: with
+// MaxStates or MaxSignals set, only that many series with the largest values are exported and the rest
+// are summed under the label "other".  Which series are grouped can change between scrapes.
+type Exporter struct {
+	Machines fsm.Machines
+
+	// Namespace is the prefix of the metric names.  Defaults to DefaultNamespace.
+	Namespace string
+
+	// MaxStates is the maximum number of series by state, not counting "other".  0 for no limit.
+	MaxStates int
+
+	// MaxSignals is the maximum number of series by signal, not counting "other".  0 for no limit.
+	MaxSignals int
+}
+
+type series struct {
+	label string
+	value float64
+}
+
+// capped returns the series sorted by label, with all but the max largest values summed under Other.
+func capped(all []series, max int) []series {
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].value != all[j].value {
+			return all[i].value > all[j].value
+		}
+		return all[i].label < all[j].label
+	})
+	if max > 0 && len(all) > max {
+		other := series{label: Other}
+		for _, s := range all[max:] {
+			other.value += s.value
+		}
+		all = append(all[:max:max], other)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].label < all[j].label })
+	return all
+}
+
+// Write writes the metrics in the Prometheus text format
+func (e Exporter) Write(w io.Writer) error {
+	namespace := e.Namespace
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	stats := e.Machines.Stats()
+
+	states := []series{}
+	for index, count := range stats.Instances {
+		states = append(states, series{label: e.Machines.StateStringer(index).GoString(), value: float64(count)})
+	}
+	signals := []series{}
+	for signal, count := range stats.Transitions {
+		signals = append(signals, series{label: e.Machines.SignalStringer(signal).GoString(), value: float64(count)})
+	}
+
+	out := bufio.NewWriter(w)
+	metric := func(name, kind, help, label string, values []series) {
+		name = namespace + "_" + name
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, s := range values {
+			if label == "" {
+				fmt.Fprintf(out, "%s %v\n", name, s.value)
+				continue
+			}
+			fmt.Fprintf(out, "%s{%s=\"%s\"} %v\n", name, label, escape(s.label), s.value)
+		}
+	}
+	metric("instances", "gauge", "Number of instances in each state.", "state",
+		capped(states, e.MaxStates))
+	metric("transitions_total", "counter", "Number of committed transitions on each signal.", "signal",
+		capped(signals, e.MaxSignals))
+	metric("ticks_total", "counter", "Number of clock ticks processed.", "",
+		[]series{{value: float64(stats.Ticks)}})
+	metric("tick_lag", "gauge", "Number of clock ticks received but not yet processed.", "",
+		[]series{{value: float64(stats.TickLag)}})
+	return out.Flush()
+}
+
+// ServeHTTP implements http.Handler
+func (e Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := e.Write(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(label string) string {
+	return escaper.Replace(label)
+}
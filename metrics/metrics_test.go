@@ -0,0 +1,94 @@
+package metrics // import "github.com/orkestr8/fsm/metrics"
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/orkestr8/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExporter(t *testing.T) {
+
+	const (
+		pending fsm.Index = iota
+		running
+		stopped
+		failed
+	)
+
+	const (
+		start fsm.Signal = iota
+		stop
+		fail
+	)
+
+	machines, err := fsm.Define(
+		fsm.State{
+			Index: pending,
+			Transitions: map[fsm.Signal]fsm.Index{
+				start: running,
+				fail:  failed,
+			},
+		},
+		fsm.State{
+			Index: running,
+			Transitions: map[fsm.Signal]fsm.Index{
+				stop: stopped,
+			},
+		},
+		fsm.State{
+			Index: stopped,
+		},
+		fsm.State{
+			Index: failed,
+		},
+	)
+	require.NoError(t, err)
+
+	options := fsm.DefaultOptions()
+	options.StateNames = map[fsm.Index]string{pending: "pending", running: "running", stopped: "stopped", failed: "failed"}
+	options.SignalNames = map[fsm.Signal]string{start: "start", stop: "stop", fail: `fail "hard"`}
+	require.NoError(t, machines.Run(fsm.NewClock(), options))
+	defer machines.Done()
+
+	for i, signals := range [][]fsm.Signal{{start}, {start}, {start, stop}, {fail}, {}, {}, {}} {
+		f, err := machines.New(pending)
+		require.NoError(t, err)
+		if len(signals) > 0 {
+			require.NoError(t, f.Sequence(signals...), "instance %d", i)
+		}
+	}
+
+	buff := &bytes.Buffer{}
+	require.NoError(t, Exporter{Machines: machines}.Write(buff))
+	require.Equal(t, `# HELP fsm_instances Number of instances in each state.
+# TYPE fsm_instances gauge
+fsm_instances{state="failed"} 1
+fsm_instances{state="pending"} 3
+fsm_instances{state="running"} 2
+fsm_instances{state="stopped"} 1
+# HELP fsm_transitions_total Number of committed transitions on each signal.
+# TYPE fsm_transitions_total counter
+fsm_transitions_total{signal="fail \"hard\""} 1
+fsm_transitions_total{signal="start"} 3
+fsm_transitions_total{signal="stop"} 1
+# HELP fsm_ticks_total Number of clock ticks processed.
+# TYPE fsm_ticks_total counter
+fsm_ticks_total 0
+# HELP fsm_tick_lag Number of clock ticks received but not yet processed.
+# TYPE fsm_tick_lag gauge
+fsm_tick_lag 0
+`, buff.String())
+
+	recorder := httptest.NewRecorder()
+	Exporter{Machines: machines, Namespace: "vm", MaxStates: 2, MaxSignals: 1}.ServeHTTP(recorder, nil)
+	require.Contains(t, recorder.Body.String(), `vm_instances{state="other"} 2
+vm_instances{state="pending"} 3
+vm_instances{state="running"} 2
+`)
+	require.Contains(t, recorder.Body.String(), `vm_transitions_total{signal="other"} 2
+vm_transitions_total{signal="start"} 3
+`)
+}
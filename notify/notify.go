@@ -0,0 +1,173 @@
+// Package notify provides fsm.Notifier implementations that send alerts to Slack and PagerDuty.
+package notify // import "github.com/orkestr8/fsm/notify"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+
+	"github.com/orkestr8/fsm"
+)
+
+// DefaultTemplate is the default message template
+const DefaultTemplate = `instance {{.ID}}{{range $k, $v := .Labels}} {{$k}}={{$v}}{{end}}: ` +
+	`{{.Names.From}} -[{{.Names.Signal}}]-> {{.Names.To}}{{if .Err}} ({{.Err}}){{end}}`
+
+// Message is the data of the message templates.  The labels are those of the instance of the transition.
+type Message struct {
+	fsm.Transition
+}
+
+// Config is common to all the notifiers
+type Config struct {
+
+	// Template is a text/template executed with a Message.  Defaults to DefaultTemplate.
+	Template string
+
+	// Client is the http client to use.  Defaults to http.DefaultClient.
+	Client *http.Client
+
+	tmpl *template.Template // parsed by the constructors of the notifiers
+}
+
+// parse parses the template
+func (c *Config) parse() error {
+	text := c.Template
+	if text == "" {
+		text = DefaultTemplate
+	}
+	tmpl, err := template.New("message").Parse(text)
+	if err != nil {
+		return err
+	}
+	c.tmpl = tmpl
+	return nil
+}
+
+func (c Config) message(t fsm.Transition) (Message, string, error) {
+	if c.tmpl == nil {
+		// not made by a constructor, so parsed for each message
+		if err := c.parse(); err != nil {
+			return Message{}, "", err
+		}
+	}
+
+	message := Message{Transition: t}
+	var buff bytes.Buffer
+	if err := c.tmpl.Execute(&buff, message); err != nil {
+		return Message{}, "", err
+	}
+	return message, buff.String(), nil
+}
+
+func (c Config) post(url string, body interface{}) error {
+	buff, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(buff))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("notify %v: %v", url, resp.Status)
+	}
+	return nil
+}
+
+// Slack posts messages to a Slack incoming webhook
+type Slack struct {
+	Config
+
+	// WebhookURL is the URL of the incoming webhook
+	WebhookURL string
+}
+
+// NewSlack returns a notifier that posts to the incoming webhook, or an error if the template doesn't parse.
+func NewSlack(webhookURL string, config Config) (*Slack, error) {
+	if err := config.parse(); err != nil {
+		return nil, err
+	}
+	return &Slack{Config: config, WebhookURL: webhookURL}, nil
+}
+
+// Notify implements fsm.Notifier
+func (s *Slack) Notify(t fsm.Transition) error {
+	_, text, err := s.message(t)
+	if err != nil {
+		return err
+	}
+	return s.post(s.WebhookURL, map[string]string{"text": text})
+}
+
+// PagerDutyEventsURL is the endpoint of the PagerDuty Events API v2
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty triggers incidents with the PagerDuty Events API v2.  Incidents are deduplicated by instance.
+type PagerDuty struct {
+	Config
+
+	// RoutingKey is the integration key of the service
+	RoutingKey string
+
+	// Source is the name of the monitoring system.  Defaults to "fsm".
+	Source string
+
+	// Severity is one of critical, error, warning, or info.  Defaults to critical.
+	Severity string
+
+	// URL is the events endpoint.  Defaults to PagerDutyEventsURL.
+	URL string
+}
+
+// NewPagerDuty returns a notifier that triggers incidents on the service of the routing key, or an error if the
+// template doesn't parse.
+func NewPagerDuty(routingKey string, config Config) (*PagerDuty, error) {
+	if err := config.parse(); err != nil {
+		return nil, err
+	}
+	return &PagerDuty{Config: config, RoutingKey: routingKey}, nil
+}
+
+// Notify implements fsm.Notifier
+func (p *PagerDuty) Notify(t fsm.Transition) error {
+	message, summary, err := p.message(t)
+	if err != nil {
+		return err
+	}
+
+	source, severity, url := p.Source, p.Severity, p.URL
+	if source == "" {
+		source = "fsm"
+	}
+	if severity == "" {
+		severity = "critical"
+	}
+	if url == "" {
+		url = PagerDutyEventsURL
+	}
+
+	return p.post(url, map[string]interface{}{
+		"routing_key":  p.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    fmt.Sprintf("%s-%d", source, t.ID),
+		"payload": map[string]interface{}{
+			"summary":  summary,
+			"source":   source,
+			"severity": severity,
+			"custom_details": map[string]interface{}{
+				"transition": message.Transition,
+				"labels":     message.Labels,
+			},
+		},
+	})
+}
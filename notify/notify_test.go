@@ -0,0 +1,86 @@
+package notify // import "github.com/orkestr8/fsm/notify"
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/orkestr8/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func receiver(t *testing.T, bodies chan<- map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies <- body
+	}))
+}
+
+var cordoned = fsm.Transition{
+	ID:   3,
+	From: 1,
+	To:   2,
+	Names: fsm.TransitionNames{
+		From:   "running",
+		To:     "cordoned",
+		Signal: "cordon",
+	},
+}
+
+func TestSlack(t *testing.T) {
+	bodies := make(chan map[string]interface{}, 1)
+	server := receiver(t, bodies)
+	defer server.Close()
+
+	// the labels of the instance
+	labeled := cordoned
+	labeled.Labels = map[string]string{"zone": "us-east-1a"}
+
+	slack, err := NewSlack(server.URL, Config{})
+	require.NoError(t, err)
+	require.NoError(t, slack.Notify(labeled))
+	require.Equal(t, map[string]interface{}{
+		"text": "instance 3 zone=us-east-1a: running -[cordon]-> cordoned",
+	}, <-bodies)
+
+	slack, err = NewSlack(server.URL, Config{Template: "{{.Names.To}} {{.Labels.zone}}"})
+	require.NoError(t, err)
+	require.NoError(t, slack.Notify(labeled))
+	require.Equal(t, map[string]interface{}{"text": "cordoned us-east-1a"}, <-bodies)
+
+	_, err = NewSlack(server.URL, Config{Template: "{{.Bad"})
+	require.Error(t, err)
+	_, err = NewPagerDuty("key", Config{Template: "{{.Bad"})
+	require.Error(t, err)
+}
+
+func TestPagerDuty(t *testing.T) {
+	bodies := make(chan map[string]interface{}, 1)
+	server := receiver(t, bodies)
+	defer server.Close()
+
+	pd, err := NewPagerDuty("key", Config{})
+	require.NoError(t, err)
+	pd.URL = server.URL
+	require.NoError(t, pd.Notify(cordoned))
+
+	body := <-bodies
+	require.Equal(t, "key", body["routing_key"])
+	require.Equal(t, "trigger", body["event_action"])
+	require.Equal(t, "fsm-3", body["dedup_key"])
+
+	payload := body["payload"].(map[string]interface{})
+	require.Equal(t, "instance 3: running -[cordon]-> cordoned", payload["summary"])
+	require.Equal(t, "critical", payload["severity"])
+}
+
+func TestPostError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	require.Error(t, (&Slack{WebhookURL: server.URL}).Notify(cordoned))
+}
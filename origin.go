@@ -0,0 +1,34 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// Origin tags where a signal comes from, e.g. to tell operator actions from automatic ones.
+// Applications can define their own, such as "admin-api".
+type Origin string
+
+const (
+	// OriginAPI is a signal sent with FSM.Signal or FSM.Sequence
+	OriginAPI Origin = "api"
+
+	// OriginSource is a signal emitted by a Source
+	OriginSource Origin = "source"
+
+	// OriginTTL is a signal raised by an expired TTL
+	OriginTTL Origin = "ttl"
+
+	// OriginVisitLimit is a signal raised by a visit limit
+	OriginVisitLimit Origin = "visit-limit"
+
+	// OriginFlap is a signal raised by a flap limit
+	OriginFlap Origin = "flap"
+
+	// OriginWatchdog is a signal raised by a watchdog
+	OriginWatchdog Origin = "watchdog"
+
+	// OriginErrorBudget is a signal raised by an exhausted error budget
+	OriginErrorBudget Origin = "error-budget"
+
+	// OriginRoute is a signal raised by a Router on a transition in other machines
+	OriginRoute Origin = "route"
+
+	// OriginSubmachine is the signal raised when the child instance of a Submachine completes
+	OriginSubmachine Origin = "submachine"
+)
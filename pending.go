@@ -0,0 +1,59 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"time"
+)
+
+// PendingSignal is a signal queued for an instance but not yet applied
+type PendingSignal struct {
+	Signal Signal
+	Origin Origin
+
+	// Queued is when the signal was queued
+	Queued time.Time
+
+	// Deferred is true if the signal is held until the end of a blackout
+	Deferred bool
+}
+
+// Pending returns the signals queued for the instance and not yet applied, in the order they were queued.
+// This doesn't go through the transaction loop, so that it shows what the loop is behind on.
+func (i *instance) Pending() []PendingSignal {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	pending := make([]PendingSignal, 0, len(i.pending))
+	for _, e := range i.pending {
+		pending = append(pending, PendingSignal{
+			Signal:   e.signal,
+			Origin:   e.origin,
+			Queued:   e.queued,
+			Deferred: e.deferred,
+		})
+	}
+	return pending
+}
+
+// enqueue tracks the event as pending until it's handled, or marks it deferred if already pending
+func (i *instance) enqueue(e *event, deferred bool) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	e.deferred = deferred
+	for _, p := range i.pending {
+		if p == e {
+			return
+		}
+	}
+	i.pending = append(i.pending, e)
+}
+
+// dequeue stops tracking the event
+func (i *instance) dequeue(e *event) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	for k, p := range i.pending {
+		if p == e {
+			i.pending = append(i.pending[:k:k], i.pending[k+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPending(t *testing.T) {
+
+	const (
+		running Index = iota
+		restarting
+		terminated
+	)
+
+	const (
+		health Signal = iota
+		restart
+		terminate
+	)
+
+	machines, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				health:    running,
+				restart:   restarting,
+				terminate: terminated,
+			},
+			TTL: Expiry{1, restart},
+		},
+		State{
+			Index: restarting,
+		},
+		State{
+			Index: terminated,
+		},
+	)
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	options.Blackouts = []Blackout{{Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour)}}
+	driver, err := machines.Drive(nil, options)
+	require.NoError(t, err)
+	defer machines.Done()
+
+	var a FSM
+	drive(driver, func() {
+		a, err = machines.New(running)
+		require.NoError(t, err)
+	})
+	require.Len(t, a.Pending(), 0)
+
+	driver.Tick() // the restart is deferred by the blackout
+	for i := 0; i < 3; i++ {
+		require.NoError(t, a.Signal(health))
+	}
+	require.NoError(t, a.Signal(terminate))
+
+	pending := a.Pending()
+	require.Len(t, pending, 5)
+	require.Equal(t, PendingSignal{Signal: restart, Origin: OriginTTL, Queued: pending[0].Queued, Deferred: true}, pending[0])
+	for _, p := range pending[1:4] {
+		require.Equal(t, health, p.Signal)
+		require.Equal(t, OriginAPI, p.Origin)
+		require.False(t, p.Deferred)
+	}
+	require.Equal(t, terminate, pending[4].Signal)
+
+	for work, has := driver.Next(); has; work, has = driver.Next() {
+		driver.Process(work)
+	}
+	require.Equal(t, []PendingSignal{pending[0]}, a.Pending())
+}
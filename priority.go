@@ -0,0 +1,44 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// Priority is the order in which the inputs of the machines are taken when more than one is waiting.  The
+// inputs taken are queued, up to Options.BufferSize, and processed in order, so the priority applies as they are
+// queued and not as they are processed: a tick or signal with priority still waits for the transactions queued
+// before it.  A small BufferSize keeps that wait short where the priority matters.
+type Priority int
+
+const (
+	// PriorityNone takes whichever input is picked at random among those waiting
+	PriorityNone Priority = iota
+
+	// PriorityTicks takes clock ticks, and so the expiry of deadlines, before the waiting signals,
+	// so that timers fire on time
+	PriorityTicks
+
+	// PriorityEvents takes the waiting signals before clock ticks, so that operator commands are
+	// never delayed by the processing of deadlines
+	PriorityEvents
+)
+
+// prioritized returns the transaction of the input with priority, if it's waiting, or nil.
+// Signals raised by the machines themselves are already queued and are not affected.
+func (g *runner) prioritized(tid int64, onTick func(Tick) *txn) *txn {
+	switch g.options.Priority {
+	case PriorityTicks:
+		select {
+		case tick, ok := <-g.clock.C:
+			if ok {
+				return onTick(tick)
+			}
+		default:
+		}
+	case PriorityEvents:
+		select {
+		case event, ok := <-g.events:
+			if ok {
+				return g.eventTxn(tid, event)
+			}
+		default:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriority(t *testing.T) {
+
+	const (
+		running Index = iota
+		stopped
+	)
+
+	const (
+		stop Signal = iota
+	)
+
+	for _, priority := range []Priority{PriorityTicks, PriorityEvents} {
+
+		machines, err := define(
+			State{
+				Index: running,
+				Transitions: map[Signal]Index{
+					stop: stopped,
+				},
+			},
+			State{
+				Index: stopped,
+			},
+		)
+		require.NoError(t, err)
+
+		options := DefaultOptions()
+		options.Priority = priority
+		clock := NewClock()
+		driver, err := machines.Drive(clock, options)
+		require.NoError(t, err)
+
+		var a FSM
+		drive(driver, func() {
+			a, err = machines.New(running)
+			require.NoError(t, err)
+		})
+
+		// both a tick and an event are waiting
+		go clock.Tick()
+		time.Sleep(50 * time.Millisecond)
+		require.NoError(t, a.Signal(stop))
+
+		work, has := driver.Next()
+		require.True(t, has)
+		driver.Process(work)
+
+		// the driving goroutine is the only one to touch the runner
+		switch priority {
+		case PriorityTicks:
+			require.Equal(t, Time(1), machines.runner.now)
+			require.Equal(t, running, a.(*instance).state)
+		case PriorityEvents:
+			require.Equal(t, Time(0), machines.runner.now)
+			require.Equal(t, stopped, a.(*instance).state)
+		}
+
+		// then the other
+		work, has = driver.Next()
+		require.True(t, has)
+		driver.Process(work)
+		require.Equal(t, Time(1), machines.runner.now)
+		require.Equal(t, stopped, a.(*instance).state)
+
+		machines.Done()
+	}
+}
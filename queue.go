@@ -23,14 +23,14 @@ func (pq *queue) dequeue() *instance {
 }
 
 func (pq *queue) remove(instance *instance) {
-	if instance.index > 0 {
+	if instance.index >= 0 {
 		heap.Remove(pq, instance.index)
 		instance.index = -1
 	}
 }
 
 func (pq *queue) update(instance *instance) {
-	if instance.index > 0 {
+	if instance.index >= 0 {
 		heap.Fix(pq, instance.index)
 	}
 }
@@ -0,0 +1,18 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// dataQuota returns ErrDataQuota if the data, as encoded by the codec, is larger than the MaxData of the
+// state.  This must be called from within the transaction loop.
+func (g *runner) dataQuota(id ID, state Index, signal Signal, data interface{}) error {
+	max := g.spec().states[state].MaxData
+	if max <= 0 {
+		return nil
+	}
+	buff, err := g.codec().Encode(data)
+	if err != nil {
+		return err
+	}
+	if len(buff) > max {
+		return ErrDataQuota{spec: g.spec(), ID: id, State: state, Signal: signal, Size: len(buff), Max: max}
+	}
+	return nil
+}
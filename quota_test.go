@@ -0,0 +1,122 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataQuota(t *testing.T) {
+
+	const (
+		pending Index = iota
+		polled
+	)
+
+	const (
+		poll Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				poll: polled,
+			},
+		},
+		State{
+			Index: polled,
+			Transitions: map[Signal]Index{
+				poll: polled,
+			},
+			MaxData: 64,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	errs, cancel := machines.SubscribeErrors(ErrorFilter{Types: []error{ErrDataQuota{}}, Buffer: 1})
+	defer cancel()
+
+	a, err := machines.New(pending)
+	require.NoError(t, err)
+
+	require.NoError(t, a.Signal(poll, "ok"))
+	require.Equal(t, polled, a.State())
+	require.Equal(t, []interface{}{"ok"}, a.Data())
+
+	// the data is encoded as JSON: ["..."]
+	require.NoError(t, a.Signal(poll, strings.Repeat("x", 100)))
+	select {
+	case e := <-errs:
+		require.Equal(t, ErrDataQuota{spec: machines.spec, ID: a.ID(), State: polled, Signal: poll, Size: 104, Max: 64},
+			e.Err)
+	case <-time.After(time.Second):
+		require.Fail(t, "no error")
+	}
+	require.Equal(t, []interface{}{"ok"}, a.Data())
+
+	_, err = machines.Seed([]SeedItem{{State: polled, Data: strings.Repeat("x", 100)}})
+	require.IsType(t, ErrDataQuota{}, err)
+	require.Equal(t, 102, err.(ErrDataQuota).Size)
+	require.Equal(t, 1, machines.Count())
+}
+
+func TestDataQuotaOnErrors(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+		failed
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			Actions: map[Signal]Action{
+				start: func(FSM) error { return Errorf(UserError, "no capacity") },
+			},
+			Errors: map[Signal]Index{
+				start: failed,
+			},
+		},
+		State{
+			Index: running,
+		},
+		State{
+			Index:   failed,
+			MaxData: 64,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	errs, cancel := machines.SubscribeErrors(ErrorFilter{Types: []error{ErrDataQuota{}}, Buffer: 1})
+	defer cancel()
+
+	a, err := machines.New(pending)
+	require.NoError(t, err)
+
+	// the data fits running, but not failed, which the instance enters without it
+	require.NoError(t, a.Signal(start, strings.Repeat("x", 100)))
+	select {
+	case e := <-errs:
+		require.Equal(t, ErrDataQuota{spec: machines.spec, ID: a.ID(), State: failed, Signal: start, Size: 104, Max: 64},
+			e.Err)
+	case <-time.After(time.Second):
+		require.Fail(t, "no error")
+	}
+	require.Equal(t, failed, a.State())
+	require.Nil(t, a.Data())
+}
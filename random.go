@@ -0,0 +1,25 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	crypto "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"time"
+)
+
+// newRandom returns the random numbers from the source, or from a source seeded by crypto/rand if nil
+func newRandom(source rand.Source) *rand.Rand {
+	if source != nil {
+		return rand.New(source)
+	}
+	return rand.New(rand.NewSource(cryptoSeed()))
+}
+
+// cryptoSeed returns a seed from crypto/rand, or from the time if it can't be read
+func cryptoSeed() int64 {
+	b := [8]byte{}
+	if _, err := crypto.Read(b[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
@@ -0,0 +1,88 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// RateAnomaly detects surges of transitions between two states across all the instances, e.g. many
+// instances going from running to down at once.  The rate is the number of transitions in the last
+// Window ticks, and the baseline is the average rate of the Baseline windows before it.  A rate above
+// Factor times the baseline, and at least Min, is reported as ErrRateAnomaly on the error stream, once
+// until the rate is back under the threshold.  Nothing is reported until the history is complete.
+type RateAnomaly struct {
+	Window   Tick
+	Baseline int
+	Factor   float64
+	Min      int
+}
+
+func (r RateAnomaly) enabled() bool {
+	return r.Window > 0 && r.Baseline > 0 && r.Factor > 0
+}
+
+// rateTracker counts the transitions between two states in each tick, in a ring of Window * (Baseline+1) ticks
+type rateTracker struct {
+	counts   []int
+	created  Time // the tick of the first transition counted
+	last     Time
+	alerting bool
+}
+
+// advance clears the counts of the ticks since the last update
+func (r *rateTracker) advance(now Time) {
+	size := Time(len(r.counts))
+	for t := r.last + 1; t <= now && t <= r.last+size; t++ {
+		r.counts[t%size] = 0
+	}
+	if now > r.last {
+		r.last = now
+	}
+}
+
+// rates returns the count in the last window and the average count of the windows before it.
+// now must be at least the size of the ring.
+func (r *rateTracker) rates(now Time, window Tick, baseline int) (int, float64) {
+	size := Time(len(r.counts))
+	rate, past := 0, 0
+	for i := Time(0); i < size; i++ {
+		count := r.counts[(now-i)%size]
+		if i < Time(window) {
+			rate += count
+		} else {
+			past += count
+		}
+	}
+	return rate, float64(past) / float64(baseline)
+}
+
+// observeRate counts the transition and reports if its rate is an anomaly.  This must be called from
+// within the transaction loop.
+func (g *runner) observeRate(transition Transition) {
+	anomaly := g.options.RateAnomaly
+	if !anomaly.enabled() {
+		return
+	}
+
+	pair := [2]Index{transition.From, transition.To}
+	tracker, has := g.rates[pair]
+	if !has {
+		size := int(anomaly.Window) * (anomaly.Baseline + 1)
+		tracker = &rateTracker{counts: make([]int, size), created: g.now, last: g.now}
+		g.rates[pair] = tracker
+	}
+	tracker.advance(g.now)
+	tracker.counts[g.now%Time(len(tracker.counts))]++
+
+	if g.now-tracker.created < Time(len(tracker.counts)) {
+		return // not enough history for a baseline
+	}
+
+	rate, baseline := tracker.rates(g.now, anomaly.Window, anomaly.Baseline)
+	if rate < anomaly.Min || float64(rate) <= anomaly.Factor*baseline {
+		tracker.alerting = false
+		return
+	}
+	if tracker.alerting {
+		return
+	}
+	tracker.alerting = true
+	g.handleError(g.tid(), ErrRateAnomaly{
+		spec: g.spec(), From: transition.From, To: transition.To, Rate: rate, Baseline: baseline,
+	}, pair)
+}
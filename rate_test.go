@@ -0,0 +1,121 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateAnomaly(t *testing.T) {
+
+	const (
+		up Index = iota
+		down
+	)
+
+	const (
+		fail Signal = iota
+		recover
+	)
+
+	machines, err := define(
+		State{
+			Index: up,
+			Transitions: map[Signal]Index{
+				fail: down,
+			},
+		},
+		State{
+			Index: down,
+			Transitions: map[Signal]Index{
+				recover: up,
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	options.RateAnomaly = RateAnomaly{Window: 2, Baseline: 3, Factor: 2, Min: 3}
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, options))
+	defer machines.Done()
+
+	errs, cancel := machines.SubscribeErrors(ErrorFilter{Types: []error{ErrRateAnomaly{}}})
+	defer cancel()
+
+	instances := []FSM{}
+	for i := 0; i < 6; i++ {
+		f, err := machines.New(up)
+		require.NoError(t, err)
+		instances = append(instances, f)
+	}
+
+	// no baseline yet
+	clock.Ticks(2)
+	for _, f := range instances[:3] {
+		require.NoError(t, f.Sequence(fail, recover))
+	}
+	require.Len(t, errs, 0)
+
+	// one failure per window as the baseline
+	for i := 0; i < 4; i++ {
+		clock.Ticks(2)
+		require.NoError(t, instances[i].Sequence(fail, recover))
+	}
+	require.Equal(t, Time(10), machines.Stats().Now)
+	require.Len(t, errs, 0)
+
+	// a surge of failures is reported once, when the rate in the window of ticks 10 and 11 reaches 3
+	clock.Tick()
+	for _, f := range instances[:4] {
+		require.NoError(t, f.Sequence(fail))
+	}
+	require.Len(t, errs, 1)
+	e := <-errs
+	require.False(t, e.HasID)
+	require.Equal(t, ErrRateAnomaly{spec: machines.runner.spec(), From: up, To: down, Rate: 3, Baseline: 1}, e.Err)
+}
+
+func TestRateAnomalyWarmup(t *testing.T) {
+
+	const (
+		up Index = iota
+		down
+	)
+
+	const (
+		fail Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: up,
+			Transitions: map[Signal]Index{
+				fail: down,
+			},
+		},
+		State{
+			Index: down,
+		},
+	)
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	options.RateAnomaly = RateAnomaly{Window: 2, Baseline: 3, Factor: 2, Min: 3}
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, options))
+	defer machines.Done()
+
+	errs, cancel := machines.SubscribeErrors(ErrorFilter{Types: []error{ErrRateAnomaly{}}})
+	defer cancel()
+
+	// the first failures long after the start have no history for a baseline yet
+	clock.Ticks(20)
+	for i := 0; i < 4; i++ {
+		f, err := machines.New(up)
+		require.NoError(t, err)
+		require.NoError(t, f.Signal(fail))
+	}
+	require.Equal(t, 4, machines.CountIn(down))
+	require.Len(t, errs, 0)
+}
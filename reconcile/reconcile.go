@@ -0,0 +1,101 @@
+// Package reconcile reconciles the instances of fsm.Machines with the set of resources observed in an
+// external system, such as the result of a describe call to a cloud provider.
+package reconcile // import "github.com/orkestr8/fsm/reconcile"
+
+import (
+	"sort"
+
+	"github.com/orkestr8/fsm"
+)
+
+// Reconciler matches the observed external IDs with the instances by the value of a label.
+type Reconciler struct {
+
+	// Label is the label of the instances that holds the external ID
+	Label string
+
+	// Initial is the state of the instances created for external IDs with no instance
+	Initial fsm.Index
+
+	// Found is signaled to the instances whose external ID is observed.  fsm.NoSignal to skip.
+	Found fsm.Signal
+
+	// Gone is signaled to the instances whose external ID is no longer observed.  fsm.NoSignal to skip.
+	Gone fsm.Signal
+}
+
+// Result is the outcome of a reconciliation
+type Result struct {
+
+	// Created are the instances created for the external IDs that had none
+	Created []fsm.FSM
+
+	// Found are the IDs of the instances whose external ID is observed
+	Found []fsm.ID
+
+	// Gone are the IDs of the instances whose external ID is not observed
+	Gone []fsm.ID
+}
+
+// Reconcile creates an instance in the initial state for each observed ID without one, signals Found
+// to the instances whose ID is observed and signals Gone to those whose ID is not.  Instances without
+// the label are left alone.  Signals are only sent to instances whose state can receive them.
+// The first error is returned, after all the instances are processed.
+func (r Reconciler) Reconcile(machines fsm.Machines, observed []string) (result Result, err error) {
+
+	seen := map[string]bool{}
+	for _, id := range observed {
+		seen[id] = true
+	}
+
+	// the signals are sent after the iteration, which must not block
+	signals := map[fsm.Signal][]fsm.FSM{}
+	known := map[string]bool{}
+	machines.ForEach(func(f fsm.FSM) bool {
+		id, has := f.Labels()[r.Label]
+		if !has {
+			return true
+		}
+		known[id] = true
+		s := r.Gone
+		if seen[id] {
+			result.Found = append(result.Found, f.ID())
+			s = r.Found
+		} else {
+			result.Gone = append(result.Gone, f.ID())
+		}
+		if !fsm.IsNoSignal(s) && f.CanReceive(s) {
+			signals[s] = append(signals[s], f)
+		}
+		return true
+	})
+
+	missing := []string{}
+	for id := range seen {
+		if !known[id] {
+			missing = append(missing, id)
+		}
+	}
+	sort.Strings(missing)
+
+	if len(missing) > 0 {
+		items := make([]fsm.SeedItem, len(missing))
+		for i, id := range missing {
+			items[i] = fsm.SeedItem{State: r.Initial, Labels: map[string]string{r.Label: id}}
+		}
+		result.Created, err = machines.Seed(items)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, s := range []fsm.Signal{r.Found, r.Gone} {
+		for _, f := range signals[s] {
+			if e := f.Signal(s); e != nil && err == nil {
+				err = e
+			}
+		}
+		delete(signals, s) // in case Found and Gone are the same signal
+	}
+	return
+}
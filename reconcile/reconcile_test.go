@@ -0,0 +1,95 @@
+package reconcile // import "github.com/orkestr8/fsm/reconcile"
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/orkestr8/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcile(t *testing.T) {
+
+	const (
+		requested fsm.Index = iota
+		running
+		terminated
+	)
+
+	const (
+		found fsm.Signal = iota
+		gone
+	)
+
+	machines, err := fsm.Define(
+		fsm.State{
+			Index: requested,
+			Transitions: map[fsm.Signal]fsm.Index{
+				found: running,
+				gone:  terminated,
+			},
+		},
+		fsm.State{
+			Index: running,
+			Transitions: map[fsm.Signal]fsm.Index{
+				gone: terminated,
+			},
+		},
+		fsm.State{
+			Index: terminated,
+		},
+	)
+	require.NoError(t, err)
+
+	clock := fsm.NewClock()
+	require.NoError(t, machines.Run(clock, fsm.DefaultOptions()))
+	defer machines.Done()
+
+	// an instance not managed by the reconciler
+	_, err = machines.New(requested)
+	require.NoError(t, err)
+
+	r := Reconciler{Label: "instance-id", Initial: requested, Found: found, Gone: gone}
+
+	result, err := r.Reconcile(machines, []string{"i-2", "i-1"})
+	require.NoError(t, err)
+	require.Len(t, result.Created, 2)
+	require.Equal(t, "i-1", result.Created[0].Labels()["instance-id"])
+	require.Equal(t, "i-2", result.Created[1].Labels()["instance-id"])
+	require.Empty(t, result.Found)
+	require.Empty(t, result.Gone)
+	require.Equal(t, 3, machines.CountIn(requested))
+
+	result, err = r.Reconcile(machines, []string{"i-2", "i-3"})
+	require.NoError(t, err)
+	require.Len(t, result.Created, 1)
+	require.Equal(t, []fsm.ID{result.Created[0].ID() - 1}, result.Found)
+	require.Len(t, result.Gone, 1)
+
+	waitFor := func(state fsm.Index, count int) {
+		for i := 0; i < 100 && machines.CountIn(state) != count; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		require.Equal(t, count, machines.CountIn(state))
+	}
+	waitFor(running, 1)
+	waitFor(terminated, 1)
+
+	// signals are only sent to the instances that can receive them
+	result, err = r.Reconcile(machines, []string{"i-2", "i-3"})
+	require.NoError(t, err)
+	require.Empty(t, result.Created)
+	require.Len(t, result.Found, 2)
+	require.Len(t, result.Gone, 1)
+	waitFor(running, 2)
+	waitFor(terminated, 1)
+
+	states := []fsm.Index{}
+	machines.ForEach(func(f fsm.FSM) bool {
+		states = append(states, f.State())
+		return true
+	})
+	sort.Slice(states, func(i, j int) bool { return states[i] < states[j] })
+	require.Equal(t, []fsm.Index{requested, running, running, terminated}, states)
+}
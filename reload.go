@@ -0,0 +1,86 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ErrStateInUse is returned by Reload when a state that's not in the new spec still has instances in it
+type ErrStateInUse struct {
+	spec      *spec
+	State     Index
+	Instances int
+}
+
+func (e ErrStateInUse) Error() string {
+	return fmt.Sprintf("state in use: state=%v, instances=%v", e.spec.stateName(e.State), e.Instances)
+}
+
+func (e ErrStateInUse) Is(target error) bool {
+	return target == SpecError
+}
+
+// Reload swaps the spec of the machines for the states given.  The instances keep their states, which must all
+// be in the new spec, and their pending deadlines.  The new TTLs apply as the states are entered.  The names,
+// flap limits and actions of the options given to Run are bound to the new spec, and the flap limits of the
+// spec carry over unless given in the options.  The methods that describe the spec, like Meta or WriteDOT,
+// see either the old or the new spec while it's reloaded.
+func (m *machines) Reload(states ...State) (err error) {
+	if len(states) == 0 {
+		return Errorf(SpecError, "reload: no states")
+	}
+	s, err := newSpec().build(states[0], states[1:]...)
+	if err != nil {
+		return err
+	}
+	if err := m.checkSubs(s); err != nil {
+		return err
+	}
+	current := m.current()
+	s.stateNames = current.stateNames
+	s.signalNames = current.signalNames
+	if len(m.Options.Limits) == 0 {
+		limits := []Flap{}
+		for _, flap := range current.flaps {
+			limits = append(limits, *flap)
+		}
+		if _, err := s.compileFlapping(limits); err != nil {
+			return err
+		}
+	}
+
+	if m.runner == nil {
+		m.swap(s, states)
+		return nil
+	}
+
+	bound, actionNames, err := bind(s, m.Options)
+	if err != nil {
+		return err
+	}
+	m.runner.do(func(g *runner) {
+		inUse := []Index{}
+		for index, instances := range g.bystate {
+			if _, has := bound.states[index]; !has && len(instances) > 0 {
+				inUse = append(inUse, index)
+			}
+		}
+		if len(inUse) > 0 {
+			sort.Slice(inUse, func(i, j int) bool { return inUse[i] < inUse[j] })
+			err = ErrStateInUse{spec: g.spec(), State: inUse[0], Instances: len(g.bystate[inUse[0]])}
+			return
+		}
+		g.specs.Store(&bound)
+		g.actionNames = actionNames
+		m.swap(s, states)
+		g.log.Info("Reloaded", "states", len(states))
+	})
+	return
+}
+
+func (m *machines) swap(s *spec, states []State) {
+	m.specLock.Lock()
+	defer m.specLock.Unlock()
+	m.spec = s
+	m.States = states
+}
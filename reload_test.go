@@ -0,0 +1,211 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+
+	const (
+		waiting Index = iota
+		running
+		paused
+	)
+
+	const (
+		start Signal = iota
+		pause
+		resume
+	)
+
+	machines, err := define(
+		State{
+			Index: waiting,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+		},
+		State{
+			Index: running,
+		},
+		State{
+			Index: paused,
+			Transitions: map[Signal]Index{
+				resume: running,
+			},
+		},
+	)
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.IgnoreUndefinedTransitions = false
+	options.StateNames = map[Index]string{waiting: "waiting", running: "running", paused: "paused"}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	a, err := machines.New(waiting)
+	require.NoError(t, err)
+	require.NoError(t, a.Signal(start))
+	require.Equal(t, running, a.State())
+
+	// running gets a transition and a TTL
+	require.NoError(t, machines.Reload(
+		State{
+			Index: waiting,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+		},
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				pause: paused,
+			},
+			TTL: Expiry{5, pause},
+		},
+		State{
+			Index: paused,
+			Transitions: map[Signal]Index{
+				resume: running,
+			},
+		},
+	))
+	require.NoError(t, a.Signal(pause))
+	require.Equal(t, paused, a.State())
+	require.False(t, machines.Terminal(running))
+
+	// paused has an instance
+	err = machines.Reload(
+		State{
+			Index: waiting,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+		},
+		State{
+			Index: running,
+		},
+	)
+	require.Equal(t, "state in use: state=paused, instances=1", err.Error())
+	require.Equal(t, SpecError, ClassOf(err))
+	require.NoError(t, a.Signal(resume))
+	require.Equal(t, running, a.State())
+
+	// and no longer
+	require.NoError(t, machines.Reload(
+		State{
+			Index: waiting,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+		},
+		State{
+			Index: running,
+		},
+	))
+	require.True(t, machines.Terminal(running))
+	require.Equal(t, 1, machines.CountIn(running))
+
+	// the spec must be valid
+	require.Error(t, machines.Reload(State{
+		Index: running,
+		Transitions: map[Signal]Index{
+			pause: paused,
+		},
+	}))
+}
+
+func TestReloadDescribed(t *testing.T) {
+
+	const (
+		waiting Index = iota
+		running
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	states := []State{
+		{
+			Index: waiting,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+		},
+		{
+			Index: running,
+			Meta:  map[string]string{"phase": "run"},
+		},
+	}
+	machines, err := define(states[0], states[1:]...)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	// the spec is described while it's reloaded, e.g. by the watchers of KeyedSet
+	described := make(chan bool)
+	go func() {
+		ok := true
+		for i := 0; i < 100; i++ {
+			ok = ok && machines.Terminal(running) && machines.Meta(running)["phase"] == "run"
+		}
+		described <- ok
+	}()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, machines.Reload(states...))
+	}
+	require.True(t, <-described)
+}
+
+func TestReloadWhileSignaled(t *testing.T) {
+
+	const (
+		up Index = iota
+		down
+	)
+
+	const (
+		fail Signal = iota
+		recover
+	)
+
+	states := []State{
+		{
+			Index: up,
+			Transitions: map[Signal]Index{
+				fail: down,
+			},
+		},
+		{
+			Index: down,
+			Transitions: map[Signal]Index{
+				recover: up,
+			},
+		},
+	}
+	machines, err := define(states[0], states[1:]...)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	a, err := machines.New(up)
+	require.NoError(t, err)
+
+	// the signals are admitted outside of the transaction loop, as the spec is swapped in it
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			require.NoError(t, a.Signal(fail))
+			require.NoError(t, a.Signal(recover))
+			require.Equal(t, ErrUnknownSignal{Signal: 42}, a.Signal(42))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, machines.Reload(states...))
+	}
+	<-done
+	require.Equal(t, up, a.State())
+}
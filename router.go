@@ -0,0 +1,138 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Route raises a signal on an instance of a Machines when an instance of another enters a state
+type Route struct {
+	// From is the namespace of the machines of the source instance
+	From string
+
+	// State is the state of the source whose entry triggers the route
+	State Index
+
+	// To is the namespace of the machines of the target instance
+	To string
+
+	// Raise is the signal raised on the target.  It must be a signal of the target's spec.
+	Raise Signal
+
+	// Address returns the external ID of the target, see Machines.SignalExternal.  If nil, the target is
+	// the instance with the same ID as the source.
+	Address func(Transition) string
+}
+
+// ErrUnknownNamespace is returned for a route to or from a namespace with no machines
+type ErrUnknownNamespace string
+
+func (e ErrUnknownNamespace) Error() string {
+	return fmt.Sprintf("unknown namespace: %v", string(e))
+}
+
+func (e ErrUnknownNamespace) Is(target error) bool {
+	return target == SpecError
+}
+
+// Router routes signals between the machines running in one process, e.g. so that the fsm of a node can
+// nudge the fsm of its load balancer.  Transitions are watched with a buffer of the given size, and those
+// that come faster than they're routed are dropped.  Errors in signaling the target are reported on the
+// error stream of the target.
+type Router struct {
+	Buffer int
+
+	machines map[string]*machines
+	routes   map[string][]Route
+	cancels  []func()
+	wg       sync.WaitGroup
+}
+
+// NewRouter returns a router
+func NewRouter() *Router {
+	return &Router{
+		Buffer:   defaultBufferSize,
+		machines: map[string]*machines{},
+		routes:   map[string][]Route{},
+	}
+}
+
+// Add adds the machines under the namespace.  The machines must be of this package, e.g. not a wrapper of them.
+func (r *Router) Add(namespace string, m Machines) error {
+	target, ok := m.(*machines)
+	if !ok {
+		return Errorf(UserError, "routing machines of type %T is not supported", m)
+	}
+	r.machines[namespace] = target
+	return nil
+}
+
+// Route adds the route, validated against the specs of its source and target
+func (r *Router) Route(route Route) error {
+	from, has := r.machines[route.From]
+	if !has {
+		return ErrUnknownNamespace(route.From)
+	}
+	to, has := r.machines[route.To]
+	if !has {
+		return ErrUnknownNamespace(route.To)
+	}
+	if _, has := from.current().states[route.State]; !has {
+		return ErrUnknownState{spec: from.current(), Index: route.State, defining: true}
+	}
+	if _, has := to.current().signals[route.Raise]; !has {
+		return ErrUnknownSignal{
+			spec: to.current(), Signal: route.Raise, Index: NoState,
+			Help:     fmt.Sprintf("route from %v raises a signal that's not in the spec of %v", route.From, route.To),
+			defining: true,
+		}
+	}
+	r.routes[route.From] = append(r.routes[route.From], route)
+	return nil
+}
+
+// Start starts routing.  The machines must be running.
+func (r *Router) Start() {
+	for namespace, routes := range r.routes {
+		transitions, cancel := r.machines[namespace].Watch(r.Buffer)
+		r.cancels = append(r.cancels, cancel)
+		r.wg.Add(1)
+		go func(routes []Route) {
+			defer r.wg.Done()
+			for transition := range transitions {
+				for _, route := range routes {
+					if route.State == transition.To {
+						r.route(route, transition)
+					}
+				}
+			}
+		}(routes)
+	}
+}
+
+// Stop stops routing and waits for the routes in flight
+func (r *Router) Stop() {
+	for _, cancel := range r.cancels {
+		cancel()
+	}
+	r.cancels = nil
+	r.wg.Wait()
+}
+
+func (r *Router) route(route Route, transition Transition) {
+	target := r.machines[route.To]
+	data := []interface{}{}
+	if transition.CorrelationID != "" {
+		data = append(data, transition.CorrelationID)
+	}
+	if route.Address == nil {
+		if err := target.runner.signalID(OriginRoute, transition.ID, route.Raise, data...); err != nil {
+			target.runner.handleError(target.runner.tid(), err, transition.ID)
+		}
+		return
+	}
+	externalID := route.Address(transition)
+	if _, err := target.signalExternal(OriginRoute, externalID, route.Raise, data...); err != nil {
+		target.runner.handleError(target.runner.tid(), err, externalID)
+	}
+}
@@ -0,0 +1,105 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter(t *testing.T) {
+
+	// nodes
+	const (
+		up Index = iota
+		draining
+	)
+
+	const (
+		drain Signal = iota
+	)
+
+	// load balancer backends
+	const (
+		serving Index = iota
+		removed
+	)
+
+	const (
+		remove Signal = 10
+	)
+
+	nodes, err := define(
+		State{
+			Index: up,
+			Transitions: map[Signal]Index{
+				drain: draining,
+			},
+		},
+		State{
+			Index: draining,
+		},
+	)
+	require.NoError(t, err)
+
+	backends, err := define(
+		State{
+			Index: serving,
+			Transitions: map[Signal]Index{
+				remove: removed,
+			},
+		},
+		State{
+			Index: removed,
+		},
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, nodes.Run(NewClock(), DefaultOptions()))
+	defer nodes.Done()
+	require.NoError(t, backends.Run(NewClock(), DefaultOptions()))
+	defer backends.Done()
+
+	node, err := nodes.Seed([]SeedItem{{State: up, Labels: map[string]string{"backend": "lb-7"}}})
+	require.NoError(t, err)
+	backend, err := backends.Seed([]SeedItem{{State: serving, Labels: map[string]string{DefaultExternalIDLabel: "lb-7"}}})
+	require.NoError(t, err)
+
+	labels := map[ID]string{node[0].ID(): node[0].Labels()["backend"]}
+
+	router := NewRouter()
+	require.NoError(t, router.Add("nodes", nodes))
+	require.NoError(t, router.Add("backends", backends))
+	require.True(t, errors.Is(router.Add("wrapped", struct{ Machines }{nodes}), UserError))
+
+	require.Equal(t, ErrUnknownNamespace("dns"), router.Route(Route{From: "nodes", To: "dns"}))
+	err = router.Route(Route{From: "nodes", State: draining, To: "backends", Raise: drain})
+	require.IsType(t, ErrUnknownSignal{}, err)
+
+	require.NoError(t, router.Route(Route{
+		From:  "nodes",
+		State: draining,
+		To:    "backends",
+		Raise: remove,
+		Address: func(t Transition) string {
+			return labels[t.ID]
+		},
+	}))
+	router.Start()
+	defer router.Stop()
+
+	watch, cancel := backends.Watch(1)
+	defer cancel()
+
+	require.NoError(t, node[0].Signal(drain))
+
+	select {
+	case transition := <-watch:
+		require.Equal(t, backend[0].ID(), transition.ID)
+		require.Equal(t, removed, transition.To)
+		require.Equal(t, OriginRoute, transition.Origin)
+	case <-time.After(time.Second):
+		require.Fail(t, "not routed")
+	}
+}
@@ -2,28 +2,78 @@ package fsm // import "github.com/orkestr8/fsm"
 
 import (
 	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 )
 
 const (
-	defaultBufferSize = 1 << 8
+	defaultBufferSize      = 1 << 8
+	defaultDeadLetterLimit = 1 << 10
 )
 
 // runner manages the channels used to receive state transition signals
 type runner struct {
 	options      Options
 	reads        chan func(*runner) // given a view which is a copy of the runner
-	spec         spec
+	specs        atomic.Value       // the *spec, swapped by Reload
 	now          Time
+	warmup       Time // the end of the warm-up, until which TTLs are held
 	next         ID
+	freed        []ID       // freed IDs to reuse
+	random       *rand.Rand // for random IDs and jitter
 	clock        *Clock
 	stop         chan struct{}
 	errors       chan error
 	events       chan *event
 	transactions chan *txn
 	deadlines    *queue
+	members      map[ID]*instance
+	bystate      map[Index]map[ID]*instance
+	externals    map[string]*instance // by external ID
 	running      bool
 	log          Logger
+
+	transitionLog *template.Template
+	watchers      map[int]chan<- Transition
+	terminals     map[int]func(Transition) // hooks of the transitions to terminal states
+	watcher       int
+
+	// guards the IgnoreUndefined options and the error subscribers, as errors are also
+	// reported outside of the transaction loop
+	errorLock        sync.RWMutex
+	ignoreUndefined  IgnoreUndefined
+	errorSubscribers map[int]errorSubscriber
+	errorSubscriber  int
+
+	received  int64 // ticks received from the clock; accessed atomically
+	ticks     int64 // ticks processed
+	lagAlerts int64
+	stalls    int64
+
+	deadLetters        []DeadLetter
+	deadLettersDropped int64 // the oldest, beyond Options.DeadLetterLimit
+
+	vetoers     []Vetoer
+	actionNames map[actionKey]string // before wrapping, for dry runs
+
+	blackouts []Blackout
+	deferred  []deferredEvent // raised during a blackout
+
+	logged      map[ID]int  // records in the WAL since the last snapshot of each instance
+	compactions map[ID]bool // instances due for compaction in the WAL
+	compacted   Time        // when the last periodic compaction was done
+
+	transitions map[Signal]int64           // committed transitions by signal
+	undefined   map[Index]map[Signal]int64 // signals with no transition by state, ignored or not
+	rates       map[[2]Index]*rateTracker
+	subs        *submachines // creates and frees the children of the submachines, once one is entered
+
+	latency   *Histogram // queue latency of events
+	durations *Histogram // execution time of actions
 }
 
 func newRunner(spec *spec, clock *Clock, optional ...Options) (*runner, error) {
@@ -36,18 +86,13 @@ func newRunner(spec *spec, clock *Clock, optional ...Options) (*runner, error) {
 	if options.BufferSize == 0 {
 		options.BufferSize = defaultBufferSize
 	}
-
-	if len(options.StateNames) > 0 {
-		spec.stateNames = options.StateNames
-	}
-	if len(options.SignalNames) > 0 {
-		spec.signalNames = options.SignalNames
+	if options.DeadLetterLimit == 0 {
+		options.DeadLetterLimit = defaultDeadLetterLimit
 	}
-	if len(options.Limits) > 0 {
-		_, err := spec.compileFlapping(options.Limits)
-		if err != nil {
-			return nil, err
-		}
+
+	bound, actionNames, err := bind(spec, options)
+	if err != nil {
+		return nil, err
 	}
 
 	logger := options.Logger
@@ -55,23 +100,103 @@ func newRunner(spec *spec, clock *Clock, optional ...Options) (*runner, error) {
 		logger = &nilLogger{}
 	}
 
+	transitionLog, err := parseTransitionLogFormat(options.TransitionLogFormat)
+	if err != nil {
+		return nil, err
+	}
+
 	gp := &runner{
 		log:          logger,
 		options:      options,
-		spec:         *spec,
+		now:          options.Now,
+		warmup:       options.Now + Time(options.WarmupTicks),
+		actionNames:  actionNames,
 		stop:         make(chan struct{}),
 		clock:        clock,
+		random:       newRandom(options.Random),
 		reads:        make(chan func(*runner)),
 		errors:       make(chan error),
 		events:       make(chan *event),
 		transactions: make(chan *txn, options.BufferSize),
 		deadlines:    newQueue(),
+		members:      map[ID]*instance{},
+		bystate:      map[Index]map[ID]*instance{},
+		externals:    map[string]*instance{},
+
+		transitionLog: transitionLog,
+		watchers:      map[int]chan<- Transition{},
+
+		ignoreUndefined: IgnoreUndefined{
+			States:      options.IgnoreUndefinedStates,
+			Transitions: options.IgnoreUndefinedTransitions,
+			Signals:     options.IgnoreUndefinedSignals,
+		},
+		errorSubscribers: map[int]errorSubscriber{},
+
+		logged:      map[ID]int{},
+		compactions: map[ID]bool{},
+		transitions: map[Signal]int64{},
+		undefined:   map[Index]map[Signal]int64{},
+		rates:       map[[2]Index]*rateTracker{},
+		blackouts:   options.Blackouts,
+		latency:     newHistogram(defaultBuckets...),
+		durations:   newHistogram(defaultBuckets...),
 	}
+	gp.specs.Store(&bound)
 
 	// TODO - add validation error here
 	return gp, nil
 }
 
+// spec returns the current spec.  Reload swaps it in the transaction loop, so that outside of the loop it is
+// read once for a consistent view.
+func (g *runner) spec() *spec {
+	return g.specs.Load().(*spec)
+}
+
+// bind sets the names and flap limits of the options on the spec, and returns a copy of the spec with the
+// actions of the options bound and wrapped, and the names of the actions before they are wrapped
+func bind(spec *spec, options Options) (bound spec, actionNames map[actionKey]string, err error) {
+	if len(options.StateNames) > 0 {
+		spec.stateNames = options.StateNames
+	}
+	if len(options.SignalNames) > 0 {
+		spec.signalNames = options.SignalNames
+	}
+	if len(options.Limits) > 0 {
+		_, err := spec.compileFlapping(options.Limits)
+		if err != nil {
+			return bound, nil, err
+		}
+	}
+
+	if err := options.Enums.check(spec); err != nil {
+		return bound, nil, err
+	}
+
+	if budget := options.ErrorBudget; budget.Errors > 0 {
+		if _, has := spec.signals[budget.Raise]; !has {
+			return bound, nil, ErrUnknownSignal{
+				spec: spec, Signal: budget.Raise, Index: NoState,
+				Help: "error budget raises signal that's not in any state's transitions",
+			}
+		}
+	}
+
+	bound = *spec
+	states, err := bound.bindActions(options.Actions)
+	if err != nil {
+		return bound, nil, err
+	}
+	bound.states = states
+	actionNames = bound.actionNames()
+
+	if options.WrapAction != nil {
+		bound.states = bound.wrapActions(options.WrapAction)
+	}
+	return bound, actionNames, nil
+}
+
 // Stop stops the state machine loop
 func (g *runner) Stop() {
 	if g.running {
@@ -91,31 +216,42 @@ type event struct {
 	ref      *instance
 	signal   Signal
 	data     []interface{}
+	due      Time      // when the signal was due to be raised, if raised by an expired deadline
+	queued   time.Time // when the event was queued
+	origin   Origin
+	at       time.Time // when the event happened, if timestamped by the sender
+	deferred bool      // held until the end of a blackout; guarded by the lock of the instance
+	corrID   CorrelationID
 }
 
 func (g *runner) handleError(tid int64, err error, ctx interface{}) {
 
+	g.errorLock.RLock()
+	ignore := g.ignoreUndefined
+	g.errorLock.RUnlock()
+
+	spec := g.spec() // may be called outside of the transaction loop
 	message := err.Error()
 	switch err := err.(type) {
 	case ErrUnknownState:
-		if g.options.IgnoreUndefinedStates {
+		if ignore.States {
 			return
 		}
 		message = fmt.Sprintf("Unknown: %v", err)
 
 	case ErrUnknownTransition:
-		if g.options.IgnoreUndefinedTransitions {
+		if ignore.Transitions {
 			return
 		}
 		message = fmt.Sprintf("%s: state(%v) on signal(%v)", err.Error(),
-			g.spec.stateName(err.State), g.spec.signalName(err.Signal))
+			spec.stateName(err.State), spec.signalName(err.Signal))
 
 	case ErrUnknownSignal:
-		if g.options.IgnoreUndefinedSignals {
+		if ignore.Signals {
 			return
 		}
 		message = fmt.Sprintf("UnknownSignal: %v, state(%v) on signal(%v)", err,
-			g.spec.stateName(Index(err.Index)), g.spec.signalName(Signal(err.Signal)))
+			spec.stateName(Index(err.Index)), spec.signalName(Signal(err.Signal)))
 
 	case ErrDuplicateState:
 		message = fmt.Sprintf("Duplicate: %v", err)
@@ -129,64 +265,347 @@ func (g *runner) handleError(tid int64, err error, ctx interface{}) {
 	case g.errors <- err: // non-blocking send
 	default:
 	}
+	g.publishError(err, ctx)
+}
+
+func (g *runner) signal(origin Origin, signal Signal, instance *instance, optionalData ...interface{}) error {
+	return g.signalAt(origin, time.Time{}, signal, instance, optionalData...)
+}
+
+// signalAt sends the signal with the time of the event, if not zero, for Options.EventTime
+func (g *runner) signalAt(origin Origin, at time.Time, signal Signal, instance *instance,
+	optionalData ...interface{}) error {
+	if h := instance.handedOff(); h != nil {
+		return h.follow(&event{instance: instance.id, signal: signal, data: optionalData, origin: origin, at: at})
+	}
+	e, err := g.newEvent(origin, at, signal, instance, optionalData)
+	if err != nil {
+		return err
+	}
+	if h := instance.admit(e); h != nil {
+		return h.follow(e) // the instance started moving since the check above
+	}
+	g.events <- e
+	return nil
+}
+
+// newEvent admits the signal to the instance and returns its event, with the data copied and the correlation
+// ID taken out.  The error is why the signal is not admitted.
+func (g *runner) newEvent(origin Origin, at time.Time, signal Signal, instance *instance,
+	optionalData []interface{}) (*event, error) {
+	if instance.isFreed() {
+		return nil, ErrFreed(instance.id)
+	}
+	if err := g.options.Enums.checkSignal(signal); err != nil {
+		return nil, err
+	}
+	spec := g.spec() // called outside of the transaction loop
+	if _, has := spec.signals[signal]; !has {
+		return nil, ErrUnknownSignal{Signal: signal}
+	}
+
+	if g.options.OnSignal != nil && !g.options.OnSignal(instance.id, signal, optionalData) {
+		g.debug("Signal rejected", snapshot{instance},
+			"signal", spec.signalName(signal), "instance", instance.id)
+		return nil, ErrSignalRejected{spec: spec, ID: instance.id, Signal: signal}
+	}
+
+	if g.options.CopyData != nil && len(optionalData) > 0 {
+		copied := make([]interface{}, len(optionalData))
+		for i, data := range optionalData {
+			copied[i] = g.options.CopyData(data)
+		}
+		optionalData = copied
+	}
+	optionalData, corrID := correlation(optionalData)
+
+	g.debug("Signal", snapshot{instance}, "signal", spec.signalName(signal), "instance", instance.id)
+	return &event{instance: instance.id, ref: instance, signal: signal, data: optionalData, queued: time.Now(),
+		origin: origin, at: at, corrID: corrID}, nil
+}
+
+// sequence applies the signals to the instance in one transaction, so that no other event is handled in
+// between.  The signals are admitted as any other signal.  It stops at the first signal that can't be received,
+// is absorbed without a transition, or whose handling fails.
+func (g *runner) sequence(instance *instance, signals []Signal) (err error) {
+	g.do(func(g *runner) {
+		if instance.freed {
+			err = ErrFreed(instance.id)
+			return
+		}
+		if _, has := g.members[instance.id]; !has {
+			err = ErrUnknownFSM(instance.id)
+			return
+		}
+		tid := g.tid()
+		for i, signal := range signals {
+			e, reason := g.newEvent(OriginAPI, time.Time{}, signal, instance, nil)
+			if reason != nil {
+				err = ErrSequence{Applied: i, Err: reason}
+				return
+			}
+			current := instance.state
+			ok, _, reason := g.check(current, signal)
+			if !ok {
+				err = ErrSequence{Applied: i, Err: reason}
+				return
+			}
+			// keep-alives and re-arming signals with no transition are applied without one
+			_, _, undefined := g.spec().transition(current, signal)
+
+			instance.enqueue(e, false)
+			committed := g.transitions[signal]
+			reason = g.handleEvent(tid, instance, e)
+			if reason == nil && undefined == nil && g.transitions[signal] == committed {
+				reason = ErrAbsorbed{spec: g.spec(), ID: instance.id, State: current, Signal: signal}
+			}
+			if reason != nil {
+				err = ErrSequence{Applied: i, Err: reason}
+				return
+			}
+		}
+	})
+	return
+}
+
+// check returns whether the state can receive the signal, the next state, and the reason if not.
+// Signals that only keep alive or re-arm the state are received without changing the state.
+func (g *runner) check(current Index, signal Signal) (ok bool, next Index, reason error) {
+	next, _, reason = g.spec().transition(current, signal)
+	if reason == nil {
+		return true, next, nil
+	}
+	if watchdog := g.spec().watchdog(current); g.spec().rearms(current, signal) ||
+		(watchdog != nil && watchdog.KeepAlive == signal) {
+		return true, current, nil
+	}
+	return false, next, reason
+}
+
+// do executes the function on the transaction loop and waits for it to complete.
+func (g *runner) do(f func(*runner)) {
+	done := make(chan struct{})
+	g.reads <- func(view *runner) {
+		defer close(done)
+		f(view)
+	}
+	<-done
+}
+
+// doRunning is do unless the runner is stopped, e.g. to cancel a watch that may outlive the runner.  It
+// returns false if the function may not have run.
+func (g *runner) doRunning(f func(*runner)) bool {
+	done := make(chan struct{})
+	select {
+	case g.reads <- func(view *runner) {
+		defer close(done)
+		f(view)
+	}:
+	case <-g.stop:
+		return false
+	}
+	select {
+	case <-done:
+		return true
+	case <-g.stop:
+		return false
+	}
+}
+
+// forEach visits the instances in the order of ID until the function returns false.
+// This must be called from within the transaction loop.
+func (g *runner) forEach(f func(*instance) bool) {
+	ids := make([]ID, 0, len(g.members))
+	for id := range g.members {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		if !f(g.members[id]) {
+			return
+		}
+	}
+}
+
+// reindex moves the instance from one state to another in the by-state index
+func (g *runner) reindex(i *instance, from, to Index) {
+	if set, has := g.bystate[from]; has {
+		delete(set, i.id)
+	}
+	set, has := g.bystate[to]
+	if !has {
+		set = map[ID]*instance{}
+		g.bystate[to] = set
+	}
+	set[i.id] = i
+}
+
+// count returns the number of instances in the given states.
+// This must be called from within the transaction loop.
+func (g *runner) count(states ...Index) (total int) {
+	for _, state := range states {
+		total += len(g.bystate[state])
+	}
+	return
 }
 
-func (g *runner) signal(signal Signal, instance *instance, optionalData ...interface{}) error {
-	if _, has := g.spec.signals[signal]; !has {
-		return ErrUnknownSignal{Signal: signal}
+// counts returns the number of instances in each state.  This must be called from within the transaction loop.
+func (g *runner) counts() map[Index]int {
+	counts := map[Index]int{}
+	for state, members := range g.bystate {
+		if len(members) > 0 {
+			counts[state] = len(members)
+		}
 	}
+	return counts
+}
 
-	g.log.Debug("Signal", "signal", g.spec.signalName(signal), "instance", instance)
-	g.events <- &event{instance: instance.id, ref: instance, signal: signal, data: optionalData}
+// free removes the instance from the set.  This must be called from within the transaction loop.
+func (g *runner) free(id ID) error {
+	instance, has := g.members[id]
+	if !has {
+		return ErrUnknownFSM(id)
+	}
+	if instance.Pinned() {
+		return ErrPinned(id)
+	}
+	if instance.index > -1 {
+		g.deadlines.remove(instance)
+	}
+	delete(g.members, id)
+	delete(g.bystate[instance.state], id)
+	g.unindexExternal(instance)
+	g.exitSub(instance)
+	instance.setFreed()
+	if g.options.IDs == IDReuse {
+		g.freed = append(g.freed, id)
+	}
+	g.logFree(g.tid(), id)
 	return nil
 }
 
-func (g *runner) alloc(initial Index) (FSM, error) {
+func (g *runner) alloc(initial Index) (fsm FSM, err error) {
+	g.do(func(g *runner) {
+		fsm, err = g.allocate(initial)
+	})
+	return
+}
 
-	tid := g.tid()
+func (g *runner) allocate(initial Index) (FSM, error) {
+	new, err := g.add(g.tid(), SeedItem{State: initial})
+	if err != nil {
+		return nil, err
+	}
+	return new, nil
+}
+
+func (g *runner) allocateAt(initial Index, elapsed Tick) (FSM, error) {
+	new, err := g.add(g.tid(), SeedItem{State: initial, Elapsed: elapsed})
+	if err != nil {
+		return nil, err
+	}
+	return new, nil
+}
+
+// add adds a new instance in the state of the item, with its labels, data and remaining TTL.
+// This must be called from within the transaction loop.
+func (g *runner) add(tid int64, item SeedItem) (*instance, error) {
+
+	initial := item.State
+	if err := g.options.Enums.checkState(initial); err != nil {
+		return nil, err
+	}
 
 	// add a new instance
-	id := g.next
-	g.next++
+	id := g.nextID()
 
 	new := &instance{
-		id:     id,
-		state:  initial,
-		index:  -1,
-		parent: g,
-		flaps:  *newFlaps(),
+		id:      id,
+		state:   initial,
+		index:   -1,
+		parent:  g,
+		flaps:   *newFlaps(),
+		streaks: *newStreaks(),
 		visits: map[Index]int{
-			initial: 1,
+			initial: 1, // counted again on entering the initial state below, for the limits of Visit
 		},
+		labels: copyMeta(item.Labels),
+		data:   item.Data,
+	}
+	if new.data == nil && g.options.NewData != nil {
+		new.data = g.options.NewData(id)
+	}
+	if new.data != nil {
+		if err := g.dataQuota(id, initial, NoSignal, new.data); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := g.processDeadline(tid, new, initial); err != nil {
-		g.log.Error("error process deadline", "err", err)
+		g.log.Error("error process deadline", g.withFields(snapshot{new}, "err", err)...)
 		return nil, err
 	}
+	if new.deadline > 0 && (item.TTL > 0 || item.Elapsed > 0) {
+		if item.TTL > 0 {
+			new.deadline = g.ct() + Time(item.TTL)
+		} else {
+			new.deadline -= Time(item.Elapsed)
+		}
+		if new.deadline <= g.ct() {
+			new.deadline = g.ct() + 1 // overdue; expires on the next tick
+		}
+		g.deadlines.update(new)
+	}
+	new.created = new.changed
+	g.members[id] = new
+	g.reindex(new, NoState, initial)
+	g.indexExternal(new)
+	g.logNew(tid, new)
+	g.enterSub(new, initial)
+
 	if new.index > -1 {
-		g.log.Debug("runner deadline",
-			"tid", tid, "id", id, "initial", g.spec.stateName(initial),
+		g.debug("runner deadline", snapshot{new},
+			"tid", tid, "id", id, "initial", g.spec().stateName(initial),
 			"deadline", new.deadline, "queuePosition", new.index)
 	}
 
 	return new, nil
 }
 
-func (g *runner) tick() {
+func (g *runner) tick(tick Tick) {
+	g.ticks++
+	if tick > 1 && g.options.ClockJump == JumpFire {
+		g.now += Time(tick)
+		return
+	}
 	g.now++
 }
 
+// tickLag returns the number of ticks received but not yet processed
+func (g *runner) tickLag() int64 {
+	return atomic.LoadInt64(&g.received) - g.ticks
+}
+
 func (g *runner) ct() Time {
 	return g.now
 }
 
-func (g *runner) handleClockTick(tid int64) error {
+func (g *runner) handleClockTick(tid int64, tick Tick) error {
 
-	g.tick()
+	g.tick(tick)
 	now := g.ct()
 
 	g.log.Debug("Clock tick", "tid", tid, "now", now)
+
+	if lag := g.tickLag(); g.options.MaxTickLag > 0 && lag > g.options.MaxTickLag {
+		g.lagAlerts++
+		g.handleError(tid, ErrTickLag(lag), now)
+	}
+
+	g.releaseDeferred(tid)
+	g.processWatchdogs(tid)
+	g.compactOnTick(tid)
+
 	for g.deadlines.Len() > 0 {
 
 		instance := g.deadlines.peek()
@@ -199,40 +618,89 @@ func (g *runner) handleClockTick(tid int64) error {
 		}
 
 		instance = g.deadlines.dequeue()
+		due := instance.deadline
+
+		// reset the state for future queueing
+		instance.deadline = -1
+		instance.index = -1
 
 		// check > 0 here because we could have already raised the signal
 		// when a real event came in.
-		if instance.deadline > 0 {
+		if due > 0 && g.holdInWarmup(instance) {
+			continue
+		}
+
+		if due > 0 {
 
 			// raise the signal
-			if ttl, err := g.spec.expiry(instance.state); err != nil {
+			if ttl, err := g.spec().expiry(instance.state); err != nil {
 
 				return err
 
 			} else if ttl != nil {
 
-				g.log.Error("deadline exceeded", "tid", tid, "id", instance.id,
-					"raise", g.spec.signalName(ttl.Raise), "now", now)
+				g.log.Error("deadline exceeded", g.withFields(snapshot{instance}, "tid", tid, "id", instance.id,
+					"raise", g.spec().signalName(ttl.Raise), "now", now, "due", due)...)
 
-				g.raise(tid, instance, ttl.Raise, instance.state)
+				g.expired(instance, instance.state)
+
+				event := &event{instance: instance.id, ref: instance, signal: ttl.Raise, due: due, origin: OriginTTL}
+				if g.deferInBlackout(instance, event) {
+					continue
+				}
+				if g.options.InlineDeadlines {
+					if err := g.handleEvent(tid, instance, event); err != nil {
+						g.handleError(tid, err, event)
+					}
+				} else if !g.raiseEvent(tid, instance, event) {
+					// the signal is dropped by a full queue, so it's raised again on the next tick
+					if instance.expiries[instance.state] > 0 {
+						instance.expiries[instance.state]--
+					}
+					instance.deadline = now + 1
+					g.deadlines.enqueue(instance)
+				}
 			}
 		}
-		// reset the state for future queueing
-		instance.deadline = -1
-		instance.index = -1
 
 	}
 	return nil
 }
 
+// pendingDeadlines returns the deadlines in the queue in the order they are due.
+// This must be called from within the transaction loop.
+func (g *runner) pendingDeadlines() []DeadlineInfo {
+	pending := []DeadlineInfo{}
+	for _, instance := range *g.deadlines {
+		if instance.deadline <= 0 {
+			continue
+		}
+		if ttl, err := g.spec().expiry(instance.state); err == nil && ttl != nil {
+			pending = append(pending, DeadlineInfo{
+				ID:    instance.id,
+				State: instance.state,
+				Due:   instance.deadline,
+				Raise: ttl.Raise,
+			})
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].Due == pending[j].Due {
+			return pending[i].ID < pending[j].ID
+		}
+		return pending[i].Due < pending[j].Due
+	})
+	return pending
+}
+
 func (g *runner) processDeadline(tid int64, instance *instance, state Index) error {
 	now := g.ct()
 	ttl := Tick(0)
 	// check for TTL
-	if exp, err := g.spec.expiry(state); err != nil {
+	if exp, err := g.spec().expiry(state); err != nil {
 		return err
 	} else if exp != nil {
-		ttl = exp.TTL
+		ttl = g.ttl(instance, state, exp.TTL)
 	}
 
 	instance.update(state, now, ttl)
@@ -241,19 +709,19 @@ func (g *runner) processDeadline(tid int64, instance *instance, state Index) err
 		// case where this instance is in the deadlines queue (since it has a > -1 index)
 		if instance.deadline > 0 {
 			// in the queue and deadline is different now
-			g.log.Debug("Deadline updating", "now", now, "tid", tid,
+			g.debug("Deadline updating", snapshot{instance}, "now", now, "tid", tid,
 				"instance", instance.id, "deadline", instance.deadline,
 				"deadline-queue-index", instance.index)
 			g.deadlines.update(instance)
 		} else {
-			g.log.Debug("Deadline removing", "now", now, "tid", tid,
+			g.debug("Deadline removing", snapshot{instance}, "now", now, "tid", tid,
 				"instance", instance.id, "deadline", instance.deadline,
 				"deadline-queue-index", instance.index)
 			g.deadlines.remove(instance)
 		}
 	} else if instance.deadline > 0 {
 		// index == -1 means it's not in the queue yet and we have a deadline
-		g.log.Debug("Deadline enqueuing", "now", now, "tid", tid,
+		g.debug("Deadline enqueuing", snapshot{instance}, "now", now, "tid", tid,
 			"instance", instance.id, "deadline", instance.deadline,
 			"deadline-queue-index", instance.index)
 		g.deadlines.enqueue(instance)
@@ -262,9 +730,34 @@ func (g *runner) processDeadline(tid int64, instance *instance, state Index) err
 	return nil
 }
 
+// rearmDeadline resets the deadline of the instance if the signal re-arms the TTL of the current state.
+// Returns true if the signal has been consumed, i.e. it re-arms and is not also a transition.
+func (g *runner) rearmDeadline(tid int64, instance *instance, signal Signal) bool {
+	if !g.spec().rearms(instance.state, signal) {
+		return false
+	}
+
+	state := g.spec().states[instance.state]
+	instance.deadline = g.ct() + Time(g.ttl(instance, instance.state, state.TTL.TTL))
+
+	g.debug("Deadline rearming", snapshot{instance}, "now", g.ct(), "tid", tid,
+		"instance", instance.id, "deadline", instance.deadline,
+		"deadline-queue-index", instance.index)
+
+	if instance.index > -1 {
+		g.deadlines.update(instance)
+	} else {
+		g.deadlines.enqueue(instance)
+	}
+	g.logRearm(tid, instance)
+
+	_, has := state.Transitions[signal]
+	return !has
+}
+
 func (g *runner) processVisitLimit(tid int64, instance *instance, state Index) error {
 	// have we visited next state too many times?
-	if limit, err := g.spec.visit(state); err != nil {
+	if limit, err := g.spec().visit(state); err != nil {
 
 		return err
 
@@ -272,11 +765,11 @@ func (g *runner) processVisitLimit(tid int64, instance *instance, state Index) e
 
 		if limit.Value > 0 && instance.visits[state] == limit.Value {
 
-			g.log.Debug("Max visit limit hit", "tid", tid,
-				"instance", instance.id, "state", g.spec.stateName(instance.state),
-				"raise", g.spec.signalName(limit.Raise))
+			g.debug("Max visit limit hit", snapshot{instance}, "tid", tid,
+				"instance", instance.id, "state", g.spec().stateName(instance.state),
+				"raise", g.spec().signalName(limit.Raise))
 
-			g.raise(tid, instance, limit.Raise, instance.state)
+			g.raise(tid, instance, limit.Raise, instance.state, OriginVisitLimit)
 
 			return nil
 		}
@@ -285,120 +778,320 @@ func (g *runner) processVisitLimit(tid int64, instance *instance, state Index) e
 }
 
 // raises a signal by placing directly on the txn queue
-func (g *runner) raise(tid int64, instance *instance, signal Signal, current Index) (err error) {
+func (g *runner) raise(tid int64, instance *instance, signal Signal, current Index, origin Origin) (err error) {
 	defer func() {
-		g.log.Debug("instance.signal", "instance", instance.ID(),
-			"signal", g.spec.signalName(signal), "state", g.spec.stateName(current), "err", err)
+		g.debug("instance.signal", snapshot{instance}, "instance", instance.ID(),
+			"signal", g.spec().signalName(signal), "state", g.spec().stateName(current), "err", err)
 	}()
 
-	if _, has := g.spec.signals[signal]; !has {
+	if _, has := g.spec().signals[signal]; !has {
 		err = ErrUnknownSignal{Signal: signal}
 		return
 	}
 
-	event := &event{instance: instance.id, ref: instance, signal: signal}
+	event := &event{instance: instance.id, ref: instance, signal: signal, origin: origin}
+	if !g.deferInBlackout(instance, event) {
+		g.raiseEvent(tid, instance, event)
+	}
+	return nil
+}
+
+// overdue returns how many ticks past its due time the event is being processed, including
+// the ticks received but not yet processed.
+func (g *runner) overdue(event *event) Tick {
+	if event.due <= 0 {
+		return 0
+	}
+	return Tick(g.ct()-event.due) + Tick(g.tickLag())
+}
+
+// invoke calls the action, bounded by the action timeout of the current state, if any.
+// On timeout, the action continues to run in the background but its result is ignored.
+func (g *runner) invoke(instance *instance, current Index, signal Signal, action Action) error {
+	return g.invokeAs(instance, instance, current, signal, action)
+}
+
+// invokeAs is invoke with the FSM given to the action, e.g. with the context of the transition
+func (g *runner) invokeAs(f FSM, instance *instance, current Index, signal Signal, action Action) error {
+	timeout := g.spec().states[current].ActionTimeout
+	if timeout <= 0 {
+		return action(f)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- action(f)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-result:
+		return err
+	case <-timer.C:
+		return ErrActionTimeout{spec: g.spec(), ID: instance.id, State: current, Signal: signal, Timeout: timeout}
+	}
+}
 
-	g.transactions <- &txn{
+// raiseEvent places the event directly on the txn queue
+func (g *runner) raiseEvent(tid int64, instance *instance, event *event) (queued bool) {
+	if event.queued.IsZero() {
+		event.queued = time.Now()
+	}
+	instance.enqueue(event, false)
+	select {
+	case g.transactions <- &txn{
 		Func: func(tid int64) (interface{}, error) {
 			return event, g.handleEvent(tid, instance, event)
 		},
 		tid: tid,
+	}:
+		return true
+	default:
+		// the loop is the consumer of the queue, so it can't wait for room
+		instance.dequeue(event)
+		g.handleError(tid, ErrQueueFull{spec: g.spec(), ID: instance.id, Signal: event.signal, Origin: event.origin},
+			instance.id)
+		return false
 	}
-	return nil
 }
 
 func (g *runner) handleEvent(tid int64, instance *instance, event *event) error {
 
 	now := g.ct()
 
-	// instance, has := g.members[event.instance]
-	// if !has {
-	// 	return ErrUnknownFSM(event.instance)
-	// }
+	instance.dequeue(event)
+
+	if !event.queued.IsZero() {
+		g.latency.observe(time.Since(event.queued))
+	}
+
+	// signals to instances moved to another runner follow them
+	if g.forward(instance, event) {
+		return nil
+	}
+
+	// signals to freed instances or instances in terminal states
+	if handled, err := g.terminal(instance, event); handled {
+		return err
+	}
+
+	// sticky states hold against automation until acknowledged
+	if handled, err := g.sticky(tid, instance, event); handled {
+		return err
+	}
+
+	// keep-alives and re-arming signals don't necessarily transition
+	alive := g.keepAlive(tid, instance, event.signal)
+	rearmed := g.rearmDeadline(tid, instance, event.signal)
+	if alive || rearmed {
+		return nil
+	}
 
 	current := instance.state
-	next, action, err := g.spec.transition(current, event.signal)
+	next, action, err := g.spec().transition(current, event.signal)
 	if err != nil {
+		g.countUndefined(current, event.signal)
 		return err
 	}
-
-	g.log.Debug("Transition",
+	g.debug("Transition", snapshot{instance},
 		"now", now,
 		"tid", tid,
 		"instance", instance.id,
-		"state", g.spec.stateName(current),
-		"signal", g.spec.signalName(event.signal),
-		"next", g.spec.stateName(next),
+		"state", g.spec().stateName(current),
+		"signal", g.spec().signalName(event.signal),
+		"next", g.spec().stateName(next),
 		"deadline", instance.deadline, "deadlineQueueIndex", instance.index)
 
+	// windows are in event time, if enabled
+	at := g.eventTime(event, now)
+
+	// has the signal been received enough times to fire?
+	if !instance.streaks.receive(event.signal, g.spec().threshold(current, event.signal), at) {
+
+		g.debug("Below threshold", snapshot{instance}, "tid", tid, "instance", instance.id,
+			"state", g.spec().stateName(current), "signal", g.spec().signalName(event.signal))
+
+		return nil
+	}
+
+	// the choice is made once the signal fires, so that the quota and vetoers check the state chosen
+	if !g.spec().errorsFirst(current, event.signal) {
+		// signals that follow Errors first have no choice
+		if next, err = g.choose(instance, current, next, event); err != nil {
+			return err
+		}
+	}
+
+	// can the transition be made?
+	if event.data != nil {
+		if err := g.dataQuota(instance.id, next, event.signal, event.data); err != nil {
+			return err
+		}
+	}
+	if err := g.veto(instance, current, next, event); err != nil {
+		return err
+	}
 	// any flap detection?
-	limit := g.spec.flap(current, next)
+	limit := g.spec().flap(current, next)
 	if limit != nil && limit.Count > 0 {
 
-		instance.flaps.record(current, next)
+		instance.flaps.recordAt(current, next, instance.start, at)
 		flaps := instance.flaps.count(current, next)
 
 		if flaps >= limit.Count {
 
-			g.log.Debug("Flapping", "tid", tid, "flaps", flaps,
+			g.debug("Flapping", snapshot{instance}, "tid", tid, "flaps", flaps,
 				"instance", instance.id, "state", instance.state, "raise", limit.Raise)
-			g.raise(tid, instance, limit.Raise, instance.state)
+			g.raise(tid, instance, limit.Raise, instance.state, OriginFlap)
 
 			return nil // done -- another transition
 		}
 	}
 
+	// prepared after the flap detection, as flapping raises another transition instead
+	if err := g.prepare(instance, current, event); err != nil {
+		return err
+	}
+
 	// Associate custom data - do this before calling on the action so action can do something with it.
+	data := instance.data
 	if event.data != nil {
 		instance.data = event.data
 	}
 
 	// call action before transitiion
-	if action != nil {
+	var failed error
+	var skipped *SkippedAction
+	if g.spec().errorsFirst(current, event.signal) {
+
+		g.onError(tid, instance, current, event)
+
+	} else if action != nil && g.options.DryRun {
+
+		skipped = g.skipAction(current, event)
+
+	} else if action != nil {
 
-		g.log.Debug("Invoking action",
+		g.debug("Invoking action", snapshot{instance},
 			"now", now,
 			"tid", tid,
 			"instance", instance.id,
-			"state", g.spec.stateName(current),
-			"signal", g.spec.signalName(event.signal),
-			"next", g.spec.stateName(next),
+			"state", g.spec().stateName(current),
+			"signal", g.spec().signalName(event.signal),
+			"next", g.spec().stateName(next),
 			"deadline", instance.deadline, "deadlineQueueIndex", instance.index)
 
-		if err := action(instance); err != nil {
+		instance.setOverdue(g.overdue(event))
+		started := time.Now()
+		err := g.invokeAs(g.given(instance, current, next, event), instance, current, event.signal, action)
+		g.durations.observe(time.Since(started))
+		instance.setOverdue(0)
+		failed = err
 
-			g.log.Debug("Error transition", "err", err)
+		if err != nil {
 
-			if alternate, err := g.spec.error(current, event.signal); err != nil {
+			g.log.Debug("Error transition", "err", err)
 
+			if _, is := err.(ErrActionTimeout); is {
 				g.handleError(tid, err, []interface{}{current, event, instance})
+			}
+
+			if alternate, routeErr := g.spec().error(current, event.signal); routeErr != nil {
+
+				if g.spec().states[current].RollbackOnError {
+					// stay in the current state, with its deadline as it is
+					instance.data = data
+					return ErrRolledBack{spec: g.spec(), ID: instance.id, State: current, Signal: event.signal, Err: err}
+				}
+
+				g.handleError(tid, routeErr, []interface{}{current, event, instance})
 
 			} else {
 
-				g.log.Debug("Err executing action", "tid", tid, "instance", instance.id,
+				g.debug("Err executing action", snapshot{instance}, "tid", tid, "instance", instance.id,
 					"state", current, "signal", event.signal, "alternate", alternate, "next", next)
 
 				next = alternate
+				if event.data != nil {
+					// the quota was checked against the state of the transition, not of Errors
+					if err := g.dataQuota(instance.id, next, event.signal, event.data); err != nil {
+						instance.data = data
+						g.handleError(tid, err, []interface{}{current, event, instance})
+					}
+				}
+				g.onError(tid, instance, current, event)
 			}
 		}
 	}
 
 	// Action has been run... We landed in the new state (next)
 
+	if next == current && g.spec().internal(current, event.signal) {
+		return g.handleInternal(tid, instance, event, failed, skipped, now)
+	}
+
+	g.resetBackoff(instance, current, next, event)
+
 	// process deadline, if any
 	if err := g.processDeadline(tid, instance, next); err != nil {
 		return err
 	}
 
+	instance.setOrigin(event.origin)
+
 	// update the index
-	// BYSTATE
-	// delete(g.bystate[current], instance.id)
-	// g.bystate[next][instance.id] = instance
+	g.reindex(instance, current, next)
+	if next != current {
+		instance.acked = false
+		g.exitSub(instance)
+		g.enterSub(instance, next)
+	}
+	g.recordChange(instance, next, now)
+
+	transition := g.transition(instance, current, next, event.signal, failed)
+	transition.Skipped = skipped
+	transition.CorrelationID = event.corrID
+	g.committed(transition)
+	g.logCommitted(tid, instance, transition)
+
+	if failed == nil {
+		g.commit(tid, instance, current, event.signal)
+	}
+
+	if failed != nil {
+		if err := g.spendErrorBudget(tid, instance, next, now); err != nil {
+			return err
+		}
+	}
 
 	// visits limit trigger
 	return g.processVisitLimit(tid, instance, next)
 }
 
+// handleInternal completes an internal transition, without re-entering the state
+func (g *runner) handleInternal(tid int64, instance *instance, event *event, failed error, skipped *SkippedAction,
+	now Time) error {
+
+	current := instance.state
+	instance.setOrigin(event.origin)
+
+	transition := g.transition(instance, current, current, event.signal, failed)
+	transition.Skipped = skipped
+	transition.CorrelationID = event.corrID
+	transition.Internal = true
+	transition.WallTime = time.Now() // the state, and when it was entered, don't change
+	g.committed(transition)
+	g.logCommitted(tid, instance, transition)
+
+	if failed == nil {
+		g.commit(tid, instance, current, event.signal)
+		return nil
+	}
+	return g.spendErrorBudget(tid, instance, current, now)
+}
+
 func (g *runner) tid() int64 {
 	return time.Now().UnixNano()
 }
@@ -428,9 +1121,7 @@ func (g *runner) run() {
 				if t == nil {
 					return
 				}
-				if ctx, err := t.Func(t.tid); err != nil {
-					g.handleError(t.tid, err, ctx)
-				}
+				g.process(t)
 
 			}
 		}
@@ -440,22 +1131,54 @@ func (g *runner) run() {
 
 	go func() {
 
+		// detect stalls in the clock, if configured
+		var stall *time.Timer
+		var stalled <-chan time.Time
+		if g.options.ClockStall > 0 {
+			stall = time.NewTimer(g.options.ClockStall)
+			defer stall.Stop()
+			stalled = stall.C
+		}
+
+		onTick := func(tick Tick) *txn {
+			if stall != nil {
+				if !stall.Stop() {
+					select {
+					case <-stall.C:
+					default:
+					}
+				}
+				stall.Reset(g.options.ClockStall)
+			}
+			return g.tickTxn(tick)
+		}
+
 	loop:
 		for {
 
-			var tx *txn
 			tid := g.tid()
 
+			tx := g.prioritized(tid, onTick)
+			if tx != nil {
+				g.transactions <- tx
+				continue
+			}
+
 			select {
 
-			case <-g.clock.C:
+			case <-stalled:
+				stall.Reset(g.options.ClockStall)
 				tx = &txn{
-					tid: g.tid(),
+					tid: tid,
 					Func: func(tid int64) (interface{}, error) {
-						return nil, g.handleClockTick(tid)
+						g.stalls++
+						return g.ct(), ErrClockStalled(g.options.ClockStall)
 					},
 				}
 
+			case tick := <-g.clock.C:
+				tx = onTick(tick)
+
 			case <-g.stop:
 				break loop
 
@@ -464,24 +1187,10 @@ func (g *runner) run() {
 				if !ok {
 					break loop
 				}
-
-				copy := event
-				tx = &txn{
-					tid: tid,
-					Func: func(tid int64) (interface{}, error) {
-						return copy, g.handleEvent(tid, event.ref, copy)
-					},
-				}
+				tx = g.eventTxn(tid, event)
 
 			case reader := <-g.reads:
-				tx = &txn{
-					tid: tid,
-					Func: func(tid int64) (interface{}, error) {
-						// For reads on the runner itself.  All the reads are serialized.
-						reader(g)
-						return nil, nil
-					},
-				}
+				tx = g.readTxn(tid, reader)
 			}
 
 			// send to transaction processing pipeline
@@ -491,3 +1200,39 @@ func (g *runner) run() {
 
 	}()
 }
+
+func (g *runner) process(t *txn) {
+	if ctx, err := t.Func(t.tid); err != nil {
+		g.handleError(t.tid, err, ctx)
+	}
+}
+
+func (g *runner) tickTxn(tick Tick) *txn {
+	atomic.AddInt64(&g.received, 1)
+	return &txn{
+		tid: g.tid(),
+		Func: func(tid int64) (interface{}, error) {
+			return nil, g.handleClockTick(tid, tick)
+		},
+	}
+}
+
+func (g *runner) eventTxn(tid int64, event *event) *txn {
+	return &txn{
+		tid: tid,
+		Func: func(tid int64) (interface{}, error) {
+			return event, g.handleEvent(tid, event.ref, event)
+		},
+	}
+}
+
+func (g *runner) readTxn(tid int64, reader func(*runner)) *txn {
+	return &txn{
+		tid: tid,
+		Func: func(tid int64) (interface{}, error) {
+			// For reads on the runner itself.  All the reads are serialized.
+			reader(g)
+			return nil, nil
+		},
+	}
+}
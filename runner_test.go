@@ -502,3 +502,614 @@ func TestActionErrors(t *testing.T) {
 
 	t.Log("stopping")
 }
+
+func TestRearmDeadline(t *testing.T) {
+
+	const (
+		leased Index = iota
+		expired
+	)
+
+	const (
+		ping Signal = iota
+		expire
+	)
+
+	machines, err := define(
+		State{
+			Index: leased,
+			Transitions: map[Signal]Index{
+				expire: expired,
+			},
+			TTL:   Expiry{3, expire},
+			Rearm: []Signal{ping},
+		},
+		State{
+			Index: expired,
+		},
+	)
+	require.NoError(t, err)
+
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, DefaultOptions()))
+	defer machines.Done()
+
+	instance, err := machines.New(leased)
+	require.NoError(t, err)
+
+	for i := 0; i < 6; i++ {
+		clock.Tick()
+		require.NoError(t, instance.Signal(ping))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, leased, instance.State())
+
+	clock.Tick()
+	clock.Tick()
+	clock.Tick()
+
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, expired, instance.State())
+
+	// there's nothing to rearm without a TTL
+	_, err = define(
+		State{
+			Index: leased,
+			Transitions: map[Signal]Index{
+				expire: expired,
+			},
+			Rearm: []Signal{ping},
+		},
+		State{
+			Index: expired,
+		},
+	)
+	require.Equal(t, SpecError, ClassOf(err))
+}
+
+func TestOnSignal(t *testing.T) {
+
+	const (
+		on Index = iota
+		off
+	)
+
+	const (
+		turnOn Signal = iota
+		turnOff
+	)
+
+	machines, err := define(
+		State{
+			Index: on,
+			Transitions: map[Signal]Index{
+				turnOff: off,
+			},
+		},
+		State{
+			Index: off,
+			Transitions: map[Signal]Index{
+				turnOn: on,
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	seen := []Signal{}
+	options := DefaultOptions()
+	options.OnSignal = func(id ID, s Signal, data []interface{}) bool {
+		seen = append(seen, s)
+		return s != turnOff
+	}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	instance, err := machines.New(off)
+	require.NoError(t, err)
+
+	require.NoError(t, instance.Signal(turnOn))
+	require.Equal(t, on, instance.State())
+
+	err = instance.Signal(turnOff)
+	require.Error(t, err)
+	require.IsType(t, ErrSignalRejected{}, err)
+	require.Equal(t, on, instance.State())
+
+	require.Equal(t, []Signal{turnOn, turnOff}, seen)
+}
+
+func TestNewData(t *testing.T) {
+
+	const (
+		on Index = iota
+		off
+	)
+
+	const (
+		turnOn Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: on,
+		},
+		State{
+			Index: off,
+			Transitions: map[Signal]Index{
+				turnOn: on,
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	type target struct {
+		id  ID
+		url string
+	}
+
+	options := DefaultOptions()
+	options.NewData = func(id ID) interface{} {
+		return &target{id: id}
+	}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	a, err := machines.New(off)
+	require.NoError(t, err)
+	b, err := machines.New(off)
+	require.NoError(t, err)
+
+	require.Equal(t, &target{id: a.ID()}, a.Data())
+	require.Equal(t, &target{id: b.ID()}, b.Data())
+
+	a.Data().(*target).url = "http://localhost:8080"
+	require.NoError(t, a.Signal(turnOn))
+	require.Equal(t, on, a.State())
+	require.Equal(t, &target{id: a.ID(), url: "http://localhost:8080"}, a.Data())
+}
+
+func TestInlineDeadlines(t *testing.T) {
+
+	const (
+		waiting Index = iota
+		starting
+		idle
+		busy
+	)
+
+	const (
+		start Signal = iota
+		timeout
+		work
+	)
+
+	overdue := make(chan Tick, 1)
+	release := make(chan struct{})
+	machines, err := define(
+		State{
+			Index: waiting,
+			Transitions: map[Signal]Index{
+				start: starting,
+			},
+			Actions: map[Signal]Action{
+				start: func(f FSM) error {
+					overdue <- f.Overdue()
+					return nil
+				},
+			},
+			TTL: Expiry{2, start},
+		},
+		State{
+			Index: starting,
+			Transitions: map[Signal]Index{
+				timeout: waiting,
+			},
+			TTL: Expiry{10, timeout},
+		},
+		State{
+			Index: idle,
+			Transitions: map[Signal]Index{
+				work: busy,
+			},
+			Actions: map[Signal]Action{
+				work: func(FSM) error {
+					<-release
+					return nil
+				},
+			},
+		},
+		State{
+			Index: busy,
+		},
+	)
+	require.NoError(t, err)
+
+	clock := NewClock()
+	options := DefaultOptions()
+	options.InlineDeadlines = true
+	require.NoError(t, machines.Run(clock, options))
+	defer machines.Done()
+
+	instance, err := machines.New(waiting)
+	require.NoError(t, err)
+
+	blocker, err := machines.New(idle)
+	require.NoError(t, err)
+	require.NoError(t, blocker.Signal(work))
+
+	// the ticks back up while the action blocks
+	clock.Ticks(5)
+	close(release)
+
+	// expired at t=2 while 3 more ticks were waiting to be processed
+	require.Equal(t, Tick(3), <-overdue)
+	require.Equal(t, []DeadlineInfo{
+		{ID: instance.ID(), State: starting, Due: 12, Raise: timeout},
+	}, machines.PendingDeadlines())
+}
+
+func TestActionTimeout(t *testing.T) {
+
+	const (
+		down Index = iota
+		provisioning
+		failed
+	)
+
+	const (
+		provision Signal = iota
+	)
+
+	release := make(chan struct{})
+	defer close(release)
+
+	machines, err := define(
+		State{
+			Index: down,
+			Transitions: map[Signal]Index{
+				provision: provisioning,
+			},
+			Actions: map[Signal]Action{
+				provision: func(FSM) error {
+					<-release
+					return nil
+				},
+			},
+			Errors: map[Signal]Index{
+				provision: failed,
+			},
+			ActionTimeout: 50 * time.Millisecond,
+		},
+		State{
+			Index: provisioning,
+		},
+		State{
+			Index: failed,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- <-machines.Errors()
+	}()
+
+	instance, err := machines.New(down)
+	require.NoError(t, err)
+	require.NoError(t, instance.Signal(provision))
+	require.Equal(t, failed, instance.State())
+
+	err = <-errs
+	require.IsType(t, ErrActionTimeout{}, err)
+	require.Equal(t, provision, err.(ErrActionTimeout).Signal)
+}
+
+func TestCheck(t *testing.T) {
+
+	const (
+		up Index = iota
+		down
+	)
+
+	const (
+		shutdown Signal = iota
+		startup
+		ping
+		undefined
+	)
+
+	machines, err := define(
+		State{
+			Index: up,
+			Transitions: map[Signal]Index{
+				shutdown: down,
+			},
+			Watchdog: Watchdog{KeepAlive: ping, TTL: 5, Raise: shutdown},
+		},
+		State{
+			Index: down,
+			Transitions: map[Signal]Index{
+				startup: up,
+			},
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	instance, err := machines.New(up)
+	require.NoError(t, err)
+
+	ok, next, reason := instance.Check(shutdown)
+	require.True(t, ok)
+	require.Equal(t, down, next)
+	require.NoError(t, reason)
+
+	ok, next, reason = instance.Check(ping)
+	require.True(t, ok)
+	require.Equal(t, up, next)
+	require.NoError(t, reason)
+
+	ok, _, reason = instance.Check(startup)
+	require.False(t, ok)
+	require.IsType(t, ErrUnknownTransition{}, reason)
+
+	ok, _, reason = instance.Check(undefined)
+	require.False(t, ok)
+	require.IsType(t, ErrUnknownSignal{}, reason)
+
+	require.True(t, instance.CanReceive(shutdown))
+	require.False(t, instance.CanReceive(startup))
+}
+
+func TestWrapAction(t *testing.T) {
+
+	const (
+		down Index = iota
+		up
+		failed
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: down,
+			Transitions: map[Signal]Index{
+				start: up,
+			},
+			Actions: map[Signal]Action{
+				start: func(FSM) error {
+					panic("boom")
+				},
+			},
+			Errors: map[Signal]Index{
+				start: failed,
+			},
+		},
+		State{
+			Index: up,
+		},
+		State{
+			Index: failed,
+		},
+	)
+	require.NoError(t, err)
+
+	names := []string{}
+	options := DefaultOptions()
+	options.StateNames = map[Index]string{down: "down"}
+	options.SignalNames = map[Signal]string{start: "start"}
+	options.WrapAction = func(name string, a Action) Action {
+		names = append(names, name)
+		return func(f FSM) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			return a(f)
+		}
+	}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	require.Equal(t, []string{"down/start"}, names)
+
+	instance, err := machines.New(down)
+	require.NoError(t, err)
+	require.NoError(t, instance.Signal(start))
+	require.Equal(t, failed, instance.State())
+}
+
+func TestSequence(t *testing.T) {
+
+	const (
+		pending Index = iota
+		provisioning
+		running
+		stopped
+	)
+
+	const (
+		provision Signal = iota
+		ready
+		stop
+	)
+
+	visited := []Index{}
+	record := func(state Index) Action {
+		return func(FSM) error {
+			visited = append(visited, state)
+			return nil
+		}
+	}
+
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				provision: provisioning,
+			},
+			Actions: map[Signal]Action{
+				provision: record(pending),
+			},
+		},
+		State{
+			Index: provisioning,
+			Transitions: map[Signal]Index{
+				ready: running,
+			},
+			Actions: map[Signal]Action{
+				ready: record(provisioning),
+			},
+		},
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				stop: stopped,
+			},
+		},
+		State{
+			Index: stopped,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	instance, err := machines.New(pending)
+	require.NoError(t, err)
+
+	require.NoError(t, instance.Sequence(provision, ready))
+	require.Equal(t, running, instance.State())
+	require.Equal(t, []Index{pending, provisioning}, visited)
+
+	err = instance.Sequence(stop, ready, stop)
+	require.Error(t, err)
+	require.Equal(t, 1, err.(ErrSequence).Applied)
+	require.IsType(t, ErrNoTransitions{}, err.(ErrSequence).Err) // stopped is terminal
+	require.Equal(t, stopped, instance.State())
+
+	require.NoError(t, machines.Free(instance.ID()))
+	require.Equal(t, ErrFreed(instance.ID()), instance.Sequence(provision))
+}
+
+func TestSequenceAdmission(t *testing.T) {
+
+	const (
+		up Index = iota
+		down
+	)
+
+	const (
+		fail Signal = iota
+		recover
+	)
+
+	machines, err := define(
+		State{
+			Index: up,
+			Transitions: map[Signal]Index{
+				fail: down,
+			},
+			Hysteresis: map[Signal]Threshold{
+				fail: {Count: 2, Within: 10},
+			},
+		},
+		State{
+			Index: down,
+			Transitions: map[Signal]Index{
+				recover: up,
+			},
+		},
+	)
+	require.NoError(t, err)
+	admitted := []Signal{}
+	options := DefaultOptions()
+	options.OnSignal = func(id ID, s Signal, data []interface{}) bool {
+		admitted = append(admitted, s)
+		return s != recover
+	}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	instance, err := machines.New(up)
+	require.NoError(t, err)
+
+	// the first fail is below the threshold
+	err = instance.Sequence(fail, fail)
+	require.Equal(t, 0, err.(ErrSequence).Applied)
+	require.IsType(t, ErrAbsorbed{}, err.(ErrSequence).Err)
+	require.Equal(t, up, instance.State())
+
+	require.NoError(t, instance.Sequence(fail))
+	require.Equal(t, down, instance.State())
+
+	err = instance.Sequence(recover)
+	require.Equal(t, 0, err.(ErrSequence).Applied)
+	require.IsType(t, ErrSignalRejected{}, err.(ErrSequence).Err)
+	require.Equal(t, []Signal{fail, fail, recover}, admitted)
+}
+
+func TestActionRegistry(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	states := []State{
+		{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			ActionNames: map[Signal]string{
+				start: "provision",
+			},
+		},
+		{
+			Index: running,
+		},
+	}
+
+	machines, err := define(states[0], states[1:]...)
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	err = machines.Run(NewClock(), options)
+	require.Error(t, err)
+	require.Equal(t, "provision", err.(ErrUnboundAction).Name)
+
+	provisioned := []ID{}
+	options.Actions = ActionRegistry{
+		"provision": func(f FSM) error {
+			provisioned = append(provisioned, f.ID())
+			return nil
+		},
+	}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	instance, err := machines.New(pending)
+	require.NoError(t, err)
+	require.NoError(t, instance.Signal(start))
+	require.Equal(t, running, instance.State())
+	require.Equal(t, []ID{instance.ID()}, provisioned)
+
+	states[0].Actions = map[Signal]Action{
+		start: func(FSM) error { return nil },
+	}
+	_, err = define(states[0], states[1:]...)
+	require.IsType(t, ErrDuplicateAction{}, err)
+}
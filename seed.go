@@ -0,0 +1,45 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// SeedItem is an instance to add with Machines.Seed, e.g. from an inventory of existing resources.
+type SeedItem struct {
+	// State is the state of the instance
+	State Index
+
+	// Labels are the labels of the instance, returned by FSM.Labels
+	Labels map[string]string
+
+	// Data is the data of the instance.  If nil, Options.NewData is used.
+	Data interface{}
+
+	// TTL is the remaining ticks before the deadline of the state.  If 0, the TTL of the state is used.
+	// It's ignored for states without a TTL.
+	TTL Tick
+
+	// Elapsed is the number of ticks already spent in the state, taken off the TTL of the state if TTL
+	// is 0.  An instance whose TTL is used up expires on the next tick.
+	Elapsed Tick
+}
+
+// seed adds an instance for each of the items, in order.  Nothing is added if any item has an unknown
+// state.  This must be called from within the transaction loop.
+func (g *runner) seed(items []SeedItem) ([]FSM, error) {
+	for _, item := range items {
+		if err := g.options.Enums.checkState(item.State); err != nil {
+			return nil, err
+		}
+		if _, has := g.spec().states[item.State]; !has {
+			return nil, ErrUnknownState{spec: g.spec(), Index: item.State}
+		}
+	}
+
+	tid := g.tid()
+	fsms := make([]FSM, 0, len(items))
+	for _, item := range items {
+		new, err := g.add(tid, item)
+		if err != nil {
+			return fsms, err
+		}
+		fsms = append(fsms, new)
+	}
+	return fsms, nil
+}
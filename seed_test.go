@@ -0,0 +1,121 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeed(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+		unknown
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	spec := []State{
+		{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			TTL: Expiry{10, start},
+		},
+		{
+			Index: running,
+		},
+	}
+
+	options := DefaultOptions()
+	options.NewData = func(id ID) interface{} { return "new" }
+
+	machines, err := define(spec[0], spec[1:]...)
+	require.NoError(t, err)
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, options))
+	defer machines.Done()
+
+	clock.Ticks(2)
+
+	_, err = machines.Seed([]SeedItem{
+		{State: running},
+		{State: unknown},
+	})
+	require.Error(t, err)
+	require.Equal(t, 0, machines.Count())
+
+	fsms, err := machines.Seed([]SeedItem{
+		{State: pending, Labels: map[string]string{"zone": "a"}, Data: "vm-1", TTL: 3},
+		{State: pending, Labels: map[string]string{"zone": "b"}},
+		{State: running, Data: "vm-3", TTL: 5},
+	})
+	require.NoError(t, err)
+	require.Len(t, fsms, 3)
+
+	require.Equal(t, 2, machines.CountIn(pending))
+	require.Equal(t, 1, machines.CountIn(running))
+	require.Equal(t, map[string]string{"zone": "a"}, fsms[0].Labels())
+	require.Equal(t, "vm-1", fsms[0].Data())
+	require.Equal(t, "new", fsms[1].Data())
+	require.Nil(t, fsms[2].Labels())
+
+	require.Equal(t, []DeadlineInfo{
+		{ID: fsms[0].ID(), State: pending, Due: 5, Raise: start},
+		{ID: fsms[1].ID(), State: pending, Due: 12, Raise: start},
+	}, machines.PendingDeadlines())
+
+	snapshot, err := machines.Snapshot()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"zone": "b"}, snapshot.Instances[1].Labels)
+}
+
+func TestNewAt(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			TTL: Expiry{10, start},
+		},
+		State{
+			Index: running,
+		},
+	)
+	require.NoError(t, err)
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, DefaultOptions()))
+	defer machines.Done()
+
+	clock.Ticks(2)
+
+	a, err := machines.NewAt(pending, 4)
+	require.NoError(t, err)
+	b, err := machines.NewAt(pending, 20)
+	require.NoError(t, err)
+	c, err := machines.NewAt(running, 4)
+	require.NoError(t, err)
+	_, err = machines.NewAt(Index(5), 4)
+	require.Error(t, err)
+
+	require.Equal(t, []DeadlineInfo{
+		{ID: b.ID(), State: pending, Due: 3, Raise: start},
+		{ID: a.ID(), State: pending, Due: 8, Raise: start},
+	}, machines.PendingDeadlines())
+	require.Equal(t, running, c.State())
+}
@@ -0,0 +1,186 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// Simulation configures randomized rollouts of the spec, without running actions.  At each tick, the
+// signals the current state can receive are raised with their probabilities, checked in the order of
+// the signals; the first one raised is applied.  TTLs expire and visit limits are enforced as when running.
+type Simulation struct {
+	// Initial is the state each rollout starts in
+	Initial Index
+
+	// Rollouts is the number of rollouts
+	Rollouts int
+
+	// MaxTicks ends a rollout that has not reached a terminal state
+	MaxTicks Tick
+
+	// Signals are the probabilities, between 0 and 1, of each signal being raised at a tick
+	Signals map[Signal]float64
+
+	// Seed seeds the random numbers so that reports can be reproduced
+	Seed int64
+
+	// Source, if set, is the source of the random numbers instead of one seeded by Seed.  When the machines
+	// run with Options.Random, the default is a source seeded from it instead.
+	Source rand.Source
+}
+
+// TickDistribution is the distribution of a number of ticks
+type TickDistribution struct {
+	Count int
+	Min   Tick
+	Max   Tick
+	Mean  float64
+	P50   Tick
+	P90   Tick
+	P99   Tick
+}
+
+func newTickDistribution(ticks []Tick) TickDistribution {
+	d := TickDistribution{Count: len(ticks)}
+	if len(ticks) == 0 {
+		return d
+	}
+	sort.Slice(ticks, func(i, j int) bool { return ticks[i] < ticks[j] })
+	sum := 0.
+	for _, t := range ticks {
+		sum += float64(t)
+	}
+	percentile := func(p int) Tick {
+		return ticks[(len(ticks)-1)*p/100]
+	}
+	d.Min, d.Max, d.Mean = ticks[0], ticks[len(ticks)-1], sum/float64(len(ticks))
+	d.P50, d.P90, d.P99 = percentile(50), percentile(90), percentile(99)
+	return d
+}
+
+// SimulationReport is the aggregate of the rollouts of a simulation
+type SimulationReport struct {
+	Rollouts int
+
+	// Terminated is the number of rollouts that reached a terminal state within MaxTicks
+	Terminated int
+
+	// TimeToTerminal is the distribution of the ticks to reach a terminal state, of the terminated rollouts
+	TimeToTerminal TickDistribution
+
+	// Terminals is the number of rollouts ending in each terminal state
+	Terminals map[Index]int
+
+	// Visits is the mean number of visits to each state per rollout
+	Visits map[Index]float64
+
+	// Flaps is the mean number of flaps per rollout, i.e. transitions back to the state before the
+	// previous one, for each pair of states ordered by index
+	Flaps map[[2]Index]float64
+}
+
+// simulate runs the rollouts of the simulation on the spec
+func (s *spec) simulate(sim Simulation) (SimulationReport, error) {
+	if _, has := s.states[sim.Initial]; !has {
+		return SimulationReport{}, ErrUnknownState{spec: s, Index: sim.Initial}
+	}
+	for signal := range sim.Signals {
+		if _, has := s.signals[signal]; !has {
+			return SimulationReport{}, ErrUnknownSignal{spec: s, Signal: signal, Index: NoState,
+				Help: "simulated signal that's not in any state's transitions"}
+		}
+	}
+
+	signals := []Signal{}
+	for signal := range sim.Signals {
+		signals = append(signals, signal)
+	}
+	sort.Slice(signals, func(i, j int) bool { return signals[i] < signals[j] })
+
+	source := sim.Source
+	if source == nil {
+		source = rand.NewSource(sim.Seed)
+	}
+	random := rand.New(source)
+	report := SimulationReport{
+		Rollouts:  sim.Rollouts,
+		Terminals: map[Index]int{},
+		Visits:    map[Index]float64{},
+		Flaps:     map[[2]Index]float64{},
+	}
+	ticks := []Tick{}
+
+	for r := 0; r < sim.Rollouts; r++ {
+		current, previous := sim.Initial, NoState
+		visits := map[Index]int{current: 1}
+		entered := Tick(0)
+
+		enter := func(next Index, now Tick) {
+			if next == previous && next != current {
+				pair := [2]Index{current, next}
+				if pair[0] > pair[1] {
+					pair[0], pair[1] = pair[1], pair[0]
+				}
+				report.Flaps[pair]++
+			}
+			previous, current, entered = current, next, now
+			visits[next]++
+		}
+
+		for now := Tick(1); now <= sim.MaxTicks; now++ {
+			state := s.states[current]
+			if len(state.Transitions) == 0 {
+				break
+			}
+
+			raised := NoSignal
+			if state.TTL.TTL > 0 && now-entered >= state.TTL.TTL {
+				raised = state.TTL.Raise
+			} else {
+				for _, signal := range signals {
+					if _, has := state.Transitions[signal]; has && random.Float64() < sim.Signals[signal] {
+						raised = signal
+						break
+					}
+				}
+			}
+			if IsNoSignal(raised) {
+				continue
+			}
+			enter(state.Transitions[raised], now)
+
+			// a visit limit raises its signal when the state is visited that many times
+			for i := 0; i < len(s.states); i++ {
+				limit := s.states[current].Visit
+				if limit.Value == 0 || visits[current] != limit.Value {
+					break
+				}
+				next, has := s.states[current].Transitions[limit.Raise]
+				if !has || next == current {
+					break
+				}
+				enter(next, now)
+			}
+		}
+
+		if len(s.states[current].Transitions) == 0 {
+			report.Terminated++
+			report.Terminals[current]++
+			ticks = append(ticks, entered)
+		}
+		for index, count := range visits {
+			report.Visits[index] += float64(count)
+		}
+	}
+
+	if sim.Rollouts > 0 {
+		for index := range report.Visits {
+			report.Visits[index] /= float64(sim.Rollouts)
+		}
+		for pair := range report.Flaps {
+			report.Flaps[pair] /= float64(sim.Rollouts)
+		}
+	}
+	report.TimeToTerminal = newTickDistribution(ticks)
+	return report, nil
+}
@@ -0,0 +1,172 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulate(t *testing.T) {
+
+	const (
+		up Index = iota
+		down
+		failed
+	)
+
+	const (
+		goDown Signal = iota
+		goUp
+		fail
+	)
+
+	machines, err := define(
+		State{
+			Index: up,
+			Transitions: map[Signal]Index{
+				goDown: down,
+			},
+		},
+		State{
+			Index: down,
+			Transitions: map[Signal]Index{
+				goUp: up,
+				fail: failed,
+			},
+			Visit: Limit{3, fail},
+		},
+		State{
+			Index: failed,
+		},
+	)
+	require.NoError(t, err)
+
+	_, err = machines.Simulate(Simulation{Initial: up, Signals: map[Signal]float64{Signal(9): 1}})
+	require.Error(t, err)
+
+	report, err := machines.Simulate(Simulation{
+		Initial:  up,
+		Rollouts: 10,
+		MaxTicks: 100,
+		Signals:  map[Signal]float64{goDown: 1, goUp: 1},
+	})
+	require.NoError(t, err)
+	require.Equal(t, SimulationReport{
+		Rollouts:       10,
+		Terminated:     10,
+		TimeToTerminal: TickDistribution{Count: 10, Min: 5, Max: 5, Mean: 5, P50: 5, P90: 5, P99: 5},
+		Terminals:      map[Index]int{failed: 10},
+		Visits:         map[Index]float64{up: 3, down: 3, failed: 1},
+		Flaps:          map[[2]Index]float64{{up, down}: 4},
+	}, report)
+
+	// with a rare signal, some rollouts don't terminate in time
+	report, err = machines.Simulate(Simulation{
+		Initial:  up,
+		Rollouts: 1000,
+		MaxTicks: 10,
+		Signals:  map[Signal]float64{goDown: 0.3, goUp: 0.3},
+		Seed:     1,
+	})
+	require.NoError(t, err)
+	require.True(t, report.Terminated > 0 && report.Terminated < 1000)
+	require.Equal(t, report.Terminated, report.TimeToTerminal.Count)
+	require.True(t, report.TimeToTerminal.Min >= 5 && report.TimeToTerminal.Max <= 10)
+}
+
+func TestSimulateTTL(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+		stopped
+	)
+
+	const (
+		start Signal = iota
+		stop
+	)
+
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			TTL: Expiry{3, start},
+		},
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				stop: stopped,
+			},
+			TTL: Expiry{2, stop},
+		},
+		State{
+			Index: stopped,
+		},
+	)
+	require.NoError(t, err)
+
+	report, err := machines.Simulate(Simulation{Initial: pending, Rollouts: 3, MaxTicks: 100})
+	require.NoError(t, err)
+	require.Equal(t, 3, report.Terminated)
+	require.Equal(t, TickDistribution{Count: 3, Min: 5, Max: 5, Mean: 5, P50: 5, P90: 5, P99: 5}, report.TimeToTerminal)
+	require.Equal(t, map[Index]float64{pending: 1, running: 1, stopped: 1}, report.Visits)
+}
+
+func TestSimulateRandom(t *testing.T) {
+
+	const (
+		up Index = iota
+		down
+		failed
+	)
+
+	const (
+		goDown Signal = iota
+		goUp
+		fail
+	)
+
+	simulate := func(seed int64) SimulationReport {
+		machines, err := define(
+			State{
+				Index: up,
+				Transitions: map[Signal]Index{
+					goDown: down,
+				},
+			},
+			State{
+				Index: down,
+				Transitions: map[Signal]Index{
+					goUp: up,
+					fail: failed,
+				},
+				Visit: Limit{3, fail},
+			},
+			State{
+				Index: failed,
+			},
+		)
+		require.NoError(t, err)
+		options := DefaultOptions()
+		options.Random = rand.NewSource(seed)
+		require.NoError(t, machines.Run(NewClock(), options))
+		defer machines.Done()
+
+		report, err := machines.Simulate(Simulation{
+			Initial:  up,
+			Rollouts: 1000,
+			MaxTicks: 10,
+			Signals:  map[Signal]float64{goDown: 0.3, goUp: 0.3},
+		})
+		require.NoError(t, err)
+		return report
+	}
+
+	// reproduced by the seed of Options.Random
+	require.Equal(t, simulate(7), simulate(7))
+	require.NotEqual(t, simulate(7), simulate(8))
+}
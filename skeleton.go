@@ -0,0 +1,98 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// edge is a transition of the spec, or the transition on action error if onError
+type edge struct {
+	from    Index
+	signal  Signal
+	to      Index
+	choice  bool
+	onError bool
+}
+
+// order is the order of the edge among those of the same state and signal
+func (e edge) order() int {
+	switch {
+	case e.choice:
+		return 1
+	case e.onError:
+		return 2
+	}
+	return 0
+}
+
+// edges returns the transitions of the spec in the order of state and signal, with the transitions to the
+// candidates of choices, and then those on action error, after the regular ones
+func (s *spec) edges() []edge {
+	edges := []edge{}
+	for index, st := range s.states {
+		for signal, to := range st.Transitions {
+			edges = append(edges, edge{from: index, signal: signal, to: to})
+		}
+		for signal, choice := range st.Choices {
+			for _, to := range choice.To {
+				edges = append(edges, edge{from: index, signal: signal, to: to, choice: true})
+			}
+		}
+		for signal, to := range st.Errors {
+			edges = append(edges, edge{from: index, signal: signal, to: to, onError: true})
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if a.from != b.from {
+			return a.from < b.from
+		}
+		if a.signal != b.signal {
+			return a.signal < b.signal
+		}
+		if a.order() != b.order() {
+			return a.order() < b.order()
+		}
+		return a.to < b.to
+	})
+	return edges
+}
+
+// writeTestSkeleton writes a table-driven Go test with a case for every edge of the spec, with
+// placeholders for the assertions.  The test skips until it's filled in.
+func (s *spec) writeTestSkeleton(w io.Writer, pkg string) error {
+	buff := &bytes.Buffer{}
+	fmt.Fprintf(buff, "package %v\n\n", pkg)
+	fmt.Fprintf(buff, "import (\n\t\"testing\"\n\n\t\"github.com/orkestr8/fsm\"\n)\n\n")
+	fmt.Fprintf(buff, "func TestTransitions(t *testing.T) {\n\n")
+	fmt.Fprintf(buff, "\tfor _, tc := range []struct {\n")
+	fmt.Fprintf(buff, "\t\tname string\n\t\tfrom fsm.Index\n\t\tsignal fsm.Signal\n\t\tto fsm.Index\n")
+	fmt.Fprintf(buff, "\t\tactionErr bool // the edge is taken when the action fails\n\t}{\n")
+	for _, e := range s.edges() {
+		name := fmt.Sprintf("%v/%v->%v", s.stateName(e.from), s.signalName(e.signal), s.stateName(e.to))
+		if e.choice {
+			name += " by choice"
+		}
+		if e.onError {
+			name += " on error"
+		}
+		fmt.Fprintf(buff, "\t\t{name: %v, from: %d, signal: %d, to: %d, actionErr: %v},\n",
+			strconv.Quote(name), e.from, e.signal, e.to, e.onError)
+	}
+	fmt.Fprintf(buff, "\t} {\n\t\ttc := tc\n\t\tt.Run(tc.name, func(t *testing.T) {\n")
+	fmt.Fprintf(buff, "\t\t\t// TODO: define and run the machines of the spec, with a failing action if tc.actionErr\n")
+	fmt.Fprintf(buff, "\t\t\t// TODO: create an instance in tc.from and signal it with tc.signal\n")
+	fmt.Fprintf(buff, "\t\t\t// TODO: assert the instance is in tc.to, and the effects of the action\n")
+	fmt.Fprintf(buff, "\t\t\tt.Skip(\"not implemented\")\n\t\t})\n\t}\n}\n")
+
+	formatted, err := format.Source(buff.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(formatted)
+	return err
+}
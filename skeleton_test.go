@@ -0,0 +1,68 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTestSkeleton(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+		failed
+	)
+
+	const (
+		start Signal = iota
+		fail
+	)
+
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			Actions: map[Signal]Action{
+				start: func(FSM) error { return nil },
+			},
+			Errors: map[Signal]Index{
+				start: failed,
+			},
+		},
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				fail: failed,
+			},
+			Choices: map[Signal]Choice{
+				fail: {To: []Index{pending}, Select: func(FSM) Index { return failed }},
+			},
+		},
+		State{
+			Index: failed,
+		},
+	)
+	require.NoError(t, err)
+	machines.spec.stateNames = map[Index]string{pending: "pending", running: "running", failed: "failed"}
+	machines.spec.signalNames = map[Signal]string{start: "start", fail: "fail"}
+
+	buff := &bytes.Buffer{}
+	require.NoError(t, machines.WriteTestSkeleton(buff, "spec_test"))
+
+	_, err = parser.ParseFile(token.NewFileSet(), "skeleton_test.go", buff.Bytes(), 0)
+	require.NoError(t, err)
+
+	require.Contains(t, buff.String(), "package spec_test\n")
+	require.Contains(t, buff.String(), `
+		{name: "pending/start->running", from: 0, signal: 0, to: 1, actionErr: false},
+		{name: "pending/start->failed on error", from: 0, signal: 0, to: 2, actionErr: true},
+		{name: "running/fail->failed", from: 1, signal: 1, to: 2, actionErr: false},
+		{name: "running/fail->pending by choice", from: 1, signal: 1, to: 0, actionErr: false},
+	} {`)
+}
@@ -0,0 +1,144 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"time"
+)
+
+// InstanceSnapshot is the persisted form of an instance.  Data is encoded with the codec in Options.
+type InstanceSnapshot struct {
+	ID               ID                `json:"id"`
+	State            Index             `json:"state"`
+	Data             []byte            `json:"data,omitempty"`
+	TTL              Tick              `json:"ttl,omitempty"` // remaining ticks before the deadline, if any
+	Visits           map[Index]int     `json:"visits,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	CreatedAt        time.Time         `json:"createdAt"`
+	LastTransitionAt time.Time         `json:"lastTransitionAt"`
+}
+
+// Snapshot is the persisted form of all the instances
+type Snapshot struct {
+	Now       Time               `json:"now"`
+	Instances []InstanceSnapshot `json:"instances"`
+
+	// TickLag is the number of ticks received but not yet processed when the snapshot was taken
+	TickLag int64 `json:"tickLag,omitempty"`
+}
+
+// Resume returns the logical time to start a runner from the snapshot with Options.Now, counting the
+// ticks that were pending when it was taken.
+func (s Snapshot) Resume() Time {
+	return s.Now + Time(s.TickLag)
+}
+
+// snapshot returns the snapshot of the instance.  This must be called from within the transaction loop.
+func (g *runner) snapshot(i *instance) (InstanceSnapshot, error) {
+	s := InstanceSnapshot{
+		ID:               i.id,
+		State:            i.state,
+		Visits:           map[Index]int{},
+		CreatedAt:        i.created,
+		LastTransitionAt: i.changed,
+		Labels:           copyMeta(i.labels),
+	}
+	for index, count := range i.visits {
+		s.Visits[index] = count
+	}
+	if due := g.due(i); due > 0 {
+		s.TTL = Tick(due - g.now)
+	}
+	if i.data != nil {
+		buff, err := g.codec().Encode(i.data)
+		if err != nil {
+			return s, err
+		}
+		s.Data = buff
+	}
+	return s, nil
+}
+
+// takeSnapshot returns the snapshot of the instances, in the order of ID.  This must be called from
+// within the transaction loop.
+func (g *runner) takeSnapshot() (snapshot Snapshot, err error) {
+	snapshot = Snapshot{Now: g.now, Instances: []InstanceSnapshot{}, TickLag: g.tickLag()}
+	g.forEach(func(i *instance) bool {
+		var s InstanceSnapshot
+		s, err = g.snapshot(i)
+		if err != nil {
+			return false
+		}
+		snapshot.Instances = append(snapshot.Instances, s)
+		return true
+	})
+	return
+}
+
+// restore adds the instances in the snapshot with their IDs.  Nothing is added if any of the instances
+// has an ID in use or an unknown state, or its data can't be decoded.  This must be called from within
+// the transaction loop.
+func (g *runner) restore(snapshot Snapshot) error {
+	snapshot = g.options.Migration.snapshot(snapshot)
+	if incompatible := g.incompatible(snapshot, nil); len(incompatible) > 0 {
+		return incompatible
+	}
+	return g.load(snapshot)
+}
+
+// load adds the instances in the snapshot, which is of this spec, with their IDs.  This must be called
+// from within the transaction loop.
+func (g *runner) load(snapshot Snapshot) error {
+	restored := []*instance{}
+	seen := map[ID]bool{}
+	for _, s := range snapshot.Instances {
+		if _, has := g.members[s.ID]; has || seen[s.ID] {
+			return ErrDuplicateID(s.ID)
+		}
+		seen[s.ID] = true
+
+		i := &instance{
+			id:      s.ID,
+			state:   s.State,
+			index:   -1,
+			parent:  g,
+			flaps:   *newFlaps(),
+			streaks: *newStreaks(),
+			visits:  map[Index]int{},
+			start:   g.now,
+			alive:   g.now,
+			created: s.CreatedAt,
+			changed: s.LastTransitionAt,
+			labels:  copyMeta(s.Labels),
+		}
+		for index, count := range s.Visits {
+			i.visits[index] = count
+		}
+		if len(s.Data) > 0 {
+			data, err := g.codec().Decode(s.Data)
+			if err != nil {
+				return err
+			}
+			i.data = data
+		}
+		if s.TTL > 0 {
+			i.deadline = g.now + Time(s.TTL)
+		}
+		restored = append(restored, i)
+	}
+
+	for _, i := range restored {
+		g.members[i.id] = i
+		g.reindex(i, NoState, i.state)
+		g.indexExternal(i)
+		g.enterSub(i, i.state)
+		if i.deadline > 0 {
+			g.deadlines.enqueue(i)
+		}
+		if g.options.WAL != nil {
+			g.compactions[i.id] = true // the snapshot replaces any records of the instance
+		}
+	}
+	if g.options.WAL != nil {
+		g.compact(g.tid())
+	}
+	return nil
+}
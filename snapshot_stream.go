@@ -0,0 +1,111 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+)
+
+// maxStreamRecord is the largest record accepted when reading a snapshot stream
+const maxStreamRecord = 1 << 30
+
+// streamHeader is the first record of a snapshot stream.  It's followed by Count instance snapshots.
+type streamHeader struct {
+	Now     Time  `json:"now"`
+	TickLag int64 `json:"tickLag,omitempty"`
+	Count   int   `json:"count"`
+}
+
+func (m *machines) SnapshotStream(w io.Writer) (err error) {
+	m.runner.do(func(g *runner) {
+		err = g.streamSnapshot(w)
+	})
+	return
+}
+
+// streamSnapshot writes the snapshot of the instances, in the order of ID, as a header followed by
+// one record per instance.  This must be called from within the transaction loop.
+func (g *runner) streamSnapshot(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	header := streamHeader{Now: g.now, TickLag: g.tickLag(), Count: len(g.members)}
+	if err := writeRecord(out, header); err != nil {
+		return err
+	}
+	var err error
+	g.forEach(func(i *instance) bool {
+		var s InstanceSnapshot
+		s, err = g.snapshot(i)
+		if err != nil {
+			return false
+		}
+		err = writeRecord(out, s)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// ReadSnapshotStream reads a snapshot written by Machines.SnapshotStream, calling the function with each
+// instance as it's read, and returns the snapshot without its instances.
+func ReadSnapshotStream(r io.Reader, f func(InstanceSnapshot) error) (Snapshot, error) {
+	in := bufio.NewReader(r)
+	header := streamHeader{}
+	if err := readRecord(in, &header); err != nil {
+		return Snapshot{}, err
+	}
+	for i := 0; i < header.Count; i++ {
+		s := InstanceSnapshot{}
+		if err := readRecord(in, &s); err != nil {
+			return Snapshot{}, err
+		}
+		if err := f(s); err != nil {
+			return Snapshot{}, err
+		}
+	}
+	return Snapshot{Now: header.Now, TickLag: header.TickLag}, nil
+}
+
+// ReadSnapshot reads a snapshot written by Machines.SnapshotStream, for Machines.Restore
+func ReadSnapshot(r io.Reader) (Snapshot, error) {
+	instances := []InstanceSnapshot{}
+	snapshot, err := ReadSnapshotStream(r, func(s InstanceSnapshot) error {
+		instances = append(instances, s)
+		return nil
+	})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot.Instances = instances
+	return snapshot, nil
+}
+
+func writeRecord(w *bufio.Writer, v interface{}) error {
+	buff, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	size := make([]byte, binary.MaxVarintLen64)
+	if _, err := w.Write(size[:binary.PutUvarint(size, uint64(len(buff)))]); err != nil {
+		return err
+	}
+	_, err = w.Write(buff)
+	return err
+}
+
+func readRecord(r *bufio.Reader, v interface{}) error {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	if size > maxStreamRecord {
+		return Errorf(RuntimeError, "snapshot record too large: %v bytes", size)
+	}
+	buff := make([]byte, size)
+	if _, err := io.ReadFull(r, buff); err != nil {
+		return err
+	}
+	return json.Unmarshal(buff, v)
+}
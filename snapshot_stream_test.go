@@ -0,0 +1,88 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotStream(t *testing.T) {
+
+	const (
+		waiting Index = iota
+		running
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	spec := []State{
+		{
+			Index: waiting,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			TTL: Expiry{5, start},
+		},
+		{
+			Index: running,
+		},
+	}
+
+	machines, err := define(spec[0], spec[1:]...)
+	require.NoError(t, err)
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, DefaultOptions()))
+	defer machines.Done()
+
+	for i := 0; i < 100; i++ {
+		instance, err := machines.New(waiting)
+		require.NoError(t, err)
+		if i%2 == 0 {
+			require.NoError(t, instance.Signal(start, i))
+		}
+	}
+	clock.Ticks(2)
+
+	buff := &bytes.Buffer{}
+	require.NoError(t, machines.SnapshotStream(buff))
+
+	expected, err := machines.Snapshot()
+	require.NoError(t, err)
+
+	ids := []ID{}
+	header, err := ReadSnapshotStream(bytes.NewReader(buff.Bytes()), func(s InstanceSnapshot) error {
+		ids = append(ids, s.ID)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, Time(2), header.Now)
+	require.Len(t, ids, 100)
+
+	read, err := ReadSnapshot(bytes.NewReader(buff.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, expected.Now, read.Now)
+	require.Len(t, read.Instances, len(expected.Instances))
+	for i, s := range read.Instances {
+		require.Equal(t, expected.Instances[i].ID, s.ID)
+		require.Equal(t, expected.Instances[i].State, s.State)
+		require.Equal(t, expected.Instances[i].TTL, s.TTL)
+		require.Equal(t, expected.Instances[i].Data, s.Data)
+	}
+
+	restored, err := define(spec[0], spec[1:]...)
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.Now = read.Resume()
+	require.NoError(t, restored.Run(NewClock(), options))
+	defer restored.Done()
+	require.NoError(t, restored.Restore(read))
+	require.Equal(t, 50, restored.CountIn(running))
+	require.Equal(t, machines.PendingDeadlines(), restored.PendingDeadlines())
+
+	// truncated
+	_, err = ReadSnapshot(bytes.NewReader(buff.Bytes()[:buff.Len()/2]))
+	require.Error(t, err)
+}
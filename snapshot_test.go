@@ -0,0 +1,154 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+
+	const (
+		waiting Index = iota
+		running
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	spec := []State{
+		{
+			Index: waiting,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			TTL: Expiry{5, start},
+		},
+		{
+			Index: running,
+		},
+	}
+
+	options := DefaultOptions()
+	options.Codec = GobCodec{New: func() interface{} { return &codecTarget{} }}
+	options.NewData = func(id ID) interface{} {
+		return codecTarget{URL: "http://target", Tries: int(id)}
+	}
+
+	machines, err := define(spec[0], spec[1:]...)
+	require.NoError(t, err)
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, options))
+	defer machines.Done()
+
+	a, err := machines.New(waiting)
+	require.NoError(t, err)
+	clock.Ticks(2)
+	b, err := machines.New(waiting)
+	require.NoError(t, err)
+	require.NoError(t, b.Signal(start))
+
+	snapshot, err := machines.Snapshot()
+	require.NoError(t, err)
+	require.Equal(t, Time(2), snapshot.Now)
+	require.Len(t, snapshot.Instances, 2)
+	require.Equal(t, Tick(3), snapshot.Instances[0].TTL)
+	require.Equal(t, Tick(0), snapshot.Instances[1].TTL)
+
+	// restore into another set, whose clock is at a different time
+	options.NewData = nil
+	restored, err := define(spec[0], spec[1:]...)
+	require.NoError(t, err)
+	clock2 := NewClock()
+	require.NoError(t, restored.Run(clock2, options))
+	defer restored.Done()
+	clock2.Ticks(10)
+
+	require.NoError(t, restored.Restore(snapshot))
+	require.Equal(t, ErrDuplicateID(a.ID()), restored.Restore(snapshot))
+	require.Equal(t, 2, restored.Count())
+	require.Equal(t, 1, restored.CountIn(waiting))
+	require.Equal(t, []DeadlineInfo{
+		{ID: a.ID(), State: waiting, Due: 13, Raise: start},
+	}, restored.PendingDeadlines())
+
+	restored.ForEach(func(f FSM) bool {
+		require.Equal(t, codecTarget{URL: "http://target", Tries: int(f.ID())}, f.Data())
+		return true
+	})
+
+	// new instances don't collide with the restored IDs
+	c, err := restored.New(waiting)
+	require.NoError(t, err)
+	require.Equal(t, ID(2), c.ID())
+}
+
+func TestSnapshotResume(t *testing.T) {
+
+	const (
+		waiting Index = iota
+		running
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	spec := []State{
+		{
+			Index: waiting,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			TTL: Expiry{5, start},
+		},
+		{
+			Index: running,
+		},
+	}
+
+	machines, err := define(spec[0], spec[1:]...)
+	require.NoError(t, err)
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, DefaultOptions()))
+	defer machines.Done()
+
+	clock.Ticks(100)
+	a, err := machines.New(waiting)
+	require.NoError(t, err)
+	clock.Ticks(2)
+
+	snapshot, err := machines.Snapshot()
+	require.NoError(t, err)
+	require.Equal(t, Time(102), snapshot.Now)
+
+	// round trip in both encodings
+	buff := &bytes.Buffer{}
+	require.NoError(t, gob.NewEncoder(buff).Encode(snapshot))
+	decoded := Snapshot{}
+	require.NoError(t, gob.NewDecoder(buff).Decode(&decoded))
+	js, err := json.Marshal(decoded)
+	require.NoError(t, err)
+	decoded = Snapshot{}
+	require.NoError(t, json.Unmarshal(js, &decoded))
+	require.Equal(t, snapshot.Now, decoded.Now)
+	require.Equal(t, snapshot.Instances[0].TTL, decoded.Instances[0].TTL)
+
+	// the restored runner continues from the logical time of the snapshot
+	options := DefaultOptions()
+	options.Now = decoded.Resume()
+	restored, err := define(spec[0], spec[1:]...)
+	require.NoError(t, err)
+	clock2 := NewClock()
+	require.NoError(t, restored.Run(clock2, options))
+	defer restored.Done()
+
+	require.NoError(t, restored.Restore(decoded))
+	require.Equal(t, Time(102), restored.Stats().Now)
+	require.Equal(t, machines.PendingDeadlines(), restored.PendingDeadlines())
+	require.Equal(t, []DeadlineInfo{{ID: a.ID(), State: waiting, Due: 105, Raise: start}}, restored.PendingDeadlines())
+}
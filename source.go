@@ -0,0 +1,100 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"context"
+	"sync"
+)
+
+// Source is a source of signals such as a poller, a message bus consumer, or a file watcher.
+// Run should emit signals until the context is cancelled and then return.
+type Source interface {
+	Run(ctx context.Context, emit func(ID, Signal, ...interface{}))
+}
+
+// checkedSource is a Source told of the signals rejected before they are queued, e.g. to answer the
+// sender with the error
+type checkedSource interface {
+	runChecked(ctx context.Context, signal func(ID, Signal, ...interface{}) error)
+}
+
+// sources manages the lifecycle of the sources registered with the machines
+type sources struct {
+	pending []Source
+	ctx     context.Context
+	cancel  func()
+	wg      sync.WaitGroup
+	lock    sync.Mutex
+}
+
+// add registers the source, starting it if the sources are already started
+func (s *sources) add(source Source, g *runner) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.ctx == nil {
+		s.pending = append(s.pending, source)
+		return
+	}
+	s.start(source, g)
+}
+
+// run starts all the registered sources
+func (s *sources) run(g *runner) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	for _, source := range s.pending {
+		s.start(source, g)
+	}
+	s.pending = nil
+}
+
+func (s *sources) start(source Source, g *runner) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if checked, is := source.(checkedSource); is {
+			checked.runChecked(s.ctx, g.emitChecked)
+			return
+		}
+		source.Run(s.ctx, g.emit)
+	}()
+}
+
+// stop cancels all the sources and waits for them to return
+func (s *sources) stop() {
+	s.lock.Lock()
+	cancel := s.cancel
+	s.lock.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	s.wg.Wait()
+}
+
+// emit signals the instance of the given ID.  Errors are reported on the error stream.
+func (g *runner) emit(id ID, signal Signal, optionalData ...interface{}) {
+	if err := g.signalID(OriginSource, id, signal, optionalData...); err != nil {
+		g.handleError(g.tid(), err, id)
+	}
+}
+
+// emitChecked signals the instance of the given ID and returns the error if the signal is rejected before it's
+// queued.  Errors in handling it are reported on the error stream.
+func (g *runner) emitChecked(id ID, signal Signal, optionalData ...interface{}) error {
+	return g.signalID(OriginSource, id, signal, optionalData...)
+}
+
+// signalID signals the instance of the given ID.
+func (g *runner) signalID(origin Origin, id ID, signal Signal, optionalData ...interface{}) error {
+	var instance *instance
+	g.do(func(g *runner) {
+		instance = g.members[id]
+	})
+	if instance == nil {
+		return ErrUnknownFSM(id)
+	}
+	return g.signal(origin, signal, instance, optionalData...)
+}
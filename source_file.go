@@ -0,0 +1,109 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// FileEvent is the kind of change observed on a watched path
+type FileEvent int
+
+const (
+	// FileCreated is observed when a path comes into existence
+	FileCreated FileEvent = iota
+
+	// FileModified is observed when a path is written to
+	FileModified
+
+	// FileDeleted is observed when a path is removed or renamed
+	FileDeleted
+)
+
+// FileSource is a Source that watches files and directories with fsnotify and signals the instances mapped
+// to them on changes.  A watched directory reports changes of its immediate entries.  A watched file is
+// watched in its directory, so that it is still watched after it's deleted and created again.  The path that
+// changed is sent as the signal's data.
+type FileSource struct {
+
+	// Paths maps each watched file or directory to the instance to signal
+	Paths map[string]ID
+
+	// Signals maps the kind of change to the signal to raise.  Changes without a signal are ignored.
+	Signals map[FileEvent]Signal
+
+	// OnError is called with the errors of watching the paths, if set.  A path that can't be watched is
+	// skipped.
+	OnError func(error)
+}
+
+// Run implements Source
+func (s *FileSource) Run(ctx context.Context, emit func(ID, Signal, ...interface{})) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		s.error(err)
+		return
+	}
+	defer watcher.Close()
+
+	dirs := map[string]ID{}  // watched directories, whose entries are signaled
+	files := map[string]ID{} // watched files, watched in their directories
+	for path, id := range s.Paths {
+		path = filepath.Clean(path)
+		watch := path
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			dirs[path] = id
+		} else {
+			files[path] = id
+			watch = filepath.Dir(path)
+		}
+		if err := watcher.Add(watch); err != nil {
+			s.error(err)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			s.error(err)
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			id, has := files[event.Name]
+			if !has {
+				id, has = dirs[filepath.Dir(event.Name)]
+			}
+			if !has {
+				continue
+			}
+			switch {
+			case event.Has(fsnotify.Create):
+				s.emit(emit, id, FileCreated, event.Name)
+			case event.Has(fsnotify.Write):
+				s.emit(emit, id, FileModified, event.Name)
+			case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+				s.emit(emit, id, FileDeleted, event.Name)
+			}
+		}
+	}
+}
+
+func (s *FileSource) error(err error) {
+	if s.OnError != nil {
+		s.OnError(err)
+	}
+}
+
+func (s *FileSource) emit(emit func(ID, Signal, ...interface{}), id ID, event FileEvent, path string) {
+	if signal, has := s.Signals[event]; has {
+		emit(id, signal, path)
+	}
+}
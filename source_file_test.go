@@ -0,0 +1,101 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSource(t *testing.T) {
+
+	const (
+		created Signal = iota
+		modified
+		deleted
+	)
+
+	dir, err := os.MkdirTemp("", "fsm-file-source")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	type emitted struct {
+		id     ID
+		signal Signal
+		path   string
+	}
+
+	watched := filepath.Join(dir, "watched")
+	require.NoError(t, os.Mkdir(watched, 0755))
+	single := filepath.Join(dir, "single")
+
+	events := make(chan emitted, 100)
+	source := &FileSource{
+		Paths: map[string]ID{
+			watched: 7,
+			single:  8,
+		},
+		Signals: map[FileEvent]Signal{
+			FileCreated:  created,
+			FileModified: modified,
+			FileDeleted:  deleted,
+		},
+		OnError: func(err error) {
+			t.Error(err)
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		source.Run(ctx, func(id ID, s Signal, data ...interface{}) {
+			events <- emitted{id: id, signal: s, path: data[0].(string)}
+		})
+	}()
+
+	// skips the repeated events of a change, e.g. the writes of truncating and writing a file
+	await := func(signal Signal) emitted {
+		for {
+			select {
+			case e := <-events:
+				if e.signal == signal {
+					return e
+				}
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "no event", "signal %v", signal)
+			}
+		}
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	// the entries of a watched directory
+	path := filepath.Join(watched, "config")
+	require.NoError(t, os.WriteFile(path, []byte("a"), 0644))
+	require.Equal(t, emitted{id: 7, signal: created, path: path}, await(created))
+
+	require.NoError(t, os.WriteFile(path, []byte("abc"), 0644))
+	require.Equal(t, emitted{id: 7, signal: modified, path: path}, await(modified))
+
+	require.NoError(t, os.Remove(path))
+	require.Equal(t, emitted{id: 7, signal: deleted, path: path}, await(deleted))
+
+	// a watched file, but not its neighbours
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other"), []byte("a"), 0644))
+	require.NoError(t, os.WriteFile(single, []byte("a"), 0644))
+	require.Equal(t, emitted{id: 8, signal: created, path: single}, await(created))
+
+	require.NoError(t, os.Remove(single))
+	require.Equal(t, emitted{id: 8, signal: deleted, path: single}, await(deleted))
+
+	// still watched when created again
+	require.NoError(t, os.WriteFile(single, []byte("b"), 0644))
+	require.Equal(t, emitted{id: 8, signal: created, path: single}, await(created))
+
+	cancel()
+	<-done
+}
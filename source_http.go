@@ -0,0 +1,103 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// WebhookEvent is the JSON body POSTed to the webhook to signal an instance
+type WebhookEvent struct {
+	ID     ID          `json:"id"`
+	Signal string      `json:"signal"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+// maxWebhookBody is the largest body of a WebhookEvent
+const maxWebhookBody = 1 << 20
+
+// WebhookSource is a Source and an http.Handler that receives POSTed WebhookEvents and signals the
+// instances.  Signals are referenced by their friendly names.  Events are accepted once the source is
+// running.  Signals the machines reject up front, e.g. for an unknown instance, are answered with 404 or 400;
+// the others are delivered asynchronously and errors are reported on the error stream.
+type WebhookSource struct {
+	signals map[string]Signal
+	signal  func(ID, Signal, ...interface{}) error
+	lock    sync.RWMutex
+}
+
+// NewWebhookSource returns a webhook that accepts the signals of the given names, typically Options.SignalNames.
+func NewWebhookSource(names map[Signal]string) *WebhookSource {
+	signals := map[string]Signal{}
+	for signal, name := range names {
+		signals[name] = signal
+	}
+	return &WebhookSource{signals: signals}
+}
+
+// Run implements Source
+func (s *WebhookSource) Run(ctx context.Context, emit func(ID, Signal, ...interface{})) {
+	s.runChecked(ctx, func(id ID, signal Signal, optionalData ...interface{}) error {
+		emit(id, signal, optionalData...)
+		return nil
+	})
+}
+
+// runChecked implements checkedSource, when the source is added to machines of this package
+func (s *WebhookSource) runChecked(ctx context.Context, signal func(ID, Signal, ...interface{}) error) {
+	s.lock.Lock()
+	s.signal = signal
+	s.lock.Unlock()
+
+	<-ctx.Done()
+
+	s.lock.Lock()
+	s.signal = nil
+	s.lock.Unlock()
+}
+
+// ServeHTTP implements http.Handler
+func (s *WebhookSource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	event := WebhookEvent{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBody)).Decode(&event); err != nil {
+		http.Error(w, fmt.Sprintf("bad event: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	signal, has := s.signals[event.Signal]
+	if !has {
+		http.Error(w, fmt.Sprintf("unknown signal: %v", event.Signal), http.StatusBadRequest)
+		return
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.signal == nil {
+		http.Error(w, "not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	var err error
+	if event.Data == nil {
+		err = s.signal(event.ID, signal)
+	} else {
+		err = s.signal(event.ID, signal, event.Data)
+	}
+	switch err.(type) {
+	case nil:
+		w.WriteHeader(http.StatusAccepted)
+	case ErrUnknownFSM, ErrFreed:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,85 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSource(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+	)
+
+	const (
+		found Signal = iota
+		stray        // not in the spec
+	)
+
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				found: running,
+			},
+		},
+		State{
+			Index: running,
+		},
+	)
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	options.SignalNames = map[Signal]string{found: "found", stray: "stray"}
+
+	webhook := NewWebhookSource(options.SignalNames)
+	server := httptest.NewServer(webhook)
+	defer server.Close()
+
+	post := func(body string) int {
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusServiceUnavailable, post(`{"id":0,"signal":"found"}`))
+
+	machines.AddSource(webhook)
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	instance, err := machines.New(pending)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusBadRequest, post(`{"id":0,"signal":"lost"}`))
+	require.Equal(t, http.StatusBadRequest, post(`not json`))
+	require.Equal(t, pending, instance.State())
+
+	// the source starts asynchronously
+	status := post(`{"id":0,"signal":"found","data":{"url":"http://x"}}`)
+	for ; status == http.StatusServiceUnavailable; status = post(`{"id":0,"signal":"found","data":{"url":"http://x"}}`) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(t, http.StatusAccepted, status)
+	require.Equal(t, running, instance.State())
+	require.Equal(t, []interface{}{map[string]interface{}{"url": "http://x"}}, instance.Data())
+
+	// rejected by the machines before it's queued
+	require.Equal(t, http.StatusNotFound, post(`{"id":99,"signal":"found"}`))
+	require.Equal(t, http.StatusBadRequest, post(`{"id":0,"signal":"stray"}`))
+
+	// too large
+	require.Equal(t, http.StatusBadRequest, post(`{"id":0,"signal":"found","data":"`+strings.Repeat("x", 2<<20)+`"}`))
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
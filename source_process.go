@@ -0,0 +1,88 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+)
+
+// Waiter waits for a process to exit.  *exec.Cmd implements this interface, and a process supervisor
+// can be adapted to it.
+type Waiter interface {
+	Wait() error
+}
+
+// ProcessSource is a Source that waits for watched processes to exit and signals their instances,
+// with the exit code as the signal's data.  An exit code of -1 means the exit status is unknown, for
+// example when the process was killed by a signal.  The zero value, with the signals set, is ready to use.
+type ProcessSource struct {
+
+	// Exited is raised when a process exits successfully
+	Exited Signal
+
+	// Crashed is raised when a process exits with an error
+	Crashed Signal
+
+	init    sync.Once
+	stop    sync.Once
+	exits   chan processExit
+	stopped chan struct{} // closed when Run returns
+}
+
+type processExit struct {
+	id  ID
+	err error
+}
+
+// NewProcessSource returns a source that raises the given signals when processes exit.
+func NewProcessSource(exited, crashed Signal) *ProcessSource {
+	return &ProcessSource{
+		Exited:  exited,
+		Crashed: crashed,
+	}
+}
+
+func (s *ProcessSource) channels() {
+	s.init.Do(func() {
+		s.exits = make(chan processExit)
+		s.stopped = make(chan struct{})
+	})
+}
+
+// Watch waits for the started process to exit and signals the instance of the given ID.  Once the context
+// of Run is done, the exit is dropped and the watch ends as soon as the process exits.
+func (s *ProcessSource) Watch(id ID, process Waiter) {
+	s.channels()
+	go func() {
+		exit := processExit{id: id, err: process.Wait()}
+		select {
+		case s.exits <- exit:
+		case <-s.stopped:
+		}
+	}()
+}
+
+// Run implements Source
+func (s *ProcessSource) Run(ctx context.Context, emit func(ID, Signal, ...interface{})) {
+	s.channels()
+	defer s.stop.Do(func() { close(s.stopped) })
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case exit := <-s.exits:
+			if exit.err == nil {
+				emit(exit.id, s.Exited, 0)
+			} else {
+				emit(exit.id, s.Crashed, exitCode(exit.err))
+			}
+		}
+	}
+}
+
+func exitCode(err error) int {
+	if exit, is := err.(*exec.ExitError); is {
+		return exit.ExitCode()
+	}
+	return -1
+}
@@ -0,0 +1,71 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessSource(t *testing.T) {
+
+	const (
+		exited Signal = iota
+		crashed
+	)
+
+	type emitted struct {
+		id     ID
+		signal Signal
+		code   int
+	}
+
+	source := NewProcessSource(exited, crashed)
+
+	events := make(chan emitted)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		source.Run(ctx, func(id ID, s Signal, data ...interface{}) {
+			events <- emitted{id: id, signal: s, code: data[0].(int)}
+		})
+	}()
+
+	ok := exec.Command("sh", "-c", "exit 0")
+	require.NoError(t, ok.Start())
+	source.Watch(1, ok)
+	require.Equal(t, emitted{id: 1, signal: exited, code: 0}, <-events)
+
+	fail := exec.Command("sh", "-c", "exit 3")
+	require.NoError(t, fail.Start())
+	source.Watch(2, fail)
+	require.Equal(t, emitted{id: 2, signal: crashed, code: 3}, <-events)
+
+	cancel()
+	<-done
+}
+
+func TestProcessSourceZeroValue(t *testing.T) {
+
+	source := &ProcessSource{Exited: 0, Crashed: 1}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	source.Run(ctx, func(ID, Signal, ...interface{}) {})
+
+	// a watch after the source has stopped ends when the process exits
+	ended := make(chan struct{})
+	source.Watch(1, waiterFunc(func() error {
+		defer close(ended)
+		return nil
+	}))
+	<-ended
+}
+
+type waiterFunc func() error
+
+func (f waiterFunc) Wait() error {
+	return f()
+}
@@ -0,0 +1,72 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type sourceFunc func(context.Context, func(ID, Signal, ...interface{}))
+
+func (f sourceFunc) Run(ctx context.Context, emit func(ID, Signal, ...interface{})) {
+	f(ctx, emit)
+}
+
+func TestSource(t *testing.T) {
+
+	const (
+		on Index = iota
+		off
+	)
+
+	const (
+		turnOn Signal = iota
+		turnOff
+	)
+
+	machines, err := define(
+		State{
+			Index: on,
+			Transitions: map[Signal]Index{
+				turnOff: off,
+			},
+		},
+		State{
+			Index: off,
+			Transitions: map[Signal]Index{
+				turnOn: on,
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	emits := make(chan func(ID, Signal, ...interface{}), 2)
+	stopped := make(chan struct{}, 2)
+	source := sourceFunc(func(ctx context.Context, emit func(ID, Signal, ...interface{})) {
+		emits <- emit
+		<-ctx.Done()
+		stopped <- struct{}{}
+	})
+
+	// added before Run
+	machines.AddSource(source)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+
+	instance, err := machines.New(off)
+	require.NoError(t, err)
+
+	emit := <-emits
+	emit(instance.ID(), turnOn)
+	require.Equal(t, on, instance.State())
+
+	// added after Run
+	machines.AddSource(source)
+	emit = <-emits
+	emit(instance.ID(), turnOff, "data")
+	require.Equal(t, off, instance.State())
+	require.Equal(t, []interface{}{"data"}, instance.Data())
+
+	machines.Done()
+	require.Equal(t, 2, len(stopped))
+}
@@ -23,7 +23,9 @@ func newSpec() *spec {
 }
 
 // define performs basic validation, consistency checks and returns a compiled spec.
-func (s *spec) build(state State, more ...State) (*spec, error) {
+func (s *spec) build(state State, more ...State) (_ *spec, err error) {
+	defer func() { err = defining(err) }()
+
 	states := map[Index]State{
 		state.Index: state,
 	}
@@ -36,6 +38,18 @@ func (s *spec) build(state State, more ...State) (*spec, error) {
 		states[st.Index] = st
 	}
 
+	// copy the metadata so it can't be changed after compiling
+	for index, st := range states {
+		st.Meta = copyMeta(st.Meta)
+		st.Descriptions = copyDescriptions(st.Descriptions)
+		actions, err := s.contextActions(st)
+		if err != nil {
+			return s, err
+		}
+		st.Actions = actions
+		states[index] = st
+	}
+
 	// check referential integrity
 	signals, err := s.compile(states)
 	if err != nil {
@@ -84,6 +98,134 @@ func (s *spec) compile(m map[Index]State) (map[Signal]Signal, error) {
 		}
 	}
 
+	// prepare and commit steps must be in transitions
+
+	for _, st := range m {
+		for _, steps := range []map[Signal]Action{st.Prepare, st.Commit} {
+			for signal, step := range steps {
+				if _, has := st.Transitions[signal]; !has {
+					return nil, ErrUnknownTransition{
+						spec: s, Signal: signal, State: st.Index,
+						Help: "prepare or commit for signal that's not in state's transitions",
+					}
+				}
+				if step == nil {
+					return nil, ErrNilAction(signal)
+				}
+			}
+		}
+	}
+
+	// submachines must complete with a transition
+
+	for _, st := range m {
+		if st.Sub == nil {
+			continue
+		}
+		if st.Sub.Machines == nil {
+			return nil, Errorf(SpecError, "submachine with no machines: state=%v", s.stateName(st.Index))
+		}
+		if _, has := st.Transitions[st.Sub.Done]; !has {
+			return nil, ErrUnknownTransition{
+				spec: s, Signal: st.Sub.Done, State: st.Index,
+				Help: "submachine done signal that's not in state's transitions",
+			}
+		}
+	}
+
+	// choices must be on transitions, to known states
+
+	for _, st := range m {
+		for signal, choice := range st.Choices {
+			if _, has := st.Transitions[signal]; !has {
+				return nil, ErrUnknownTransition{
+					spec: s, Signal: signal, State: st.Index,
+					Help: "choice for signal that's not in state's transitions",
+				}
+			}
+			if choice.Select == nil {
+				return nil, Errorf(SpecError, "choice with no select: state=%v, signal=%v", s.stateName(st.Index),
+					s.signalName(signal))
+			}
+			for _, next := range choice.To {
+				if _, has := m[next]; !has {
+					return nil, ErrUnknownState{spec: s, Index: next}
+				}
+			}
+		}
+	}
+
+	// errors actions must be in errors
+
+	for _, st := range m {
+		for signal, action := range st.ErrorsAction {
+			if _, has := st.Errors[signal]; !has {
+				return nil, ErrUnknownTransition{
+					spec: s, Signal: signal, State: st.Index,
+					Help: "errors action for signal that's not in state's errors",
+				}
+			}
+			if action == nil {
+				return nil, ErrNilAction(signal)
+			}
+		}
+	}
+
+	// named actions must be in transitions and not also given as actions
+
+	for _, st := range m {
+		for signal := range st.ActionNames {
+			if _, has := st.Transitions[signal]; !has {
+				return nil, ErrUnknownTransition{
+					spec: s, Signal: signal, State: st.Index,
+					Help: "named action for signal that's not in state's transitions",
+				}
+			}
+			if _, has := st.Actions[signal]; has {
+				return nil, ErrDuplicateAction{spec: s, State: st.Index, Signal: signal}
+			}
+		}
+	}
+
+	// described transitions must be in the transitions
+
+	for _, st := range m {
+		for signal := range st.Descriptions {
+			if _, has := st.Transitions[signal]; !has {
+				return nil, ErrUnknownTransition{
+					spec: s, Signal: signal, State: st.Index,
+					Help: "description for signal that's not in state's transitions",
+				}
+			}
+		}
+	}
+
+	// internal transitions must be self-transitions
+
+	for _, st := range m {
+		for _, signal := range st.Internal {
+			if next, has := st.Transitions[signal]; !has || next != st.Index {
+				return nil, ErrUnknownTransition{
+					spec: s, Signal: signal, State: st.Index,
+					Help: "internal transition for signal that's not a self-transition in state's transitions",
+				}
+			}
+		}
+	}
+
+	// signals subject to thresholds must be in the transitions
+
+	for _, st := range m {
+		for signal := range st.Hysteresis {
+			if _, has := st.Transitions[signal]; !has {
+				return nil, ErrUnknownTransition{
+					spec: s, Signal: signal, State: st.Index,
+					Help: "hysteresis threshold for signal that's not in state's transitions",
+				}
+			}
+		}
+	}
+
 	// what's raised in the TTL and in the Visit limit must be defined as well
 
 	for _, st := range m {
@@ -98,6 +240,11 @@ func (s *spec) compile(m map[Index]State) (map[Signal]Signal, error) {
 			// register as valid signal
 			signals[st.TTL.Raise] = st.TTL.Raise
 
+			for _, signal := range st.Rearm {
+				signals[signal] = signal
+			}
+		} else if len(st.Rearm) > 0 {
+			return nil, Errorf(SpecError, "rearm with no ttl: state=%v", s.stateName(st.Index))
 		}
 		if st.Visit.Value > 0 {
 			if _, has := st.Transitions[st.Visit.Raise]; !has {
@@ -110,11 +257,59 @@ func (s *spec) compile(m map[Index]State) (map[Signal]Signal, error) {
 			// register as valid signal
 			signals[st.Visit.Raise] = st.Visit.Raise
 		}
+		if st.Watchdog.TTL > 0 {
+			if _, has := st.Transitions[st.Watchdog.Raise]; !has {
+				return nil, ErrUnknownSignal{
+					spec: s, Signal: st.Watchdog.Raise, Index: st.Index,
+					Help: "watchdog raises signal that's not in state's transitions",
+				}
+			}
+
+			// register as valid signals
+			signals[st.Watchdog.Raise] = st.Watchdog.Raise
+			signals[st.Watchdog.KeepAlive] = st.Watchdog.KeepAlive
+		}
+		for _, signal := range st.Acknowledge {
+			signals[signal] = signal
+		}
 	}
 
 	return signals, nil
 }
 
+// meta returns a copy of the metadata of the state
+func (s *spec) meta(current Index) map[string]string {
+	return copyMeta(s.states[current].Meta)
+}
+
+// terminal returns true if the state is known and has no transitions out
+func (s *spec) terminal(index Index) bool {
+	st, has := s.states[index]
+	return has && len(st.Transitions) == 0
+}
+
+func copyMeta(meta map[string]string) map[string]string {
+	if meta == nil {
+		return nil
+	}
+	copy := map[string]string{}
+	for k, v := range meta {
+		copy[k] = v
+	}
+	return copy
+}
+
+func copyDescriptions(descriptions map[Signal]string) map[Signal]string {
+	if descriptions == nil {
+		return nil
+	}
+	copy := map[Signal]string{}
+	for k, v := range descriptions {
+		copy[k] = v
+	}
+	return copy
+}
+
 // StateName returns the friendly name of the state, if defined
 func (s *spec) stateName(i Index) (name string) {
 	name = fmt.Sprintf("%v", i)
@@ -150,7 +345,7 @@ func (s *spec) signalName(signal Signal) (name string) {
 func (s *spec) SetAction(state Index, signal Signal, action Action) error {
 	st, has := s.states[state]
 	if !has {
-		return fmt.Errorf("no such state %v", state)
+		return ErrUnknownState{spec: s, Index: state, defining: true}
 	}
 	if st.Actions == nil {
 		st.Actions = map[Signal]Action{}
@@ -160,6 +355,47 @@ func (s *spec) SetAction(state Index, signal Signal, action Action) error {
 	return nil
 }
 
+// bindActions returns a copy of the states with the named actions looked up in the registry.
+func (s *spec) bindActions(registry ActionRegistry) (map[Index]State, error) {
+	states := map[Index]State{}
+	for index, state := range s.states {
+		if len(state.ActionNames) > 0 {
+			actions := map[Signal]Action{}
+			for signal, action := range state.Actions {
+				actions[signal] = action
+			}
+			for signal, name := range state.ActionNames {
+				action, has := registry[name]
+				if !has || action == nil {
+					return nil, ErrUnboundAction{spec: s, State: index, Signal: signal, Name: name}
+				}
+				actions[signal] = action
+			}
+			state.Actions = actions
+		}
+		states[index] = state
+	}
+	return states, nil
+}
+
+// wrapActions returns a copy of the states with every action wrapped by the function.
+// The name of an action is the name of its state and signal, as state/signal.
+func (s *spec) wrapActions(wrap func(string, Action) Action) map[Index]State {
+	states := map[Index]State{}
+	for index, state := range s.states {
+		if len(state.Actions) > 0 {
+			actions := map[Signal]Action{}
+			for signal, action := range state.Actions {
+				name := fmt.Sprintf("%s/%s", s.stateName(index), s.signalName(signal))
+				actions[signal] = wrap(name, action)
+			}
+			state.Actions = actions
+		}
+		states[index] = state
+	}
+	return states
+}
+
 // returns an expiry for the state.  if the TTL is 0 then there's no expiry for the state.
 func (s *spec) expiry(current Index) (expiry *Expiry, err error) {
 	state, has := s.states[current]
@@ -173,6 +409,30 @@ func (s *spec) expiry(current Index) (expiry *Expiry, err error) {
 	return
 }
 
+// returns true if the signal re-arms the TTL of the state
+func (s *spec) rearms(current Index, signal Signal) bool {
+	state, has := s.states[current]
+	if !has || state.TTL.TTL == 0 {
+		return false
+	}
+	for _, rearm := range state.Rearm {
+		if rearm == signal {
+			return true
+		}
+	}
+	return false
+}
+
+// internal returns true if the signal is an internal transition of the state
+func (s *spec) internal(current Index, signal Signal) bool {
+	for _, internal := range s.states[current].Internal {
+		if internal == signal {
+			return true
+		}
+	}
+	return false
+}
+
 // returns the limit on visiting this state
 func (s *spec) visit(next Index) (limit *Limit, err error) {
 	state, has := s.states[next]
@@ -214,7 +474,7 @@ func (s *spec) error(current Index, signal Signal) (next Index, err error) {
 // returns error if the transition is not possible.
 func (s *spec) transition(current Index, signal Signal) (next Index, action Action, err error) {
 
-	next = -1
+	next = NoState
 
 	state, has := s.states[current]
 	if !has {
@@ -222,6 +482,11 @@ func (s *spec) transition(current Index, signal Signal) (next Index, action Acti
 		return
 	}
 
+	if n, has := state.Errors[signal]; has && state.ErrorsFirst {
+		next = n
+		return
+	}
+
 	if len(state.Transitions) == 0 {
 		err = ErrNoTransitions(*s)
 		return
@@ -147,3 +147,37 @@ func TestBuild2(t *testing.T) {
 	_, _, err = spec.transition(on, turnOn)
 	require.Error(t, err)
 }
+
+func TestNoState(t *testing.T) {
+
+	const (
+		on Index = iota
+		off
+	)
+
+	const (
+		turnOff Signal = iota
+	)
+
+	spec, err := newSpec().build(
+		State{
+			Index: on,
+			Transitions: map[Signal]Index{
+				turnOff: off,
+			},
+		},
+		State{
+			Index: off,
+		},
+	)
+	require.NoError(t, err)
+
+	next, _, err := spec.transition(off, turnOff)
+	require.Error(t, err)
+	require.True(t, IsInvalidState(next))
+	require.Equal(t, NoState, next)
+
+	require.True(t, IsNoSignal(NoSignal))
+	require.False(t, IsNoSignal(turnOff))
+	require.False(t, IsInvalidState(on))
+}
@@ -0,0 +1,82 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"sort"
+)
+
+// StabilityConfig configures the stability score of instances, from the transitions in a sliding window
+type StabilityConfig struct {
+	// Window is the number of ticks of the window.  Stability is not tracked if 0.
+	Window Tick
+
+	// Weights are the weights of entering the states, e.g. higher for error states.  The default is 1.
+	Weights map[Index]float64
+}
+
+// Stability is the rate of change of an instance in the window
+type Stability struct {
+	ID ID
+
+	// Transitions is the number of transitions in the window
+	Transitions int
+
+	// Weighted is the sum of the weights of the states entered in the window
+	Weighted float64
+
+	// Score is 1 / (1 + Weighted), from 1 for an instance that hasn't changed in the window towards 0
+	Score float64
+}
+
+// change is a transition of an instance counted in its stability
+type change struct {
+	tick   Time
+	weight float64
+}
+
+// recordChange records the transition of the instance into the state for its stability.
+// This must be called from within the transaction loop.
+func (g *runner) recordChange(instance *instance, next Index, now Time) {
+	config := g.options.Stability
+	if config.Window <= 0 {
+		return
+	}
+	weight, has := config.Weights[next]
+	if !has {
+		weight = 1
+	}
+	instance.changes = append(g.window(instance.changes, now), change{tick: now, weight: weight})
+}
+
+// window returns the changes in the window ending now
+func (g *runner) window(changes []change, now Time) []change {
+	start := 0
+	for start < len(changes) && now-changes[start].tick >= Time(g.options.Stability.Window) {
+		start++
+	}
+	return changes[start:]
+}
+
+// stability returns the stability of the instances, the most stable first and then in the order of ID.
+// This must be called from within the transaction loop.
+func (g *runner) stability() []Stability {
+	out := []Stability{}
+	g.forEach(func(i *instance) bool {
+		i.changes = g.window(i.changes, g.now)
+		s := Stability{ID: i.id, Transitions: len(i.changes)}
+		for _, c := range i.changes {
+			s.Weighted += c.weight
+		}
+		s.Score = 1 / (1 + s.Weighted)
+		out = append(out, s)
+		return true
+	})
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out
+}
+
+func (m *machines) Stability() (stability []Stability) {
+	m.runner.do(func(g *runner) {
+		stability = g.stability()
+	})
+	return
+}
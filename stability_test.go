@@ -0,0 +1,79 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStability(t *testing.T) {
+
+	const (
+		healthy Index = iota
+		degraded
+		down
+	)
+
+	const (
+		degrade Signal = iota
+		fail
+		recover
+	)
+
+	machines, err := define(
+		State{
+			Index: healthy,
+			Transitions: map[Signal]Index{
+				degrade: degraded,
+				fail:    down,
+			},
+		},
+		State{
+			Index: degraded,
+			Transitions: map[Signal]Index{
+				recover: healthy,
+			},
+		},
+		State{
+			Index: down,
+			Transitions: map[Signal]Index{
+				recover: healthy,
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	options.Stability = StabilityConfig{Window: 10, Weights: map[Index]float64{down: 3}}
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, options))
+	defer machines.Done()
+
+	a, err := machines.New(healthy)
+	require.NoError(t, err)
+	b, err := machines.New(healthy)
+	require.NoError(t, err)
+	c, err := machines.New(healthy)
+	require.NoError(t, err)
+
+	require.NoError(t, a.Signal(degrade))
+	require.NoError(t, a.Signal(recover))
+	require.NoError(t, b.Signal(fail))
+
+	require.Equal(t, []Stability{
+		{ID: c.ID(), Transitions: 0, Weighted: 0, Score: 1},
+		{ID: a.ID(), Transitions: 2, Weighted: 2, Score: 1.0 / 3},
+		{ID: b.ID(), Transitions: 1, Weighted: 3, Score: 1.0 / 4},
+	}, machines.Stability())
+
+	clock.Ticks(5)
+	require.NoError(t, b.Signal(recover))
+	clock.Ticks(5)
+
+	// the first changes are out of the window
+	require.Equal(t, []Stability{
+		{ID: a.ID(), Transitions: 0, Weighted: 0, Score: 1},
+		{ID: c.ID(), Transitions: 0, Weighted: 0, Score: 1},
+		{ID: b.ID(), Transitions: 1, Weighted: 1, Score: 1.0 / 2},
+	}, machines.Stability())
+}
@@ -0,0 +1,142 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"time"
+)
+
+var defaultBuckets = []time.Duration{
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// Histogram is a distribution of durations.  Counts[i] is the number of observations less than or
+// equal to Bounds[i] and greater than the bound before it; the last count is of those above all bounds.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []int64
+	Count  int64
+	Sum    time.Duration
+	Max    time.Duration
+}
+
+func newHistogram(bounds ...time.Duration) *Histogram {
+	return &Histogram{
+		Bounds: bounds,
+		Counts: make([]int64, len(bounds)+1),
+	}
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(h.Bounds) && d > h.Bounds[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+	if d > h.Max {
+		h.Max = d
+	}
+}
+
+// Mean returns the average of the observations
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+func (h *Histogram) copy() Histogram {
+	copy := *h
+	copy.Counts = append([]int64(nil), h.Counts...)
+	return copy
+}
+
+// Stats is a snapshot of the runtime statistics of the machines
+type Stats struct {
+
+	// Now is the current logical time
+	Now Time
+
+	// Ticks is the number of clock ticks processed
+	Ticks int64
+
+	// TickLag is the number of clock ticks received but not yet processed
+	TickLag int64
+
+	// TickLagAlerts is the number of times the tick lag exceeded Options.MaxTickLag
+	TickLagAlerts int64
+
+	// ClockStalls is the number of times no tick was received within Options.ClockStall
+	ClockStalls int64
+
+	// QueueLatency is the distribution of the time from queueing an event to handling it
+	QueueLatency Histogram
+
+	// ActionDuration is the distribution of the time spent executing actions
+	ActionDuration Histogram
+
+	// Clock are the counters of the clock driving the machines
+	Clock ClockStats
+
+	// Instances is the number of instances in each state
+	Instances map[Index]int
+
+	// Transitions is the number of committed transitions on each signal
+	Transitions map[Signal]int64
+
+	// Undefined is the number of signals received in each state with no transition for them, whether the
+	// errors are ignored or not, to discover the signals the spec doesn't handle
+	Undefined map[Index]map[Signal]int64
+
+	// DeadLettersDropped is the number of dead letters dropped beyond Options.DeadLetterLimit
+	DeadLettersDropped int64
+}
+
+// countUndefined counts the signal received in the state with no transition for it.  This must be called from
+// within the transaction loop.
+func (g *runner) countUndefined(state Index, signal Signal) {
+	counts, has := g.undefined[state]
+	if !has {
+		counts = map[Signal]int64{}
+		g.undefined[state] = counts
+	}
+	counts[signal]++
+}
+
+// stats returns the statistics of the runner.  This must be called from within the transaction loop.
+func (g *runner) stats() Stats {
+	transitions := map[Signal]int64{}
+	for signal, count := range g.transitions {
+		transitions[signal] = count
+	}
+	undefined := map[Index]map[Signal]int64{}
+	for state, counts := range g.undefined {
+		undefined[state] = map[Signal]int64{}
+		for signal, count := range counts {
+			undefined[state][signal] = count
+		}
+	}
+	return Stats{
+		Now:           g.now,
+		Ticks:         g.ticks,
+		TickLag:       g.tickLag(),
+		TickLagAlerts: g.lagAlerts,
+		ClockStalls:   g.stalls,
+
+		QueueLatency:   g.latency.copy(),
+		ActionDuration: g.durations.copy(),
+		Clock:          g.clock.Stats(),
+
+		Instances:   g.counts(),
+		Transitions: transitions,
+		Undefined:   undefined,
+
+		DeadLettersDropped: g.deadLettersDropped,
+	}
+}
@@ -0,0 +1,251 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTickLag(t *testing.T) {
+
+	const (
+		idle Index = iota
+		busy
+	)
+
+	const (
+		work Signal = iota
+	)
+
+	release := make(chan struct{})
+	machines, err := define(
+		State{
+			Index: idle,
+			Transitions: map[Signal]Index{
+				work: busy,
+			},
+			Actions: map[Signal]Action{
+				work: func(FSM) error {
+					<-release
+					return nil
+				},
+			},
+		},
+		State{
+			Index: busy,
+		},
+	)
+	require.NoError(t, err)
+
+	clock := NewClock()
+	options := DefaultOptions()
+	options.MaxTickLag = 2
+	require.NoError(t, machines.Run(clock, options))
+	defer machines.Done()
+
+	errs := make(chan error, 10)
+	go func() {
+		for err := range machines.Errors() {
+			errs <- err
+		}
+	}()
+
+	instance, err := machines.New(idle)
+	require.NoError(t, err)
+	require.NoError(t, instance.Signal(work))
+
+	// the action blocks the processing while the clock keeps ticking
+	clock.Ticks(5)
+	close(release)
+
+	require.Equal(t, ErrTickLag(4), <-errs)
+
+	stats := machines.Stats()
+	require.Equal(t, int64(5), stats.Ticks)
+	require.Equal(t, int64(0), stats.TickLag)
+	require.Equal(t, int64(2), stats.TickLagAlerts)
+	require.Equal(t, int64(5), stats.Clock.Delivered)
+}
+
+func TestClockStall(t *testing.T) {
+
+	const (
+		idle Index = iota
+	)
+
+	machines, err := define(State{Index: idle})
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	options.ClockStall = 50 * time.Millisecond
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	err = <-machines.Errors()
+	require.Equal(t, ErrClockStalled(50*time.Millisecond), err)
+	require.True(t, machines.Stats().ClockStalls > 0)
+}
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram(time.Millisecond, time.Second)
+	h.observe(time.Millisecond)
+	h.observe(2 * time.Millisecond)
+	h.observe(3 * time.Millisecond)
+	h.observe(2 * time.Second)
+
+	require.Equal(t, []int64{1, 2, 1}, h.Counts)
+	require.Equal(t, int64(4), h.Count)
+	require.Equal(t, 2*time.Second, h.Max)
+	require.Equal(t, 2006*time.Millisecond/4, h.Mean())
+
+	copy := h.copy()
+	h.observe(0)
+	require.Equal(t, []int64{1, 2, 1}, copy.Counts)
+}
+
+func TestLatencyStats(t *testing.T) {
+
+	const (
+		idle Index = iota
+		busy
+	)
+
+	const (
+		work Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: idle,
+			Transitions: map[Signal]Index{
+				work: busy,
+			},
+			Actions: map[Signal]Action{
+				work: func(FSM) error {
+					time.Sleep(20 * time.Millisecond)
+					return nil
+				},
+			},
+		},
+		State{
+			Index: busy,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	a, err := machines.New(idle)
+	require.NoError(t, err)
+	b, err := machines.New(idle)
+	require.NoError(t, err)
+
+	require.NoError(t, a.Signal(work))
+	require.NoError(t, b.Signal(work)) // queued behind the action of a
+
+	stats := machines.Stats()
+	require.Equal(t, int64(2), stats.ActionDuration.Count)
+	require.True(t, stats.ActionDuration.Max >= 20*time.Millisecond)
+	require.Equal(t, int64(2), stats.QueueLatency.Count)
+	require.True(t, stats.QueueLatency.Max >= 10*time.Millisecond)
+}
+
+func TestClockJump(t *testing.T) {
+
+	const (
+		waiting Index = iota
+		running
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	for _, policy := range []JumpPolicy{JumpRearm, JumpFire} {
+
+		machines, err := define(
+			State{
+				Index: waiting,
+				Transitions: map[Signal]Index{
+					start: running,
+				},
+				TTL: Expiry{5, start},
+			},
+			State{
+				Index: running,
+			},
+		)
+		require.NoError(t, err)
+
+		clock := NewClock()
+		options := DefaultOptions()
+		options.ClockJump = policy
+		require.NoError(t, machines.Run(clock, options))
+
+		instance, err := machines.New(waiting)
+		require.NoError(t, err)
+
+		clock.c <- Tick(10) // the wall clock jumped by 9 ticks
+		time.Sleep(100 * time.Millisecond)
+
+		switch policy {
+		case JumpRearm:
+			require.Equal(t, Time(1), machines.Stats().Now)
+			require.Equal(t, waiting, instance.State())
+		case JumpFire:
+			require.Equal(t, Time(10), machines.Stats().Now)
+			require.Equal(t, running, instance.State())
+		}
+		machines.Done()
+	}
+}
+
+func TestUndefinedStats(t *testing.T) {
+
+	const (
+		running Index = iota
+		stopped
+	)
+
+	const (
+		stop Signal = iota
+		start
+		pause
+	)
+
+	machines, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				stop: stopped,
+			},
+		},
+		State{
+			Index: stopped,
+			Transitions: map[Signal]Index{
+				start: running,
+				pause: stopped,
+			},
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	a, err := machines.New(running)
+	require.NoError(t, err)
+
+	// ignored by default, but counted
+	require.NoError(t, a.Signal(start))
+	require.NoError(t, a.Signal(pause))
+	require.NoError(t, a.Signal(start))
+	require.NoError(t, a.Signal(stop))
+	require.NoError(t, a.Signal(stop))
+	require.Equal(t, stopped, a.State())
+
+	require.Equal(t, map[Index]map[Signal]int64{
+		running: {start: 2, pause: 1},
+		stopped: {stop: 1},
+	}, machines.Stats().Undefined)
+}
@@ -0,0 +1,43 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// automatic returns true for the signals raised by the machines themselves
+func automatic(origin Origin) bool {
+	switch origin {
+	case OriginTTL, OriginVisitLimit, OriginFlap, OriginWatchdog, OriginErrorBudget:
+		return true
+	}
+	return false
+}
+
+// sticky handles the acknowledgement of a sticky state, and drops the signals raised by the machines until
+// then.  It returns true if the event is handled.  This must be called from within the transaction loop.
+func (g *runner) sticky(tid int64, instance *instance, event *event) (bool, error) {
+	state := g.spec().states[instance.state]
+	if len(state.Acknowledge) == 0 {
+		return false, nil
+	}
+
+	for _, ack := range state.Acknowledge {
+		if ack != event.signal {
+			continue
+		}
+		if !instance.acked {
+			instance.acked = true
+			g.log.Info("Acknowledged", g.withFields(snapshot{instance}, "tid", tid, "instance", instance.id,
+				"state", g.spec().stateName(instance.state), "signal", g.spec().signalName(event.signal))...)
+			if err := g.processDeadline(tid, instance, instance.state); err != nil {
+				return true, err
+			}
+		}
+		_, transition := state.Transitions[event.signal]
+		return !transition, nil
+	}
+
+	if automatic(event.origin) && !instance.acked {
+		g.debug("Unacknowledged", snapshot{instance}, "tid", tid, "instance", instance.id,
+			"state", g.spec().stateName(instance.state), "signal", g.spec().signalName(event.signal))
+		return true, ErrUnacknowledged{spec: g.spec(), ID: instance.id, State: instance.state,
+			Signal: event.signal, Origin: event.origin}
+	}
+	return false, nil
+}
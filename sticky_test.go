@@ -0,0 +1,80 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSticky(t *testing.T) {
+
+	const (
+		running Index = iota
+		failed
+	)
+
+	const (
+		fail Signal = iota
+		retry
+		ack
+	)
+
+	machines, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				fail: failed,
+			},
+		},
+		State{
+			Index: failed,
+			Transitions: map[Signal]Index{
+				retry: running,
+			},
+			TTL:         Expiry{2, retry},
+			Acknowledge: []Signal{ack},
+		},
+	)
+	require.NoError(t, err)
+
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, DefaultOptions()))
+	defer machines.Done()
+
+	errs, cancel := machines.SubscribeErrors(ErrorFilter{Types: []error{ErrUnacknowledged{}}, Buffer: 1})
+	defer cancel()
+
+	a, err := machines.New(running)
+	require.NoError(t, err)
+	require.NoError(t, a.Signal(fail))
+
+	// the expiry is dropped until a human looks at it
+	clock.Ticks(3)
+	select {
+	case e := <-errs:
+		require.Equal(t, ErrUnacknowledged{spec: machines.spec, ID: a.ID(), State: failed, Signal: retry,
+			Origin: OriginTTL}, e.Err)
+	case <-time.After(time.Second):
+		require.Fail(t, "no error")
+	}
+	require.Equal(t, failed, a.State())
+	require.Len(t, machines.PendingDeadlines(), 0)
+
+	// acknowledging restarts the TTL
+	require.NoError(t, a.Signal(ack))
+	require.Equal(t, failed, a.State())
+	require.Equal(t, []DeadlineInfo{{ID: a.ID(), State: failed, Due: 5, Raise: retry}}, machines.PendingDeadlines())
+
+	clock.Ticks(2)
+	for i := 0; i < 100 && a.State() != running; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(t, running, a.State())
+
+	// sticky again on the next visit
+	require.NoError(t, a.Signal(fail))
+	clock.Ticks(3)
+	<-errs
+	require.Equal(t, failed, a.State())
+}
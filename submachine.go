@@ -0,0 +1,213 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"sync"
+)
+
+// Submachine is a child instance of other machines run while an instance is in a state, e.g. to reuse the machines
+// that provision a host in several larger ones.  Entering the state creates the child in the initial state, and
+// leaving the state, or freeing the instance, frees the child.  When the child reaches a terminal state, Done
+// is raised on the instance.  The machines of the child must be other machines of this package, and running.
+// The children are created and freed shortly after, not within, the transitions, so Child may be nil right after
+// entering the state.  Children are not kept in snapshots: an instance restored or handed off in the state gets
+// a new child, and the child it had is freed.
+type Submachine struct {
+	Machines Machines
+	Initial  Index
+
+	// Done is raised when the child reaches a state with no transitions out.  It must be in the transitions of
+	// the state.
+	Done Signal
+}
+
+// children are the child instances of a machines, by the ID of the child
+type children struct {
+	cancel func() // stops watching the transitions of the machines
+
+	lock    sync.Mutex
+	parents map[ID]childOf
+
+	// the children that completed, queued by the loop of the machines without waiting on lock, which is held
+	// while a child is created
+	queueLock sync.Mutex
+	completed []ID
+	wake      chan struct{}
+}
+
+// childOf is the instance of a child and the signal raised on it when the child completes
+type childOf struct {
+	instance *instance
+	done     Signal
+}
+
+// subOp creates the child of the submachine for the instance, or frees its child if sub is nil
+type subOp struct {
+	instance *instance
+	sub      *Submachine
+}
+
+// submachines create and free the children of the instances, in order, outside of the transaction loop, so
+// that the loop never waits on the loops of the machines of the children.
+type submachines struct {
+	lock sync.Mutex
+	ops  []subOp
+	wake chan struct{}
+
+	children map[Machines]*children // only used by the goroutine of the submachines
+}
+
+// enterSub creates the child of the instance, if the state runs a submachine.  This must be called from within
+// the transaction loop.
+func (g *runner) enterSub(instance *instance, state Index) {
+	sub := g.spec().states[state].Sub
+	if sub == nil {
+		return
+	}
+	instance.sub = true
+	g.queueSub(subOp{instance: instance, sub: sub})
+}
+
+// exitSub frees the child of the instance, if any.  This must be called from within the transaction loop.
+func (g *runner) exitSub(instance *instance) {
+	if !instance.sub {
+		return
+	}
+	instance.sub = false
+	g.queueSub(subOp{instance: instance})
+}
+
+func (g *runner) queueSub(op subOp) {
+	if g.subs == nil {
+		g.subs = &submachines{wake: make(chan struct{}, 1), children: map[Machines]*children{}}
+		go g.subs.run(g)
+	}
+	g.subs.lock.Lock()
+	g.subs.ops = append(g.subs.ops, op)
+	g.subs.lock.Unlock()
+	select {
+	case g.subs.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run applies the ops in order until the runner stops, and then stops watching the machines of the children
+func (s *submachines) run(g *runner) {
+	for {
+		select {
+		case <-g.stop:
+			for _, c := range s.children {
+				c.cancel()
+			}
+			return
+		case <-s.wake:
+		}
+		for {
+			s.lock.Lock()
+			if len(s.ops) == 0 {
+				s.lock.Unlock()
+				break
+			}
+			op := s.ops[0]
+			s.ops = s.ops[1:]
+			s.lock.Unlock()
+
+			if op.sub != nil {
+				s.create(g, op.instance, op.sub)
+			} else {
+				s.free(op.instance)
+			}
+		}
+	}
+}
+
+func (s *submachines) create(g *runner, instance *instance, sub *Submachine) {
+	// the child is registered before its transitions are looked up by the watcher
+	c := s.watch(g, sub.Machines)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	child, err := sub.Machines.New(sub.Initial)
+	if err != nil {
+		g.handleError(g.tid(), err, instance.id)
+		return
+	}
+	instance.setChild(child, sub.Machines)
+	c.parents[child.ID()] = childOf{instance: instance, done: sub.Done}
+}
+
+func (s *submachines) free(instance *instance) {
+	child, machines := instance.takeChild()
+	if child == nil {
+		return
+	}
+	if c, has := s.children[machines]; has {
+		c.lock.Lock()
+		delete(c.parents, child.ID())
+		c.lock.Unlock()
+	}
+	machines.Free(child.ID()) // fails if the child is pinned, and then it's left as it is
+}
+
+// watch returns the children of the machines, hooking their transitions to terminal states to raise Done on the
+// instances when the children complete.
+func (s *submachines) watch(g *runner, m Machines) *children {
+	if c, has := s.children[m]; has {
+		return c
+	}
+	c := &children{parents: map[ID]childOf{}, wake: make(chan struct{}, 1)}
+	c.cancel = m.(*machines).watchTerminal(func(transition Transition) {
+		c.queueLock.Lock()
+		c.completed = append(c.completed, transition.ID)
+		c.queueLock.Unlock()
+		select {
+		case c.wake <- struct{}{}:
+		default:
+		}
+	})
+	s.children[m] = c
+
+	go func() {
+		for {
+			select {
+			case <-g.stop:
+				return
+			case <-c.wake:
+			}
+			c.queueLock.Lock()
+			completed := c.completed
+			c.completed = nil
+			c.queueLock.Unlock()
+
+			for _, id := range completed {
+				c.lock.Lock()
+				parent, has := c.parents[id]
+				c.lock.Unlock()
+				if !has {
+					continue
+				}
+				if err := g.signal(OriginSubmachine, parent.done, parent.instance); err != nil {
+					g.handleError(g.tid(), err, parent.instance.id)
+				}
+			}
+		}
+	}()
+	return c
+}
+
+// checkSubs returns an error if a state runs the machines themselves, or machines of another type, as a
+// submachine
+func (m *machines) checkSubs(s *spec) error {
+	for _, st := range s.states {
+		if st.Sub == nil {
+			continue
+		}
+		if st.Sub.Machines == Machines(m) {
+			return Errorf(SpecError, "state %v runs its own machines as a submachine", s.stateName(st.Index))
+		}
+		if _, ok := st.Sub.Machines.(*machines); !ok {
+			return Errorf(SpecError, "state %v runs machines of type %T as a submachine, which is not supported",
+				s.stateName(st.Index), st.Sub.Machines)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,419 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmachine(t *testing.T) {
+
+	const (
+		requested Index = iota
+		ready
+	)
+
+	const (
+		boot Signal = iota
+	)
+
+	provision, err := define(
+		State{
+			Index: requested,
+			Transitions: map[Signal]Index{
+				boot: ready,
+			},
+		},
+		State{
+			Index: ready,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, provision.Run(NewClock(), DefaultOptions()))
+	defer provision.Done()
+
+	const (
+		pending Index = iota
+		provisioning
+		running
+		cancelled
+	)
+
+	const (
+		start Signal = iota
+		provisioned
+		cancel
+	)
+
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: provisioning,
+			},
+		},
+		State{
+			Index: provisioning,
+			Transitions: map[Signal]Index{
+				provisioned: running,
+				cancel:      cancelled,
+			},
+			Sub: &Submachine{Machines: provision, Initial: requested, Done: provisioned},
+		},
+		State{
+			Index: running,
+		},
+		State{
+			Index: cancelled,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	// the children are created and freed after the transitions
+	eventually := func(cond func() bool) {
+		for i := 0; i < 100 && !cond(); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		require.True(t, cond())
+	}
+
+	a, err := machines.New(pending)
+	require.NoError(t, err)
+	require.Nil(t, a.Child())
+	require.NoError(t, a.Signal(start))
+	require.Equal(t, provisioning, a.State())
+
+	eventually(func() bool { return a.Child() != nil })
+	child := a.Child()
+	require.Equal(t, requested, child.State())
+	require.Equal(t, 1, provision.Count())
+
+	// the child completes, and the parent moves on
+	require.NoError(t, child.Signal(boot))
+	eventually(func() bool { return a.State() == running })
+	require.Equal(t, OriginSubmachine, a.Origin())
+	eventually(func() bool { return a.Child() == nil && provision.Count() == 0 })
+
+	// leaving the state tears down the child
+	b, err := machines.New(provisioning)
+	require.NoError(t, err)
+	eventually(func() bool { return b.Child() != nil })
+	require.Equal(t, 1, provision.Count())
+	require.NoError(t, b.Signal(cancel))
+	require.Equal(t, cancelled, b.State())
+	eventually(func() bool { return provision.Count() == 0 })
+
+	// the child is freed after a reload that drops the submachine of the state
+	c, err := machines.New(provisioning)
+	require.NoError(t, err)
+	eventually(func() bool { return c.Child() != nil })
+	require.NoError(t, machines.Reload(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: provisioning,
+			},
+		},
+		State{
+			Index: provisioning,
+			Transitions: map[Signal]Index{
+				cancel: cancelled,
+			},
+		},
+		State{
+			Index: running,
+		},
+		State{
+			Index: cancelled,
+		},
+	))
+	require.NoError(t, c.Signal(cancel))
+	eventually(func() bool { return c.Child() == nil && provision.Count() == 0 })
+
+	// the done signal must have a transition
+	_, err = define(
+		State{
+			Index: provisioning,
+			Transitions: map[Signal]Index{
+				cancel: cancelled,
+			},
+			Sub: &Submachine{Machines: provision, Initial: requested, Done: provisioned},
+		},
+		State{
+			Index: cancelled,
+		},
+	)
+	require.Equal(t, SpecError, ClassOf(err))
+}
+
+func TestSubmachineSignalsParent(t *testing.T) {
+
+	const (
+		idle Index = iota
+		working
+		done
+	)
+
+	const (
+		run Signal = iota
+		finish
+	)
+
+	// the action of the child signals the parent, whose loop created the child
+	var parent FSM
+	child, err := define(
+		State{
+			Index: idle,
+			Transitions: map[Signal]Index{
+				run: done,
+			},
+			Actions: map[Signal]Action{
+				run: func(FSM) error {
+					return parent.Signal(finish)
+				},
+			},
+		},
+		State{
+			Index: done,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, child.Run(NewClock(), DefaultOptions()))
+	defer child.Done()
+
+	machines, err := define(
+		State{
+			Index: idle,
+			Transitions: map[Signal]Index{
+				run: working,
+			},
+		},
+		State{
+			Index: working,
+			Transitions: map[Signal]Index{
+				finish: done,
+			},
+			Sub: &Submachine{Machines: child, Initial: idle, Done: finish},
+		},
+		State{
+			Index: done,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	parent, err = machines.New(idle)
+	require.NoError(t, err)
+	require.NoError(t, parent.Signal(run))
+	for i := 0; i < 100 && parent.Child() == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.NoError(t, parent.Child().Signal(run))
+	for i := 0; i < 100 && parent.State() != done; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(t, done, parent.State())
+
+	// the machines can't run themselves as a submachine
+	sub := &Submachine{Machines: child, Initial: idle, Done: finish}
+	recursive, err := define(
+		State{
+			Index: idle,
+			Transitions: map[Signal]Index{
+				finish: done,
+			},
+			Sub: sub,
+		},
+		State{
+			Index: done,
+		},
+	)
+	require.NoError(t, err)
+	sub.Machines = recursive
+	require.Equal(t, SpecError, ClassOf(recursive.Run(NewClock(), DefaultOptions())))
+}
+
+func TestSubmachineBurst(t *testing.T) {
+
+	const (
+		requested Index = iota
+		ready
+	)
+
+	const (
+		boot Signal = iota
+	)
+
+	provision, err := define(
+		State{
+			Index: requested,
+			Transitions: map[Signal]Index{
+				boot: ready,
+			},
+		},
+		State{
+			Index: ready,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, provision.Run(NewClock(), DefaultOptions()))
+	defer provision.Done()
+
+	const (
+		provisioning Index = iota
+		running
+	)
+
+	const (
+		provisioned Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: provisioning,
+			Transitions: map[Signal]Index{
+				provisioned: running,
+			},
+			Sub: &Submachine{Machines: provision, Initial: requested, Done: provisioned},
+		},
+		State{
+			Index: running,
+		},
+	)
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.BufferSize = 1
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	parents := []FSM{}
+	for i := 0; i < 50; i++ {
+		parent, err := machines.New(provisioning)
+		require.NoError(t, err)
+		parents = append(parents, parent)
+	}
+	for _, parent := range parents {
+		for i := 0; i < 100 && parent.Child() == nil; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// the children complete at once, and none of their parents is left behind
+	for _, parent := range parents {
+		require.NoError(t, parent.Child().Signal(boot))
+	}
+	for i := 0; i < 100 && machines.CountIn(running) < len(parents); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(t, len(parents), machines.CountIn(running))
+}
+
+func TestSubmachineRestore(t *testing.T) {
+
+	const (
+		requested Index = iota
+		ready
+	)
+
+	const (
+		boot Signal = iota
+	)
+
+	provision, err := define(
+		State{
+			Index: requested,
+			Transitions: map[Signal]Index{
+				boot: ready,
+			},
+		},
+		State{
+			Index: ready,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, provision.Run(NewClock(), DefaultOptions()))
+	defer provision.Done()
+
+	const (
+		provisioning Index = iota
+		running
+	)
+
+	const (
+		provisioned Signal = iota
+	)
+
+	states := []State{
+		{
+			Index: provisioning,
+			Transitions: map[Signal]Index{
+				provisioned: running,
+			},
+			Sub: &Submachine{Machines: provision, Initial: requested, Done: provisioned},
+		},
+		{
+			Index: running,
+		},
+	}
+	machines, err := define(states[0], states[1:]...)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	eventually := func(cond func() bool) {
+		for i := 0; i < 100 && !cond(); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		require.True(t, cond())
+	}
+
+	a, err := machines.New(provisioning)
+	require.NoError(t, err)
+	eventually(func() bool { return a.Child() != nil })
+	snapshot, err := machines.Snapshot()
+	require.NoError(t, err)
+
+	// the restored instance gets a child of its own
+	restored, err := define(states[0], states[1:]...)
+	require.NoError(t, err)
+	require.NoError(t, restored.Run(NewClock(), DefaultOptions()))
+	defer restored.Done()
+	require.NoError(t, restored.Restore(snapshot))
+
+	// the handles of ForEach are only read for their children, which are safe to read
+	only := func(m Machines) (f FSM) {
+		m.ForEach(func(each FSM) bool {
+			f = each
+			return false
+		})
+		return f
+	}
+	eventually(func() bool { return only(restored).Child() != nil })
+	require.NotEqual(t, a.Child().ID(), only(restored).Child().ID())
+	require.Equal(t, 2, provision.Count())
+
+	require.NoError(t, only(restored).Child().Signal(boot))
+	eventually(func() bool { return restored.CountIn(running) == 1 })
+	require.Equal(t, provisioning, a.State())
+
+	// the child of a handed off instance is freed, and the instance gets a new one where it's moved
+	target, err := define(states[0], states[1:]...)
+	require.NoError(t, err)
+	require.NoError(t, target.Run(NewClock(), DefaultOptions()))
+	defer target.Done()
+
+	child := a.Child()
+	moved, err := machines.Handoff(target, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, moved)
+	eventually(func() bool { return only(target).Child() != nil })
+	require.NotEqual(t, child.ID(), only(target).Child().ID())
+	eventually(func() bool { return provision.Count() == 1 })
+
+	require.NoError(t, only(target).Child().Signal(boot))
+	eventually(func() bool { return target.CountIn(running) == 1 })
+}
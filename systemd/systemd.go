@@ -0,0 +1,116 @@
+// Package systemd integrates a Machines-based supervisor with the systemd notify and watchdog protocol.
+package systemd // import "github.com/orkestr8/fsm/systemd"
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/orkestr8/fsm"
+)
+
+const (
+	// Ready tells systemd the service has started up
+	Ready = "READY=1"
+
+	// Watchdog tells systemd the service is alive
+	Watchdog = "WATCHDOG=1"
+
+	// Stopping tells systemd the service is shutting down
+	Stopping = "STOPPING=1"
+
+	defaultInterval = 1 * time.Second
+)
+
+// Notify sends the state to the socket in $NOTIFY_SOCKET.  Returns false if the socket is not set,
+// which is the case when the process is not run by systemd.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:] // abstract namespace
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the watchdog timeout configured for this process by systemd, or 0 if
+// the watchdog is not enabled.
+func WatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Notifier is a fsm.Source that notifies systemd once the machines are ready, and then pings the watchdog
+// for as long as they stay ready.  If the machines become unhealthy, the pings stop so that systemd can
+// restart the service when the watchdog times out.
+type Notifier struct {
+
+	// Ready returns true when the aggregate health of the instances is good, e.g. all targets are running.
+	Ready func() bool
+
+	// Interval is how often readiness is checked.  Defaults to half the watchdog timeout, or 1 second
+	// if the watchdog is not enabled.
+	Interval time.Duration
+
+	// OnError is called with errors notifying systemd, if set.
+	OnError func(error)
+}
+
+// Run implements fsm.Source
+func (n *Notifier) Run(ctx context.Context, emit func(fsm.ID, fsm.Signal, ...interface{})) {
+	interval := n.Interval
+	if interval == 0 {
+		interval = WatchdogInterval() / 2
+	}
+	if interval == 0 {
+		interval = defaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ready := false
+	for {
+		if n.Ready == nil || n.Ready() {
+			if !ready {
+				ready = n.notify(Ready)
+			}
+			n.notify(Watchdog)
+		}
+
+		select {
+		case <-ctx.Done():
+			n.notify(Stopping)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (n *Notifier) notify(state string) bool {
+	sent, err := Notify(state)
+	if err != nil && n.OnError != nil {
+		n.OnError(err)
+	}
+	return sent
+}
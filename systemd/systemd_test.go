@@ -0,0 +1,77 @@
+package systemd // import "github.com/orkestr8/fsm/systemd"
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/orkestr8/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchdogInterval(t *testing.T) {
+	defer os.Unsetenv("WATCHDOG_USEC")
+	defer os.Unsetenv("WATCHDOG_PID")
+
+	os.Unsetenv("WATCHDOG_USEC")
+	require.Equal(t, time.Duration(0), WatchdogInterval())
+
+	os.Setenv("WATCHDOG_USEC", "3000000")
+	require.Equal(t, 3*time.Second, WatchdogInterval())
+
+	os.Setenv("WATCHDOG_PID", "1")
+	require.Equal(t, time.Duration(0), WatchdogInterval())
+}
+
+func TestNotifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fsm-systemd")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	sent, err := Notify(Ready)
+	require.NoError(t, err)
+	require.False(t, sent)
+
+	path := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", path)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	read := func() string {
+		buff := make([]byte, 64)
+		n, err := conn.Read(buff)
+		require.NoError(t, err)
+		return string(buff[:n])
+	}
+
+	ready := int32(0)
+	notifier := &Notifier{
+		Ready:    func() bool { return atomic.LoadInt32(&ready) == 1 },
+		Interval: 10 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		notifier.Run(ctx, func(fsm.ID, fsm.Signal, ...interface{}) {})
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	atomic.StoreInt32(&ready, 1)
+
+	require.Equal(t, Ready, read())
+	require.Equal(t, Watchdog, read())
+	require.Equal(t, Watchdog, read())
+
+	cancel()
+	<-done
+}
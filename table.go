@@ -0,0 +1,154 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// writeTable writes the transition table of the spec as an aligned markdown table, with a row for each
+// state and a column for each signal.  A cell is the next state, followed by the action, the candidates of
+// the choice and the state on action error, if any.  The last columns are the TTL and visit limit of the
+// state, and the metadata if any state has it.  The descriptions of the transitions follow the table as a list of notes.
+func (s *spec) writeTable(w io.Writer) error {
+	indexes := []Index{}
+	for index := range s.states {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	signals := []Signal{}
+	for signal := range s.signals {
+		signals = append(signals, signal)
+	}
+	sort.Slice(signals, func(i, j int) bool { return signals[i] < signals[j] })
+
+	header := []string{"state"}
+	for _, signal := range signals {
+		header = append(header, s.signalName(signal))
+	}
+	header = append(header, "ttl", "visit")
+
+	withMeta := false
+	for _, st := range s.states {
+		withMeta = withMeta || len(st.Meta) > 0
+	}
+	if withMeta {
+		header = append(header, "meta")
+	}
+
+	rows := [][]string{header}
+	for _, index := range indexes {
+		st := s.states[index]
+		row := []string{s.stateName(index)}
+		for _, signal := range signals {
+			cell := ""
+			if next, has := st.Transitions[signal]; has {
+				cell = s.stateName(next)
+				if action, has := st.ContextActions[signal]; has {
+					cell += fmt.Sprintf(" (%v)", actionName(action))
+				} else if action, has := st.Actions[signal]; has {
+					cell += fmt.Sprintf(" (%v)", actionName(action))
+				} else if name, has := st.ActionNames[signal]; has {
+					cell += fmt.Sprintf(" (%v)", name)
+				}
+				if choice, has := st.Choices[signal]; has && len(choice.To) > 0 {
+					names := []string{}
+					for _, to := range choice.To {
+						names = append(names, s.stateName(to))
+					}
+					cell += fmt.Sprintf(" or: %v", strings.Join(names, "|"))
+				}
+				if next, has := st.Errors[signal]; has {
+					cell += fmt.Sprintf(" err: %v", s.stateName(next))
+				}
+			}
+			row = append(row, cell)
+		}
+		ttl, visit := "", ""
+		if st.TTL.TTL > 0 {
+			ttl = fmt.Sprintf("%d: %v", st.TTL.TTL, s.signalName(st.TTL.Raise))
+		}
+		if st.Visit.Value > 0 {
+			visit = fmt.Sprintf("%d: %v", st.Visit.Value, s.signalName(st.Visit.Raise))
+		}
+		row = append(row, ttl, visit)
+		if withMeta {
+			keys := []string{}
+			for k := range st.Meta {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			meta := []string{}
+			for _, k := range keys {
+				meta = append(meta, k+"="+st.Meta[k])
+			}
+			row = append(row, strings.Join(meta, " "))
+		}
+		rows = append(rows, row)
+	}
+
+	widths := make([]int, len(header))
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+
+	line := func(cells []string) error {
+		padded := make([]string, len(cells))
+		for i, cell := range cells {
+			padded[i] = cell + strings.Repeat(" ", widths[i]-len(cell))
+		}
+		_, err := fmt.Fprintf(w, "| %s |\n", strings.Join(padded, " | "))
+		return err
+	}
+
+	if err := line(rows[0]); err != nil {
+		return err
+	}
+	rule := make([]string, len(widths))
+	for i, width := range widths {
+		rule[i] = strings.Repeat("-", width)
+	}
+	if err := line(rule); err != nil {
+		return err
+	}
+	for _, row := range rows[1:] {
+		if err := line(row); err != nil {
+			return err
+		}
+	}
+
+	notes := []string{}
+	for _, index := range indexes {
+		st := s.states[index]
+		for _, signal := range signals {
+			if description, has := st.Descriptions[signal]; has {
+				notes = append(notes, fmt.Sprintf("- %v -[%v]-> %v: %v", s.stateName(index), s.signalName(signal),
+					s.stateName(st.Transitions[signal]), description))
+			}
+		}
+	}
+	if len(notes) > 0 {
+		if _, err := fmt.Fprintf(w, "\n%s\n", strings.Join(notes, "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// actionName returns the name of the function of the action, or context action, without the package path.
+func actionName(action interface{}) string {
+	f := runtime.FuncForPC(reflect.ValueOf(action).Pointer())
+	if f == nil {
+		return "action"
+	}
+	return path.Base(f.Name())
+}
@@ -0,0 +1,180 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func provision(FSM) error {
+	return nil
+}
+
+func TestWriteTable(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+		failed
+	)
+
+	const (
+		start Signal = iota
+		fail
+		retry
+	)
+
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			Actions: map[Signal]Action{
+				start: provision,
+			},
+			Errors: map[Signal]Index{
+				start: failed,
+			},
+			TTL: Expiry{5, start},
+		},
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				fail: failed,
+			},
+			Choices: map[Signal]Choice{
+				fail: {To: []Index{pending}, Select: func(FSM) Index { return failed }},
+			},
+		},
+		State{
+			Index: failed,
+			Transitions: map[Signal]Index{
+				retry: pending,
+			},
+			Visit: Limit{3, retry},
+		},
+	)
+	require.NoError(t, err)
+	machines.spec.stateNames = map[Index]string{pending: "pending", running: "running", failed: "failed"}
+	machines.spec.signalNames = map[Signal]string{start: "start", fail: "fail", retry: "retry"}
+
+	buff := &bytes.Buffer{}
+	require.NoError(t, machines.WriteTable(buff))
+	require.Equal(t, ""+
+		"| state   | start                               | fail               | retry   | ttl      | visit    |\n"+
+		"| ------- | ----------------------------------- | ------------------ | ------- | -------- | -------- |\n"+
+		"| pending | running (fsm.provision) err: failed |                    |         | 5: start |          |\n"+
+		"| running |                                     | failed or: pending |         |          |          |\n"+
+		"| failed  |                                     |                    | pending |          | 3: retry |\n",
+		buff.String())
+}
+
+func TestMeta(t *testing.T) {
+
+	const (
+		running Index = iota
+		failed
+	)
+
+	const (
+		fail Signal = iota
+	)
+
+	meta := map[string]string{"severity": "critical", "runbook": "http://wiki/failed"}
+	machines, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				fail: failed,
+			},
+		},
+		State{
+			Index: failed,
+			Meta:  meta,
+		},
+	)
+	require.NoError(t, err)
+
+	meta["severity"] = "low" // no effect after define
+	require.Equal(t, map[string]string{"severity": "critical", "runbook": "http://wiki/failed"}, machines.Meta(failed))
+	require.Nil(t, machines.Meta(running))
+
+	machines.Meta(failed)["owner"] = "ops" // returns a copy
+	require.Len(t, machines.Meta(failed), 2)
+
+	buff := &bytes.Buffer{}
+	require.NoError(t, machines.WriteTable(buff))
+	require.Equal(t, ""+
+		"| state | 0 | ttl | visit | meta                                         |\n"+
+		"| ----- | - | --- | ----- | -------------------------------------------- |\n"+
+		"| 0     | 1 |     |       |                                              |\n"+
+		"| 1     |   |     |       | runbook=http://wiki/failed severity=critical |\n",
+		buff.String())
+}
+
+func TestDescriptions(t *testing.T) {
+
+	const (
+		running Index = iota
+		failed
+	)
+
+	const (
+		fail Signal = iota
+		retry
+	)
+
+	_, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				fail: failed,
+			},
+			Descriptions: map[Signal]string{
+				retry: "not a transition",
+			},
+		},
+		State{
+			Index: failed,
+		},
+	)
+	require.Error(t, err)
+
+	machines, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				fail: failed,
+			},
+			Descriptions: map[Signal]string{
+				fail: "health check fails 3 times; a few per day",
+			},
+		},
+		State{
+			Index: failed,
+			Transitions: map[Signal]Index{
+				retry: running,
+			},
+			Descriptions: map[Signal]string{
+				retry: "operator retries",
+			},
+		},
+	)
+	require.NoError(t, err)
+	machines.spec.stateNames = map[Index]string{running: "running", failed: "failed"}
+	machines.spec.signalNames = map[Signal]string{fail: "fail", retry: "retry"}
+
+	buff := &bytes.Buffer{}
+	require.NoError(t, machines.WriteTable(buff))
+	require.Equal(t, ""+
+		"| state   | fail   | retry   | ttl | visit |\n"+
+		"| ------- | ------ | ------- | --- | ----- |\n"+
+		"| running | failed |         |     |       |\n"+
+		"| failed  |        | running |     |       |\n"+
+		"\n"+
+		"- running -[fail]-> failed: health check fails 3 times; a few per day\n"+
+		"- failed -[retry]-> running: operator retries\n",
+		buff.String())
+}
@@ -0,0 +1,140 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"fmt"
+)
+
+// ParamField is a field of a state that can be set by a parameter of a template
+type ParamField int
+
+const (
+	// ParamTTL is State.TTL.TTL
+	ParamTTL ParamField = iota
+
+	// ParamVisitLimit is State.Visit.Value
+	ParamVisitLimit
+
+	// ParamWatchdogTTL is State.Watchdog.TTL
+	ParamWatchdogTTL
+
+	// ParamBackoffMax is State.Backoff.Max
+	ParamBackoffMax
+)
+
+func (f ParamField) String() string {
+	switch f {
+	case ParamTTL:
+		return "ttl"
+	case ParamVisitLimit:
+		return "visit-limit"
+	case ParamWatchdogTTL:
+		return "watchdog-ttl"
+	case ParamBackoffMax:
+		return "backoff-max"
+	}
+	return fmt.Sprintf("field-%d", int(f))
+}
+
+// Param is a named parameter of a template that sets a field of a state.  Several params may share a
+// name to set the same value in more than one place.
+type Param struct {
+	Name  string
+	State Index
+	Field ParamField
+
+	// Default is the value if not given.  The parameter is required if 0.
+	Default int64
+
+	// Min and Max bound the value, if not 0
+	Min int64
+	Max int64
+}
+
+// Params are the values of the parameters of a template, by name
+type Params map[string]int64
+
+// Template is a spec with named parameters, e.g. for TTLs and limits, so that more than one set of
+// machines can be defined from it with different values, like prod and canary timeouts.
+type Template struct {
+	States []State
+	Params []Param
+}
+
+// ErrParam is returned when defining machines from a template with an invalid parameter
+type ErrParam struct {
+	Name   string
+	Reason string
+}
+
+func (e ErrParam) Error() string {
+	return fmt.Sprintf("parameter %v: %v", e.Name, e.Reason)
+}
+
+func (e ErrParam) Is(target error) bool {
+	return target == SpecError
+}
+
+// Define validates the parameters and defines the machines from the template with their values
+func (t Template) Define(params Params) (Machines, error) {
+	states, err := t.Instantiate(params)
+	if err != nil {
+		return nil, err
+	}
+	if len(states) == 0 {
+		return nil, ErrParam{Reason: "template has no states"}
+	}
+	return Define(states[0], states[1:]...)
+}
+
+// Instantiate validates the parameters and returns a copy of the states of the template with their values
+func (t Template) Instantiate(params Params) ([]State, error) {
+	known := map[string]bool{}
+	for _, p := range t.Params {
+		known[p.Name] = true
+	}
+	for name := range params {
+		if !known[name] {
+			return nil, ErrParam{Name: name, Reason: "not in the template"}
+		}
+	}
+
+	states := append([]State{}, t.States...)
+	positions := map[Index]int{}
+	for i, s := range states {
+		positions[s.Index] = i
+	}
+
+	for _, p := range t.Params {
+		value, has := params[p.Name]
+		if !has {
+			if p.Default == 0 {
+				return nil, ErrParam{Name: p.Name, Reason: "required"}
+			}
+			value = p.Default
+		}
+		if (p.Min != 0 && value < p.Min) || (p.Max != 0 && value > p.Max) {
+			return nil, ErrParam{Name: p.Name, Reason: fmt.Sprintf("%v out of range [%v, %v]", value, p.Min, p.Max)}
+		}
+		if value <= 0 {
+			return nil, ErrParam{Name: p.Name, Reason: fmt.Sprintf("%v must be positive", value)}
+		}
+		position, has := positions[p.State]
+		if !has {
+			return nil, ErrParam{Name: p.Name, Reason: fmt.Sprintf("unknown state %v", p.State)}
+		}
+		state := &states[position]
+		switch p.Field {
+		case ParamTTL:
+			state.TTL.TTL = Tick(value)
+		case ParamVisitLimit:
+			state.Visit.Value = int(value)
+		case ParamWatchdogTTL:
+			state.Watchdog.TTL = Tick(value)
+		case ParamBackoffMax:
+			state.Backoff.Max = Tick(value)
+		default:
+			return nil, ErrParam{Name: p.Name, Reason: fmt.Sprintf("unknown field %v", p.Field)}
+		}
+	}
+	return states, nil
+}
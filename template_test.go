@@ -0,0 +1,78 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate(t *testing.T) {
+
+	const (
+		provisioning Index = iota
+		running
+		failed
+	)
+
+	const (
+		ready Signal = iota
+		timeout
+		retry
+	)
+
+	template := Template{
+		States: []State{
+			{
+				Index: provisioning,
+				Transitions: map[Signal]Index{
+					ready:   running,
+					timeout: failed,
+				},
+				TTL: Expiry{Raise: timeout},
+			},
+			{
+				Index: running,
+			},
+			{
+				Index: failed,
+				Transitions: map[Signal]Index{
+					retry: provisioning,
+				},
+				Visit: Limit{Raise: retry},
+			},
+		},
+		Params: []Param{
+			{Name: "timeout", State: provisioning, Field: ParamTTL, Min: 1, Max: 100},
+			{Name: "retries", State: failed, Field: ParamVisitLimit, Default: 3},
+		},
+	}
+
+	prod, err := template.Instantiate(Params{"timeout": 30})
+	require.NoError(t, err)
+	require.Equal(t, Expiry{30, timeout}, prod[0].TTL)
+	require.Equal(t, Limit{3, retry}, prod[2].Visit)
+
+	canary, err := template.Instantiate(Params{"timeout": 5, "retries": 1})
+	require.NoError(t, err)
+	require.Equal(t, Expiry{5, timeout}, canary[0].TTL)
+	require.Equal(t, Limit{1, retry}, canary[2].Visit)
+
+	// the template is not changed
+	require.Equal(t, Expiry{0, timeout}, template.States[0].TTL)
+
+	machines, err := template.Define(Params{"timeout": 2})
+	require.NoError(t, err)
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, DefaultOptions()))
+	defer machines.Done()
+	a, err := machines.New(provisioning)
+	require.NoError(t, err)
+	require.Equal(t, []DeadlineInfo{{ID: a.ID(), State: provisioning, Due: 2, Raise: timeout}}, machines.PendingDeadlines())
+
+	_, err = template.Define(Params{})
+	require.Equal(t, ErrParam{Name: "timeout", Reason: "required"}, err)
+	_, err = template.Define(Params{"timeout": 500})
+	require.Equal(t, ErrParam{Name: "timeout", Reason: "500 out of range [1, 100]"}, err)
+	_, err = template.Define(Params{"timeout": 5, "ttl": 5})
+	require.Equal(t, ErrParam{Name: "ttl", Reason: "not in the template"}, err)
+}
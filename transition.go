@@ -0,0 +1,137 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"time"
+)
+
+// Transition is the record of a committed transition of an instance.  It is the schema shared by
+// all the surfaces that observe transitions.
+type Transition struct {
+	ID       ID              `json:"id"`
+	From     Index           `json:"from"`
+	To       Index           `json:"to"`
+	Signal   Signal          `json:"signal"`
+	Names    TransitionNames `json:"names"`
+	Tick     Time            `json:"tick"`
+	WallTime time.Time       `json:"wallTime"`
+	Err      string          `json:"err,omitempty"`
+
+	// Visits is the number of times the instance has visited the To state, including this transition, as
+	// counted for State.Visit.  The initial state of an instance counts as two visits.
+	Visits int `json:"visits"`
+
+	// Flaps is the current count of flaps between the From and To states, if a flap limit is set for them
+	Flaps int `json:"flaps,omitempty"`
+
+	// Origin is where the signal of the transition comes from
+	Origin Origin `json:"origin,omitempty"`
+
+	// Fields are the key value pairs of Options.Fields for the instance
+	Fields []interface{} `json:"fields,omitempty"`
+
+	// Labels are the labels of the instance, if it was seeded with any
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Skipped is the action that would have run, in dry run
+	Skipped *SkippedAction `json:"skipped,omitempty"`
+
+	// Internal is true for an internal transition, which doesn't re-enter the state, so Options.Notify is not
+	// notified of it
+	Internal bool `json:"internal,omitempty"`
+
+	// CorrelationID is the external request that caused the transition, if any
+	CorrelationID CorrelationID `json:"correlationId,omitempty"`
+}
+
+// TransitionNames are the friendly names of the states and signal of a transition
+type TransitionNames struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Signal string `json:"signal"`
+}
+
+// transition returns the record of a transition.  err is the error of the action, if any.
+func (g *runner) transition(instance *instance, from, to Index, signal Signal, err error) Transition {
+	t := Transition{
+		ID:     instance.id,
+		From:   from,
+		To:     to,
+		Signal: signal,
+		Names: TransitionNames{
+			From:   g.spec().stateName(from),
+			To:     g.spec().stateName(to),
+			Signal: g.spec().signalName(signal),
+		},
+		Tick:     g.ct(),
+		WallTime: instance.changed,
+		Visits:   instance.visits[to],
+		Origin:   instance.origin,
+		Labels:   copyMeta(instance.labels),
+	}
+	if g.spec().flap(from, to) != nil {
+		t.Flaps = instance.flaps.count(from, to)
+	}
+	if err != nil {
+		t.Err = err.Error()
+	}
+	if g.options.Fields != nil {
+		t.Fields = g.options.Fields(snapshot{instance})
+	}
+	return t
+}
+
+// committed publishes the record of a committed transition.
+func (g *runner) committed(transition Transition) {
+	g.transitions[transition.Signal]++
+	g.observeRate(transition)
+	g.logTransition(transition)
+
+	for _, watcher := range g.watchers {
+		select {
+		case watcher <- transition: // non-blocking send
+		default:
+		}
+	}
+	if len(g.terminals) > 0 && g.spec().terminal(transition.To) {
+		for _, hook := range g.terminals {
+			hook(transition) // never dropped, unlike the watchers
+		}
+	}
+
+	if notifier, has := g.options.Notify[transition.To]; has && !transition.Internal { // the state is not entered
+		go func() {
+			if err := notifier.Notify(transition); err != nil {
+				g.handleError(g.tid(), err, transition)
+			}
+		}()
+	}
+}
+
+// watch registers a channel to receive transitions.  This must be called from within the transaction loop.
+func (g *runner) watch(buffer int) (<-chan Transition, int) {
+	ch := make(chan Transition, buffer)
+	g.watcher++
+	g.watchers[g.watcher] = ch
+	return ch, g.watcher
+}
+
+// watchTerminal registers the hook to call with the transitions to terminal states.  The hook is called in the
+// transaction loop and must not block.  This must be called from within the transaction loop.
+func (g *runner) watchTerminal(hook func(Transition)) int {
+	if g.terminals == nil {
+		g.terminals = map[int]func(Transition){}
+	}
+	g.watcher++
+	g.terminals[g.watcher] = hook
+	return g.watcher
+}
+
+// unwatch removes and closes the watch channel, or removes the hook.  This must be called from within the
+// transaction loop.
+func (g *runner) unwatch(id int) {
+	delete(g.terminals, id)
+	if ch, has := g.watchers[id]; has {
+		delete(g.watchers, id)
+		close(ch)
+	}
+}
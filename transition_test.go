@@ -0,0 +1,247 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+
+	const (
+		down Index = iota
+		up
+		failed
+	)
+
+	const (
+		start Signal = iota
+		stop
+	)
+
+	machines, err := define(
+		State{
+			Index: down,
+			Transitions: map[Signal]Index{
+				start: up,
+			},
+			Actions: map[Signal]Action{
+				start: func(FSM) error {
+					return fmt.Errorf("boom")
+				},
+			},
+			Errors: map[Signal]Index{
+				start: failed,
+			},
+		},
+		State{
+			Index: up,
+			Transitions: map[Signal]Index{
+				stop: down,
+			},
+		},
+		State{
+			Index: failed,
+		},
+	)
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	options.StateNames = map[Index]string{down: "DOWN", up: "UP", failed: "FAILED"}
+	options.SignalNames = map[Signal]string{start: "start", stop: "stop"}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	transitions, cancel := machines.Watch(10)
+
+	instance, err := machines.New(down)
+	require.NoError(t, err)
+	require.NoError(t, instance.Signal(start))
+
+	transition := <-transitions
+	require.Equal(t, instance.ID(), transition.ID)
+	require.Equal(t, down, transition.From)
+	require.Equal(t, failed, transition.To)
+	require.Equal(t, start, transition.Signal)
+	require.Equal(t, TransitionNames{From: "DOWN", To: "FAILED", Signal: "start"}, transition.Names)
+	require.Equal(t, "boom", transition.Err)
+	require.False(t, transition.WallTime.IsZero())
+
+	buff, err := json.Marshal(transition)
+	require.NoError(t, err)
+	decoded := Transition{}
+	require.NoError(t, json.Unmarshal(buff, &decoded))
+	require.Equal(t, transition.Names, decoded.Names)
+	require.Contains(t, string(buff), `"err":"boom"`)
+
+	cancel()
+	_, open := <-transitions
+	require.False(t, open)
+}
+
+type notifierFunc func(Transition) error
+
+func (f notifierFunc) Notify(t Transition) error {
+	return f(t)
+}
+
+func TestNotify(t *testing.T) {
+
+	const (
+		running Index = iota
+		cordoned
+	)
+
+	const (
+		cordon Signal = iota
+	)
+
+	machines, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				cordon: cordoned,
+			},
+		},
+		State{
+			Index: cordoned,
+		},
+	)
+	require.NoError(t, err)
+
+	notified := make(chan Transition, 1)
+	options := DefaultOptions()
+	options.Notify = map[Index]Notifier{
+		cordoned: notifierFunc(func(t Transition) error {
+			notified <- t
+			return fmt.Errorf("unreachable")
+		}),
+	}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- <-machines.Errors()
+	}()
+
+	seeded, err := machines.Seed([]SeedItem{{State: running, Labels: map[string]string{"zone": "a"}}})
+	require.NoError(t, err)
+	require.NoError(t, seeded[0].Signal(cordon))
+
+	transition := <-notified
+	require.Equal(t, cordoned, transition.To)
+	require.Equal(t, map[string]string{"zone": "a"}, transition.Labels)
+	require.Equal(t, "unreachable", (<-errs).Error())
+}
+
+func TestTransitionCounters(t *testing.T) {
+
+	const (
+		running Index = iota
+		down
+	)
+
+	const (
+		timeout Signal = iota
+		ping
+	)
+
+	machines, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				timeout: down,
+			},
+		},
+		State{
+			Index: down,
+			Transitions: map[Signal]Index{
+				ping: running,
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	options.Limits = []Flap{
+		{States: [2]Index{running, down}, Count: 100},
+	}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	transitions, cancel := machines.Watch(10)
+	defer cancel()
+
+	instance, err := machines.New(running)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, instance.Signal(timeout))
+		require.NoError(t, instance.Signal(ping))
+	}
+
+	visits := []int{}
+	flaps := []int{}
+	for i := 0; i < 4; i++ {
+		transition := <-transitions
+		visits = append(visits, transition.Visits)
+		flaps = append(flaps, transition.Flaps)
+	}
+	require.Equal(t, []int{1, 3, 2, 4}, visits) // running counts twice on allocation
+	require.Equal(t, []int{0, 1, 1, 2}, flaps)
+}
+
+func TestOrigin(t *testing.T) {
+
+	const (
+		running Index = iota
+		cordoned
+	)
+
+	const (
+		cordon Signal = iota
+		uncordon
+	)
+
+	machines, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				cordon: cordoned,
+			},
+		},
+		State{
+			Index: cordoned,
+			Transitions: map[Signal]Index{
+				uncordon: running,
+			},
+			TTL: Expiry{5, uncordon},
+		},
+	)
+	require.NoError(t, err)
+
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, DefaultOptions()))
+	defer machines.Done()
+
+	transitions, cancel := machines.Watch(10)
+	defer cancel()
+
+	instance, err := machines.New(running)
+	require.NoError(t, err)
+	require.Equal(t, Origin(""), instance.Origin())
+
+	require.NoError(t, instance.SignalFrom("admin-api", cordon))
+	require.Equal(t, Origin("admin-api"), (<-transitions).Origin)
+	require.Equal(t, Origin("admin-api"), instance.Origin())
+
+	clock.Ticks(5)
+	require.Equal(t, OriginTTL, (<-transitions).Origin)
+	require.Equal(t, OriginTTL, instance.Origin())
+
+	require.NoError(t, instance.Signal(cordon))
+	require.Equal(t, OriginAPI, (<-transitions).Origin)
+}
@@ -0,0 +1,32 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// prepare runs the prepare step of the transition on the signal, if any, with the data of the event
+// attached.  If it fails, the transition is vetoed and the data of the instance is restored.
+func (g *runner) prepare(instance *instance, current Index, event *event) error {
+	prepare, has := g.spec().states[current].Prepare[event.signal]
+	if !has || g.options.DryRun || g.spec().errorsFirst(current, event.signal) {
+		return nil
+	}
+
+	data := instance.data
+	if event.data != nil {
+		instance.data = event.data
+	}
+	if err := g.invoke(instance, current, event.signal, prepare); err != nil {
+		instance.data = data
+		return ErrVetoed{spec: g.spec(), ID: instance.id, State: current, Signal: event.signal, Err: err}
+	}
+	return nil
+}
+
+// commit runs the commit step of the transition on the signal, if any, after the state is updated.
+func (g *runner) commit(tid int64, instance *instance, current Index, signal Signal) {
+	commit, has := g.spec().states[current].Commit[signal]
+	if !has || g.options.DryRun || g.spec().errorsFirst(current, signal) {
+		return
+	}
+	if err := g.invoke(instance, current, signal, commit); err != nil {
+		g.handleError(tid, ErrCommit{spec: g.spec(), ID: instance.id, State: current, Signal: signal, Err: err},
+			[]interface{}{current, signal, instance})
+	}
+}
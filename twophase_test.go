@@ -0,0 +1,207 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepareCommit(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	steps := []string{}
+	committed := []Index{}
+
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			Prepare: map[Signal]Action{
+				start: func(f FSM) error {
+					steps = append(steps, "prepare")
+					if data, is := f.Data().([]interface{}); is && data[0] == "bad" {
+						return fmt.Errorf("bad data")
+					}
+					return nil
+				},
+			},
+			Actions: map[Signal]Action{
+				start: func(FSM) error {
+					steps = append(steps, "action")
+					return nil
+				},
+			},
+			Commit: map[Signal]Action{
+				start: func(f FSM) error {
+					steps = append(steps, "commit")
+					committed = append(committed, f.(*instance).state)
+					return nil
+				},
+			},
+		},
+		State{
+			Index: running,
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	errs := machines.Errors()
+
+	instance, err := machines.New(pending)
+	require.NoError(t, err)
+
+	require.NoError(t, instance.Signal(start, "bad"))
+	err = <-errs
+	require.IsType(t, ErrVetoed{}, err)
+	require.Equal(t, pending, instance.State())
+	require.Nil(t, instance.Data())
+	require.Equal(t, []string{"prepare"}, steps)
+
+	require.NoError(t, instance.Signal(start, "good"))
+	require.Equal(t, running, instance.State())
+	require.Equal(t, []interface{}{"good"}, instance.Data())
+	require.Equal(t, []string{"prepare", "prepare", "action", "commit"}, steps)
+	require.Equal(t, []Index{running}, committed)
+}
+
+func TestRollbackOnError(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+	)
+
+	const (
+		start Signal = iota
+	)
+
+	fail := true
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			Actions: map[Signal]Action{
+				start: func(FSM) error {
+					if fail {
+						return fmt.Errorf("provision failed")
+					}
+					return nil
+				},
+			},
+			TTL:             Expiry{5, start},
+			RollbackOnError: true,
+		},
+		State{
+			Index: running,
+		},
+	)
+	require.NoError(t, err)
+
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, DefaultOptions()))
+	defer machines.Done()
+
+	errs := machines.Errors()
+
+	instance, err := machines.New(pending)
+	require.NoError(t, err)
+
+	clock.Ticks(2)
+	require.NoError(t, instance.Signal(start, "data"))
+	err = <-errs
+	require.IsType(t, ErrRolledBack{}, err)
+	require.Equal(t, pending, instance.State())
+	require.Nil(t, instance.Data())
+	require.Equal(t, []DeadlineInfo{
+		{ID: instance.ID(), State: pending, Due: 5, Raise: start},
+	}, machines.PendingDeadlines())
+
+	fail = false
+	require.NoError(t, instance.Signal(start))
+	require.Equal(t, running, instance.State())
+}
+
+func TestPrepareFlapping(t *testing.T) {
+
+	const (
+		up Index = iota
+		down
+		cordoned
+	)
+
+	const (
+		fail Signal = iota
+		recover
+		cordon
+	)
+
+	prepared, acted := 0, 0
+	prepare := func(FSM) error {
+		prepared++
+		return nil
+	}
+	act := func(FSM) error {
+		acted++
+		return nil
+	}
+	machines, err := define(
+		State{
+			Index: up,
+			Transitions: map[Signal]Index{
+				fail: down,
+			},
+			Prepare: map[Signal]Action{fail: prepare},
+			Actions: map[Signal]Action{fail: act},
+		},
+		State{
+			Index: down,
+			Transitions: map[Signal]Index{
+				recover: up,
+				cordon:  cordoned,
+			},
+			Prepare: map[Signal]Action{recover: prepare},
+			Actions: map[Signal]Action{recover: act},
+		},
+		State{
+			Index: cordoned,
+		},
+	)
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	options.Limits = []Flap{{States: [2]Index{up, down}, Count: 2, Raise: cordon}}
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	instance, err := machines.New(up)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		signal := fail
+		if instance.State() == down {
+			signal = recover
+		}
+		if err := instance.Sequence(signal); err != nil {
+			require.IsType(t, ErrAbsorbed{}, err.(ErrSequence).Err) // flapping raises cordon instead
+			break
+		}
+	}
+	require.Equal(t, 1, machines.CountIn(cordoned))
+
+	// the transition that flapped is not prepared
+	require.Equal(t, acted, prepared)
+}
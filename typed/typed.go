@@ -0,0 +1,308 @@
+// Package typed defines fsm specs with states and signals as typed string constants, instead of iota ints.  The
+// states and signals are numbered, and named in the options, by the package.  The package uses generics, so the
+// module requires Go 1.18 or later.
+//
+// The numbers are what snapshots, WAL records and the like persist.  The states are numbered in the order they
+// are given, so new states must be added at the end.  Define numbers the signals in alphabetical order, so adding
+// or renaming a signal renumbers the others and breaks persisted data.  DefineNumbered numbers them in the
+// order given instead, which is stable as long as new signals are added at the end and none are removed.
+package typed // import "github.com/orkestr8/fsm/typed"
+
+import (
+	"sort"
+
+	"github.com/orkestr8/fsm"
+)
+
+// Expiry is the TTL of a state and the signal raised when it expires
+type Expiry[G ~string] struct {
+	TTL   fsm.Tick
+	Raise G
+}
+
+// Limit is the limit of visits of a state and the signal raised when it's reached
+type Limit[G ~string] struct {
+	Value int
+	Raise G
+}
+
+// State is a state of the spec, with the states and signals given by their names.  See fsm.State.
+type State[S ~string, G ~string] struct {
+	// Name is the name of the state.  The states are numbered in the order they are given to Define.
+	Name S
+
+	// Transitions are the next states for each signal
+	Transitions map[G]S
+
+	// Actions are the actions of the transitions
+	Actions map[G]fsm.Action
+
+	// Errors are the next states for each signal when the action fails
+	Errors map[G]S
+
+	// ErrorsAction are the actions run when the instance follows Errors
+	ErrorsAction map[G]fsm.Action
+
+	// ErrorsFirst has the signals in Errors follow Errors without running the action of the transition
+	ErrorsFirst bool
+
+	// TTL is the time the instance can stay in the state, and the signal raised when it expires
+	TTL Expiry[G]
+
+	// Rearm are signals that restart the TTL without transitioning
+	Rearm []G
+
+	// Visit is the limit of visits of the state, and the signal raised when it's reached
+	Visit Limit[G]
+
+	// Terminal marks the state as an intended end state
+	Terminal bool
+
+	// Meta is metadata of the state
+	Meta map[string]string
+}
+
+// Machines are the machines of a spec with typed states and signals
+type Machines[S ~string, G ~string] struct {
+	fsm.Machines
+
+	states  map[S]fsm.Index
+	signals map[G]fsm.Signal
+	names   map[fsm.Index]S
+	signame map[fsm.Signal]G
+}
+
+// Define numbers the states, in the order given, and the signals, in alphabetical order, and defines the spec.
+// Adding or renaming a signal renumbers the signals after it, so use DefineNumbered if the numbers are persisted.
+func Define[S ~string, G ~string](state State[S, G], more ...State[S, G]) (*Machines[S, G], error) {
+	return define(nil, append([]State[S, G]{state}, more...))
+}
+
+// DefineNumbered is Define with the signals numbered in the order given, e.g. to add signals at the end without
+// renumbering those persisted.  Every signal of the states must be in the list.
+func DefineNumbered[S ~string, G ~string](signals []G, state State[S, G], more ...State[S, G]) (*Machines[S, G], error) {
+	if signals == nil {
+		signals = []G{}
+	}
+	return define(signals, append([]State[S, G]{state}, more...))
+}
+
+// define numbers the signals in the given order, or in alphabetical order if nil
+func define[S ~string, G ~string](numbered []G, all []State[S, G]) (*Machines[S, G], error) {
+
+	m := &Machines[S, G]{
+		states:  map[S]fsm.Index{},
+		signals: map[G]fsm.Signal{},
+		names:   map[fsm.Index]S{},
+		signame: map[fsm.Signal]G{},
+	}
+	for i, st := range all {
+		if _, has := m.states[st.Name]; has {
+			return nil, fsm.Errorf(fsm.SpecError, "duplicate state %v", st.Name)
+		}
+		m.states[st.Name] = fsm.Index(i)
+		m.names[fsm.Index(i)] = st.Name
+	}
+
+	names := map[G]bool{}
+	for _, st := range all {
+		for signal := range st.Transitions {
+			names[signal] = true
+		}
+		for signal := range st.Errors {
+			names[signal] = true
+		}
+		for signal := range st.Actions {
+			names[signal] = true
+		}
+		for signal := range st.ErrorsAction {
+			names[signal] = true
+		}
+		for _, signal := range st.Rearm {
+			names[signal] = true
+		}
+		if st.TTL.TTL > 0 {
+			names[st.TTL.Raise] = true
+		}
+		if st.Visit.Value > 0 {
+			names[st.Visit.Raise] = true
+		}
+	}
+	sorted := numbered
+	if sorted == nil {
+		for signal := range names {
+			sorted = append(sorted, signal)
+		}
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	}
+	for i, signal := range sorted {
+		if _, has := m.signals[signal]; has {
+			return nil, fsm.Errorf(fsm.SpecError, "duplicate signal %v", signal)
+		}
+		m.signals[signal] = fsm.Signal(i)
+		m.signame[fsm.Signal(i)] = signal
+	}
+	for signal := range names {
+		if _, has := m.signals[signal]; !has {
+			return nil, fsm.Errorf(fsm.SpecError, "signal %v is not numbered", signal)
+		}
+	}
+
+	states := []fsm.State{}
+	for _, st := range all {
+		s := fsm.State{
+			Index:       m.states[st.Name],
+			ErrorsFirst: st.ErrorsFirst,
+			Terminal:    st.Terminal,
+			Meta:        st.Meta,
+		}
+		var err error
+		if s.Transitions, err = m.edges(st.Transitions); err != nil {
+			return nil, err
+		}
+		if s.Errors, err = m.edges(st.Errors); err != nil {
+			return nil, err
+		}
+		s.Actions = m.actions(st.Actions)
+		s.ErrorsAction = m.actions(st.ErrorsAction)
+		for _, signal := range st.Rearm {
+			s.Rearm = append(s.Rearm, m.signals[signal])
+		}
+		if st.TTL.TTL > 0 {
+			s.TTL = fsm.Expiry{TTL: st.TTL.TTL, Raise: m.signals[st.TTL.Raise]}
+		}
+		if st.Visit.Value > 0 {
+			s.Visit = fsm.Limit{Value: st.Visit.Value, Raise: m.signals[st.Visit.Raise]}
+		}
+		states = append(states, s)
+	}
+
+	machines, err := fsm.Define(states[0], states[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	m.Machines = machines
+	return m, nil
+}
+
+func (m *Machines[S, G]) edges(edges map[G]S) (map[fsm.Signal]fsm.Index, error) {
+	if edges == nil {
+		return nil, nil
+	}
+	out := map[fsm.Signal]fsm.Index{}
+	for signal, next := range edges {
+		index, has := m.states[next]
+		if !has {
+			return nil, fsm.Errorf(fsm.SpecError, "unknown state %v", next)
+		}
+		out[m.signals[signal]] = index
+	}
+	return out, nil
+}
+
+func (m *Machines[S, G]) actions(actions map[G]fsm.Action) map[fsm.Signal]fsm.Action {
+	if actions == nil {
+		return nil
+	}
+	out := map[fsm.Signal]fsm.Action{}
+	for signal, action := range actions {
+		out[m.signals[signal]] = action
+	}
+	return out
+}
+
+// Index returns the index of the state, or fsm.NoState if it's not a state of the spec
+func (m *Machines[S, G]) Index(state S) fsm.Index {
+	if index, has := m.states[state]; has {
+		return index
+	}
+	return fsm.NoState
+}
+
+// Signal returns the signal of the name, or fsm.NoSignal if it's not a signal of the spec
+func (m *Machines[S, G]) Signal(signal G) fsm.Signal {
+	if s, has := m.signals[signal]; has {
+		return s
+	}
+	return fsm.NoSignal
+}
+
+// StateOf returns the name of the state of the index
+func (m *Machines[S, G]) StateOf(index fsm.Index) S {
+	return m.names[index]
+}
+
+// SignalOf returns the name of the signal
+func (m *Machines[S, G]) SignalOf(signal fsm.Signal) G {
+	return m.signame[signal]
+}
+
+// Options returns the default options with the names of the states and signals
+func (m *Machines[S, G]) Options() fsm.Options {
+	options := fsm.DefaultOptions()
+	return m.named(options)
+}
+
+func (m *Machines[S, G]) named(options fsm.Options) fsm.Options {
+	if options.StateNames == nil {
+		options.StateNames = map[fsm.Index]string{}
+		for index, name := range m.names {
+			options.StateNames[index] = string(name)
+		}
+	}
+	if options.SignalNames == nil {
+		options.SignalNames = map[fsm.Signal]string{}
+		for signal, name := range m.signame {
+			options.SignalNames[signal] = string(name)
+		}
+	}
+	return options
+}
+
+// Run runs the machines, with the names of the states and signals in the options unless they are given
+func (m *Machines[S, G]) Run(clock *fsm.Clock, options fsm.Options) error {
+	return m.Machines.Run(clock, m.named(options))
+}
+
+// New creates an instance in the initial state
+func (m *Machines[S, G]) New(initial S) (*FSM[S, G], error) {
+	index, has := m.states[initial]
+	if !has {
+		return nil, fsm.Errorf(fsm.UserError, "unknown state %v", initial)
+	}
+	f, err := m.Machines.New(index)
+	if err != nil {
+		return nil, err
+	}
+	return &FSM[S, G]{FSM: f, machines: m}, nil
+}
+
+// CountIn returns the number of instances in the states
+func (m *Machines[S, G]) CountIn(states ...S) int {
+	indexes := []fsm.Index{}
+	for _, state := range states {
+		indexes = append(indexes, m.Index(state))
+	}
+	return m.Machines.CountIn(indexes...)
+}
+
+// FSM is an instance with typed states and signals
+type FSM[S ~string, G ~string] struct {
+	fsm.FSM
+
+	machines *Machines[S, G]
+}
+
+// State returns the state of the instance
+func (f *FSM[S, G]) State() S {
+	return f.machines.StateOf(f.FSM.State())
+}
+
+// Signal signals the instance, with optional data
+func (f *FSM[S, G]) Signal(signal G, optionalData ...interface{}) error {
+	s, has := f.machines.signals[signal]
+	if !has {
+		return fsm.Errorf(fsm.UserError, "unknown signal %v", signal)
+	}
+	return f.FSM.Signal(s, optionalData...)
+}
@@ -0,0 +1,144 @@
+package typed // import "github.com/orkestr8/fsm/typed"
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/orkestr8/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+type light string
+
+type toggle string
+
+const (
+	off light = "off"
+	on  light = "on"
+
+	switchOn  toggle = "switch-on"
+	switchOff toggle = "switch-off"
+)
+
+func TestDefine(t *testing.T) {
+
+	switched := make(chan toggle, 10)
+
+	machines, err := Define(
+		State[light, toggle]{
+			Name: off,
+			Transitions: map[toggle]light{
+				switchOn: on,
+			},
+			Actions: map[toggle]fsm.Action{
+				switchOn: func(fsm.FSM) error {
+					switched <- switchOn
+					return nil
+				},
+			},
+			TTL: Expiry[toggle]{TTL: 5, Raise: switchOn},
+		},
+		State[light, toggle]{
+			Name: on,
+			Transitions: map[toggle]light{
+				switchOff: off,
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, fsm.Index(0), machines.Index(off))
+	require.Equal(t, fsm.Index(1), machines.Index(on))
+	require.Equal(t, fsm.Signal(0), machines.Signal(switchOff))
+	require.Equal(t, fsm.Signal(1), machines.Signal(switchOn))
+	require.Equal(t, fsm.NoState, machines.Index("broken"))
+	require.Equal(t, on, machines.StateOf(1))
+	require.Equal(t, switchOff, machines.SignalOf(0))
+
+	options := machines.Options()
+	require.Equal(t, map[fsm.Index]string{0: "off", 1: "on"}, options.StateNames)
+	require.Equal(t, map[fsm.Signal]string{0: "switch-off", 1: "switch-on"}, options.SignalNames)
+
+	clock := fsm.NewClock()
+	require.NoError(t, machines.Run(clock, fsm.DefaultOptions()))
+	defer machines.Done()
+
+	a, err := machines.New(off)
+	require.NoError(t, err)
+	require.Equal(t, off, a.State())
+
+	require.NoError(t, a.Signal(switchOn))
+	require.Equal(t, switchOn, <-switched)
+	require.Equal(t, on, a.State())
+	require.Equal(t, 1, machines.CountIn(on))
+
+	require.NoError(t, a.Signal(switchOff))
+	require.Equal(t, off, a.State())
+
+	err = a.Signal("dim")
+	require.True(t, errors.Is(err, fsm.UserError))
+	_, err = machines.New("dimmed")
+	require.True(t, errors.Is(err, fsm.UserError))
+
+	_, err = Define(
+		State[light, toggle]{
+			Name: off,
+			Transitions: map[toggle]light{
+				switchOn: "dimmed",
+			},
+		},
+	)
+	require.True(t, errors.Is(err, fsm.SpecError))
+}
+
+func TestDefineNumbered(t *testing.T) {
+
+	const dim toggle = "dim"
+
+	// dim is added after the signals persisted, which keep their numbers
+	machines, err := DefineNumbered([]toggle{switchOn, switchOff, dim},
+		State[light, toggle]{
+			Name: off,
+			Transitions: map[toggle]light{
+				switchOn: on,
+				dim:      on,
+			},
+		},
+		State[light, toggle]{
+			Name: on,
+			Transitions: map[toggle]light{
+				switchOff: off,
+			},
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, fsm.Signal(0), machines.Signal(switchOn))
+	require.Equal(t, fsm.Signal(1), machines.Signal(switchOff))
+	require.Equal(t, fsm.Signal(2), machines.Signal(dim))
+
+	_, err = DefineNumbered([]toggle{switchOn},
+		State[light, toggle]{
+			Name: off,
+			Transitions: map[toggle]light{
+				switchOn: on,
+			},
+		},
+		State[light, toggle]{
+			Name: on,
+			Transitions: map[toggle]light{
+				switchOff: off,
+			},
+		},
+	)
+	require.True(t, errors.Is(err, fsm.SpecError))
+
+	_, err = DefineNumbered([]toggle{switchOn, switchOn},
+		State[light, toggle]{
+			Name: off,
+			Transitions: map[toggle]light{
+				switchOn: off,
+			},
+		},
+	)
+	require.True(t, errors.Is(err, fsm.SpecError))
+}
@@ -2,6 +2,9 @@ package fsm // import "github.com/orkestr8/fsm"
 
 import (
 	"fmt"
+	"io"
+	"math/rand"
+	"time"
 )
 
 // ID is the id of the instance in a given set.  It's unique in that set.
@@ -13,22 +16,80 @@ type FSM interface {
 	// ID returns the ID of the instance
 	ID() ID
 
-	// State returns the state of the instance. This is an expensive call to be submitted to queue to view
+	// State returns the state of the instance. This is an expensive call to be submitted to queue to view.
+	// It returns NoState once the instance is freed.
 	State() Index
 
-	// Data returns the custom data attached to the instance.  It's set via the optional arg in Signal
+	// Data returns the custom data attached to the instance.  It's set via the optional arg in Signal.
+	// It returns nil once the instance is freed.
 	Data() interface{}
 
-	// Signal signals the instance with optional custom data
+	// Signal signals the instance with optional custom data.  It returns ErrFreed once the instance is freed.
 	Signal(Signal, ...interface{}) error
 
+	// SignalFrom signals the instance with optional custom data, tagging the signal with its origin
+	SignalFrom(Origin, Signal, ...interface{}) error
+
+	// SignalAt signals the instance with optional custom data, with the time the event happened.  The time
+	// is used for the windows of hysteresis and flaps if Options.EventTime is set.
+	SignalAt(time.Time, Signal, ...interface{}) error
+
+	// Labels returns a copy of the labels given to the instance when it was seeded
+	Labels() map[string]string
+
+	// Origin returns the origin of the signal of the last transition
+	Origin() Origin
+
+	// Sequence applies the signals in order as a single transaction, without other events in between.  The
+	// signals are admitted as with Signal.  It stops at the first signal that can't be received, or that's
+	// absorbed without a transition (ErrAbsorbed), returning ErrSequence with the count applied.
+	Sequence(...Signal) error
+
 	// CanReceive returns true if the current state of the instance can receive the given signal
 	CanReceive(Signal) bool
+
+	// Check returns whether the current state of the instance can receive the signal, the next state,
+	// and the rule that blocks the signal if not.  It is evaluated on a consistent view of the instance.
+	Check(Signal) (ok bool, next Index, reason error)
+
+	// Overdue returns, during an action, how many ticks late a signal raised by an expired TTL is processed
+	Overdue() Tick
+
+	// Pin keeps the instance from being freed, with its data, until Unpin.  Free returns ErrPinned.
+	Pin()
+
+	// Unpin releases the instance so that it can be freed
+	Unpin()
+
+	// Pinned returns true if the instance is pinned
+	Pinned() bool
+
+	// Pending returns the signals queued for the instance and not yet applied, including those
+	// deferred by a blackout, in the order they were queued.
+	Pending() []PendingSignal
+
+	// CreatedAt returns the wall time when the instance was created
+	CreatedAt() time.Time
+
+	// LastTransitionAt returns the wall time when the instance last entered its state
+	LastTransitionAt() time.Time
+
+	// Child returns the child instance of the Submachine of the state, or nil if there's none
+	Child() FSM
 }
 
 // Index is the index of the state in a FSM
 type Index int
 
+// NoState is the index that does not correspond to any state, e.g. the next state of a signal that
+// cannot be received.  It must not be used as the index of a state.
+const NoState Index = -99999
+
+// IsInvalidState returns true if the index is invalid
+func IsInvalidState(s Index) bool {
+	return s == NoState
+}
+
 // Action is the action to take when a signal is received, prior to transition
 // to the next state.  The error returned by the function is an exception which
 // will put the state machine in an error state.  This error state is not the same
@@ -37,6 +98,25 @@ type Index int
 // programming error here).
 type Action func(FSM) error
 
+// ActionCtx is an action that is given the context of the transition it's executed for
+type ActionCtx func(TransitionContext, FSM) error
+
+// TransitionContext is the context of the transition an action is executed for
+type TransitionContext struct {
+	Signal Signal
+	From   Index
+	To     Index
+
+	// Data is the optional data sent with the signal
+	Data []interface{}
+
+	// Origin is where the signal comes from
+	Origin Origin
+
+	// CorrelationID is the external request that caused the signal, if any
+	CorrelationID CorrelationID
+}
+
 // Tick is a unit of time. Time is in relative terms and synchronized with an actual
 // timer that's provided by the client.
 type Tick int64
@@ -51,6 +131,14 @@ type Expiry struct {
 	Raise Signal
 }
 
+// DeadlineInfo describes a pending deadline of an instance
+type DeadlineInfo struct {
+	ID    ID
+	State Index
+	Due   Time
+	Raise Signal
+}
+
 // Limit is a struct that captures the limit and what signal to raise
 type Limit struct {
 	Value int
@@ -60,6 +148,14 @@ type Limit struct {
 // Signal is a signal that can drive the state machine to transfer from one state to next.
 type Signal int
 
+// NoSignal is the signal that does not correspond to any signal.  It must not be used as a signal.
+const NoSignal Signal = -99999
+
+// IsNoSignal returns true if the signal is NoSignal
+func IsNoSignal(s Signal) bool {
+	return s == NoSignal
+}
+
 // State encapsulates all the possible transitions and actions to perform during the
 // state transition.  A state can have a TTL so that it is allowed to be in that
 // state for a given TTL.  On expiration, a signal is raised.
@@ -74,14 +170,88 @@ type State struct {
 	// Actions specify for each signal, what code / action is to be executed as the fsm transits from one state to next.
 	Actions map[Signal]Action
 
+	// ContextActions are actions, like Actions, that are given the context of the transition.  A signal can
+	// have either an action or a context action.
+	ContextActions map[Signal]ActionCtx
+
+	// Prepare specify for each signal, an action that can veto the transition by returning an error.  It
+	// runs before the action, with the data of the signal attached, and the instance stays in its state if vetoed.
+	Prepare map[Signal]Action
+
+	// Commit specify for each signal, an action that runs after the instance is in the next state.
+	// It does not run if the action failed.  Errors are reported as ErrCommit.
+	Commit map[Signal]Action
+
+	// ActionNames specify actions by name, for each signal, that are bound at Run from Options.Actions.
+	ActionNames map[Signal]string
+
+	// Choices are transitions on signals in Transitions to one of several states, chosen with the instance
+	Choices map[Signal]Choice
+
 	// Errors specifies the handling of errors when executing action.  On action error, the mapped state is transitioned.
 	Errors map[Signal]Index
 
+	// ErrorsAction are the actions run when the instance follows Errors, e.g. to clean up after a failed action.
+	// An error of these actions is reported, and the instance transitions to the error state regardless.
+	ErrorsAction map[Signal]Action
+
+	// ErrorsFirst has the signals in Errors follow Errors without running the action of the transition, e.g.
+	// for signals that report failures.  Otherwise, Errors are followed only when the action fails, and
+	// a signal in Errors but not in Transitions is undefined.
+	ErrorsFirst bool
+
+	// RollbackOnError keeps the instance in this state, with its TTL as it is, when an action fails and there's
+	// no mapping in Errors for the signal.  Otherwise, the instance transitions to the next state regardless.
+	RollbackOnError bool
+
+	// ActionTimeout bounds the time an action can run.  An action that times out is handled as a failed action.
+	ActionTimeout time.Duration
+
 	// TTL specifies how long this state can last before a signal is raised.
 	TTL Expiry
 
+	// Backoff increases the TTL on each consecutive expiry of this state's deadline.
+	Backoff Backoff
+
+	// Internal lists the self-transitions that run their actions without re-entering the state: the TTL
+	// is not reset and the visit is not counted.  A failed action routed by Errors is a regular transition.
+	Internal []Signal
+
+	// Rearm lists the signals that reset the TTL countdown while remaining in this state.  The state must
+	// have a TTL.
+	Rearm []Signal
+
 	// Visit specifies a limit on the number of times the fsm can visit this state before raising a signal.
 	Visit Limit
+
+	// Watchdog specifies a signal to raise if a keep-alive signal is not received in time.
+	Watchdog Watchdog
+
+	// Hysteresis specifies for each signal how many times it must be received before the transition fires.
+	Hysteresis map[Signal]Threshold
+
+	// Acknowledge makes the state sticky: the signals raised by the machines themselves (TTLs, visit and flap
+	// limits, watchdogs and error budgets) are dropped until the instance receives one of these signals, e.g.
+	// from an operator.  Acknowledging restarts the TTL of the state.
+	Acknowledge []Signal
+
+	// Terminal marks a state that's meant to have no transitions out, for Validate
+	Terminal bool
+
+	// MaxData is the maximum size in bytes, as encoded by the codec in Options, of the data attached to
+	// instances entering this state.  Larger data is rejected with ErrDataQuota.  Zero means no limit.
+	MaxData int
+
+	// Sub runs a child instance of other machines while in this state.  See Submachine.
+	Sub *Submachine
+
+	// Meta is metadata of the state for tools and exporters, e.g. the owner or a runbook URL.
+	Meta map[string]string
+
+	// Descriptions describe the transitions, for each signal, e.g. why the edge exists and how often
+	// it's expected.  They are written as notes after the transition table, and as the tooltips of the edges
+	// in DOT.
+	Descriptions map[Signal]string
 }
 
 // DefaultOptions returns default values
@@ -120,6 +290,142 @@ type Options struct {
 
 	// Logger is a logger that implements the logging interface
 	Logger Logger
+
+	// TransitionLogFormat is a text/template for a line logged at Info for each committed transition.
+	// The template is executed with a Transition, e.g. "{{.ID}} {{.Names.From}} -[{{.Names.Signal}}]-> {{.Names.To}}".
+	TransitionLogFormat string
+
+	// MaxTickLag is the number of ticks the transaction loop can fall behind the clock before an error is reported
+	MaxTickLag int64
+
+	// ClockStall is the duration without a clock tick after which the clock is reported as stalled
+	ClockStall time.Duration
+
+	// InlineDeadlines processes expired deadlines in the order they are due as part of the clock tick,
+	// rather than queueing the raised signals behind any backlog of ticks and events.
+	InlineDeadlines bool
+
+	// EventTime computes the windows of hysteresis and the ticks of flaps on the time the events happened,
+	// as given with FSM.SignalAt, rather than when they're processed
+	EventTime EventTime
+
+	// Stability tracks the stability score of the instances, see Machines.Stability
+	Stability StabilityConfig
+
+	// Priority is whether ticks or events are taken first when both are waiting.  By default neither is.  It
+	// doesn't reorder the transactions already queued, see Priority.
+	Priority Priority
+
+	// Notify maps states to the notifiers that are notified, asynchronously, when an instance enters the state.
+	Notify map[Index]Notifier
+
+	// WrapAction wraps every action, e.g. for logging, metrics, or converting panics to errors.
+	// The name identifies the action by its state and signal names, as state/signal.
+	WrapAction func(name string, a Action) Action
+
+	// OnSignal is called synchronously before a signal is queued.  The signal is rejected if it returns false.
+	OnSignal func(id ID, s Signal, data []interface{}) (allow bool)
+
+	// NewData returns the initial data of a new instance.  It is called on New with the id of the instance.
+	NewData func(id ID) interface{}
+
+	// CopyData, if set, copies each value of the optional data of a signal before Signal returns, so that
+	// callers can reuse their buffers.  DeepCopy copies slices, maps and pointers recursively.
+	CopyData func(interface{}) interface{}
+
+	// ExternalIDLabel is the label of the instances that holds their external ID, e.g. the serial number of
+	// a device, for Machines.SignalExternal.  Defaults to DefaultExternalIDLabel.
+	ExternalIDLabel string
+
+	// AutoCreate is called with the external ID of a signal that matches no instance.  If it returns true,
+	// an instance is created in the returned state, labeled with the external ID, and then signaled.
+	AutoCreate func(externalID string) (Index, bool)
+
+	// IDs is the policy of assigning IDs to new instances
+	IDs IDPolicy
+
+	// Random is the source of the random numbers for random IDs and the jitter of backoffs.  Set a seeded
+	// source for runs that are reproducible in tests.  The default is a source seeded by crypto/rand.
+	Random rand.Source
+
+	// Actions binds the action names in the states to actions.  Run fails if a name is not bound.
+	Actions ActionRegistry
+
+	// ErrorBudget raises a signal when an instance has too many action errors
+	ErrorBudget ErrorBudget
+
+	// RateAnomaly reports surges of transitions between states across the instances
+	RateAnomaly RateAnomaly
+
+	// OnTerminal is what happens to signals for instances that are in terminal states or freed
+	OnTerminal TerminalPolicy
+
+	// DeadLetterLimit is the most dead letters kept by the TerminalDeadLetter policy until they are drained.  The
+	// oldest are dropped beyond it, and counted in Stats.  Defaults to 1024.
+	DeadLetterLimit int
+
+	// ClockJump is how a jump of the wall clock reported by the clock, e.g. after resuming from suspend, is handled
+	ClockJump JumpPolicy
+
+	// DryRun skips the actions, and their prepare and commit steps, while the transitions proceed.  The
+	// skipped actions are recorded in the transitions, as seen by Watch.
+	DryRun bool
+
+	// Fields returns key value pairs about the instance, e.g. its target URL or zone, that are appended to
+	// the log lines and transitions of the instance.  It's mostly called from the transaction loop, so it
+	// must not block or call the Machines.  It's not called for debug lines unless they are logged, see
+	// LevelLogger.
+	Fields func(FSM) []interface{}
+
+	// Now is the logical time the runner starts at, e.g. from Snapshot.Resume, so that the ticks and
+	// the deadlines of restored instances continue from a checkpoint.
+	Now Time
+
+	// WarmupTicks holds the TTLs that expire in the first ticks after Run or Restore until the end of the
+	// warm-up, e.g. while the pollers of a restarted controller catch up with reality.
+	WarmupTicks Tick
+
+	// Blackouts are the windows of wall time when the signals raised by the machines are deferred.
+	// They can be changed with Machines.SetBlackouts.
+	Blackouts []Blackout
+
+	// Codec encodes and decodes the data of instances in snapshots.  The default is JSONCodec.
+	Codec Codec
+
+	// WAL is the write-ahead log of the changes to the instances, if any
+	WAL WAL
+
+	// Migration maps the states and signals in snapshots and the WAL to those of the spec, when restoring
+	// against a spec where they were renumbered
+	Migration Migration
+
+	// Compaction is when the records of the instances in the WAL are compacted
+	Compaction Compaction
+
+	// Enums are the ranges of the state and signal constants of the spec, to reject those of other specs
+	Enums *Enums
+}
+
+// JumpPolicy is how a jump of the wall clock is handled
+type JumpPolicy int
+
+const (
+	// JumpRearm counts the jump as a single tick, so that the pending deadlines are pushed out by the time
+	// jumped.  This is the default.
+	JumpRearm JumpPolicy = iota
+
+	// JumpFire advances the time by the ticks jumped, so that the deadlines that came due fire immediately.
+	JumpFire
+)
+
+// ActionRegistry is the lookup of actions by name
+type ActionRegistry map[string]Action
+
+// IgnoreUndefined are the IgnoreUndefined* options that can be changed while running
+type IgnoreUndefined struct {
+	States      bool
+	Transitions bool
+	Signals     bool
 }
 
 // Logger is the interface used by the module to log information
@@ -129,6 +435,18 @@ type Logger interface {
 	Info(string, ...interface{})
 }
 
+// LevelLogger is a Logger that tells whether it logs debug lines, so that the Options.Fields of the instances
+// are not computed for debug lines that aren't logged
+type LevelLogger interface {
+	Logger
+	DebugEnabled() bool
+}
+
+// Notifier is notified of transitions, typically to send alerts
+type Notifier interface {
+	Notify(Transition) error
+}
+
 // Backgrounder runs in the background
 type Backgrounder interface {
 	// Stop stops the state machine loop
@@ -141,12 +459,149 @@ type Machines interface {
 	// New allocates an instance of FSM for tracking of state
 	New(Index) (FSM, error)
 
+	// NewAt allocates an instance of FSM whose TTL in the initial state is reduced by the elapsed ticks,
+	// e.g. for a resource that has already been in the state before a restart.
+	NewAt(initial Index, elapsed Tick) (FSM, error)
+
+	// Free removes the instance of the given ID so it is no longer tracked
+	Free(ID) error
+
+	// SignalExternal signals the instance labeled with the external ID, see Options.ExternalIDLabel.  If there's
+	// none, Options.AutoCreate may create it first.  It returns the instance signaled.
+	SignalExternal(externalID string, signal Signal, optionalData ...interface{}) (FSM, error)
+
 	// Run starts the machines runtime to track states
 	Run(*Clock, Options) error
 
+	// Handoff moves the instances to the machines, which must be running, in batches of the given size, so that
+	// the signals are paused only for the instances of one batch at a time.  It returns the number moved.
+	Handoff(to Machines, batch int) (moved int, err error)
+
+	// Reload swaps the spec for the states given, while the instances keep their states, which must all be
+	// in the new spec
+	Reload(states ...State) error
+
+	// Drive starts the machines runtime without any goroutines of its own.  The work is processed
+	// by the caller on its own loop, with the returned Driver.  The clock is optional.
+	Drive(*Clock, Options) (Driver, error)
+
 	// Done stops everything and releases all resources
 	Done()
 
+	// AddSource registers a source of signals.  Sources run from Run until Done.
+	AddSource(Source)
+
+	// AddVetoer registers a vetoer to approve transitions before their actions run
+	AddVetoer(Vetoer)
+
+	// ForEach calls the function with each instance, in order of ID, until the function returns false.
+	// The iteration is performed on a consistent snapshot, so the function should not block.
+	ForEach(func(FSM) bool)
+
+	// Count returns the number of instances
+	Count() int
+
+	// CountIn returns the number of instances in any of the given states
+	CountIn(...Index) int
+
+	// Snapshot returns the snapshot of all the instances, taken in one transaction
+	Snapshot() (Snapshot, error)
+
+	// SnapshotStream writes the snapshot of all the instances, taken in one transaction, as length-prefixed
+	// records one instance at a time, without holding the whole snapshot in memory.  See ReadSnapshotStream.
+	SnapshotStream(w io.Writer) error
+
+	// Restore adds the instances in the snapshot, with their IDs, states, data and remaining TTLs.
+	// It fails with ErrIncompatible if the snapshot has states that are not in the spec, after Options.Migration.
+	Restore(Snapshot) error
+
+	// CheckCompatibility returns ErrIncompatible if the snapshot has states that are not in the spec,
+	// after Options.Migration, without restoring it
+	CheckCompatibility(Snapshot) error
+
+	// Seed adds the instances in the given states, with their labels, data and remaining TTLs, in one
+	// transaction.  Nothing is added if any of the items has an unknown state.
+	Seed([]SeedItem) ([]FSM, error)
+
+	// SignalBatch sends the signals to the instances, in order and after the signals already sent, as with
+	// FSM.Signal but with the instances looked up in one transaction.  The errors are of the admission of each
+	// signal, as from FSM.Signal, with nil for those admitted.
+	SignalBatch([]SignalRequest) []error
+
+	// Recover restores the instances from the records in Options.WAL
+	Recover() error
+
+	// Ready evaluates the predicate with the number of instances in each state, from a consistent view
+	Ready(pred func(counts map[Index]int) bool) bool
+
+	// AnyIn returns true if there are instances in any of the given states
+	AnyIn(...Index) bool
+
+	// SetIgnoreUndefined changes the IgnoreUndefined* options from the next transaction, and returns
+	// their previous values.
+	SetIgnoreUndefined(IgnoreUndefined) IgnoreUndefined
+
+	// SubscribeErrors returns a channel of the errors that match the filter, and a function to stop the
+	// subscription.  Each subscriber has its own channel; errors are dropped if it's full.
+	SubscribeErrors(ErrorFilter) (<-chan ErrorEvent, func())
+
+	// SetBlackouts replaces the blackout windows from the next transaction
+	SetBlackouts([]Blackout)
+
+	// Errors returns the errors encountered during async processing of events
+	Errors() <-chan error
+
+	// Watch returns a channel of committed transitions, buffered to the given size, and a function to
+	// stop watching.  Transitions are dropped if the channel is full.
+	Watch(buffer int) (<-chan Transition, func())
+
+	// Stats returns the runtime statistics
+	Stats() Stats
+
+	// PendingDeadlines returns the deadlines that have yet to expire, in the order they are due
+	PendingDeadlines() []DeadlineInfo
+
+	// Stability returns the stability scores of the instances, the most stable first, if Options.Stability
+	// is set
+	Stability() []Stability
+
+	// FlapHistory returns the recent oscillation of the instance between states with a flap limit
+	FlapHistory(ID) (FlapHistory, error)
+
+	// GraphStats returns the metrics of the spec as a graph of states and transitions
+	GraphStats() GraphStats
+
+	// Simulate runs randomized rollouts of the spec, without actions, and returns their aggregate statistics.
+	// It does not need Run.
+	Simulate(Simulation) (SimulationReport, error)
+
+	// HistoryIter returns a page of the past transitions in the WAL that match the filter, from the cursor of the
+	// previous page, or from the start if the cursor is empty
+	HistoryIter(filter HistoryFilter, cursor string) (*HistoryIter, error)
+
+	// Validate checks the spec for unreachable states, dead ends, signals raised with no transition and
+	// duplicate names, with the names given to Run
+	Validate() []Issue
+
+	// WriteTable writes the transition table of states by signals as an aligned markdown table
+	WriteTable(w io.Writer) error
+
+	// WriteDOT writes the spec as a Graphviz digraph of the states, transitions, TTLs, visit limits and flap limits
+	WriteDOT(w io.Writer) error
+
+	// WriteTestSkeleton writes a table-driven Go test for the package, with a case for every transition of
+	// the spec and placeholders for the assertions
+	WriteTestSkeleton(w io.Writer, pkg string) error
+
+	// Meta returns a copy of the metadata of the state
+	Meta(Index) map[string]string
+
+	// Terminal returns true if the state has no transitions out
+	Terminal(Index) bool
+
+	// DeadLetters returns and clears the signals kept by the TerminalDeadLetter policy
+	DeadLetters() []DeadLetter
+
 	// StateStringer returns the state in printable form
 	StateStringer(Index) fmt.GoStringer
 
@@ -0,0 +1,340 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// IssueSyntax is a config that can't be parsed
+	IssueSyntax = "syntax"
+
+	// IssueReference is a reference to a state or signal that's not in the config
+	IssueReference = "reference"
+
+	// IssueSpec is a config that doesn't compile to a spec, as Define would fail
+	IssueSpec = "spec"
+
+	// IssueUnreachable is a state that can't be reached from the first state or from any state with no
+	// transitions into it, where instances start
+	IssueUnreachable = "unreachable"
+
+	// IssueDeadEnd is a state with no transitions out that's not marked Terminal
+	IssueDeadEnd = "dead-end"
+
+	// IssueNoTransition is a TTL, visit limit or watchdog raising a signal that's not in the state's transitions
+	IssueNoTransition = "no-transition"
+
+	// IssueDuplicateName is a name given to more than one state or signal
+	IssueDuplicateName = "duplicate-name"
+)
+
+// Issue is a problem found in a config by ValidateConfig.  Rule is one of the Issue constants or a LintRule.
+type Issue struct {
+	Severity Severity `json:"severity"`
+	Rule     string   `json:"rule"`
+
+	// Field is the path of the field in the config, e.g. states[1].transitions.start, if known
+	Field string `json:"field,omitempty"`
+
+	// Line is the line in the config, if known
+	Line int `json:"line,omitempty"`
+
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	where := []string{}
+	if i.Line > 0 {
+		where = append(where, fmt.Sprintf("line %d", i.Line))
+	}
+	if i.Field != "" {
+		where = append(where, i.Field)
+	}
+	return fmt.Sprintf("%v: %v: %v: %v", i.Severity, i.Rule, strings.Join(where, " "), i.Message)
+}
+
+// MarshalText returns the name of the severity
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ValidateConfig checks a Config in JSON, as it would be compiled by Define and checked by the Linter with
+// default severities, without binding actions or running.  It returns all the issues found; the config is
+// valid if none is a LintError.  See ValidateYAMLConfig for configs in YAML.
+func ValidateConfig(r io.Reader) []Issue {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return []Issue{{Severity: LintError, Rule: IssueSyntax, Message: err.Error()}}
+	}
+
+	config, issue := decodeConfig(raw)
+	if issue != nil {
+		return []Issue{*issue}
+	}
+	return config.Issues(raw)
+}
+
+// Issues checks the config as ValidateConfig does.  The lines of the issues are found in the source the config
+// was decoded from, if any.
+func (c Config) Issues(raw []byte) []Issue {
+	compiled, issues := c.compile()
+	for i := range issues {
+		issues[i].Line = lineOfField(raw, c, issues[i].Field)
+	}
+	if len(issues) > 0 {
+		return issues
+	}
+
+	s := newSpec()
+	s.stateNames = compiled.stateNames
+	s.signalNames = compiled.signalNames
+	if _, err := s.build(compiled.states[0], compiled.states[1:]...); err != nil {
+		field := ""
+		if index, has := errorState(err); has {
+			field = fmt.Sprintf("states[%d]", index)
+		}
+		return []Issue{{Severity: LintError, Rule: IssueSpec, Field: field,
+			Line: lineOfField(raw, c, field), Message: err.Error()}}
+	}
+	if _, err := s.compileFlapping(compiled.limits); err != nil {
+		return []Issue{{Severity: LintError, Rule: IssueSpec, Field: "limits", Message: err.Error()}}
+	}
+
+	linter := Linter{}
+	linter.StateNames = compiled.stateNames
+	linter.SignalNames = compiled.signalNames
+	linter.Limits = compiled.limits
+	for _, finding := range linter.Lint(compiled.states[0], compiled.states[1:]...) {
+		field := fmt.Sprintf("states[%d]", finding.State)
+		issues = append(issues, Issue{
+			Severity: finding.Severity,
+			Rule:     string(finding.Rule),
+			Field:    field,
+			Line:     lineOfField(raw, c, field),
+			Message:  finding.Message,
+		})
+	}
+	return issues
+}
+
+// decodeConfig decodes a Config in JSON
+func decodeConfig(raw []byte) (Config, *Issue) {
+	config, err := decodeJSONConfig(raw)
+	if err != nil {
+		issue := Issue{Severity: LintError, Rule: IssueSyntax, Message: err.Error()}
+		switch err := err.(type) {
+		case *json.SyntaxError:
+			issue.Line = lineAt(raw, err.Offset)
+		case *json.UnmarshalTypeError:
+			issue.Line = lineAt(raw, err.Offset)
+			issue.Field = err.Field
+		}
+		return config, &issue
+	}
+	return config, nil
+}
+
+// decodeJSONConfig decodes a Config in JSON.  Unknown fields are errors.
+func decodeJSONConfig(raw []byte) (Config, error) {
+	config := Config{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	return config, decoder.Decode(&config)
+}
+
+// errorState returns the state of an error from building a spec, if any
+func errorState(err error) (Index, bool) {
+	switch err := err.(type) {
+	case ErrUnknownState:
+		return err.Index, false // a reference to the state, not where it's made
+	case ErrUnknownTransition:
+		return err.State, true
+	case ErrUnknownSignal:
+		return err.Index, err.Index != NoState
+	case ErrDuplicateAction:
+		return err.State, true
+	}
+	return NoState, false
+}
+
+// lineAt returns the line of the offset in the input, counting from 1
+func lineAt(raw []byte, offset int64) int {
+	if offset > int64(len(raw)) {
+		offset = int64(len(raw))
+	}
+	return bytes.Count(raw[:offset], []byte("\n")) + 1
+}
+
+var stateField = regexp.MustCompile(`^states\[(\d+)\]`)
+
+// lineOfField returns the line of the name of the state of the field, or 0 if not found
+func lineOfField(raw []byte, config Config, field string) int {
+	match := stateField.FindStringSubmatch(field)
+	if match == nil {
+		return 0
+	}
+	i, _ := strconv.Atoi(match[1])
+	if i >= len(config.States) || config.States[i].Name == "" {
+		return 0
+	}
+	quoted := regexp.QuoteMeta(config.States[i].Name)
+	name := regexp.MustCompile(`(?m)"?name"?\s*:\s*(?:"` + quoted + `"|'` + quoted + `'|` + quoted + `\s*(?:[,}#]|$))`)
+	loc := name.FindIndex(raw)
+	if loc == nil {
+		return 0
+	}
+	return lineAt(raw, int64(loc[0]))
+}
+
+// Validate checks the states, without stopping at the first problem as Define does, and returns the issues
+// found, ordered by state.  The Field of an issue is states[i] for the state of Index i.
+func Validate(state State, more ...State) []Issue {
+	s := newSpec()
+	s.states[state.Index] = state
+	for _, st := range more {
+		s.states[st.Index] = st
+	}
+	return s.Validate()
+}
+
+// Validate checks the spec for unreachable states, dead ends, signals raised with no transition, and
+// duplicate names of states and signals.
+func (s *spec) Validate() []Issue {
+	indexes := []Index{}
+	for index := range s.states {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	issues := []Issue{}
+	report := func(severity Severity, rule string, state Index, format string, args ...interface{}) {
+		field := ""
+		if state != NoState {
+			field = fmt.Sprintf("states[%d]", state)
+		}
+		issues = append(issues, Issue{Severity: severity, Rule: rule, Field: field,
+			Message: fmt.Sprintf(format, args...)})
+	}
+	if len(indexes) == 0 {
+		return issues
+	}
+
+	// instances start in the first state or in states with no transitions into them
+	into := map[Index]bool{}
+	for _, st := range s.states {
+		for _, next := range st.nextStates() {
+			if next != st.Index {
+				into[next] = true
+			}
+		}
+	}
+	reached := map[Index]bool{}
+	var reach func(Index)
+	reach = func(index Index) {
+		if reached[index] {
+			return
+		}
+		reached[index] = true
+		for _, next := range s.states[index].nextStates() {
+			reach(next)
+		}
+	}
+	reach(indexes[0])
+	for _, index := range indexes {
+		if !into[index] && len(s.states[index].Transitions) > 0 {
+			reach(index)
+		}
+	}
+
+	for _, index := range indexes {
+		st := s.states[index]
+		name := s.stateName(index)
+
+		for _, signal := range sortedSignals(st.Transitions) {
+			if _, has := s.states[st.Transitions[signal]]; !has {
+				report(LintError, IssueReference, index, "state %v transitions on %v to unknown state %v", name,
+					s.signalName(signal), st.Transitions[signal])
+			}
+		}
+		for _, signal := range sortedSignals(st.Errors) {
+			if _, has := s.states[st.Errors[signal]]; !has {
+				report(LintError, IssueReference, index, "state %v errors on %v to unknown state %v", name,
+					s.signalName(signal), st.Errors[signal])
+			}
+		}
+
+		if !reached[index] {
+			report(LintWarning, IssueUnreachable, index, "state %v is not reachable", name)
+		}
+		if len(st.Transitions) == 0 && !st.Terminal {
+			report(LintWarning, IssueDeadEnd, index, "state %v has no transitions and is not marked terminal", name)
+		}
+
+		for _, raise := range []struct {
+			what   string
+			on     bool
+			signal Signal
+		}{
+			{"TTL", st.TTL.TTL > 0, st.TTL.Raise},
+			{"visit limit", st.Visit.Value > 0, st.Visit.Raise},
+			{"watchdog", st.Watchdog.TTL > 0, st.Watchdog.Raise},
+		} {
+			if _, has := st.Transitions[raise.signal]; raise.on && !has {
+				report(LintError, IssueNoTransition, index, "%v of state %v raises %v which has no transition", raise.what,
+					name, s.signalName(raise.signal))
+			}
+		}
+	}
+
+	states := map[string][]int64{}
+	for index, name := range s.stateNames {
+		states[name] = append(states[name], int64(index))
+	}
+	for _, duplicate := range duplicates(states) {
+		report(LintError, IssueDuplicateName, NoState, "states %v are named %v", duplicate.values, duplicate.name)
+	}
+	signals := map[string][]int64{}
+	for signal, name := range s.signalNames {
+		signals[name] = append(signals[name], int64(signal))
+	}
+	for _, duplicate := range duplicates(signals) {
+		report(LintError, IssueDuplicateName, NoState, "signals %v are named %v", duplicate.values, duplicate.name)
+	}
+	return issues
+}
+
+// sortedSignals returns the signals of the transfers in order
+func sortedSignals(transfers map[Signal]Index) []Signal {
+	signals := []Signal{}
+	for signal := range transfers {
+		signals = append(signals, signal)
+	}
+	sort.Slice(signals, func(i, j int) bool { return signals[i] < signals[j] })
+	return signals
+}
+
+type duplicateName struct {
+	name   string
+	values []int64
+}
+
+// duplicates returns the names given to more than one value, in order of name
+func duplicates(named map[string][]int64) []duplicateName {
+	out := []duplicateName{}
+	for name, values := range named {
+		if len(values) > 1 {
+			sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+			out = append(out, duplicateName{name: name, values: values})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
@@ -0,0 +1,176 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+
+	valid := `{
+  "states": [
+    {
+      "name": "pending",
+      "transitions": {"start": "running"},
+      "actions": {"start": "provision"}
+    },
+    {
+      "name": "running",
+      "transitions": {"poll": "running", "stop": "stopped"},
+      "ttl": {"ticks": 10, "raise": "poll"}
+    },
+    {
+      "name": "stopped"
+    }
+  ]
+}`
+	require.Equal(t, []Issue{
+		{
+			Severity: LintWarning,
+			Rule:     string(RuleTTLSelfLoop),
+			Field:    "states[1]",
+			Line:     9,
+			Message:  "state running raises poll on expiry which transitions back to running, with no visit limit",
+		},
+	}, ValidateConfig(strings.NewReader(valid)))
+
+	references := `{
+  "states": [
+    {
+      "name": "pending",
+      "transitions": {"start": "runing"}
+    },
+    {
+      "name": "running",
+      "ttl": {"ticks": 10, "raise": "poll"}
+    }
+  ],
+  "signals": ["start"]
+}`
+	require.Equal(t, []Issue{
+		{Severity: LintError, Rule: IssueReference, Field: "states[0].transitions.start", Line: 4,
+			Message: "unknown state runing"},
+		{Severity: LintError, Rule: IssueReference, Field: "states[1].ttl.raise", Line: 8,
+			Message: "unknown signal poll"},
+	}, ValidateConfig(strings.NewReader(references)))
+
+	spec := `{
+  "states": [
+    {
+      "name": "pending",
+      "transitions": {"start": "running"}
+    },
+    {
+      "name": "running",
+      "ttl": {"ticks": 10, "raise": "start"}
+    }
+  ]
+}`
+	issues := ValidateConfig(strings.NewReader(spec))
+	require.Len(t, issues, 1)
+	require.Equal(t, IssueSpec, issues[0].Rule)
+	require.Equal(t, "states[1]", issues[0].Field)
+	require.Equal(t, 8, issues[0].Line)
+
+	syntax := "{\n  \"states\": [\n    {\"name\": \"pending\",}\n  ]\n}"
+	issues = ValidateConfig(strings.NewReader(syntax))
+	require.Len(t, issues, 1)
+	require.Equal(t, IssueSyntax, issues[0].Rule)
+	require.Equal(t, 3, issues[0].Line)
+
+	issues = ValidateConfig(strings.NewReader(`{"states": [{"name": "a", "ttl": 5}]}`))
+	require.Len(t, issues, 1)
+	require.Equal(t, IssueSyntax, issues[0].Rule)
+	require.Contains(t, issues[0].Field, "ttl")
+}
+
+func TestValidate(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+		stuck
+		left
+		right
+		done
+	)
+
+	const (
+		start Signal = iota
+		stop
+		poll
+		swap
+	)
+
+	issues := Validate(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+			TTL: Expiry{5, poll},
+		},
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				stop: stuck,
+				poll: 42,
+			},
+			Visit: Limit{3, swap},
+		},
+		State{
+			Index: stuck,
+		},
+		State{
+			Index: left,
+			Transitions: map[Signal]Index{
+				swap: right,
+			},
+		},
+		State{
+			Index: right,
+			Transitions: map[Signal]Index{
+				swap: left,
+			},
+		},
+		State{
+			Index:    done,
+			Terminal: true,
+		},
+	)
+	require.Equal(t, []Issue{
+		{Severity: LintError, Rule: IssueNoTransition, Field: "states[0]",
+			Message: "TTL of state 0 raises 2 which has no transition"},
+		{Severity: LintError, Rule: IssueReference, Field: "states[1]",
+			Message: "state 1 transitions on 2 to unknown state 42"},
+		{Severity: LintError, Rule: IssueNoTransition, Field: "states[1]",
+			Message: "visit limit of state 1 raises 3 which has no transition"},
+		{Severity: LintWarning, Rule: IssueDeadEnd, Field: "states[2]",
+			Message: "state 2 has no transitions and is not marked terminal"},
+		{Severity: LintWarning, Rule: IssueUnreachable, Field: "states[3]", Message: "state 3 is not reachable"},
+		{Severity: LintWarning, Rule: IssueUnreachable, Field: "states[4]", Message: "state 4 is not reachable"},
+		{Severity: LintWarning, Rule: IssueUnreachable, Field: "states[5]", Message: "state 5 is not reachable"},
+	}, issues)
+
+	machines, err := define(
+		State{
+			Index: pending,
+			Transitions: map[Signal]Index{
+				start: running,
+			},
+		},
+		State{
+			Index:    running,
+			Terminal: true,
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []Issue{}, machines.Validate())
+
+	machines.spec.stateNames = map[Index]string{pending: "idle", running: "idle"}
+	require.Equal(t, []Issue{
+		{Severity: LintError, Rule: IssueDuplicateName, Message: "states [0 1] are named idle"},
+	}, machines.Validate())
+}
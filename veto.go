@@ -0,0 +1,59 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// Proposal is a transition about to be made, given to the vetoers for approval
+type Proposal struct {
+	ID     ID
+	From   Index
+	To     Index
+	Signal Signal
+	Names  TransitionNames
+
+	// Origin is where the signal comes from
+	Origin Origin
+
+	// Data is the optional data of the signal
+	Data []interface{}
+}
+
+// Vetoer approves transitions after the next state is resolved and before the action runs.  It is
+// called in the transaction loop, so it must not block or call the Machines.
+type Vetoer interface {
+	// Veto returns an error to reject the transition.  The instance stays in its state and the
+	// rejection is reported as ErrVetoed.
+	Veto(Proposal) error
+}
+
+// VetoFunc is a function that implements Vetoer
+type VetoFunc func(Proposal) error
+
+// Veto implements Vetoer
+func (f VetoFunc) Veto(p Proposal) error {
+	return f(p)
+}
+
+// veto asks the vetoers, in the order they were added, to approve the transition.  This must be called
+// from within the transaction loop.
+func (g *runner) veto(instance *instance, current, next Index, event *event) error {
+	if len(g.vetoers) == 0 {
+		return nil
+	}
+	proposal := Proposal{
+		ID:     instance.id,
+		From:   current,
+		To:     next,
+		Signal: event.signal,
+		Names: TransitionNames{
+			From:   g.spec().stateName(current),
+			To:     g.spec().stateName(next),
+			Signal: g.spec().signalName(event.signal),
+		},
+		Origin: event.origin,
+		Data:   event.data,
+	}
+	for _, vetoer := range g.vetoers {
+		if err := vetoer.Veto(proposal); err != nil {
+			return ErrVetoed{spec: g.spec(), ID: instance.id, State: current, Signal: event.signal, Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVetoer(t *testing.T) {
+
+	const (
+		running Index = iota
+		terminated
+	)
+
+	const (
+		terminate Signal = iota
+	)
+
+	actions := 0
+	machines, err := define(
+		State{
+			Index: running,
+			Transitions: map[Signal]Index{
+				terminate: terminated,
+			},
+			Actions: map[Signal]Action{
+				terminate: func(FSM) error {
+					actions++
+					return nil
+				},
+			},
+		},
+		State{
+			Index: terminated,
+		},
+	)
+	require.NoError(t, err)
+
+	proposals := []Proposal{}
+	machines.AddVetoer(VetoFunc(func(p Proposal) error {
+		proposals = append(proposals, p)
+		return nil
+	}))
+	require.NoError(t, machines.Run(NewClock(), DefaultOptions()))
+	defer machines.Done()
+
+	machines.AddVetoer(VetoFunc(func(p Proposal) error {
+		if len(p.Data) == 0 || p.Data[0] != "approved" {
+			return fmt.Errorf("%v not approved", p.Names.Signal)
+		}
+		return nil
+	}))
+
+	errs, cancel := machines.SubscribeErrors(ErrorFilter{Types: []error{ErrVetoed{}}})
+	defer cancel()
+
+	instance, err := machines.New(running)
+	require.NoError(t, err)
+
+	require.NoError(t, instance.Signal(terminate))
+	e := <-errs
+	require.Equal(t, ErrVetoed{spec: machines.runner.spec(), ID: instance.ID(), State: running, Signal: terminate,
+		Err: fmt.Errorf("0 not approved")}, e.Err)
+	require.Equal(t, running, instance.State())
+	require.Equal(t, 0, actions)
+
+	require.NoError(t, instance.SignalFrom(OriginSource, terminate, "approved"))
+	require.Equal(t, terminated, instance.State())
+	require.Equal(t, 1, actions)
+
+	require.Equal(t, []Proposal{
+		{ID: instance.ID(), From: running, To: terminated, Signal: terminate, Origin: OriginAPI,
+			Names: TransitionNames{From: "0", To: "1", Signal: "0"}},
+		{ID: instance.ID(), From: running, To: terminated, Signal: terminate, Origin: OriginSource,
+			Names: TransitionNames{From: "0", To: "1", Signal: "0"}, Data: []interface{}{"approved"}},
+	}, proposals)
+}
@@ -0,0 +1,476 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// RecordKind is the kind of a record in the write-ahead log
+type RecordKind string
+
+const (
+	// RecordSnapshot is the full state of an instance, written on New and on compaction
+	RecordSnapshot RecordKind = "snapshot"
+
+	// RecordTransition is a committed transition of an instance, with the data of the instance after it
+	RecordTransition RecordKind = "transition"
+
+	// RecordFree is written when an instance is freed
+	RecordFree RecordKind = "free"
+
+	// RecordRearm is written when a signal re-arms the TTL of the state of an instance, with the new deadline
+	RecordRearm RecordKind = "rearm"
+)
+
+// Record is an entry in the write-ahead log
+type Record struct {
+	Kind       RecordKind        `json:"kind"`
+	ID         ID                `json:"id"`
+	Tick       Time              `json:"tick"`
+	Snapshot   *InstanceSnapshot `json:"snapshot,omitempty"`
+	Transition *Transition       `json:"transition,omitempty"`
+	Data       []byte            `json:"data,omitempty"`
+
+	// Due is the tick of the deadline of the instance after a transition or a rearm, if any, as the TTL may
+	// have been scaled by a backoff or re-armed since the state was entered.
+	Due Time `json:"due,omitempty"`
+}
+
+// WAL is a write-ahead log of the changes to the instances, used to recover them with Machines.Recover.
+type WAL interface {
+	// Append adds the record to the end of the log
+	Append(Record) error
+
+	// Compact replaces all the records of the instance with the given record
+	Compact(id ID, record Record) error
+
+	// Records returns all the records in the order they were appended
+	Records() ([]Record, error)
+}
+
+// Compaction is when the records of an instance in the WAL are replaced by its snapshot: after the given
+// number of events, or when it enters a terminal state.  Compactions are done every given number of ticks,
+// or right away if Every is 0.
+type Compaction struct {
+	Events   int
+	Terminal bool
+	Every    Tick
+}
+
+// logNew writes the snapshot of a new instance to the WAL, if any.
+func (g *runner) logNew(tid int64, i *instance) {
+	if g.options.WAL == nil {
+		return
+	}
+	s, err := g.snapshot(i)
+	if err != nil {
+		g.handleError(tid, err, i.id)
+		return
+	}
+	g.appendRecord(tid, Record{Kind: RecordSnapshot, ID: i.id, Tick: g.now, Snapshot: &s})
+}
+
+// logCommitted writes the transition of the instance to the WAL, if any, and compacts the records of
+// the instance when due.
+func (g *runner) logCommitted(tid int64, i *instance, transition Transition) {
+	if g.options.WAL == nil {
+		return
+	}
+	record := Record{Kind: RecordTransition, ID: i.id, Tick: g.now, Transition: &transition, Due: g.due(i)}
+	if i.data != nil {
+		buff, err := g.codec().Encode(i.data)
+		if err != nil {
+			g.handleError(tid, err, i.id)
+			return
+		}
+		record.Data = buff
+	}
+	g.appendRecord(tid, record)
+	g.countLogged(tid, i)
+}
+
+// logRearm writes the new deadline of the instance to the WAL, if any, when its TTL is re-armed.
+func (g *runner) logRearm(tid int64, i *instance) {
+	if g.options.WAL == nil {
+		return
+	}
+	g.appendRecord(tid, Record{Kind: RecordRearm, ID: i.id, Tick: g.now, Due: g.due(i)})
+	g.countLogged(tid, i)
+}
+
+// due returns the deadline of the instance, or 0 if none is pending
+func (g *runner) due(i *instance) Time {
+	if i.deadline > 0 && i.index > -1 {
+		return i.deadline
+	}
+	return 0
+}
+
+// countLogged counts a record of the instance written to the WAL, and compacts its records when due.
+func (g *runner) countLogged(tid int64, i *instance) {
+	compaction := g.options.Compaction
+	g.logged[i.id]++
+	if (compaction.Events > 0 && g.logged[i.id] >= compaction.Events) ||
+		(compaction.Terminal && len(g.spec().states[i.state].Transitions) == 0) {
+		g.compactions[i.id] = true
+	}
+	if compaction.Every <= 0 {
+		g.compact(tid)
+	}
+}
+
+// logFree writes the freeing of the instance to the WAL, if any, replacing all its records.
+func (g *runner) logFree(tid int64, id ID) {
+	if g.options.WAL == nil {
+		return
+	}
+	delete(g.logged, id)
+	delete(g.compactions, id)
+	if err := g.options.WAL.Compact(id, Record{Kind: RecordFree, ID: id, Tick: g.now}); err != nil {
+		g.handleError(tid, err, id)
+	}
+}
+
+func (g *runner) appendRecord(tid int64, record Record) {
+	if err := g.options.WAL.Append(record); err != nil {
+		g.handleError(tid, err, record)
+	}
+}
+
+// compact replaces the records of the instances due for compaction with their snapshots.
+func (g *runner) compact(tid int64) {
+	for id := range g.compactions {
+		delete(g.compactions, id)
+		i, has := g.members[id]
+		if !has {
+			continue
+		}
+		s, err := g.snapshot(i)
+		if err != nil {
+			g.handleError(tid, err, id)
+			continue
+		}
+		if err := g.options.WAL.Compact(id, Record{Kind: RecordSnapshot, ID: id, Tick: g.now, Snapshot: &s}); err != nil {
+			g.handleError(tid, err, id)
+			continue
+		}
+		g.logged[id] = 0
+	}
+}
+
+// compactOnTick runs the periodic compaction, if configured.
+func (g *runner) compactOnTick(tid int64) {
+	if g.options.WAL == nil || g.options.Compaction.Every <= 0 {
+		return
+	}
+	if g.now-g.compacted >= Time(g.options.Compaction.Every) {
+		g.compacted = g.now
+		g.compact(tid)
+	}
+}
+
+// replay folds the records into a snapshot of the instances, as of the last tick in the records.
+func (g *runner) replay(records []Record) Snapshot {
+	now := Time(0)
+	for _, r := range records {
+		if r.Tick > now {
+			now = r.Tick
+		}
+	}
+
+	type replayed struct {
+		InstanceSnapshot
+		due Time
+	}
+	instances := map[ID]*replayed{}
+	order := []ID{}
+
+	for _, r := range records {
+		switch r.Kind {
+		case RecordSnapshot:
+			if r.Snapshot == nil {
+				continue
+			}
+			if _, has := instances[r.ID]; !has {
+				order = append(order, r.ID)
+			}
+			s := &replayed{InstanceSnapshot: *r.Snapshot}
+			if s.TTL > 0 {
+				s.due = r.Tick + Time(s.TTL)
+			}
+			instances[r.ID] = s
+
+		case RecordTransition:
+			s, has := instances[r.ID]
+			if !has || r.Transition == nil {
+				continue
+			}
+			s.State = r.Transition.To
+			s.LastTransitionAt = r.Transition.WallTime
+			if s.Visits == nil {
+				s.Visits = map[Index]int{}
+			}
+			s.Visits[r.Transition.To] = r.Transition.Visits
+			if r.Data != nil {
+				s.Data = r.Data
+			}
+			s.due = r.Due
+
+		case RecordRearm:
+			if s, has := instances[r.ID]; has {
+				s.due = r.Due
+			}
+
+		case RecordFree:
+			delete(instances, r.ID)
+		}
+	}
+
+	snapshot := Snapshot{Now: now, Instances: []InstanceSnapshot{}}
+	for _, id := range order {
+		s, has := instances[id]
+		if !has {
+			continue
+		}
+		s.TTL = 0
+		if s.due > 0 {
+			s.TTL = Tick(s.due - now)
+			if s.TTL <= 0 {
+				s.TTL = 1 // overdue; expires on the next tick
+			}
+		}
+		snapshot.Instances = append(snapshot.Instances, s.InstanceSnapshot)
+		delete(instances, id) // in case of repeated snapshots of the same instance
+	}
+	return snapshot
+}
+
+// MemoryWAL is a WAL kept in memory
+type MemoryWAL struct {
+	records []Record
+	lock    sync.Mutex
+}
+
+// Append implements WAL
+func (w *MemoryWAL) Append(record Record) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.records = append(w.records, record)
+	return nil
+}
+
+// Compact implements WAL
+func (w *MemoryWAL) Compact(id ID, record Record) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.records = append(without(w.records, id), record)
+	return nil
+}
+
+// Records implements WAL
+func (w *MemoryWAL) Records() ([]Record, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return append([]Record(nil), w.records...), nil
+}
+
+// FileWAL is a WAL kept in a file, as one JSON record per line, synced on every write.  Compaction appends
+// the record, and the file is rewritten without the records replaced once they are more than half of it.  A
+// record cut short at the end of the file, e.g. by a crash while writing it, is dropped.
+type FileWAL struct {
+	path   string
+	file   *os.File
+	lines  int        // records in the file
+	counts map[ID]int // records of each instance in the file, since its last compaction
+	stale  int        // records in the file replaced by compactions
+	lock   sync.Mutex
+}
+
+// fileRecord is a line of a FileWAL.  A compaction replaces the records of the instance before it.
+type fileRecord struct {
+	Record
+	Compact bool `json:"compact,omitempty"`
+}
+
+// walFile is the content of the file of a FileWAL
+type walFile struct {
+	records []Record // without the records replaced by compactions
+	lines   int
+	counts  map[ID]int
+	end     int64 // the end of the last whole record
+}
+
+// NewFileWAL opens the WAL in the file at the path, creating it if it doesn't exist.
+func NewFileWAL(path string) (*FileWAL, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w := &FileWAL{path: path, file: file}
+	content, err := w.read()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Truncate(content.end); err != nil { // drops a record cut short
+		file.Close()
+		return nil, err
+	}
+	w.lines, w.counts, w.stale = content.lines, content.counts, content.lines-len(content.records)
+	return w, nil
+}
+
+// Append implements WAL
+func (w *FileWAL) Append(record Record) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if err := w.write(w.file, fileRecord{Record: record}); err != nil {
+		return err
+	}
+	w.lines++
+	w.counts[record.ID]++
+	return w.file.Sync()
+}
+
+// Compact implements WAL
+func (w *FileWAL) Compact(id ID, record Record) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if err := w.write(w.file, fileRecord{Record: record, Compact: true}); err != nil {
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.lines++
+	w.stale += w.counts[id]
+	w.counts[id] = 1
+	if w.stale*2 > w.lines {
+		return w.rewrite()
+	}
+	return nil
+}
+
+// rewrite replaces the file with one of the records not replaced by compactions
+func (w *FileWAL) rewrite() error {
+	content, err := w.read()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.Create(w.path + ".compact")
+	if err != nil {
+		return err
+	}
+	for _, r := range content.records {
+		if err := w.write(tmp, fileRecord{Record: r}); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), w.path); err != nil {
+		return err // the file is left as it is, and still open
+	}
+
+	file, err := os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w.file.Close()
+	w.file = file
+	w.lines, w.stale = len(content.records), 0
+	w.counts = map[ID]int{}
+	for _, r := range content.records {
+		w.counts[r.ID]++
+	}
+	return nil
+}
+
+// Records implements WAL
+func (w *FileWAL) Records() ([]Record, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	content, err := w.read()
+	return content.records, err
+}
+
+// Close closes the file
+func (w *FileWAL) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.file.Close()
+}
+
+func (w *FileWAL) write(file *os.File, record fileRecord) error {
+	buff, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(buff, '\n'))
+	return err
+}
+
+func (w *FileWAL) read() (walFile, error) {
+	content := walFile{records: []Record{}, counts: map[ID]int{}}
+	raw, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		return content, err
+	}
+
+	all := []Record{}
+	replaced := map[int]bool{}
+	positions := map[ID][]int{} // of the records of each instance since its last compaction
+	for len(raw) > 0 {
+		n := bytes.IndexByte(raw, '\n')
+		if n < 0 {
+			break // cut short
+		}
+		record := fileRecord{}
+		if err := json.Unmarshal(raw[:n], &record); err != nil {
+			if len(bytes.TrimSpace(raw[n+1:])) == 0 {
+				break // the last record is cut short
+			}
+			return content, err
+		}
+		if record.Compact {
+			for _, k := range positions[record.ID] {
+				replaced[k] = true
+			}
+			positions[record.ID] = nil
+			content.counts[record.ID] = 0
+		}
+		positions[record.ID] = append(positions[record.ID], len(all))
+		all = append(all, record.Record)
+		content.counts[record.ID]++
+		content.lines++
+		content.end += int64(n + 1)
+		raw = raw[n+1:]
+	}
+	for k, r := range all {
+		if !replaced[k] {
+			content.records = append(content.records, r)
+		}
+	}
+	return content, nil
+}
+
+func without(records []Record, id ID) []Record {
+	kept := []Record{}
+	for _, r := range records {
+		if r.ID != id {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,323 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWALCompaction(t *testing.T) {
+
+	const (
+		running Index = iota
+		stopped
+	)
+
+	const (
+		poll Signal = iota
+		stop
+	)
+
+	spec := []State{
+		{
+			Index: running,
+			Transitions: map[Signal]Index{
+				poll: running,
+				stop: stopped,
+			},
+			TTL: Expiry{10, poll},
+		},
+		{
+			Index: stopped,
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "fsm-wal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	wal, err := NewFileWAL(filepath.Join(dir, "wal"))
+	require.NoError(t, err)
+	defer wal.Close()
+
+	options := DefaultOptions()
+	options.WAL = wal
+	options.Compaction = Compaction{Events: 3, Terminal: true}
+
+	machines, err := define(spec[0], spec[1:]...)
+	require.NoError(t, err)
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, options))
+
+	a, err := machines.New(running)
+	require.NoError(t, err)
+	b, err := machines.New(running)
+	require.NoError(t, err)
+
+	kinds := func(id ID) []RecordKind {
+		machines.Count() // wait for the signals to be processed
+		records, err := wal.Records()
+		require.NoError(t, err)
+		out := []RecordKind{}
+		for _, r := range records {
+			if r.ID == id {
+				out = append(out, r.Kind)
+			}
+		}
+		return out
+	}
+
+	require.NoError(t, a.Signal(poll))
+	require.NoError(t, a.Signal(poll, "data"))
+	require.Equal(t, []RecordKind{RecordSnapshot, RecordTransition, RecordTransition}, kinds(a.ID()))
+
+	require.NoError(t, a.Signal(poll))
+	require.Equal(t, []RecordKind{RecordSnapshot}, kinds(a.ID())) // compacted after 3 events
+
+	clock.Ticks(4)
+	require.NoError(t, b.Signal(stop))
+	require.Equal(t, []RecordKind{RecordSnapshot}, kinds(b.ID())) // compacted on terminal state
+
+	clock.Ticks(2)
+	require.NoError(t, a.Signal(poll)) // a is due at 16
+	require.Equal(t, running, a.State())
+	machines.Done()
+
+	// recover in another set from the WAL
+	recovered, err := define(spec[0], spec[1:]...)
+	require.NoError(t, err)
+	require.NoError(t, recovered.Run(NewClock(), options))
+	defer recovered.Done()
+
+	require.NoError(t, recovered.Recover())
+	require.Equal(t, 1, recovered.CountIn(running))
+	require.Equal(t, 1, recovered.CountIn(stopped))
+	require.Equal(t, []DeadlineInfo{
+		{ID: a.ID(), State: running, Due: 10, Raise: poll},
+	}, recovered.PendingDeadlines())
+
+	recovered.ForEach(func(f FSM) bool {
+		if f.ID() == a.ID() {
+			require.Equal(t, []interface{}{"data"}, f.Data())
+		}
+		return true
+	})
+
+	// recovering compacts the records to one snapshot for each instance
+	records, err := wal.Records()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+}
+
+func TestMemoryWALFree(t *testing.T) {
+
+	const (
+		running Index = iota
+	)
+
+	wal := &MemoryWAL{}
+	options := DefaultOptions()
+	options.WAL = wal
+
+	machines, err := define(State{Index: running})
+	require.NoError(t, err)
+	require.NoError(t, machines.Run(NewClock(), options))
+	defer machines.Done()
+
+	a, err := machines.New(running)
+	require.NoError(t, err)
+	require.NoError(t, machines.Free(a.ID()))
+
+	records, err := wal.Records()
+	require.NoError(t, err)
+	require.Equal(t, []Record{{Kind: RecordFree, ID: a.ID()}}, records)
+}
+
+func TestFileWALTornRecord(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "fsm-wal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "wal")
+
+	wal, err := NewFileWAL(path)
+	require.NoError(t, err)
+	require.NoError(t, wal.Append(Record{Kind: RecordSnapshot, ID: 1, Snapshot: &InstanceSnapshot{ID: 1}}))
+	require.NoError(t, wal.Close())
+
+	// a crash while writing the next record
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = file.Write([]byte(`{"kind":"snap`))
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	wal, err = NewFileWAL(path)
+	require.NoError(t, err)
+	defer wal.Close()
+	records, err := wal.Records()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	// the torn record is dropped, and the records after it are read
+	require.NoError(t, wal.Append(Record{Kind: RecordFree, ID: 2}))
+	records, err = wal.Records()
+	require.NoError(t, err)
+	require.Equal(t, []RecordKind{RecordSnapshot, RecordFree}, []RecordKind{records[0].Kind, records[1].Kind})
+}
+
+func TestFileWALRewrite(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "fsm-wal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "wal")
+
+	wal, err := NewFileWAL(path)
+	require.NoError(t, err)
+	defer wal.Close()
+
+	lines := func() int {
+		raw, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		return bytes.Count(raw, []byte{'\n'})
+	}
+
+	for id := ID(1); id <= 3; id++ {
+		require.NoError(t, wal.Append(Record{Kind: RecordSnapshot, ID: id, Snapshot: &InstanceSnapshot{ID: id}}))
+		require.NoError(t, wal.Append(Record{Kind: RecordTransition, ID: id, Transition: &Transition{}}))
+	}
+
+	// the compaction is appended until the records replaced are more than half of the file
+	require.NoError(t, wal.Compact(1, Record{Kind: RecordSnapshot, ID: 1, Snapshot: &InstanceSnapshot{ID: 1}}))
+	require.Equal(t, 7, lines())
+	records, err := wal.Records()
+	require.NoError(t, err)
+	require.Len(t, records, 5)
+
+	require.NoError(t, wal.Compact(2, Record{Kind: RecordFree, ID: 2}))
+	require.Equal(t, 8, lines())
+	require.NoError(t, wal.Compact(3, Record{Kind: RecordFree, ID: 3}))
+	require.Equal(t, 3, lines())
+
+	records, err = wal.Records()
+	require.NoError(t, err)
+	require.Equal(t, []ID{1, 2, 3}, []ID{records[0].ID, records[1].ID, records[2].ID})
+}
+
+func TestWALCompactionEvery(t *testing.T) {
+
+	const (
+		running Index = iota
+	)
+
+	const (
+		poll Signal = iota
+	)
+
+	wal := &MemoryWAL{}
+	options := DefaultOptions()
+	options.WAL = wal
+	options.Compaction = Compaction{Events: 1, Every: 5}
+	options.ClockJump = JumpFire
+
+	machines, err := define(State{Index: running, Transitions: map[Signal]Index{poll: running}})
+	require.NoError(t, err)
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, options))
+	defer machines.Done()
+
+	a, err := machines.New(running)
+	require.NoError(t, err)
+	require.NoError(t, a.Signal(poll))
+	machines.Count()
+	records, err := wal.Records()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	// the tick of the compaction is skipped
+	clock.c <- Tick(7)
+	for i := 0; i < 100 && len(records) > 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+		records, err = wal.Records()
+		require.NoError(t, err)
+	}
+	require.Len(t, records, 1)
+}
+
+func TestWALRecoverDeadlines(t *testing.T) {
+
+	const (
+		leased Index = iota
+		expired
+		down
+	)
+
+	const (
+		ping Signal = iota
+		expire
+		retry
+	)
+
+	spec := []State{
+		{
+			Index: leased,
+			Transitions: map[Signal]Index{
+				expire: expired,
+			},
+			TTL:   Expiry{5, expire},
+			Rearm: []Signal{ping},
+		},
+		{
+			Index: expired,
+		},
+		{
+			Index: down,
+			Transitions: map[Signal]Index{
+				retry: down,
+			},
+			TTL:     Expiry{2, retry},
+			Backoff: Backoff{Factor: 2, Max: 10},
+		},
+	}
+
+	options := DefaultOptions()
+	options.WAL = &MemoryWAL{}
+	options.InlineDeadlines = true
+
+	machines, err := define(spec[0], spec[1:]...)
+	require.NoError(t, err)
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, options))
+
+	a, err := machines.New(leased)
+	require.NoError(t, err)
+	b, err := machines.New(down)
+	require.NoError(t, err)
+
+	clock.Ticks(4)                     // b retries, due at 2+4
+	require.NoError(t, a.Signal(ping)) // a is re-armed, due at 4+5
+	clock.Ticks(2)                     // b retries again, due at 6+8
+	require.Equal(t, []DeadlineInfo{
+		{ID: a.ID(), State: leased, Due: 9, Raise: expire},
+		{ID: b.ID(), State: down, Due: 14, Raise: retry},
+	}, machines.PendingDeadlines())
+	machines.Done()
+
+	// recover in another set from the WAL, as of tick 6
+	recovered, err := define(spec[0], spec[1:]...)
+	require.NoError(t, err)
+	require.NoError(t, recovered.Run(NewClock(), options))
+	defer recovered.Done()
+
+	require.NoError(t, recovered.Recover())
+	require.Equal(t, []DeadlineInfo{
+		{ID: a.ID(), State: leased, Due: 3, Raise: expire},
+		{ID: b.ID(), State: down, Due: 8, Raise: retry},
+	}, recovered.PendingDeadlines())
+}
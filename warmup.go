@@ -0,0 +1,14 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+// holdInWarmup pushes the expired deadline of the instance to the end of the warm-up, if still warming up.
+// This must be called from within the transaction loop.
+func (g *runner) holdInWarmup(instance *instance) bool {
+	if g.now >= g.warmup {
+		return false
+	}
+	g.debug("Deadline held in warm-up", snapshot{instance}, "id", instance.id, "now", g.now,
+		"warmup", g.warmup)
+	instance.deadline = g.warmup
+	g.deadlines.enqueue(instance)
+	return true
+}
@@ -0,0 +1,80 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmupTicks(t *testing.T) {
+
+	const (
+		up Index = iota
+		unknown
+	)
+
+	const (
+		timeout Signal = iota
+		poll
+	)
+
+	machines, err := define(
+		State{
+			Index: up,
+			Transitions: map[Signal]Index{
+				timeout: unknown,
+			},
+			Rearm: []Signal{poll},
+			TTL:   Expiry{2, timeout},
+		},
+		State{
+			Index: unknown,
+			Transitions: map[Signal]Index{
+				poll: up,
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	clock := NewClock()
+	options := DefaultOptions()
+	options.InlineDeadlines = true
+	options.WarmupTicks = 5
+	require.NoError(t, machines.Run(clock, options))
+	defer machines.Done()
+
+	a, err := machines.New(up)
+	require.NoError(t, err)
+	b, err := machines.New(up)
+	require.NoError(t, err)
+
+	// the expiries are held until the end of the warm-up
+	clock.Ticks(2)
+	require.Equal(t, []DeadlineInfo{
+		{ID: a.ID(), State: up, Due: 5, Raise: timeout},
+		{ID: b.ID(), State: up, Due: 5, Raise: timeout},
+	}, machines.PendingDeadlines())
+
+	// a is polled in time, b isn't
+	clock.Ticks(2)
+	require.NoError(t, a.Signal(poll))
+	clock.Ticks(1)
+	require.Equal(t, up, a.State())
+	require.Equal(t, unknown, b.State())
+
+	// after the warm-up, TTLs expire as usual
+	clock.Ticks(1)
+	require.Equal(t, unknown, a.State())
+
+	// and restoring warms up again
+	require.NoError(t, b.Signal(poll))
+	snapshot, err := machines.Snapshot()
+	require.NoError(t, err)
+	require.NoError(t, machines.Free(a.ID()))
+	require.NoError(t, machines.Free(b.ID()))
+	require.NoError(t, machines.Restore(Snapshot{Now: snapshot.Now, Instances: snapshot.Instances[1:]}))
+	clock.Ticks(2)
+	require.Equal(t, []DeadlineInfo{
+		{ID: b.ID(), State: up, Due: 6 + 5, Raise: timeout},
+	}, machines.PendingDeadlines())
+}
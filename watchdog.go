@@ -0,0 +1,70 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"sort"
+)
+
+// Watchdog specifies a signal to raise when a keep-alive signal has not been received for TTL ticks
+// while in the state.  Unlike the state's TTL, the countdown is reset on each keep-alive and not just
+// on entering the state.
+type Watchdog struct {
+	KeepAlive Signal
+	TTL       Tick
+	Raise     Signal
+}
+
+// returns the watchdog for the state.  if the TTL is 0 then there's no watchdog.
+func (s *spec) watchdog(current Index) *Watchdog {
+	state, has := s.states[current]
+	if !has {
+		return nil
+	}
+	if state.Watchdog.TTL > 0 {
+		return &state.Watchdog
+	}
+	return nil
+}
+
+// keepAlive resets the watchdog if the signal is the keep-alive of the current state.  Returns true if
+// the signal has been consumed, i.e. it is a keep-alive and not also a transition.
+func (g *runner) keepAlive(tid int64, instance *instance, signal Signal) bool {
+	watchdog := g.spec().watchdog(instance.state)
+	if watchdog == nil || watchdog.KeepAlive != signal {
+		return false
+	}
+
+	g.debug("Keep-alive", snapshot{instance}, "tid", tid, "instance", instance.id,
+		"state", g.spec().stateName(instance.state), "now", g.ct())
+
+	instance.alive = g.ct()
+	_, has := g.spec().states[instance.state].Transitions[signal]
+	return !has
+}
+
+// processWatchdogs raises signals for instances that have not received keep-alives in time, in the order of ID.
+func (g *runner) processWatchdogs(tid int64) {
+	now := g.ct()
+	expired := []*instance{}
+	for index := range g.spec().states {
+		watchdog := g.spec().watchdog(index)
+		if watchdog == nil {
+			continue
+		}
+		for _, instance := range g.bystate[index] {
+			if now-instance.alive >= Time(watchdog.TTL) {
+				expired = append(expired, instance)
+			}
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].id < expired[j].id })
+
+	for _, instance := range expired {
+		watchdog := g.spec().watchdog(instance.state)
+
+		g.debug("Watchdog expired", snapshot{instance}, "tid", tid, "id", instance.id,
+			"raise", g.spec().signalName(watchdog.Raise), "now", now)
+
+		instance.alive = now
+		g.raise(tid, instance, watchdog.Raise, instance.state, OriginWatchdog)
+	}
+}
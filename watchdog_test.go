@@ -0,0 +1,128 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchdog(t *testing.T) {
+
+	const (
+		healthy Index = iota
+		unhealthy
+	)
+
+	const (
+		heartbeat Signal = iota
+		missing
+		recover
+	)
+
+	machines, err := define(
+		State{
+			Index: healthy,
+			Transitions: map[Signal]Index{
+				missing: unhealthy,
+			},
+			Watchdog: Watchdog{KeepAlive: heartbeat, TTL: 3, Raise: missing},
+		},
+		State{
+			Index: unhealthy,
+			Transitions: map[Signal]Index{
+				recover: healthy,
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, DefaultOptions()))
+	defer machines.Done()
+
+	instance, err := machines.New(healthy)
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		clock.Tick()
+		require.NoError(t, instance.Signal(heartbeat))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, healthy, instance.State())
+
+	clock.Tick()
+	clock.Tick()
+	clock.Tick()
+
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, unhealthy, instance.State())
+
+	_, err = define(
+		State{
+			Index:    healthy,
+			Watchdog: Watchdog{KeepAlive: heartbeat, TTL: 3, Raise: missing},
+		},
+	)
+	require.Error(t, err)
+}
+
+func TestWatchdogOrder(t *testing.T) {
+
+	const (
+		healthy Index = iota
+		unhealthy
+	)
+
+	const (
+		heartbeat Signal = iota
+		missing
+	)
+
+	machines, err := define(
+		State{
+			Index: healthy,
+			Transitions: map[Signal]Index{
+				missing: unhealthy,
+			},
+			Watchdog: Watchdog{KeepAlive: heartbeat, TTL: 3, Raise: missing},
+		},
+		State{
+			Index: unhealthy,
+		},
+	)
+	require.NoError(t, err)
+
+	clock := NewClock()
+	require.NoError(t, machines.Run(clock, DefaultOptions()))
+	defer machines.Done()
+
+	ids := []ID{}
+	for i := 0; i < 20; i++ {
+		instance, err := machines.New(healthy)
+		require.NoError(t, err)
+		ids = append(ids, instance.ID())
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	transitions, cancel := machines.Watch(len(ids))
+	defer cancel()
+
+	clock.Tick()
+	clock.Tick()
+	clock.Tick()
+
+	// all expire on the same tick, and are raised in the order of ID
+	raised := []ID{}
+	for range ids {
+		select {
+		case transition := <-transitions:
+			raised = append(raised, transition.ID)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "watchdogs not raised", "raised %v", raised)
+		}
+	}
+	require.Equal(t, ids, raised)
+}
@@ -0,0 +1,131 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// DefineFromYAML compiles a Config in YAML into a spec, as DefineFromJSON does one in JSON.  YAML is a superset
+// of JSON, so the config can be in either.  Plain scalars are taken as written where a name is expected, so that
+// states and signals can be named e.g. true, 1 or null without quotes.
+func DefineFromYAML(r io.Reader) (Machines, Options, error) {
+	config, err := ReadYAMLConfig(r)
+	if err != nil {
+		return nil, Options{}, err
+	}
+	return DefineFromConfig(config)
+}
+
+// ReadYAMLConfig reads a Config in YAML.  Unknown fields are errors.
+func ReadYAMLConfig(r io.Reader) (Config, error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Config{}, err
+	}
+	return decodeYAMLConfig(raw)
+}
+
+// ValidateYAMLConfig checks a Config in YAML, as ValidateConfig does one in JSON.
+func ValidateYAMLConfig(r io.Reader) []Issue {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return []Issue{{Severity: LintError, Rule: IssueSyntax, Message: err.Error()}}
+	}
+	config, err := decodeYAMLConfig(raw)
+	if err != nil {
+		return []Issue{{Severity: LintError, Rule: IssueSyntax, Line: yamlLine(err), Message: err.Error()}}
+	}
+	return config.Issues(raw)
+}
+
+func decodeYAMLConfig(raw []byte) (Config, error) {
+	config := Config{}
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return config, err
+	}
+	if len(doc.Content) == 0 {
+		return config, nil // empty
+	}
+	if err := checkYAML(doc.Content[0], reflect.TypeOf(config)); err != nil {
+		return config, err
+	}
+	return config, doc.Decode(&config)
+}
+
+// checkYAML checks for unknown fields in the node decoded as the type, and marks the plain nulls where a string is
+// expected as strings, e.g. a state named null.  Empty values stay null.
+func checkYAML(node *yaml.Node, t reflect.Type) error {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if t.Kind() == reflect.String && node.Tag == "!!null" && node.Style == 0 && node.Value != "" &&
+			node.Value != "~" {
+			node.Tag = "!!str"
+		}
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return nil // a type error when decoded
+		}
+		for _, item := range node.Content {
+			if err := checkYAML(item, t.Elem()); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			switch t.Kind() {
+			case reflect.Map:
+				if err := checkYAML(key, t.Key()); err != nil {
+					return err
+				}
+				if err := checkYAML(value, t.Elem()); err != nil {
+					return err
+				}
+			case reflect.Struct:
+				field, has := yamlField(t, key.Value)
+				if !has {
+					return fmt.Errorf("yaml: line %d: field %v not found in %v", key.Line, key.Value, t.Name())
+				}
+				if err := checkYAML(value, field.Type); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// yamlField returns the field of the struct named as in YAML
+func yamlField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == name || (tag == "" && strings.ToLower(field.Name) == name) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// yamlLine returns the first line in the error, if any
+func yamlLine(err error) int {
+	match := yamlErrorLine.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0
+	}
+	line, _ := strconv.Atoi(match[1])
+	return line
+}
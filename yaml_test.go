@@ -0,0 +1,119 @@
+package fsm // import "github.com/orkestr8/fsm"
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefineFromYAML(t *testing.T) {
+
+	const (
+		pending Index = iota
+		running
+		stopped
+	)
+
+	const (
+		start Signal = iota
+		stop
+		restart
+	)
+
+	m, options, err := DefineFromYAML(strings.NewReader(`
+---
+# a comment
+states:
+  - name: pending
+    transitions:
+      start: running
+    ttl: {ticks: 2, raise: start}
+  - name: running
+    transitions:
+      stop: stopped
+    visit: {value: 3, raise: stop}
+  - name: stopped
+    transitions: {restart: running}
+signals: [start, stop, restart]
+limits:
+  - states: [running, stopped]
+    count: 2
+    raise: stop
+`))
+	require.NoError(t, err)
+	require.Equal(t, "running", options.StateNames[running])
+	require.Equal(t, "restart", options.SignalNames[restart])
+	require.Equal(t, []Flap{{States: [2]Index{running, stopped}, Count: 2, Raise: stop}}, options.Limits)
+
+	clock := NewClock()
+	require.NoError(t, m.Run(clock, options))
+	defer m.Done()
+
+	a, err := m.New(pending)
+	require.NoError(t, err)
+	clock.Ticks(2)
+	for i := 0; i < 100 && a.State() != running; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(t, running, a.State())
+
+	_, _, err = DefineFromYAML(strings.NewReader(`
+states:
+  - name: pending
+    transitions: {start: runing}
+`))
+	require.Equal(t, ErrInvalidConfig{
+		{Severity: LintError, Rule: IssueReference, Field: "states[0].transitions.start",
+			Message: "unknown state runing"},
+	}, err)
+
+	_, _, err = DefineFromYAML(strings.NewReader("states:\n  - name: pending\n    ttl: 5\n"))
+	require.Error(t, err)
+
+	_, _, err = DefineFromYAML(strings.NewReader("states:\n  - name: pending\n    tll: {ticks: 5, raise: start}\n"))
+	require.EqualError(t, err, "yaml: line 3: field tll not found in StateConfig")
+}
+
+func TestYAMLPlainNames(t *testing.T) {
+
+	config, err := ReadYAMLConfig(strings.NewReader(`
+states:
+  - name: true
+    transitions: {1: null, 2.5: false}
+  - name: null
+    ttl: ~
+  - name: false
+`))
+	require.NoError(t, err)
+	require.Equal(t, Config{States: []StateConfig{
+		{Name: "true", Transitions: map[string]string{"1": "null", "2.5": "false"}},
+		{Name: "null"},
+		{Name: "false"},
+	}}, config)
+
+	// JSON is YAML
+	config, err = ReadYAMLConfig(strings.NewReader(`{"states": [{"name": "on", "errorsFirst": true}]}`))
+	require.NoError(t, err)
+	require.Equal(t, Config{States: []StateConfig{{Name: "on", ErrorsFirst: true}}}, config)
+}
+
+func TestValidateYAMLConfig(t *testing.T) {
+
+	issues := ValidateYAMLConfig(strings.NewReader(`
+states:
+  - name: pending
+    transitions: {start: running}
+  - name: running
+    ttl: {ticks: 10, raise: start}
+`))
+	require.Len(t, issues, 1)
+	require.Equal(t, IssueSpec, issues[0].Rule)
+	require.Equal(t, 5, issues[0].Line)
+
+	issues = ValidateYAMLConfig(strings.NewReader("states:\n  - name: pending\n    ttl: 5\n"))
+	require.Len(t, issues, 1)
+	require.Equal(t, IssueSyntax, issues[0].Rule)
+	require.Equal(t, 3, issues[0].Line)
+}